@@ -45,6 +45,9 @@ type Config struct {
 
 	// filesystem cache used for handlers.
 	fscache *fscache
+
+	// files and envs accessed by init.
+	deps *configDeps
 }
 
 // New returns new build config.
@@ -126,8 +129,11 @@ func (e HandlerError) Unwrap() error {
 
 // Init initializes config by running `init`.
 func (cfg *Config) Init(ctx context.Context, hashFS *hashfs.HashFS, buildPath *build.Path) (string, error) {
+	ctx, span := trace.NewSpan(ctx, "starlark-init")
+	defer span.Close(nil)
 	// Clear fscache to read updated contents after `gn gen`.
 	cfg.fscache = &fscache{m: make(map[string][]byte)}
+	cfg.deps = newConfigDeps()
 
 	fun, ok := cfg.globals[configEntryPoint]
 	if !ok {
@@ -147,8 +153,8 @@ func (cfg *Config) Init(ctx context.Context, hashFS *hashfs.HashFS, buildPath *b
 		"actions":  starInitActions(cfg.Metadata),
 		"metadata": starMetadata(cfg.Metadata),
 		"flags":    starFlags(cfg.flags),
-		// want "envs" ?
-		"fs": starFS(ctx, hashFS.FileSystem(ctx, buildPath.ExecRoot), buildPath, cfg.fscache),
+		"env":      starEnv{deps: cfg.deps},
+		"fs":       starFS(ctx, hashFS, buildPath, cfg.fscache, cfg.deps),
 	})
 	clog.Infof(ctx, "hctx: %v", hctx)
 	ret, err := starlark.Call(thread, fun, starlark.Tuple([]starlark.Value{hctx}), nil)
@@ -195,6 +201,18 @@ func (cfg *Config) Init(ctx context.Context, hashFS *hashfs.HashFS, buildPath *b
 	return s, nil
 }
 
+// DepFiles returns absolute paths of files and dirs accessed by `init`
+// via `ctx.fs`.
+func (cfg *Config) DepFiles() []string {
+	return cfg.deps.fileList()
+}
+
+// EnvChanged reports whether environment variables accessed by `init`
+// or handlers via `ctx.env` have been changed since they were accessed.
+func (cfg *Config) EnvChanged() bool {
+	return cfg.deps.envChanged()
+}
+
 // Func returns a function for the handler name.
 func (cfg *Config) Func(ctx context.Context, handler string) (starlark.Value, bool) {
 	if cfg.handlers == nil {
@@ -221,6 +239,11 @@ func (cfg *Config) Handle(ctx context.Context, handler string, bpath *build.Path
 	defer func() {
 		clog.Infof(ctx, "handle:%s %s", handler, time.Since(started))
 	}()
+	return cfg.callHandler(ctx, handler, fun, bpath, cmd, expandedInputs)
+}
+
+// callHandler calls handler function fun with ctx and cmd.
+func (cfg *Config) callHandler(ctx context.Context, handler string, fun starlark.Value, bpath *build.Path, cmd *execute.Cmd, expandedInputs func() []string) error {
 	thread := &starlark.Thread{
 		Name: "handler:" + handler,
 		Print: func(thread *starlark.Thread, msg string) {
@@ -231,11 +254,14 @@ func (cfg *Config) Handle(ctx context.Context, handler string, bpath *build.Path
 		},
 	}
 
+	// env accessed by handlers is recorded in cfg.deps too,
+	// as handlers may change commands depending on env.
 	hctx := starlarkstruct.FromStringDict(starlark.String("ctx"), map[string]starlark.Value{
 		"actions":  starCmdActions(ctx, cmd),
 		"metadata": starMetadata(cfg.Metadata),
 		"flags":    starFlags(cfg.flags),
-		"fs":       starFS(ctx, cmd.HashFS.FileSystem(ctx, cmd.ExecRoot), bpath, cfg.fscache),
+		"env":      starEnv{deps: cfg.deps},
+		"fs":       starFS(ctx, cmd.HashFS, bpath, cfg.fscache, nil),
 	})
 	if log.V(1) {
 		clog.Infof(ctx, "hctx: %v", hctx)
@@ -0,0 +1,85 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package buildconfig
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// configDeps records files accessed by `init`, and environment
+// variables accessed by `init` and handlers, to detect whether
+// the config can be reused.
+// nil configDeps doesn't record anything.
+type configDeps struct {
+	mu    sync.Mutex
+	files map[string]bool
+	envs  map[string]envValue
+}
+
+type envValue struct {
+	value string
+	ok    bool
+}
+
+func newConfigDeps() *configDeps {
+	return &configDeps{
+		files: make(map[string]bool),
+		envs:  make(map[string]envValue),
+	}
+}
+
+// addFile records fname relative to execRoot.
+func (d *configDeps) addFile(execRoot, fname string) {
+	if d == nil {
+		return
+	}
+	if !filepath.IsAbs(fname) {
+		fname = filepath.Join(execRoot, fname)
+	}
+	d.mu.Lock()
+	d.files[fname] = true
+	d.mu.Unlock()
+}
+
+// addEnv records value of environment variable name.
+func (d *configDeps) addEnv(name, value string, ok bool) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	d.envs[name] = envValue{value: value, ok: ok}
+	d.mu.Unlock()
+}
+
+// fileList returns absolute paths of files and dirs accessed by `init`.
+func (d *configDeps) fileList() []string {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Sorted(maps.Keys(d.files))
+}
+
+// envChanged reports whether environment variables accessed by `init`
+// or handlers have been changed since then.
+func (d *configDeps) envChanged() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, v := range d.envs {
+		value, ok := os.LookupEnv(name)
+		if value != v.value || ok != v.ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package buildconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// envAllowlist is a list of environment variables accessible via `ctx.env`.
+// A name ending with "*" matches environment variables with the prefix.
+var envAllowlist = []string{
+	"ANDROID_HOME",
+	"ANDROID_NDK_HOME",
+	"ANDROID_SDK_ROOT",
+	"DEPOT_TOOLS_WIN_TOOLCHAIN",
+	"DEVELOPER_DIR",
+	"GYP_MSVS_OVERRIDE_PATH",
+	"JAVA_HOME",
+	"LANG",
+	"PATH",
+	"SDKROOT",
+	"SISO_*",
+	"TMPDIR",
+	"WINDOWSSDKDIR",
+}
+
+func envAllowed(name string) bool {
+	for _, a := range envAllowlist {
+		if prefix, ok := strings.CutSuffix(a, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		if name == a {
+			return true
+		}
+	}
+	return false
+}
+
+// starEnv is a read-only view of allowlisted environment variables.
+//
+//	env[name]: value of name. error if not set.
+//	env.get(name, default): value of name, or default if not set.
+//	name in env: check name is set.
+type starEnv struct {
+	deps *configDeps
+}
+
+func (starEnv) String() string        { return "env" }
+func (starEnv) Type() string          { return "env" }
+func (starEnv) Freeze()               {}
+func (starEnv) Truth() starlark.Bool  { return starlark.True }
+func (starEnv) Hash() (uint32, error) { return 0, errors.New("env is not hashable") }
+func (starEnv) AttrNames() []string   { return []string{"get"} }
+
+func (e starEnv) lookup(k starlark.Value) (string, bool, error) {
+	name, ok := starlark.AsString(k)
+	if !ok {
+		return "", false, fmt.Errorf("env key %s is not a string", k.Type())
+	}
+	if !envAllowed(name) {
+		return "", false, fmt.Errorf("env %q is not allowed", name)
+	}
+	value, ok := os.LookupEnv(name)
+	e.deps.addEnv(name, value, ok)
+	return value, ok, nil
+}
+
+// Get implements starlark.Mapping for `env[name]` and `name in env`.
+func (e starEnv) Get(k starlark.Value) (starlark.Value, bool, error) {
+	value, ok, err := e.lookup(k)
+	if err != nil || !ok {
+		return starlark.None, false, err
+	}
+	return starlark.String(value), true, nil
+}
+
+// Attr returns attribute of env.
+func (e starEnv) Attr(name string) (starlark.Value, error) {
+	if name == "get" {
+		return starlark.NewBuiltin("get", starEnvGet).BindReceiver(e), nil
+	}
+	return nil, nil
+}
+
+// Starlark function `env.get(name, default)` to get value of the environment variable.
+func starEnvGet(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	e, ok := fn.Receiver().(starEnv)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var name starlark.Value
+	var defaultValue starlark.Value = starlark.None
+	err := starlark.UnpackArgs("get", args, kwargs, "name", &name, "default?", &defaultValue)
+	if err != nil {
+		return starlark.None, err
+	}
+	value, ok, err := e.lookup(name)
+	if err != nil {
+		return starlark.None, err
+	}
+	if !ok {
+		return defaultValue, nil
+	}
+	return starlark.String(value), nil
+}
@@ -10,12 +10,14 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"slices"
 
 	log "github.com/golang/glog"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
 
 	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
 )
 
 // starFS returns fs module.
@@ -25,32 +27,55 @@ import (
 //	exists(fname): check if fname exists.
 //	size(fname): report size of fname's content.
 //	canonpath(fname): canonicalize path from working dir relative path.
-func starFS(ctx context.Context, fs fs.FS, path *build.Path, fsc *fscache) starlark.Value {
+//	readdir(dir): list names in dir.
+//	glob(pattern, excludes): list files matched with pattern.
+//	digest(fname): get digest of fname.
+//	stat(fname): get file info of fname.
+//
+// Files and dirs accessed via fs are recorded in deps.
+func starFS(ctx context.Context, hashFS *hashfs.HashFS, path *build.Path, fsc *fscache, deps *configDeps) starlark.Value {
 	receiver := starFSReceiver{
 		ctx:     ctx,
-		fs:      fs,
+		hashFS:  hashFS,
+		fs:      hashFS.FileSystem(ctx, path.ExecRoot),
 		path:    path,
 		fscache: fsc,
+		deps:    deps,
 	}
 	fsRead := starlark.NewBuiltin("read", starFSRead).BindReceiver(receiver)
 	fsIsDir := starlark.NewBuiltin("is_dir", starFSIsDir).BindReceiver(receiver)
 	fsExists := starlark.NewBuiltin("exists", starFSExists).BindReceiver(receiver)
 	fsSize := starlark.NewBuiltin("size", starFSSize).BindReceiver(receiver)
 	fsCanonPath := starlark.NewBuiltin("canonpath", starFSCanonPath).BindReceiver(receiver)
+	fsReadDir := starlark.NewBuiltin("readdir", starFSReadDir).BindReceiver(receiver)
+	fsGlob := starlark.NewBuiltin("glob", starFSGlob).BindReceiver(receiver)
+	fsDigest := starlark.NewBuiltin("digest", starFSDigest).BindReceiver(receiver)
+	fsStat := starlark.NewBuiltin("stat", starFSStat).BindReceiver(receiver)
 	return starlarkstruct.FromStringDict(starlark.String("fs"), map[string]starlark.Value{
 		"read":      fsRead,
 		"is_dir":    fsIsDir,
 		"exists":    fsExists,
 		"size":      fsSize,
 		"canonpath": fsCanonPath,
+		"readdir":   fsReadDir,
+		"glob":      fsGlob,
+		"digest":    fsDigest,
+		"stat":      fsStat,
 	})
 }
 
 type starFSReceiver struct {
 	ctx     context.Context
-	fs      fs.FS
+	hashFS  *hashfs.HashFS
+	fs      hashfs.FileSystem
 	path    *build.Path
 	fscache *fscache
+	deps    *configDeps
+}
+
+// record records fname as accessed.
+func (r starFSReceiver) record(fname string) {
+	r.deps.addFile(r.path.ExecRoot, fname)
 }
 
 func (r starFSReceiver) String() string {
@@ -73,6 +98,7 @@ func starFSRead(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tup
 	if err != nil {
 		return starlark.None, err
 	}
+	c.record(fname)
 	buf, err := c.fscache.Get(c.ctx, c.fs, fname)
 	if err != nil {
 		return starlark.None, err
@@ -91,6 +117,7 @@ func starFSIsDir(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tu
 	if err != nil {
 		return starlark.None, err
 	}
+	c.record(fname)
 	fi, err := fs.Stat(c.fs, fname)
 	if err != nil {
 		return starlark.None, err
@@ -112,6 +139,7 @@ func starFSExists(thread *starlark.Thread, fn *starlark.Builtin, args starlark.T
 	if err != nil {
 		return starlark.None, err
 	}
+	c.record(fname)
 	_, err = fs.Stat(c.fs, fname)
 	if err != nil {
 		return starlark.False, nil
@@ -130,6 +158,7 @@ func starFSSize(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tup
 	if err != nil {
 		return starlark.None, err
 	}
+	c.record(fname)
 	fi, err := fs.Stat(c.fs, fname)
 	if err != nil {
 		return starlark.None, err
@@ -155,3 +184,141 @@ func starFSCanonPath(thread *starlark.Thread, fn *starlark.Builtin, args starlar
 	}
 	return starlark.String(filepath.ToSlash(s)), nil
 }
+
+// Starlark function `fs.readdir(dir)` to list names in dir.
+func starFSReadDir(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c, ok := fn.Receiver().(starFSReceiver)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var dir string
+	err := starlark.UnpackArgs("readdir", args, kwargs, "dir", &dir)
+	if err != nil {
+		return starlark.None, err
+	}
+	c.record(dir)
+	ents, err := c.fs.ReadDir(dir)
+	if err != nil {
+		return starlark.None, err
+	}
+	names := make([]string, 0, len(ents))
+	for _, ent := range ents {
+		names = append(names, ent.Name())
+	}
+	slices.Sort(names)
+	return packList(names), nil
+}
+
+// Starlark function `fs.glob(pattern, excludes)` to list files matched with pattern.
+// pattern is the syntax of path.Match for each path component.
+// excludes are patterns to exclude. The pattern without "/" matches
+// with basename, as filegroups' glob.
+func starFSGlob(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c, ok := fn.Receiver().(starFSReceiver)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var pattern string
+	var excludesValue starlark.Value
+	err := starlark.UnpackArgs("glob", args, kwargs, "pattern", &pattern, "excludes?", &excludesValue)
+	if err != nil {
+		return starlark.None, err
+	}
+	var excludes []string
+	if excludesValue != nil {
+		excludes, err = unpackList(excludesValue)
+		if err != nil {
+			return starlark.None, err
+		}
+	}
+	matches, err := fs.Glob(starFSRecorder{c}, pattern)
+	if err != nil {
+		return starlark.None, err
+	}
+	m := globSpec{includes: []string{"*"}, excludes: excludes}.matcher()
+	files := matches[:0]
+	for _, f := range matches {
+		if m(f) {
+			files = append(files, f)
+		}
+	}
+	slices.Sort(files)
+	return packList(files), nil
+}
+
+// starFSRecorder is fs.FS to record dirs and files accessed by fs.Glob.
+type starFSRecorder struct {
+	r starFSReceiver
+}
+
+func (f starFSRecorder) Open(name string) (fs.File, error) {
+	f.r.record(name)
+	return f.r.fs.Open(name)
+}
+
+func (f starFSRecorder) ReadDir(name string) ([]fs.DirEntry, error) {
+	f.r.record(name)
+	return f.r.fs.ReadDir(name)
+}
+
+func (f starFSRecorder) Stat(name string) (fs.FileInfo, error) {
+	f.r.record(name)
+	return f.r.fs.Stat(name)
+}
+
+// Starlark function `fs.digest(fname)` to get digest of fname as "<hash>/<size>".
+func starFSDigest(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c, ok := fn.Receiver().(starFSReceiver)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var fname string
+	err := starlark.UnpackArgs("digest", args, kwargs, "fname", &fname)
+	if err != nil {
+		return starlark.None, err
+	}
+	c.record(fname)
+	ents, err := c.hashFS.Entries(c.ctx, c.path.ExecRoot, []string{fname})
+	if err != nil {
+		return starlark.None, err
+	}
+	if len(ents) == 0 {
+		return starlark.None, &fs.PathError{Op: "digest", Path: fname, Err: fs.ErrNotExist}
+	}
+	if ents[0].Data.IsZero() {
+		return starlark.None, &fs.PathError{Op: "digest", Path: fname, Err: errors.New("not a regular file")}
+	}
+	return starlark.String(ents[0].Data.Digest().String()), nil
+}
+
+// Starlark function `fs.stat(fname)` to get file info of fname.
+// It doesn't follow symlink.
+func starFSStat(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c, ok := fn.Receiver().(starFSReceiver)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var fname string
+	err := starlark.UnpackArgs("stat", args, kwargs, "fname", &fname)
+	if err != nil {
+		return starlark.None, err
+	}
+	c.record(fname)
+	fi, err := c.fs.Lstat(fname)
+	if err != nil {
+		return starlark.None, err
+	}
+	var target string
+	if hfi, ok := fi.(hashfs.FileInfo); ok {
+		target = hfi.Target()
+	}
+	return starlarkstruct.FromStringDict(starlark.String("stat"), map[string]starlark.Value{
+		"name":          starlark.String(fi.Name()),
+		"size":          starlark.MakeInt64(fi.Size()),
+		"mode":          starlark.MakeInt(int(fi.Mode().Perm())),
+		"is_dir":        starlark.Bool(fi.IsDir()),
+		"is_symlink":    starlark.Bool(fi.Mode().Type() == fs.ModeSymlink),
+		"is_executable": starlark.Bool(!fi.IsDir() && fi.Mode()&0111 != 0),
+		"target":        starlark.String(target),
+	}), nil
+}
@@ -0,0 +1,215 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package buildconfig
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/execute"
+	"go.chromium.org/build/siso/hashfs"
+)
+
+func TestStarFS_HostAPI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlink and executable bits are not supported on windows")
+	}
+	ctx := t.Context()
+	t.Setenv("SISO_TEST_SDK", "sdk")
+	dir := t.TempDir()
+	for fname, content := range map[string]string{
+		"sdk/Frameworks/Foo.framework/Foo": "foo",
+		"sdk/Frameworks/Bar.framework/Bar": "bar",
+		"sdk/Frameworks/Old.framework/Old": "old",
+		"sdk/Frameworks/README":            "readme",
+		"sdk/bin/tool":                     "#!/bin/sh\n",
+	} {
+		fname := filepath.Join(dir, fname)
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.Chmod(filepath.Join(dir, "sdk/bin/tool"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Symlink("bin/tool", filepath.Join(dir, "sdk/tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := fstest.MapFS{
+		"main.star": &fstest.MapFile{
+			Data: []byte(`
+load("@builtin//encoding.star", "json")
+load("@builtin//struct.star", "module")
+
+def init(ctx):
+    sdk = ctx.env.get("SISO_TEST_SDK", "missing")
+    tool = ctx.fs.stat(sdk + "/bin/tool")
+    link = ctx.fs.stat(sdk + "/tool")
+    return module(
+        "config",
+        step_config = json.encode({
+            "readdir": ctx.fs.readdir(sdk + "/Frameworks"),
+            "glob": ctx.fs.glob(sdk + "/Frameworks/*.framework", excludes = ["Old.*"]),
+            "digest": ctx.fs.digest(sdk + "/Frameworks/Foo.framework/Foo"),
+            "tool": [tool.size, tool.is_executable, tool.is_symlink],
+            "link": [link.is_symlink, link.target],
+            "unset": ctx.env.get("SISO_TEST_UNSET"),
+            "has_unset": "SISO_TEST_UNSET" in ctx.env,
+        }),
+        filegroups = {},
+        handlers = {},
+    )
+`),
+		},
+	}
+	cfg, err := New(ctx, "@config//main.star", nil, map[string]fs.FS{"config": repo})
+	if err != nil {
+		t.Fatalf("New=%v; want nil err", err)
+	}
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := hashFS.Close(ctx)
+		if err != nil {
+			t.Errorf("hashFS.Close=%v", err)
+		}
+	}()
+	s, err := cfg.Init(ctx, hashFS, build.NewPath(dir, "out/siso"))
+	if err != nil {
+		t.Fatalf("Init=%v; want nil err", err)
+	}
+	var got map[string]any
+	err = json.Unmarshal([]byte(s), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"readdir":   []any{"Bar.framework", "Foo.framework", "Old.framework", "README"},
+		"glob":      []any{"sdk/Frameworks/Bar.framework", "sdk/Frameworks/Foo.framework"},
+		"digest":    "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae/3",
+		"tool":      []any{float64(10), true, false},
+		"link":      []any{true, "bin/tool"},
+		"unset":     nil,
+		"has_unset": false,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("init diff -want +got:\n%s", diff)
+	}
+
+	wantFiles := []string{
+		filepath.Join(dir, "sdk/Frameworks"),
+		filepath.Join(dir, "sdk/Frameworks/Foo.framework/Foo"),
+		filepath.Join(dir, "sdk/bin/tool"),
+		filepath.Join(dir, "sdk/tool"),
+	}
+	if diff := cmp.Diff(wantFiles, cfg.DepFiles()); diff != "" {
+		t.Errorf("DepFiles diff -want +got:\n%s", diff)
+	}
+	if cfg.EnvChanged() {
+		t.Errorf("EnvChanged=true; want false")
+	}
+	t.Setenv("SISO_TEST_UNSET", "set")
+	if !cfg.EnvChanged() {
+		t.Errorf("EnvChanged=false after setenv; want true")
+	}
+}
+
+func TestStarEnv_NotAllowed(t *testing.T) {
+	ctx := t.Context()
+	repo := fstest.MapFS{
+		"main.star": &fstest.MapFile{
+			Data: []byte(`
+load("@builtin//struct.star", "module")
+
+def init(ctx):
+    ctx.env.get("HOME")
+    return module("config", step_config = "{}", filegroups = {}, handlers = {})
+`),
+		},
+	}
+	cfg, err := New(ctx, "@config//main.star", nil, map[string]fs.FS{"config": repo})
+	if err != nil {
+		t.Fatalf("New=%v; want nil err", err)
+	}
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := hashFS.Close(ctx)
+		if err != nil {
+			t.Errorf("hashFS.Close=%v", err)
+		}
+	}()
+	_, err = cfg.Init(ctx, hashFS, build.NewPath(t.TempDir(), "out/siso"))
+	if err == nil {
+		t.Errorf("Init=nil; want error for not allowed env")
+	}
+}
+
+func TestStarEnv_Handler(t *testing.T) {
+	ctx := t.Context()
+	repo := fstest.MapFS{
+		"main.star": &fstest.MapFile{
+			Data: []byte(`
+load("@builtin//struct.star", "module")
+
+def __handler(ctx, cmd):
+    ctx.env.get("SISO_TEST_HANDLER")
+
+def init(ctx):
+    return module("config", step_config = "{}", filegroups = {}, handlers = {"handler": __handler})
+`),
+		},
+	}
+	cfg, err := New(ctx, "@config//main.star", nil, map[string]fs.FS{"config": repo})
+	if err != nil {
+		t.Fatalf("New=%v; want nil err", err)
+	}
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := hashFS.Close(ctx)
+		if err != nil {
+			t.Errorf("hashFS.Close=%v", err)
+		}
+	}()
+	bpath := build.NewPath(t.TempDir(), "out/siso")
+	_, err = cfg.Init(ctx, hashFS, bpath)
+	if err != nil {
+		t.Fatalf("Init=%v; want nil err", err)
+	}
+	err = cfg.Handle(ctx, "handler", bpath, &execute.Cmd{HashFS: hashFS}, nil)
+	if err != nil {
+		t.Fatalf("Handle=%v; want nil err", err)
+	}
+	if cfg.EnvChanged() {
+		t.Errorf("EnvChanged=true; want false")
+	}
+	t.Setenv("SISO_TEST_HANDLER", "set")
+	if !cfg.EnvChanged() {
+		t.Errorf("EnvChanged=false after setenv in handler; want true")
+	}
+}
@@ -0,0 +1,571 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package buildconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	starjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/build/metadata"
+	"go.chromium.org/build/siso/execute"
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/o11y/clog"
+)
+
+// testFuncPrefix is a prefix of test functions in Starlark test files.
+const testFuncPrefix = "test_"
+
+// TestOption is an option to run Starlark tests.
+type TestOption struct {
+	// Repos are Starlark repositories, e.g. "config".
+	Repos map[string]fs.FS
+
+	// Flags are default flags for config loaded by tests.
+	Flags map[string]string
+
+	// Path is the build path for tests.
+	Path *build.Path
+
+	// Match reports whether to run the test function.
+	// nil runs all test functions.
+	Match func(name string) bool
+}
+
+// TestResult is a result of a Starlark test function.
+type TestResult struct {
+	// Name is the test function name.
+	Name string
+
+	// Errors are failures reported by the test.
+	Errors []string
+
+	// Duration is the time to run the test.
+	Duration time.Duration
+}
+
+// Failed reports whether the test failed.
+func (r TestResult) Failed() bool {
+	return len(r.Errors) > 0
+}
+
+// RunTests loads Starlark test file fname (e.g. "@config//foo_test.star"),
+// and runs `test_*` functions in the file in order of definition.
+// A test function is called with testing object `t`, which contains
+//
+//	eq(got, want, msg): reports failure if got != want.
+//	true(cond, msg): reports failure if cond is false.
+//	error(msg): reports failure.
+//	init(load, flags, metadata): loads config and runs init.
+//	cmd(args, envs, dir, deps, inputs, tool_inputs, outputs, rspfile, rspfile_content): creates a fake cmd.
+//	call_handler(fn, cmd): calls handler function fn with fake ctx and cmd.
+//	write_file(fname, content): writes a file in fake fs.
+//	read_file(fname): reads a file in fake fs, or returns None if not exist.
+//
+// Config returned by `t.init` contains
+//
+//	step_config: decoded step_config returned by init.
+//	handlers: handler names.
+//	filegroups: filegroup names.
+//	handle(handler, cmd): runs the handler for the fake cmd.
+//
+// Fake cmd has attributes args, inputs, tool_inputs, outputs,
+// rspfile_content, exit_status, stdout and stderr to check side effects
+// of the handler.
+//
+// Each test function uses its own fs for t.write_file, t.read_file,
+// ctx.fs and ctx.actions of handlers, so files written by a test are
+// not visible to other tests. Files are kept in memory, and not
+// flushed to the disk.
+func RunTests(ctx context.Context, fname string, opt TestOption) ([]TestResult, error) {
+	repos := maps.Clone(opt.Repos)
+	if repos == nil {
+		repos = map[string]fs.FS{}
+	}
+	repos["builtin"] = builtinStar
+	if _, ok := repos[configOverridesRepo]; !ok {
+		repos[configOverridesRepo] = emptyFS{}
+	}
+	loader := &repoLoader{
+		ctx:         ctx,
+		repos:       repos,
+		predeclared: builtinModule(ctx),
+	}
+	thread := &starlark.Thread{
+		Name: "load",
+		Print: func(thread *starlark.Thread, msg string) {
+			clog.Infof(ctx, "thread:%s %s", thread.Name, msg)
+		},
+		Load: loader.Load,
+	}
+	thread.SetLocal("modulename", fname)
+	globals, err := loader.Load(thread, fname)
+	if err != nil {
+		var eerr *starlark.EvalError
+		if errors.As(err, &eerr) {
+			return nil, fmt.Errorf("failed to load %s: %s", fname, eerr.Backtrace())
+		}
+		return nil, fmt.Errorf("failed to load %s: %w", fname, err)
+	}
+	var tests []*starlark.Function
+	for name, v := range globals {
+		fn, ok := v.(*starlark.Function)
+		if !ok || !strings.HasPrefix(name, testFuncPrefix) {
+			continue
+		}
+		if opt.Match != nil && !opt.Match(name) {
+			continue
+		}
+		tests = append(tests, fn)
+	}
+	slices.SortFunc(tests, func(a, b *starlark.Function) int {
+		return int(a.Position().Line) - int(b.Position().Line)
+	})
+	var results []TestResult
+	for _, fn := range tests {
+		results = append(results, runTest(ctx, fn, opt))
+	}
+	return results, nil
+}
+
+func runTest(ctx context.Context, fn *starlark.Function, opt TestOption) TestResult {
+	started := time.Now()
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		return TestResult{
+			Name:     fn.Name(),
+			Errors:   []string{fmt.Sprintf("failed to create fs: %v", err)},
+			Duration: time.Since(started),
+		}
+	}
+	defer hashFS.Close(ctx)
+	t := &starTester{
+		ctx:    ctx,
+		opt:    opt,
+		hashFS: hashFS,
+	}
+	thread := &starlark.Thread{
+		Name: "test:" + fn.Name(),
+		Print: func(thread *starlark.Thread, msg string) {
+			clog.Infof(ctx, "thread:%s %s", thread.Name, msg)
+		},
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load is not allowed in test")
+		},
+	}
+	_, err = starlark.Call(thread, fn, starlark.Tuple{starTesting(t)}, nil)
+	if err != nil {
+		var eerr *starlark.EvalError
+		if errors.As(err, &eerr) {
+			t.errors = append(t.errors, eerr.Backtrace())
+		} else {
+			t.errors = append(t.errors, err.Error())
+		}
+	}
+	return TestResult{
+		Name:     fn.Name(),
+		Errors:   t.errors,
+		Duration: time.Since(started),
+	}
+}
+
+// starTester is a receiver of testing object `t`.
+type starTester struct {
+	ctx    context.Context
+	opt    TestOption
+	hashFS *hashfs.HashFS
+	errors []string
+}
+
+func (t *starTester) String() string      { return "testing" }
+func (*starTester) Type() string          { return "testing" }
+func (*starTester) Freeze()               {}
+func (*starTester) Truth() starlark.Bool  { return starlark.True }
+func (*starTester) Hash() (uint32, error) { return 0, errors.New("testing is not hashable") }
+
+func starTesting(t *starTester) starlark.Value {
+	return starlarkstruct.FromStringDict(starlark.String("testing"), map[string]starlark.Value{
+		"eq":           starlark.NewBuiltin("eq", starTestingEq).BindReceiver(t),
+		"true":         starlark.NewBuiltin("true", starTestingTrue).BindReceiver(t),
+		"error":        starlark.NewBuiltin("error", starTestingError).BindReceiver(t),
+		"init":         starlark.NewBuiltin("init", starTestingInit).BindReceiver(t),
+		"cmd":          starlark.NewBuiltin("cmd", starTestingCmd).BindReceiver(t),
+		"call_handler": starlark.NewBuiltin("call_handler", starTestingCallHandler).BindReceiver(t),
+		"write_file":   starlark.NewBuiltin("write_file", starTestingWriteFile).BindReceiver(t),
+		"read_file":    starlark.NewBuiltin("read_file", starTestingReadFile).BindReceiver(t),
+	})
+}
+
+// errorf records failure at the caller's position.
+func (t *starTester) errorf(thread *starlark.Thread, msg, format string, args ...any) {
+	var pos string
+	if thread.CallStackDepth() > 1 {
+		pos = thread.CallFrame(1).Pos.String() + ": "
+	}
+	if msg != "" {
+		msg += ": "
+	}
+	t.errors = append(t.errors, pos+msg+fmt.Sprintf(format, args...))
+}
+
+// Starlark function `t.eq(got, want, msg)` to check got equals to want.
+func starTestingEq(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var got, want starlark.Value
+	var msg string
+	err := starlark.UnpackArgs("eq", args, kwargs, "got", &got, "want", &want, "msg?", &msg)
+	if err != nil {
+		return starlark.None, err
+	}
+	eq, err := starlark.Equal(got, want)
+	if err != nil {
+		return starlark.None, err
+	}
+	if !eq {
+		t.errorf(thread, msg, "got %s; want %s", got, want)
+	}
+	return starlark.None, nil
+}
+
+// Starlark function `t.true(cond, msg)` to check cond is true.
+func starTestingTrue(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var cond starlark.Value
+	var msg string
+	err := starlark.UnpackArgs("true", args, kwargs, "cond", &cond, "msg?", &msg)
+	if err != nil {
+		return starlark.None, err
+	}
+	if !cond.Truth() {
+		t.errorf(thread, msg, "got %s; want true", cond)
+	}
+	return starlark.None, nil
+}
+
+// Starlark function `t.error(msg)` to report failure.
+func starTestingError(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var msg string
+	err := starlark.UnpackArgs("error", args, kwargs, "msg", &msg)
+	if err != nil {
+		return starlark.None, err
+	}
+	t.errorf(thread, "", "%s", msg)
+	return starlark.None, nil
+}
+
+// Starlark function `t.init(load, flags, metadata)` to load config
+// and run init.
+func starTestingInit(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	load := "@config//main.star"
+	var flagsValue, metadataValue *starlark.Dict
+	err := starlark.UnpackArgs("init", args, kwargs, "load?", &load, "flags?", &flagsValue, "metadata?", &metadataValue)
+	if err != nil {
+		return starlark.None, err
+	}
+	flags := maps.Clone(t.opt.Flags)
+	if flags == nil {
+		flags = map[string]string{}
+	}
+	err = unpackStringDict(flagsValue, func(k, v string) error {
+		flags[k] = v
+		return nil
+	})
+	if err != nil {
+		return starlark.None, fmt.Errorf("flags: %w", err)
+	}
+	cfg, err := New(t.ctx, load, flags, maps.Clone(t.opt.Repos))
+	if err != nil {
+		return starlark.None, err
+	}
+	err = unpackStringDict(metadataValue, cfg.Metadata.Set)
+	if err != nil {
+		return starlark.None, fmt.Errorf("metadata: %w", err)
+	}
+	s, err := cfg.Init(t.ctx, t.hashFS, t.opt.Path)
+	if err != nil {
+		return starlark.None, err
+	}
+	var stepConfig starlark.Value = starlark.None
+	if s != "" {
+		stepConfig, err = starlark.Call(thread, starjson.Module.Members["decode"], starlark.Tuple{starlark.String(s)}, nil)
+		if err != nil {
+			return starlark.None, fmt.Errorf("failed to decode step_config: %w", err)
+		}
+	}
+	var handlers []string
+	for _, k := range cfg.handlers.Keys() {
+		if s, ok := starlark.AsString(k); ok {
+			handlers = append(handlers, s)
+		}
+	}
+	slices.Sort(handlers)
+	filegroups := slices.Sorted(maps.Keys(cfg.filegroups))
+	return starlarkstruct.FromStringDict(starlark.String("config"), map[string]starlark.Value{
+		"step_config": stepConfig,
+		"handlers":    packList(handlers),
+		"filegroups":  packList(filegroups),
+		"handle":      starlark.NewBuiltin("handle", starTestingHandle).BindReceiver(starTestConfig{t: t, cfg: cfg}),
+	}), nil
+}
+
+func unpackStringDict(d *starlark.Dict, f func(k, v string) error) error {
+	if d == nil {
+		return nil
+	}
+	for _, item := range d.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			return fmt.Errorf("key %s is not a string", item[0])
+		}
+		v, ok := starlark.AsString(item[1])
+		if !ok {
+			return fmt.Errorf("value %s for %q is not a string", item[1], k)
+		}
+		err := f(k, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// starTestConfig is a receiver of config returned by `t.init`.
+type starTestConfig struct {
+	t   *starTester
+	cfg *Config
+}
+
+func (starTestConfig) String() string        { return "config" }
+func (starTestConfig) Type() string          { return "config" }
+func (starTestConfig) Freeze()               {}
+func (starTestConfig) Truth() starlark.Bool  { return starlark.True }
+func (starTestConfig) Hash() (uint32, error) { return 0, errors.New("config is not hashable") }
+
+// Starlark function `config.handle(handler, cmd)` to run the handler.
+func starTestingHandle(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	c, ok := fn.Receiver().(starTestConfig)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var handler string
+	var cmd *starTestCmd
+	err := starlark.UnpackArgs("handle", args, kwargs, "handler", &handler, "cmd", &cmd)
+	if err != nil {
+		return starlark.None, err
+	}
+	err = c.cfg.Handle(c.t.ctx, handler, c.t.opt.Path, cmd.cmd, cmd.expandedInputs)
+	return starlark.None, err
+}
+
+// Starlark function `t.call_handler(fn, cmd)` to call handler function
+// with fake ctx and cmd.
+func starTestingCallHandler(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var handler starlark.Callable
+	var cmd *starTestCmd
+	err := starlark.UnpackArgs("call_handler", args, kwargs, "fn", &handler, "cmd", &cmd)
+	if err != nil {
+		return starlark.None, err
+	}
+	cfg := &Config{
+		Metadata: metadata.New(),
+		flags:    t.opt.Flags,
+		fscache: &fscache{
+			m: make(map[string][]byte),
+		},
+	}
+	err = cfg.callHandler(t.ctx, handler.Name(), handler, t.opt.Path, cmd.cmd, cmd.expandedInputs)
+	return starlark.None, err
+}
+
+// Starlark function `t.cmd(args, envs, dir, deps, inputs, tool_inputs, outputs, rspfile, rspfile_content)`
+// to create a fake cmd.
+func starTestingCmd(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var argsValue, inputsValue, toolInputsValue, outputsValue starlark.Value
+	var envsValue *starlark.Dict
+	dir := t.opt.Path.Dir
+	var deps, rspfile, rspfileContent string
+	err := starlark.UnpackArgs("cmd", args, kwargs,
+		"args?", &argsValue,
+		"envs?", &envsValue,
+		"dir?", &dir,
+		"deps?", &deps,
+		"inputs?", &inputsValue,
+		"tool_inputs?", &toolInputsValue,
+		"outputs?", &outputsValue,
+		"rspfile?", &rspfile,
+		"rspfile_content?", &rspfileContent)
+	if err != nil {
+		return starlark.None, err
+	}
+	cmd := &execute.Cmd{
+		ID:             "test:" + thread.Name,
+		Dir:            dir,
+		ExecRoot:       t.opt.Path.ExecRoot,
+		Deps:           deps,
+		RSPFile:        rspfile,
+		RSPFileContent: []byte(rspfileContent),
+		HashFS:         t.hashFS,
+	}
+	for _, v := range []struct {
+		name  string
+		value starlark.Value
+		list  *[]string
+	}{
+		{name: "args", value: argsValue, list: &cmd.Args},
+		{name: "inputs", value: inputsValue, list: &cmd.Inputs},
+		{name: "tool_inputs", value: toolInputsValue, list: &cmd.ToolInputs},
+		{name: "outputs", value: outputsValue, list: &cmd.Outputs},
+	} {
+		if v.value == nil {
+			continue
+		}
+		*v.list, err = unpackList(v.value)
+		if err != nil {
+			return starlark.None, fmt.Errorf("%s: %w", v.name, err)
+		}
+	}
+	err = unpackStringDict(envsValue, func(k, v string) error {
+		cmd.Env = append(cmd.Env, k+"="+v)
+		return nil
+	})
+	if err != nil {
+		return starlark.None, fmt.Errorf("envs: %w", err)
+	}
+	return &starTestCmd{cmd: cmd}, nil
+}
+
+// starTestCmd is a fake cmd for tests.
+type starTestCmd struct {
+	cmd *execute.Cmd
+}
+
+func (c *starTestCmd) String() string      { return fmt.Sprintf("cmd[%s]", c.cmd.Args) }
+func (*starTestCmd) Type() string          { return "cmd" }
+func (*starTestCmd) Freeze()               {}
+func (*starTestCmd) Truth() starlark.Bool  { return starlark.True }
+func (*starTestCmd) Hash() (uint32, error) { return 0, errors.New("cmd is not hashable") }
+
+func (c *starTestCmd) expandedInputs() []string {
+	return c.cmd.Inputs
+}
+
+// AttrNames returns attribute names of the fake cmd.
+func (*starTestCmd) AttrNames() []string {
+	return []string{
+		"args",
+		"exit_status",
+		"inputs",
+		"outputs",
+		"rspfile_content",
+		"stderr",
+		"stdout",
+		"tool_inputs",
+	}
+}
+
+// Attr returns attribute value of the fake cmd.
+func (c *starTestCmd) Attr(name string) (starlark.Value, error) {
+	result, _ := c.cmd.ActionResult()
+	switch name {
+	case "args":
+		return packTuple(c.cmd.Args), nil
+	case "inputs":
+		return packList(c.cmd.Inputs), nil
+	case "tool_inputs":
+		return packList(c.cmd.ToolInputs), nil
+	case "outputs":
+		return packList(c.cmd.Outputs), nil
+	case "rspfile_content":
+		return starlark.Bytes(c.cmd.RSPFileContent), nil
+	case "exit_status":
+		if result == nil {
+			return starlark.None, nil
+		}
+		return starlark.MakeInt(int(result.GetExitCode())), nil
+	case "stdout":
+		return starlark.Bytes(result.GetStdoutRaw()), nil
+	case "stderr":
+		return starlark.Bytes(result.GetStderrRaw()), nil
+	}
+	return nil, nil
+}
+
+// Starlark function `t.write_file(fname, content)` to write a file in fake fs.
+func starTestingWriteFile(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var fname string
+	var content starlark.Value
+	err := starlark.UnpackArgs("write_file", args, kwargs, "fname", &fname, "content", &content)
+	if err != nil {
+		return starlark.None, err
+	}
+	var buf []byte
+	switch content := content.(type) {
+	case starlark.Bytes:
+		buf = []byte(string(content))
+	case starlark.String:
+		buf = []byte(string(content))
+	default:
+		return starlark.None, fmt.Errorf("content is not bytes nor a string: %s", content.Type())
+	}
+	err = t.hashFS.WriteFile(t.ctx, t.opt.Path.ExecRoot, fname, buf, false, time.Now(), nil, nil)
+	return starlark.None, err
+}
+
+// Starlark function `t.read_file(fname)` to read a file in fake fs.
+func starTestingReadFile(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	t, ok := fn.Receiver().(*starTester)
+	if !ok {
+		return starlark.None, fmt.Errorf("unexpected receiver: %v", fn.Receiver())
+	}
+	var fname string
+	err := starlark.UnpackArgs("read_file", args, kwargs, "fname", &fname)
+	if err != nil {
+		return starlark.None, err
+	}
+	buf, err := t.hashFS.ReadFile(t.ctx, t.opt.Path.ExecRoot, fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return starlark.None, nil
+	}
+	if err != nil {
+		return starlark.None, err
+	}
+	return starlark.Bytes(string(buf)), nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package buildconfig
+
+import (
+	"io/fs"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/build/siso/build"
+)
+
+func TestRunTests(t *testing.T) {
+	ctx := t.Context()
+	repo := fstest.MapFS{
+		"main.star": &fstest.MapFile{
+			Data: []byte(`
+load("@builtin//encoding.star", "json")
+load("@builtin//struct.star", "module")
+
+def __stamp(ctx, cmd):
+    ctx.actions.write(cmd.outputs[0])
+    ctx.actions.exit(exit_status = 0)
+
+def __copy(ctx, cmd):
+    ctx.actions.copy(cmd.inputs[0], cmd.outputs[0])
+    ctx.actions.exit(exit_status = 0)
+
+def __fix(ctx, cmd):
+    ctx.actions.fix(inputs = cmd.inputs + ["gen/extra.h"])
+
+def init(ctx):
+    rules = [{
+        "name": "stamp",
+        "action": "stamp",
+        "handler": "stamp",
+    }]
+    if ctx.flags.get("remote") == "true":
+        rules.append({
+            "name": "cxx",
+            "action": "cxx",
+            "remote": True,
+        })
+    return module(
+        "config",
+        step_config = json.encode({"rules": rules}),
+        filegroups = {},
+        handlers = {
+            "stamp": __stamp,
+            "copy": __copy,
+        },
+    )
+
+fix = __fix
+`),
+		},
+		"main_test.star": &fstest.MapFile{
+			Data: []byte(`
+load("@config//main.star", "fix")
+
+def test_init(t):
+    cfg = t.init()
+    t.eq(len(cfg.step_config["rules"]), 1)
+    t.eq(cfg.handlers, ["copy", "stamp"])
+    cfg = t.init(flags = {"remote": "true"})
+    t.eq([r["name"] for r in cfg.step_config["rules"]], ["stamp", "cxx"])
+
+def test_stamp(t):
+    cfg = t.init()
+    cmd = t.cmd(args = ["touch", "obj/foo.stamp"], outputs = ["out/siso/obj/foo.stamp"])
+    cfg.handle("stamp", cmd)
+    t.eq(cmd.exit_status, 0)
+    t.eq(t.read_file("out/siso/obj/foo.stamp"), b"")
+
+def test_copy(t):
+    t.write_file("out/siso/foo.txt", "hello")
+    cfg = t.init()
+    cmd = t.cmd(inputs = ["out/siso/foo.txt"], outputs = ["out/siso/bar.txt"])
+    cfg.handle("copy", cmd)
+    t.eq(t.read_file("out/siso/bar.txt"), b"hello")
+
+def test_isolated_fs(t):
+    # files written by other tests are not visible.
+    t.eq(t.read_file("out/siso/foo.txt"), None)
+    t.eq(t.read_file("out/siso/obj/foo.stamp"), None)
+
+def test_fix(t):
+    cmd = t.cmd(inputs = ["foo.h"])
+    t.call_handler(fix, cmd)
+    t.eq(cmd.inputs, ["foo.h", "gen/extra.h"])
+    t.eq(cmd.exit_status, None)
+
+def test_fail(t):
+    t.eq(1, 2, "one")
+    t.true(False)
+
+def helper_not_test(t):
+    fail("should not run")
+`),
+		},
+	}
+
+	results, err := RunTests(ctx, "@config//main_test.star", TestOption{
+		Repos: map[string]fs.FS{
+			"config": repo,
+		},
+		Flags: map[string]string{"dir": "out/siso"},
+		Path:  build.NewPath(t.TempDir(), "out/siso"),
+	})
+	if err != nil {
+		t.Fatalf("RunTests=%v; want nil err", err)
+	}
+	var names []string
+	for _, r := range results {
+		names = append(names, r.Name)
+		if r.Name == "test_fail" {
+			if len(r.Errors) != 2 || !strings.Contains(r.Errors[0], "one: got 1; want 2") {
+				t.Errorf("test_fail errors=%q; want 2 errors", r.Errors)
+			}
+			continue
+		}
+		if r.Failed() {
+			t.Errorf("%s failed: %q", r.Name, r.Errors)
+		}
+	}
+	want := []string{"test_init", "test_stamp", "test_copy", "test_isolated_fs", "test_fix", "test_fail"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Errorf("tests diff -want +got:\n%s", diff)
+	}
+}
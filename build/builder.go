@@ -50,8 +50,10 @@ import (
 	"go.chromium.org/build/siso/o11y/resultstore"
 	"go.chromium.org/build/siso/o11y/trace"
 	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/breaker"
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/reapi/merkletree"
+	"go.chromium.org/build/siso/reapi/signature"
 	"go.chromium.org/build/siso/runtimex"
 	"go.chromium.org/build/siso/scandeps"
 	"go.chromium.org/build/siso/sync/semaphore"
@@ -88,6 +90,8 @@ type Options struct {
 	REExecEnable       bool
 	RECacheEnableRead  bool
 	RECacheEnableWrite bool
+	RECacheSigner      *signature.Signer
+	RECacheVerifier    *signature.Verifier
 	ReproxyAddr        string
 	ActionSalt         []byte
 
@@ -105,6 +109,9 @@ type Options struct {
 	TraceExporter        *trace.Exporter
 	PprofUploader        *sisopprof.Uploader
 	ResultstoreUploader  *resultstore.Uploader
+	JUnitReport          *JUnitReport
+	DiagnosticsReport    *DiagnosticsReport
+	OperationStats       *OperationStats
 
 	// Clobber forces to rebuild ignoring existing generated files.
 	Clobber bool
@@ -149,6 +156,13 @@ type Options struct {
 
 	// LastFailureTargets is a list of targets that failed in the previous build.
 	LastFailureTargets []string
+
+	// PlatformEscalations records platform escalation levels of steps
+	// across builds.
+	PlatformEscalations *PlatformEscalations
+
+	// HungStep configures the watchdog of hung local steps.
+	HungStep HungStepOptions
 }
 
 // Builder is a builder.
@@ -161,6 +175,8 @@ type Builder struct {
 
 	statusReporter StatusReporter
 	progress       progress
+	live           liveSteps
+	scheduling     scheduling
 
 	// path system used in the build.
 	path   *Path
@@ -202,8 +218,14 @@ type Builder struct {
 	reExecEnable       bool
 	reCacheEnableRead  bool
 	reCacheEnableWrite bool
+	reCacheSigner      *signature.Signer
+	reCacheVerifier    *signature.Verifier
 	reapiclient        *reapi.Client
 
+	platformEscalations *PlatformEscalations
+
+	hungSteps *hungStepWatchdog
+
 	reproxySema *semaphore.Prioritized
 	reproxyExec *reproxyexec.REProxyExec
 
@@ -218,12 +240,15 @@ type Builder struct {
 	ninjaLogWriter       io.Writer
 	failureSummaryWriter io.Writer
 	failedCommandsWriter io.Writer
+	junitReport          *JUnitReport
+	diagnosticsReport    *DiagnosticsReport
 	localexecLogWriter   io.Writer
 	metricsJSONWriter    io.Writer
 	outputLogWriter      io.Writer
 	traceExporter        *trace.Exporter
 	traceEvents          *traceEvents
 	traceStats           *traceStats
+	operationStats       *OperationStats
 	tracePprof           *tracePprof
 	pprofUploader        *sisopprof.Uploader
 	resultstoreUploader  *resultstore.Uploader
@@ -298,6 +323,7 @@ func New(ctx context.Context, graph Graph, opts Options) (_ *Builder, err error)
 	if opts.REAPIClient != nil {
 		logger.Infof("enable built-in remote exec")
 		re = remoteexec.New(ctx, opts.REAPIClient)
+		re.SetVerifier(opts.RECacheVerifier)
 	} else {
 		logger.Infof("disable built-in remote exec")
 	}
@@ -359,16 +385,23 @@ func New(ctx context.Context, graph Graph, opts Options) (_ *Builder, err error)
 		reExecEnable:       opts.REExecEnable,
 		reCacheEnableRead:  opts.RECacheEnableRead,
 		reCacheEnableWrite: opts.RECacheEnableWrite,
+		reCacheSigner:      opts.RECacheSigner,
+		reCacheVerifier:    opts.RECacheVerifier,
 		reproxyExec:        pe,
 		reproxySema:        semaphore.NewPrioritized("reproxyexec", opts.Limits.Remote),
 		actionSalt:         opts.ActionSalt,
 		reapiclient:        opts.REAPIClient,
 
+		platformEscalations:   opts.PlatformEscalations,
+		hungSteps:             newHungStepWatchdog(opts.HungStep),
 		outputLocal:           opts.OutputLocal,
 		cacheSema:             semaphore.New("cache", opts.Limits.Cache),
 		cache:                 opts.Cache,
 		failureSummaryWriter:  opts.FailureSummaryWriter,
 		failedCommandsWriter:  opts.FailedCommandsWriter,
+		junitReport:           opts.JUnitReport,
+		diagnosticsReport:     opts.DiagnosticsReport,
+		operationStats:        opts.OperationStats,
 		outputLogWriter:       opts.OutputLogWriter,
 		explainWriter:         ew,
 		ninjaLogWriter:        nw,
@@ -629,11 +662,29 @@ func (b *Builder) Build(ctx context.Context, name string, args ...string) (err e
 				restat.ROps, restat.RErrs, numBytes(restat.RBytes),
 				restat.WOps, restat.WErrs, numBytes(restat.WBytes))
 		}
+		var flakyLine string
+		if stat.FlakyRetry > 0 {
+			flakyLine = fmt.Sprintf("flaky: %d steps succeeded after %d retries by retry policy\n",
+				stat.FlakySuccess, stat.FlakyRetry)
+		}
+		var escalationLine string
+		if stat.Escalated > 0 {
+			escalationLine = fmt.Sprintf("escalation: %d steps escalated remote platform\n",
+				stat.Escalated)
+		}
+		var circuitLine string
+		if stat.CircuitOpen > 0 {
+			circuitLine = fmt.Sprintf("circuit: %d steps ran locally while RE API circuit was open\n",
+				stat.CircuitOpen)
+		}
 		if !b.reproxyExec.Used() {
 			// this stats will be shown by reproxy shutdown.
 			msg := fmt.Sprintf("\nlocal:%d remote:%d cache:%d cache-write:%d(err:%d) fallback:%d retry:%d skip:%d\n",
 				stat.Local+stat.NoExec, stat.Remote, stat.CacheHit, stat.CacheWrite, stat.CacheWriteErr, stat.LocalFallback, stat.RemoteRetry, stat.Skipped) +
 				depsStatLine +
+				flakyLine +
+				escalationLine +
+				circuitLine +
 				restatLine +
 				fsstatLine + "\n"
 			ui.Default.PrintLines("\n", msg)
@@ -641,30 +692,20 @@ func (b *Builder) Build(ctx context.Context, name string, args ...string) (err e
 				b.resultstoreUploader.AddBuildLog(msg + "\n")
 			}
 		} else {
-			ui.Default.PrintLines("\n", "\n")
+			ui.Default.PrintLines("\n", flakyLine+escalationLine+circuitLine+"\n")
 		}
 	}()
-	semas := []semaphore.Monitorable{
-		b.cache.sema,
-		b.localSema,
-		b.remoteSema,
-		b.reproxySema,
-		b.rewrapSema,
-		b.stepSema,
-		hashfs.FlushSemaphore,
-		hashfs.ForgetMissingsSemaphore,
-		osfs.LstatSemaphore,
-		reapi.FileSemaphore,
-		gccutil.Semaphore,
-		msvcutil.Semaphore,
-		remoteexec.Semaphore,
-	}
-	b.traceEvents.Start(ctx, semas, []*iometrics.IOMetrics{
+	b.traceEvents.Start(ctx, b.monitorSemas(), []*iometrics.IOMetrics{
 		b.hashFS.OS.IOMetrics,
 		b.reapiclient.IOMetrics(),
 		// TODO: cache iometrics?
 	})
 	defer b.traceEvents.Close(ctx)
+	for _, cb := range b.reapiclient.CircuitBreakers() {
+		defer cb.Subscribe(func(t breaker.Transition) {
+			b.circuitTransition(ctx, t)
+		})()
+	}
 	b.tracePprof.SetMetadata(b.metadata)
 	b.pprofUploader.SetMetadata(ctx, b.metadata)
 	defer func(ctx context.Context) {
@@ -688,6 +729,11 @@ func (b *Builder) Build(ctx context.Context, name string, args ...string) (err e
 	clog.Infof(ctx, "build pendings=%d ready=%d", pstat.npendings, pstat.nready)
 	b.progress.start(ctx, b)
 	defer b.progress.stop()
+	if b.hungSteps != nil {
+		wctx, wcancel := context.WithCancel(ctx)
+		defer wcancel()
+		go b.hungSteps.run(wctx)
+	}
 
 	if b.clobber {
 		fmt.Fprintf(b.explainWriter, "--clobber is specified\n")
@@ -723,8 +769,17 @@ loop:
 
 		var step *Step
 		var ok bool
+		q := b.plan.q
+		resumed := b.scheduling.resumed()
+		if resumed != nil {
+			// paused. don't schedule new steps until resumed.
+			q = nil
+		}
 		select {
-		case step, ok = <-b.plan.q:
+		case <-resumed:
+			done(nil)
+			continue
+		case step, ok = <-q:
 			if !ok {
 				clog.Infof(ctx, "q is closed")
 				done(nil)
@@ -907,6 +962,28 @@ func (b *Builder) uploadBuildNinja(ctx context.Context) {
 	clog.Infof(ctx, "uploaded build files tree %s (%d entries) in %s", d, len(ents), time.Since(started))
 }
 
+// circuitTransition reports a state transition of the RE API
+// circuit breaker to the UI, siso_metrics.json and the trace.
+func (b *Builder) circuitTransition(ctx context.Context, t breaker.Transition) {
+	clog.Warningf(ctx, "RBE circuit %s", t)
+	switch t.To {
+	case breaker.Open:
+		ui.Default.PrintLines(ui.SGR(ui.Yellow, fmt.Sprintf("WARNING: RBE %s circuit is open, run steps locally: %s\n\n", t.Name, t.Reason)))
+	case breaker.Closed:
+		ui.Default.PrintLines(ui.SGR(ui.Green, fmt.Sprintf("RBE %s circuit is closed, resume remote: %s\n\n", t.Name, t.Reason)))
+	}
+	var metrics StepMetric
+	metrics.BuildID = b.id
+	metrics.Circuit = t.String()
+	metrics.CircuitTime = IntervalMetric(t.Time.Sub(b.start))
+	b.recordMetrics(ctx, metrics)
+	b.traceEvents.AddInstant(fmt.Sprintf("circuit %s %s", t.Name, t.To), "circuit", t.Time, map[string]any{
+		"from":   t.From.String(),
+		"to":     t.To.String(),
+		"reason": t.Reason,
+	})
+}
+
 func (b *Builder) recordMetrics(ctx context.Context, m StepMetric) {
 	mb, err := json.Marshal(m)
 	if err != nil {
@@ -1242,6 +1319,7 @@ func (b *Builder) updateDeps(ctx context.Context, step *Step) error {
 func (b *Builder) finalizeTrace(ctx context.Context, tc *trace.Context) {
 	b.traceEvents.Add(ctx, tc)
 	b.traceStats.update(tc)
+	b.operationStats.Add(tc)
 	b.traceExporter.Export(ctx, tc)
 	b.tracePprof.Add(ctx, tc)
 }
@@ -1305,6 +1383,25 @@ func (b *Builder) ActiveSteps() []ActiveStepInfo {
 	return b.progress.ActiveSteps()
 }
 
+// monitorSemas returns semaphores to monitor in trace and live status.
+func (b *Builder) monitorSemas() []semaphore.Monitorable {
+	return []semaphore.Monitorable{
+		b.cache.sema,
+		b.localSema,
+		b.remoteSema,
+		b.reproxySema,
+		b.rewrapSema,
+		b.stepSema,
+		hashfs.FlushSemaphore,
+		hashfs.ForgetMissingsSemaphore,
+		osfs.LstatSemaphore,
+		reapi.FileSemaphore,
+		gccutil.Semaphore,
+		msvcutil.Semaphore,
+		remoteexec.Semaphore,
+	}
+}
+
 func (b *Builder) localFallbackEnabled() bool {
 	return !b.strictRemote && !experiments.Enabled("no-fallback", "") && !b.hashFS.OnCog()
 }
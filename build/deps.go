@@ -163,6 +163,20 @@ func depsExpandInputs(ctx context.Context, b *Builder, step *Step) {
 		}
 		inputs = append(inputs, in)
 	}
+	// scandeps_scanner collects inputs imported/included by sources,
+	// so no need to specify them in inputs of siso config.
+	scanned, err := depsScanInputs(ctx, b, step)
+	if err != nil {
+		clog.Warningf(ctx, "deps scan error: %v", err)
+		step.metrics.ScandepsErr = true
+	}
+	for _, in := range scanned {
+		if seen[in] {
+			continue
+		}
+		seen[in] = true
+		inputs = append(inputs, in)
+	}
 	clog.Infof(ctx, "deps expands %d -> %d", len(step.cmd.Inputs), len(inputs))
 	step.cmd.Inputs = make([]string, len(inputs))
 	copy(step.cmd.Inputs, inputs)
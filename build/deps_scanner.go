@@ -0,0 +1,75 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	log "github.com/golang/glog"
+
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/scandeps"
+)
+
+// depsScanInputs runs scandeps with the scanner specified by
+// `scandeps_scanner` of the step (e.g. "proto", "shader"), and returns
+// exec root relative inputs of the step.
+// It returns nil if the step doesn't specify the scanner.
+func depsScanInputs(ctx context.Context, b *Builder, step *Step) ([]string, error) {
+	name := step.def.Binding("scandeps_scanner")
+	if name == "" {
+		return nil, nil
+	}
+	scanner, ok := scandeps.LookupScanner(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown scandeps scanner %q", name)
+	}
+	var ins []string
+	err := b.scanDepsSema.Do(ctx, step.weight, func(ctx context.Context) error {
+		req, err := scanner.Request(ctx, step.cmd.Args)
+		if err != nil {
+			return err
+		}
+		if len(req.Sources) == 0 {
+			clog.Infof(ctx, "%s-scandeps: no source extracted", name)
+			return nil
+		}
+		var externals []string
+		fromWD := func(paths []string) {
+			for i := range paths {
+				paths[i] = b.path.MaybeFromWD(ctx, paths[i])
+				if !filepath.IsLocal(paths[i]) {
+					externals = append(externals, paths[i])
+				}
+			}
+		}
+		fromWD(req.Sources)
+		fromWD(req.Includes)
+		fromWD(req.Dirs)
+		if len(externals) > 0 {
+			n := len(externals)
+			v := externals[:min(len(externals), 5)]
+			return fmt.Errorf("%w %d %q...: platform=%q", errNotUnderExecRoot, n, v, step.cmd.Platform)
+		}
+		req.Scanner = name
+		req.Timeout = step.cmd.Timeout
+		started := time.Now()
+		ins, err = b.scanDeps.Scan(ctx, b.path.ExecRoot, req)
+		if log.V(1) {
+			clog.Infof(ctx, "%s-scandeps %d %s: %v", name, len(ins), time.Since(started), err)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s-scandeps: %w", name, err)
+	}
+	for i := range ins {
+		ins[i] = b.path.Intern(ins[i])
+	}
+	return ins, nil
+}
@@ -14,6 +14,8 @@ import (
 
 	"go.chromium.org/build/siso/execute"
 	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/scandeps"
+	"go.chromium.org/build/siso/sync/semaphore"
 )
 
 func TestDepsExpandInputs(t *testing.T) {
@@ -72,3 +74,69 @@ func TestDepsExpandInputs(t *testing.T) {
 		t.Errorf("depsExpandInputs: diff -want +got:\n%s", diff)
 	}
 }
+
+func TestDepsExpandInputs_Scanner(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+
+	hfs, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hfs.Close(ctx)
+
+	setupFile := func(fname, content string) {
+		t.Helper()
+		fullpath := filepath.Join(dir, fname)
+		err := os.MkdirAll(filepath.Dir(fullpath), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fullpath, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	setupFile("out/siso/protoc", "")
+	setupFile("components/foo/foo.proto", `import "components/foo/bar.proto";`)
+	setupFile("components/foo/bar.proto", "")
+
+	b := &Builder{
+		hashFS:       hfs,
+		path:         NewPath(dir, "out/siso"),
+		scanDepsSema: semaphore.NewPrioritized("scandeps", 1),
+		scanDeps:     scandeps.New(hfs, nil, nil),
+	}
+	step := &Step{
+		def: &fakeStepDef{
+			scanner: "proto",
+		},
+		cmd: &execute.Cmd{
+			Args: []string{
+				"./protoc",
+				"--proto_path=../..",
+				"--cpp_out=gen",
+				"../../components/foo/foo.proto",
+			},
+			Inputs: []string{
+				"out/siso/protoc",
+				"components/foo/foo.proto",
+			},
+		},
+	}
+	depsExpandInputs(ctx, b, step)
+
+	want := []string{
+		"out/siso/protoc",
+		"components/foo/foo.proto",
+		".",
+		"components/foo",
+		"components/foo/bar.proto",
+	}
+	if diff := cmp.Diff(want, step.cmd.Inputs); diff != "" {
+		t.Errorf("depsExpandInputs: diff -want +got:\n%s", diff)
+	}
+	if step.metrics.ScandepsErr {
+		t.Errorf("metrics.ScandepsErr=true; want false")
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.chromium.org/build/siso/ui"
+)
+
+// Diagnostic is a compiler diagnostic extracted from step output.
+type Diagnostic struct {
+	// File is exec root relative path of the diagnostic.
+	File      string `json:"file"`
+	StartLine int    `json:"start_line,omitempty"`
+	StartCol  int    `json:"start_col,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	EndCol    int    `json:"end_col,omitempty"`
+	// Severity is "error", "warning" or "note".
+	Severity string `json:"severity"`
+	// Code is diagnostic code if any. e.g. C4996, E0308.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	// Tool is a tool that emits the diagnostic.
+	Tool string `json:"tool,omitempty"`
+
+	// Step is the first output of the step that emits the diagnostic.
+	Step string `json:"step,omitempty"`
+	// Rule is the ninja rule of the step.
+	Rule string `json:"rule,omitempty"`
+}
+
+var (
+	// clang, gcc: file:line:col: error: message
+	gccDiagRE = regexp.MustCompile(`^((?:[A-Za-z]:)?[^:\s][^:]*):(\d+):(?:(\d+):)? (fatal error|error|warning|note): (.*)$`)
+
+	// cl.exe, clang-cl: file(line,col): error C1234: message
+	msvcDiagRE = regexp.MustCompile(`^(.+?)\((\d+)(?:,(\d+))?(?:-(\d+))?\) ?: (fatal error|error|warning|note) ?([A-Z]+\d+)?: (.*)$`)
+
+	// javac: file.java:line: error: message
+	javacDiagRE = regexp.MustCompile(`^(.+\.java):(\d+): (error|warning|note): (.*)$`)
+
+	// rustc:
+	//  error[E0308]: message
+	//    --> file:line:col
+	rustcDiagRE     = regexp.MustCompile(`^(error|warning|note)(?:\[(\w+)\])?: (.*)$`)
+	rustcLocationRE = regexp.MustCompile(`^\s*--> (.+):(\d+):(\d+)$`)
+)
+
+func diagSeverity(s string) string {
+	if s == "fatal error" {
+		return "error"
+	}
+	return s
+}
+
+// parseDiagnostics parses compiler diagnostics in output.
+// File in the diagnostics is as is in the output.
+func parseDiagnostics(output []byte) []Diagnostic {
+	var diags []Diagnostic
+	// pending rustc diagnostic waiting for its location.
+	var rustc *Diagnostic
+	s := bufio.NewScanner(bytes.NewReader(output))
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimRight(ui.StripANSIEscapeCodes(s.Text()), "\r")
+		if rustc != nil {
+			if m := rustcLocationRE.FindStringSubmatch(line); m != nil {
+				rustc.File = m[1]
+				rustc.StartLine, _ = strconv.Atoi(m[2])
+				rustc.StartCol, _ = strconv.Atoi(m[3])
+				rustc.EndLine, rustc.EndCol = rustc.StartLine, rustc.StartCol
+				diags = append(diags, *rustc)
+				rustc = nil
+				continue
+			}
+		}
+		if m := javacDiagRE.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{
+				File:     m[1],
+				Severity: m[3],
+				Message:  m[4],
+				Tool:     "javac",
+			}
+			d.StartLine, _ = strconv.Atoi(m[2])
+			d.EndLine = d.StartLine
+			diags = append(diags, d)
+			rustc = nil
+			continue
+		}
+		if m := gccDiagRE.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{
+				File:     m[1],
+				Severity: diagSeverity(m[4]),
+				Message:  m[5],
+				Tool:     "clang",
+			}
+			d.StartLine, _ = strconv.Atoi(m[2])
+			d.StartCol, _ = strconv.Atoi(m[3])
+			d.EndLine, d.EndCol = d.StartLine, d.StartCol
+			diags = append(diags, d)
+			rustc = nil
+			continue
+		}
+		if m := msvcDiagRE.FindStringSubmatch(line); m != nil {
+			d := Diagnostic{
+				File:     m[1],
+				Severity: diagSeverity(m[5]),
+				Code:     m[6],
+				Message:  m[7],
+				Tool:     "msvc",
+			}
+			d.StartLine, _ = strconv.Atoi(m[2])
+			d.StartCol, _ = strconv.Atoi(m[3])
+			d.EndLine, d.EndCol = d.StartLine, d.StartCol
+			if m[4] != "" {
+				d.EndCol, _ = strconv.Atoi(m[4])
+			}
+			diags = append(diags, d)
+			rustc = nil
+			continue
+		}
+		if m := rustcDiagRE.FindStringSubmatch(line); m != nil {
+			rustc = &Diagnostic{
+				Severity: m[1],
+				Code:     m[2],
+				Message:  m[3],
+				Tool:     "rustc",
+			}
+			continue
+		}
+	}
+	return diags
+}
+
+// DiagnosticsReport collects compiler diagnostics from finished steps.
+// It may be shared by several builds.
+type DiagnosticsReport struct {
+	mu    sync.Mutex
+	diags []Diagnostic
+}
+
+// NewDiagnosticsReport creates new diagnostics report.
+func NewDiagnosticsReport() *DiagnosticsReport {
+	return &DiagnosticsReport{}
+}
+
+// Diagnostics returns collected diagnostics.
+func (r *DiagnosticsReport) Diagnostics() []Diagnostic {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Diagnostic(nil), r.diags...)
+}
+
+// recordDiagnostics extracts diagnostics from stdout/stderr of the
+// finished step, including successful steps and cached results.
+func (b *Builder) recordDiagnostics(ctx context.Context, step *Step, err error) {
+	r := b.diagnosticsReport
+	if r == nil || step.cmd == nil {
+		return
+	}
+	res := cmdOutput(ctx, cmdOutputResultSUCCESS, step.cmd, "", "", err)
+	if res == nil {
+		return
+	}
+	var diags []Diagnostic
+	for _, out := range [][]byte{res.stdout, res.stderr} {
+		for _, d := range parseDiagnostics(out) {
+			d.File = b.path.MaybeFromWD(ctx, filepath.FromSlash(d.File))
+			d.Step = step.metrics.Output
+			d.Rule = step.def.ActionName()
+			diags = append(diags, d)
+		}
+	}
+	if len(diags) == 0 {
+		return
+	}
+	r.mu.Lock()
+	r.diags = append(r.diags, diags...)
+	r.mu.Unlock()
+}
+
+// WriteJSON writes diagnostics as JSON lines to w.
+func (r *DiagnosticsReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, d := range r.Diagnostics() {
+		err := enc.Encode(d)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SARIF 2.1.0 https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLoc `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name string `json:"name"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId,omitempty"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations,omitempty"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLoc `json:"artifactLocation"`
+	Region           *sarifRegion     `json:"region,omitempty"`
+}
+
+type sarifArtifactLoc struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+type sarifProperties struct {
+	Tool string `json:"tool,omitempty"`
+	Step string `json:"step,omitempty"`
+	Rule string `json:"rule,omitempty"`
+}
+
+// WriteSARIF writes diagnostics in SARIF format to w.
+// Relative paths are relative to execRoot (%EXECROOT%).
+func (r *DiagnosticsReport) WriteSARIF(w io.Writer, execRoot string) error {
+	run := sarifRun{
+		Tool: sarifTool{
+			Driver: sarifDriver{
+				Name: "siso",
+			},
+		},
+		OriginalURIBaseIDs: map[string]sarifArtifactLoc{
+			"EXECROOT": {
+				URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(execRoot) + "/"}).String(),
+			},
+		},
+		Results: []sarifResult{},
+	}
+	for _, d := range r.Diagnostics() {
+		res := sarifResult{
+			RuleID:  d.Code,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: d.Message},
+			Properties: sarifProperties{
+				Tool: d.Tool,
+				Step: d.Step,
+				Rule: d.Rule,
+			},
+		}
+		if d.File != "" {
+			loc := sarifArtifactLoc{
+				URI: filepath.ToSlash(d.File),
+			}
+			if filepath.IsAbs(d.File) {
+				loc.URI = (&url.URL{Scheme: "file", Path: filepath.ToSlash(d.File)}).String()
+			} else {
+				loc.URIBaseID = "EXECROOT"
+			}
+			pl := sarifPhysicalLocation{
+				ArtifactLocation: loc,
+			}
+			if d.StartLine > 0 {
+				pl.Region = &sarifRegion{
+					StartLine:   d.StartLine,
+					StartColumn: d.StartCol,
+					EndLine:     d.EndLine,
+					EndColumn:   d.EndCol,
+				}
+			}
+			res.Locations = []sarifLocation{{PhysicalLocation: pl}}
+		}
+		run.Results = append(run.Results, res)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDiagnostics(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		output string
+		want   []Diagnostic
+	}{
+		{
+			name: "clang",
+			output: `../../base/foo.cc:10:5: error: use of undeclared identifier 'x'
+   10 |     x = 1;
+      |     ^
+../../base/foo.h:3:1: warning: unused variable 'y' [-Wunused-variable]
+1 error generated.
+`,
+			want: []Diagnostic{
+				{File: "../../base/foo.cc", StartLine: 10, StartCol: 5, EndLine: 10, EndCol: 5, Severity: "error", Message: "use of undeclared identifier 'x'", Tool: "clang"},
+				{File: "../../base/foo.h", StartLine: 3, StartCol: 1, EndLine: 3, EndCol: 1, Severity: "warning", Message: "unused variable 'y' [-Wunused-variable]", Tool: "clang"},
+			},
+		},
+		{
+			name:   "gcc_fatal",
+			output: "foo.c:1:10: fatal error: bar.h: No such file or directory\n",
+			want: []Diagnostic{
+				{File: "foo.c", StartLine: 1, StartCol: 10, EndLine: 1, EndCol: 10, Severity: "error", Message: "bar.h: No such file or directory", Tool: "clang"},
+			},
+		},
+		{
+			name:   "msvc",
+			output: "..\\..\\base\\foo.cc(12,3): error C2065: 'x': undeclared identifier\r\n..\\..\\base\\foo.cc(20): warning C4996: 'strcpy': deprecated\r\n",
+			want: []Diagnostic{
+				{File: `..\..\base\foo.cc`, StartLine: 12, StartCol: 3, EndLine: 12, EndCol: 3, Severity: "error", Code: "C2065", Message: "'x': undeclared identifier", Tool: "msvc"},
+				{File: `..\..\base\foo.cc`, StartLine: 20, EndLine: 20, Severity: "warning", Code: "C4996", Message: "'strcpy': deprecated", Tool: "msvc"},
+			},
+		},
+		{
+			name:   "javac",
+			output: "../../java/Foo.java:42: error: cannot find symbol\n",
+			want: []Diagnostic{
+				{File: "../../java/Foo.java", StartLine: 42, EndLine: 42, Severity: "error", Message: "cannot find symbol", Tool: "javac"},
+			},
+		},
+		{
+			name: "rustc",
+			output: `error[E0308]: mismatched types
+ --> ../../src/main.rs:2:18
+  |
+warning: unused import
+`,
+			want: []Diagnostic{
+				{File: "../../src/main.rs", StartLine: 2, StartCol: 18, EndLine: 2, EndCol: 18, Severity: "error", Code: "E0308", Message: "mismatched types", Tool: "rustc"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseDiagnostics([]byte(tc.output))
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("parseDiagnostics(%q) diff -want +got:\n%s", tc.output, diff)
+			}
+		})
+	}
+}
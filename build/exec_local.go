@@ -34,6 +34,12 @@ func (b *Builder) execLocal(ctx context.Context, step *Step) error {
 		return err
 	}
 	step.cmd.RecordPreOutputs(ctx)
+	// outputs may be hardlinked from local cache.
+	// make them private copies before the command writes them in place.
+	err = b.hashFS.CopyHardlinks(ctx, step.cmd.ExecRoot, step.cmd.AllOutputs())
+	if err != nil {
+		return err
+	}
 
 	stateMessage := "local exec"
 	sema := b.localSema
@@ -99,7 +105,9 @@ func (b *Builder) execLocal(ctx context.Context, step *Step) error {
 		started := time.Now()
 		// local exec might be called as fallback.
 		b.actionStarted(step)
+		b.hungSteps.start(step)
 		err := executor.Run(ctx, step.cmd)
+		b.hungSteps.finish(step)
 		dur = time.Since(started)
 		step.setPhase(stepOutput)
 		if step.cmd.Console {
@@ -222,6 +230,14 @@ func (b *Builder) cacheWrite(ctx context.Context, step *Step) {
 		for _, entry := range outputEntries {
 			ds.Set(entry.Data)
 		}
+		// signature is stored in auxiliary metadata, so the
+		// server needs to accept it, unlike other auxiliary metadata.
+		if b.reCacheSigner != nil {
+			err = b.reCacheSigner.Sign(actionDigest, result)
+			if err != nil {
+				return fmt.Errorf("failed to sign action result: %w", err)
+			}
+		}
 
 		step.setPhase(phase.wait())
 		err = b.cacheSema.Do(ctx, func(ctx context.Context) error {
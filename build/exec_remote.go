@@ -32,13 +32,14 @@ func (b *Builder) execRemote(ctx context.Context, step *Step) error {
 		timeout = step.cmd.Timeout * 4
 	}
 	step.cmd.RecordPreOutputs(ctx)
+	b.applyPlatformEscalation(ctx, step)
 	clog.Infof(ctx, "exec remote %s", step.cmd.Desc)
 	phase := stepRemoteRun
 	if step.metrics.DepsLogErr {
 		phase = stepRetryRun
 	}
 	var reExecDur time.Duration
-	err := retry.Do(ctx, func() error {
+	run := func() error {
 		step.setPhase(phase.wait())
 		err := b.remoteSema.Do(ctx, step.weight, func(ctx context.Context) error {
 			step.setPhase(phase)
@@ -89,7 +90,13 @@ func (b *Builder) execRemote(ctx context.Context, step *Step) error {
 			err = status.Errorf(codes.Unavailable, "reapi timedout %v", err)
 		}
 		return err
-	})
+	}
+	err := retry.Do(ctx, run)
+	for err != nil && b.escalatePlatform(ctx, step, err) {
+		res := cmdOutput(ctx, cmdOutputResultRETRY, step.cmd, step.def.Binding("command"), step.def.RuleName(), err)
+		b.logOutput(res, false)
+		err = retry.Do(ctx, run)
+	}
 	if err != nil {
 		return err
 	}
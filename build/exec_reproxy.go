@@ -54,6 +54,11 @@ func (b *Builder) execReproxy(ctx context.Context, step *Step) error {
 		})
 		clog.Infof(ctx, "step state: remote exec (via reproxy)")
 		maybeDisableLocalFallback(ctx, b, step)
+		if b.reCacheVerifier != nil {
+			// cached results served by reproxy can't be
+			// verified, so skip cache lookup.
+			step.cmd.SkipCacheLookup = true
+		}
 
 		err := b.reproxyExec.Run(ctx, step.cmd)
 		step.setPhase(stepOutput)
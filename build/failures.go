@@ -7,6 +7,18 @@ package build
 import (
 	"context"
 	"errors"
+	"math"
+	"sync/atomic"
+)
+
+// keep going overrides of failures allowed.
+const (
+	// keepGoingDefault uses failures allowed by the option.
+	keepGoingDefault int32 = iota
+	// keepGoingOn keeps going regardless of failures.
+	keepGoingOn
+	// keepGoingOff stops at the next failure.
+	keepGoingOff
 )
 
 // failures manages number of failures.
@@ -14,6 +26,35 @@ type failures struct {
 	allowed  int
 	n        int
 	firstErr error
+
+	// keepGoing overrides allowed at runtime, e.g. toggled by
+	// terminal UI. One of keepGoingDefault, keepGoingOn or keepGoingOff.
+	keepGoing atomic.Int32
+}
+
+// setKeepGoing sets whether to keep going regardless of failures.
+// If false, it uses failures allowed, or stops at the next failure
+// if unlimited failures are allowed (i.e. `-k 0`).
+func (f *failures) setKeepGoing(keepGoing bool) {
+	switch {
+	case keepGoing:
+		f.keepGoing.Store(keepGoingOn)
+	case f.allowed == math.MaxInt:
+		f.keepGoing.Store(keepGoingOff)
+	default:
+		f.keepGoing.Store(keepGoingDefault)
+	}
+}
+
+// isKeepGoing reports whether it keeps going regardless of failures.
+func (f *failures) isKeepGoing() bool {
+	switch f.keepGoing.Load() {
+	case keepGoingOn:
+		return true
+	case keepGoingOff:
+		return false
+	}
+	return f.allowed == math.MaxInt
 }
 
 func (f *failures) shouldFail(err error) bool {
@@ -27,5 +68,11 @@ func (f *failures) shouldFail(err error) bool {
 		f.firstErr = err
 	}
 	f.n++
+	switch f.keepGoing.Load() {
+	case keepGoingOn:
+		return false
+	case keepGoingOff:
+		return true
+	}
 	return f.n >= f.allowed
 }
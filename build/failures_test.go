@@ -0,0 +1,51 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFailuresKeepGoing(t *testing.T) {
+	errFail := errors.New("fail")
+	for _, tc := range []struct {
+		name      string
+		allowed   int
+		keepGoing bool
+		want      []bool
+	}{
+		{
+			name:      "k1_on",
+			allowed:   1,
+			keepGoing: true,
+			want:      []bool{false, false},
+		},
+		{
+			name:    "k2_off",
+			allowed: 2,
+			want:    []bool{false, true},
+		},
+		{
+			name:    "k0_off",
+			allowed: math.MaxInt,
+			want:    []bool{true, true},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &failures{allowed: tc.allowed}
+			f.setKeepGoing(tc.keepGoing)
+			if got := f.isKeepGoing(); got != tc.keepGoing {
+				t.Errorf("isKeepGoing()=%t; want %t", got, tc.keepGoing)
+			}
+			for i, want := range tc.want {
+				if got := f.shouldFail(errFail); got != want {
+					t.Errorf("shouldFail #%d=%t; want %t", i, got, want)
+				}
+			}
+		})
+	}
+}
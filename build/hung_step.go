@@ -0,0 +1,272 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/ui"
+)
+
+// HungStepOptions configures the watchdog of hung local steps.
+type HungStepOptions struct {
+	// Durations are historical run durations of local steps,
+	// keyed by the first output of the step.
+	// Steps without historical duration are not watched.
+	Durations map[string]time.Duration
+
+	// Factor is a multiple of the historical duration to flag
+	// a step as hung. Zero disables the watchdog.
+	Factor float64
+
+	// MinDuration is a minimum run duration to flag a step as hung.
+	MinDuration time.Duration
+
+	// DiagnosticsFile is a filename to write diagnostics of hung steps.
+	DiagnosticsFile string
+
+	// SigQuit sends SIGQUIT to java processes of hung steps,
+	// so they dump threads to their stdout.
+	SigQuit bool
+}
+
+// LoadStepDurations loads historical run durations of local steps from
+// siso_metrics.json files. It uses the longest duration of successful
+// local steps seen in the files. Missing files are ignored.
+func LoadStepDurations(fnames ...string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+	for _, fname := range fnames {
+		err := loadStepDurations(fname, durations)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return durations, err
+		}
+	}
+	return durations, nil
+}
+
+func loadStepDurations(fname string, durations map[string]time.Duration) error {
+	f, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	d := json.NewDecoder(f)
+	for {
+		var m StepMetric
+		err := d.Decode(&m)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("parse error in %s:%d: %w", fname, d.InputOffset(), err)
+		}
+		if m.Output == "" || !m.IsLocal || m.Cached || m.Err {
+			continue
+		}
+		dur := time.Duration(m.RunTime)
+		if dur > durations[m.Output] {
+			durations[m.Output] = dur
+		}
+	}
+}
+
+// maxCapturedHungSteps is the max number of hung steps to capture
+// diagnostics in a build, to bound memory and the diagnostics file.
+const maxCapturedHungSteps = 100
+
+// hungStepWatchdog watches running local steps, and captures
+// diagnostics of steps that run much longer than usual.
+type hungStepWatchdog struct {
+	opts HungStepOptions
+
+	mu      sync.Mutex
+	running map[*Step]*hungStepEntry
+	// captured records steps whose diagnostics were captured.
+	// It is bounded by maxCapturedHungSteps.
+	captured map[*Step]bool
+	// ncaptured is the number of steps whose diagnostics were captured.
+	ncaptured int
+
+	// fileMu protects writes to diagnostics file.
+	fileMu sync.Mutex
+}
+
+type hungStepEntry struct {
+	started   time.Time
+	threshold time.Duration
+	pid       *atomic.Int32
+}
+
+// newHungStepWatchdog returns a watchdog for opts,
+// or nil if watchdog is disabled.
+func newHungStepWatchdog(opts HungStepOptions) *hungStepWatchdog {
+	if opts.Factor <= 0 || len(opts.Durations) == 0 || opts.DiagnosticsFile == "" {
+		return nil
+	}
+	return &hungStepWatchdog{
+		opts:     opts,
+		running:  make(map[*Step]*hungStepEntry),
+		captured: make(map[*Step]bool),
+	}
+}
+
+// threshold returns the run duration to flag the step of output as hung.
+func (w *hungStepWatchdog) threshold(output string) (time.Duration, bool) {
+	dur, ok := w.opts.Durations[output]
+	if !ok || dur <= 0 {
+		return 0, false
+	}
+	threshold := time.Duration(float64(dur) * w.opts.Factor)
+	return max(threshold, w.opts.MinDuration), true
+}
+
+// start starts watching the step, which is about to run locally.
+func (w *hungStepWatchdog) start(step *Step) {
+	if w == nil {
+		return
+	}
+	threshold, ok := w.threshold(step.metrics.Output)
+	if !ok {
+		return
+	}
+	if step.cmd.Pid == nil {
+		step.cmd.Pid = new(atomic.Int32)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[step] = &hungStepEntry{
+		started:   time.Now(),
+		threshold: threshold,
+		pid:       step.cmd.Pid,
+	}
+}
+
+// finish stops watching the step.
+func (w *hungStepWatchdog) finish(step *Step) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.running, step)
+}
+
+type hungStep struct {
+	step *Step
+	ent  *hungStepEntry
+}
+
+// check returns steps that are hung at now, and not captured yet.
+// It returns no steps once maxCapturedHungSteps steps were captured.
+func (w *hungStepWatchdog) check(now time.Time) []hungStep {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var steps []hungStep
+	for step, ent := range w.running {
+		if w.ncaptured >= maxCapturedHungSteps {
+			break
+		}
+		if w.captured[step] || now.Sub(ent.started) < ent.threshold {
+			continue
+		}
+		if ent.pid.Load() == 0 {
+			// not started yet, or already finished.
+			continue
+		}
+		w.captured[step] = true
+		w.ncaptured++
+		steps = append(steps, hungStep{step: step, ent: ent})
+	}
+	return steps
+}
+
+// diagnosticsFile returns diagnostics filename if the step's
+// diagnostics were captured, and forgets the step.
+func (w *hungStepWatchdog) diagnosticsFile(step *Step) string {
+	if w == nil {
+		return ""
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.captured[step] {
+		return ""
+	}
+	delete(w.captured, step)
+	return w.opts.DiagnosticsFile
+}
+
+// run checks running steps periodically until ctx is done.
+func (w *hungStepWatchdog) run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, h := range w.check(now) {
+				w.capture(ctx, h.step, h.ent)
+			}
+		}
+	}
+}
+
+// capture captures diagnostics of the hung step into the diagnostics file,
+// and warns to the UI.
+func (w *hungStepWatchdog) capture(ctx context.Context, step *Step, ent *hungStepEntry) {
+	pid := int(ent.pid.Load())
+	elapsed := time.Since(ent.started)
+	usual := w.opts.Durations[step.metrics.Output]
+	clog.Warningf(ctx, "hung step %s pid=%d running %s (usual %s)", step, pid, elapsed, usual)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "=== %s %s\n", time.Now().Format(time.RFC3339), step.cmd.Desc)
+	fmt.Fprintf(&buf, "step_id: %s\n", step)
+	fmt.Fprintf(&buf, "output: %s\n", step.metrics.Output)
+	fmt.Fprintf(&buf, "running: %s (usual %s, threshold %s)\n", elapsed.Round(time.Second), usual.Round(time.Second), ent.threshold.Round(time.Second))
+	fmt.Fprintf(&buf, "command: %s\n", strings.Join(step.cmd.Args, " "))
+	fmt.Fprintf(&buf, "pid: %d\n\n", pid)
+	captureProcessDiagnostics(ctx, &buf, pid, w.opts.SigQuit)
+	fmt.Fprintln(&buf)
+
+	err := w.write(buf.Bytes())
+	if err != nil {
+		clog.Warningf(ctx, "failed to write hung step diagnostics: %v", err)
+	}
+	ui.Default.PrintLines(ui.SGR(ui.Yellow, fmt.Sprintf("WARNING: step may hang, running %s (usual %s): %s\ndiagnostics in %s\n\n", ui.FormatDuration(elapsed), ui.FormatDuration(usual), step.cmd.Desc, w.opts.DiagnosticsFile)))
+}
+
+func (w *hungStepWatchdog) write(buf []byte) error {
+	w.fileMu.Lock()
+	defer w.fileMu.Unlock()
+	f, err := os.OpenFile(w.opts.DiagnosticsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	cerr := f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
@@ -0,0 +1,145 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build linux
+
+package build
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.chromium.org/build/siso/o11y/clog"
+)
+
+// captureProcessDiagnostics writes diagnostics of the process tree
+// rooted at pid, read from /proc.
+func captureProcessDiagnostics(ctx context.Context, w io.Writer, pid int, sigquit bool) {
+	if pid <= 0 {
+		fmt.Fprintf(w, "process not running\n")
+		return
+	}
+	children := procChildren()
+	pids := []int{pid}
+	for i := 0; i < len(pids); i++ {
+		pids = append(pids, children[pids[i]]...)
+	}
+	fmt.Fprintf(w, "process tree:\n")
+	for _, p := range pids {
+		fmt.Fprintf(w, "  %d ppid=%d state=%s %s\n", p, procPPid(p), procState(p), procCmdline(p))
+	}
+	for _, p := range pids {
+		dir := filepath.Join("/proc", strconv.Itoa(p))
+		fmt.Fprintf(w, "\n--- pid %d: %s\n", p, procCmdline(p))
+		fmt.Fprintf(w, "wchan: %s\n", procRead(filepath.Join(dir, "wchan")))
+		fmt.Fprintf(w, "stack:\n%s\n", procRead(filepath.Join(dir, "stack")))
+		fmt.Fprintf(w, "fds:\n")
+		fdDir := filepath.Join(dir, "fd")
+		ents, err := os.ReadDir(fdDir)
+		if err != nil {
+			fmt.Fprintf(w, "  <%v>\n", err)
+		}
+		for _, ent := range ents {
+			target, err := os.Readlink(filepath.Join(fdDir, ent.Name()))
+			if err != nil {
+				target = fmt.Sprintf("<%v>", err)
+			}
+			fmt.Fprintf(w, "  %s -> %s\n", ent.Name(), target)
+		}
+		if sigquit && isJava(p) {
+			// JVM dumps threads to its stdout, which will be
+			// in the step's output.
+			err := syscall.Kill(p, syscall.SIGQUIT)
+			clog.Infof(ctx, "send SIGQUIT to java pid=%d: %v", p, err)
+			fmt.Fprintf(w, "sent SIGQUIT to dump java threads in stdout: %v\n", err)
+		}
+	}
+}
+
+// procChildren returns a map of ppid to its child pids.
+func procChildren() map[int][]int {
+	children := make(map[int][]int)
+	ents, err := os.ReadDir("/proc")
+	if err != nil {
+		return children
+	}
+	for _, ent := range ents {
+		p, err := strconv.Atoi(ent.Name())
+		if err != nil {
+			continue
+		}
+		ppid := procPPid(p)
+		if ppid <= 0 {
+			continue
+		}
+		children[ppid] = append(children[ppid], p)
+	}
+	for _, c := range children {
+		slices.Sort(c)
+	}
+	return children
+}
+
+// procStat returns fields of /proc/<pid>/stat after the command name.
+func procStat(pid int) []string {
+	buf, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil
+	}
+	// command name is in parens and may contain spaces.
+	i := bytes.LastIndexByte(buf, ')')
+	if i < 0 {
+		return nil
+	}
+	return strings.Fields(string(buf[i+1:]))
+}
+
+func procPPid(pid int) int {
+	fields := procStat(pid)
+	if len(fields) < 2 {
+		return -1
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return -1
+	}
+	return ppid
+}
+
+func procState(pid int) string {
+	fields := procStat(pid)
+	if len(fields) < 1 {
+		return "?"
+	}
+	return fields[0]
+}
+
+func procCmdline(pid int) string {
+	buf, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.TrimSpace(string(bytes.ReplaceAll(buf, []byte{0}, []byte{' '})))
+}
+
+func procRead(fname string) string {
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	return strings.TrimRight(string(buf), "\n")
+}
+
+func isJava(pid int) bool {
+	comm := procRead(filepath.Join("/proc", strconv.Itoa(pid), "comm"))
+	return comm == "java"
+}
@@ -0,0 +1,19 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build !linux
+
+package build
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// captureProcessDiagnostics is not supported other than linux.
+func captureProcessDiagnostics(ctx context.Context, w io.Writer, pid int, sigquit bool) {
+	fmt.Fprintf(w, "process diagnostics are not supported on %s\n", runtime.GOOS)
+}
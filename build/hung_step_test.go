@@ -0,0 +1,151 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/build/siso/execute"
+)
+
+func TestLoadStepDurations(t *testing.T) {
+	dir := t.TempDir()
+	for fname, content := range map[string]string{
+		"siso_metrics.0.json": `{"output":"obj/foo.o","is_local":true,"run":1.50}
+{"output":"obj/bar.o","is_local":true,"cached":true,"run":10.00}
+{"output":"obj/baz.o","is_remote":true,"run":20.00}
+{"output":"obj/err.o","is_local":true,"err":true,"run":30.00}
+{"build_id":"b","duration":100.00}
+`,
+		"siso_metrics.1.json": `{"output":"obj/foo.o","is_local":true,"run":3.00}
+{"output":"obj/bar.o","is_local":true,"run":2.00}
+`,
+	} {
+		err := os.WriteFile(filepath.Join(dir, fname), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := LoadStepDurations(
+		filepath.Join(dir, "siso_metrics.0.json"),
+		filepath.Join(dir, "siso_metrics.1.json"),
+		filepath.Join(dir, "siso_metrics.2.json"))
+	if err != nil {
+		t.Fatalf("LoadStepDurations=%v; want nil err", err)
+	}
+	want := map[string]time.Duration{
+		"obj/foo.o": 3 * time.Second,
+		"obj/bar.o": 2 * time.Second,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("LoadStepDurations diff -want +got:\n%s", diff)
+	}
+}
+
+func TestHungStepWatchdog(t *testing.T) {
+	if newHungStepWatchdog(HungStepOptions{Durations: map[string]time.Duration{"foo": time.Second}, DiagnosticsFile: "x"}) != nil {
+		t.Errorf("newHungStepWatchdog(Factor=0)=non-nil; want nil")
+	}
+	fname := filepath.Join(t.TempDir(), "siso_hung_steps.txt")
+	w := newHungStepWatchdog(HungStepOptions{
+		Durations: map[string]time.Duration{
+			"obj/fast.o": time.Second,
+			"obj/slow.o": time.Minute,
+		},
+		Factor:          10,
+		MinDuration:     time.Minute,
+		DiagnosticsFile: fname,
+	})
+	newStep := func(output string) *Step {
+		return &Step{
+			def:     fakeStepDef{},
+			cmd:     &execute.Cmd{Desc: "CXX " + output, Args: []string{"clang++", "-c", output}},
+			metrics: StepMetric{Output: output},
+		}
+	}
+	fast := newStep("obj/fast.o")
+	slow := newStep("obj/slow.o")
+	unknown := newStep("obj/unknown.o")
+	for _, s := range []*Step{fast, slow, unknown} {
+		w.start(s)
+	}
+	if unknown.cmd.Pid != nil {
+		t.Errorf("unknown step is watched; want not watched")
+	}
+	fast.cmd.Pid.Store(int32(os.Getpid()))
+	slow.cmd.Pid.Store(int32(os.Getpid()))
+
+	now := time.Now()
+	if got := w.check(now.Add(30 * time.Second)); len(got) != 0 {
+		t.Errorf("check(+30s)=%d steps; want 0 for min duration", len(got))
+	}
+	got := w.check(now.Add(2 * time.Minute))
+	if len(got) != 1 || got[0].step != fast {
+		t.Fatalf("check(+2m)=%v; want fast step", got)
+	}
+	if got := w.check(now.Add(3 * time.Minute)); len(got) != 0 {
+		t.Errorf("check(+3m)=%d steps; want 0 for captured step", len(got))
+	}
+	w.capture(t.Context(), got[0].step, got[0].ent)
+	w.finish(fast)
+	if got := w.diagnosticsFile(fast); got != fname {
+		t.Errorf("diagnosticsFile(fast)=%q; want %q", got, fname)
+	}
+	if got := w.diagnosticsFile(fast); got != "" {
+		t.Errorf("diagnosticsFile(fast) again=%q; want empty", got)
+	}
+	if got := w.diagnosticsFile(slow); got != "" {
+		t.Errorf("diagnosticsFile(slow)=%q; want empty", got)
+	}
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"CXX obj/fast.o", "output: obj/fast.o", "command: clang++ -c obj/fast.o"} {
+		if !strings.Contains(string(buf), want) {
+			t.Errorf("diagnostics doesn't contain %q:\n%s", want, buf)
+		}
+	}
+	if runtime.GOOS == "linux" && !strings.Contains(string(buf), "process tree:") {
+		t.Errorf("diagnostics doesn't contain process tree:\n%s", buf)
+	}
+	if got := w.check(now.Add(time.Hour)); len(got) != 1 || got[0].step != slow {
+		t.Errorf("check(+1h)=%v; want slow step", got)
+	}
+}
+
+func TestHungStepWatchdog_MaxCaptured(t *testing.T) {
+	w := newHungStepWatchdog(HungStepOptions{
+		Durations:       map[string]time.Duration{"obj/foo.o": time.Second},
+		Factor:          10,
+		DiagnosticsFile: filepath.Join(t.TempDir(), "siso_hung_steps.txt"),
+	})
+	for range maxCapturedHungSteps + 10 {
+		step := &Step{
+			def:     fakeStepDef{},
+			cmd:     &execute.Cmd{Desc: "CXX obj/foo.o"},
+			metrics: StepMetric{Output: "obj/foo.o"},
+		}
+		w.start(step)
+		step.cmd.Pid.Store(int32(os.Getpid()))
+	}
+	got := w.check(time.Now().Add(time.Hour))
+	if len(got) != maxCapturedHungSteps {
+		t.Errorf("check=%d steps; want %d", len(got), maxCapturedHungSteps)
+	}
+	if got := w.check(time.Now().Add(time.Hour)); len(got) != 0 {
+		t.Errorf("check again=%d steps; want 0", len(got))
+	}
+	if len(w.captured) != maxCapturedHungSteps {
+		t.Errorf("len(captured)=%d; want %d", len(w.captured), maxCapturedHungSteps)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.chromium.org/build/siso/ui"
+)
+
+// JUnitReport collects executed steps as JUnit XML test cases,
+// so CI systems can render build step failures natively.
+// It may be shared by several builds (e.g. manifest regeneration
+// and the main build).
+type JUnitReport struct {
+	// compact reports only failed steps.
+	compact bool
+
+	mu      sync.Mutex
+	started time.Time
+	cases   []junitTestCase
+}
+
+// NewJUnitReport creates new JUnit report.
+// If compact is true, it reports only failed steps.
+func NewJUnitReport(compact bool) *JUnitReport {
+	return &JUnitReport{
+		compact: compact,
+		started: time.Now(),
+	}
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// stepStatus returns how the step was executed.
+func stepStatus(m *StepMetric) string {
+	switch {
+	case m.NoExec:
+		return "noexec"
+	case m.Cached:
+		return "cached"
+	case m.IsRemote:
+		return "remote"
+	case m.IsLocal:
+		return "local"
+	}
+	return "unknown"
+}
+
+// recordJUnit records the executed step in the JUnit report.
+func (b *Builder) recordJUnit(ctx context.Context, step *Step, err error) {
+	r := b.junitReport
+	if r == nil || step.cmd == nil {
+		return
+	}
+	if r.compact && err == nil {
+		return
+	}
+	m := &step.metrics
+	tc := junitTestCase{
+		Name:      m.Output,
+		Classname: step.def.ActionName(),
+		Time:      junitSeconds(time.Duration(m.Duration)),
+		Properties: []junitProperty{
+			{Name: "rule", Value: step.def.ActionName()},
+			{Name: "siso_rule", Value: step.def.RuleName()},
+			{Name: "status", Value: stepStatus(m)},
+		},
+	}
+	if err != nil {
+		tc.Failure = &junitFailure{
+			Message: err.Error(),
+			Type:    cmdOutputResultFAILED.String(),
+		}
+		res := cmdOutput(ctx, cmdOutputResultFAILED, step.cmd, step.def.Binding("command"), step.def.RuleName(), err)
+		if res != nil {
+			tc.Failure.Text = ui.StripANSIEscapeCodes(res.String())
+		}
+	}
+	r.mu.Lock()
+	r.cases = append(r.cases, tc)
+	r.mu.Unlock()
+}
+
+// WriteXML writes the report in JUnit XML format to w.
+func (r *JUnitReport) WriteXML(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	duration := junitSeconds(time.Since(r.started))
+	suite := junitTestSuite{
+		Name:      "siso",
+		Tests:     len(r.cases),
+		Time:      duration,
+		Timestamp: r.started.Format(time.RFC3339),
+		Cases:     r.cases,
+	}
+	for _, tc := range r.cases {
+		if tc.Failure != nil {
+			suite.Failures++
+		}
+	}
+	suites := junitTestSuites{
+		Name:     "siso",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     duration,
+		Suites:   []junitTestSuite{suite},
+	}
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	err = enc.Encode(suites)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -19,6 +19,7 @@ import (
 	"go.chromium.org/build/siso/build/cachestore"
 	"go.chromium.org/build/siso/o11y/clog"
 	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/signature"
 )
 
 // LayeredCache is a multi-layer cache. It will attempt to read from caches in
@@ -28,6 +29,9 @@ import (
 type LayeredCache struct {
 	// The caches here are ordered by priority (higher priority first).
 	caches []cachestore.CacheStore
+
+	// verifier verifies action results read from caches, if set.
+	verifier *signature.Verifier
 }
 
 func NewLayeredCache() *LayeredCache {
@@ -40,17 +44,32 @@ func (lc *LayeredCache) AddLayer(cache cachestore.CacheStore) {
 	lc.caches = append(lc.caches, cache)
 }
 
+// SetVerifier sets verifier of action results.
+// Action results that are not signed by trusted keys are treated
+// as cache misses, and not written to faster caches.
+func (lc *LayeredCache) SetVerifier(v *signature.Verifier) {
+	lc.verifier = v
+}
+
 func isNotExist(err error) bool {
 	return errors.Is(err, fs.ErrNotExist) || status.Code(err) == codes.NotFound
 }
 
 // GetActionResult gets the action result of the action identified by the digest.
 func (lc *LayeredCache) GetActionResult(ctx context.Context, d digest.Digest) (ar *rpb.ActionResult, err error) {
+	var verr error
 	for i, cache := range lc.caches {
 		ar, err = cache.GetActionResult(ctx, d)
 		if isNotExist(err) {
 			continue
 		}
+		if err == nil {
+			if err := lc.verifier.Verify(d, ar); err != nil {
+				clog.Warningf(ctx, "unverifiable action result %s in cache layer %d: %v", d, i, err)
+				verr = err
+				continue
+			}
+		}
 		// Don't cache failure results, as RBE won't cache such a result.
 		if err != nil || ar.ExitCode != 0 {
 			return ar, err
@@ -69,6 +88,9 @@ func (lc *LayeredCache) GetActionResult(ctx context.Context, d digest.Digest) (a
 		}
 		return ar, err
 	}
+	if verr != nil {
+		return nil, status.Errorf(codes.NotFound, "unverifiable action result %v: %v", d, verr)
+	}
 	return nil, fmt.Errorf("no caches to retrieve action cache %v from", d)
 }
 
@@ -212,6 +234,17 @@ func (s layeredSource) Open(ctx context.Context) (io.ReadCloser, error) {
 	return &teeCloser{r: tr, rc: r, wc: w}, nil
 }
 
+// LinkableFile returns the filename of the raw content in
+// the local cache layer, if available.
+func (s layeredSource) LinkableFile(ctx context.Context) (string, bool) {
+	for _, cache := range s.lc.caches {
+		if lc, ok := cache.(*LocalCache); ok {
+			return lc.linkableFile(ctx, s.d)
+		}
+	}
+	return "", false
+}
+
 func (s layeredSource) String() string {
 	return fmt.Sprintf("cache %s for %s", s.d, s.f)
 }
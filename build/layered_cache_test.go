@@ -6,14 +6,18 @@ package build
 
 import (
 	"context"
+	"crypto/ed25519"
 	"io"
 	"testing"
 
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
 	"go.chromium.org/build/siso/build/cachestore"
 	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/signature"
 )
 
 const FirstOnly = "firstonly"
@@ -162,3 +166,66 @@ func TestWriteThroughCache(t *testing.T) {
 		t.Errorf("first.GetActionResult(%v) = %v, want %v", ActionResult, ar, wantProto)
 	}
 }
+
+func TestLayeredCacheVerifier(t *testing.T) {
+	ctx := t.Context()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewLayeredCache()
+	cache.SetVerifier(signature.NewVerifier(pub))
+
+	first, err := NewLocalCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.AddLayer(first)
+	second, err := NewLocalCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.AddLayer(second)
+
+	d := makeDigest(ActionResult)
+	unsigned := &rpb.ActionResult{
+		StdoutRaw:   []byte("poisoned"),
+		OutputFiles: []*rpb.OutputFile{{Path: "foo"}},
+	}
+	signed := &rpb.ActionResult{
+		StdoutRaw:   []byte("a"),
+		OutputFiles: []*rpb.OutputFile{{Path: "foo"}},
+	}
+	if err := signature.NewSigner(priv).Sign(d, signed); err != nil {
+		t.Fatal(err)
+	}
+
+	// unsigned result is cache miss.
+	if err := first.SetActionResult(ctx, d, unsigned); err != nil {
+		t.Fatal(err)
+	}
+	_, err = cache.GetActionResult(ctx, d)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("cache.GetActionResult(%v)=%v; want NotFound", ActionResult, err)
+	}
+
+	// signed result in the second layer is used, and overwrites
+	// the unsigned result in the first layer.
+	if err := second.SetActionResult(ctx, d, signed); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := cache.GetActionResult(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(ar, signed) {
+		t.Errorf("cache.GetActionResult(%v) = %v, want %v", ActionResult, ar, signed)
+	}
+	ar, err = first.GetActionResult(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(ar, signed) {
+		t.Errorf("first.GetActionResult(%v) = %v, want %v", ActionResult, ar, signed)
+	}
+}
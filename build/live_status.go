@@ -0,0 +1,218 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.chromium.org/build/siso/ui"
+)
+
+const (
+	// maxLiveSteps is max number of completed steps kept for live status.
+	maxLiveSteps = 1000
+
+	// maxSlowestSteps is max number of the slowest steps kept for live status.
+	maxSlowestSteps = 20
+)
+
+// LiveStatus is a snapshot of the running build, served by statusz
+// server for live build view. e.g. web UI.
+type LiveStatus struct {
+	// BuildID is the id of the build.
+	// Completed steps' Seq is valid only in the same build.
+	BuildID string
+	Elapsed string
+	Stats   Stats
+
+	ActiveSteps []ActiveStepInfo
+	Semaphores  []SemaphoreInfo
+
+	// Completed is completed steps whose Seq is greater than
+	// requested since, up to maxLiveSteps.
+	Completed []CompletedStepInfo
+	// Failures is failed steps in the build.
+	// It may be a part of failed steps for LiveSummary.
+	Failures []CompletedStepInfo
+	// NumFailures is the number of failed steps in the build.
+	NumFailures int
+	// FailuresStart is the index of Failures[0] in failed steps.
+	FailuresStart int
+	// Slowest is the slowest completed steps in the build.
+	Slowest []CompletedStepInfo
+	// Seq is the last seq of completed steps.
+	Seq int64
+}
+
+// CompletedStepInfo is information about completed step.
+type CompletedStepInfo struct {
+	Seq    int64
+	ID     string
+	Desc   string
+	Output string
+	// Status is how the step's result was produced.
+	// "cache", "remote", "local", "fallback", "noexec" or "".
+	Status string
+	Err    bool
+	Dur    string
+	// Duration is step duration for sorting.
+	Duration time.Duration
+	// Result is the output of the failed step.
+	Result string
+}
+
+// SemaphoreInfo is utilization of a semaphore.
+type SemaphoreInfo struct {
+	Name     string
+	Capacity int
+	Serv     int
+	Wait     int
+}
+
+// liveSteps keeps recently completed steps for live status.
+type liveSteps struct {
+	mu       sync.Mutex
+	seq      int64
+	steps    []CompletedStepInfo
+	failures []CompletedStepInfo
+	slowest  []CompletedStepInfo
+}
+
+func newCompletedStepInfo(step *Step, dur time.Duration, err error) CompletedStepInfo {
+	info := CompletedStepInfo{
+		ID:       step.String(),
+		Desc:     step.cmd.Desc,
+		Output:   step.metrics.Output,
+		Status:   stepResultStatus(&step.metrics),
+		Err:      err != nil,
+		Dur:      ui.FormatDuration(dur),
+		Duration: dur,
+	}
+	if err != nil {
+		info.Result = step.cmd.OutputResult()
+	}
+	return info
+}
+
+// add adds completed step info, and assigns seq to it.
+func (l *liveSteps) add(info CompletedStepInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.seq++
+	info.Seq = l.seq
+	if len(l.steps) >= maxLiveSteps {
+		l.steps = append(l.steps[:0], l.steps[len(l.steps)-maxLiveSteps+1:]...)
+	}
+	l.steps = append(l.steps, info)
+	if info.Err && len(l.failures) < maxLiveSteps {
+		l.failures = append(l.failures, info)
+	}
+	i := sort.Search(len(l.slowest), func(i int) bool {
+		return l.slowest[i].Duration < info.Duration
+	})
+	if i < maxSlowestSteps {
+		l.slowest = slices.Insert(l.slowest, i, info)
+		if len(l.slowest) > maxSlowestSteps {
+			l.slowest = l.slowest[:maxSlowestSteps]
+		}
+	}
+}
+
+// since returns completed steps after seq, failures, the slowest steps and
+// the last seq.
+func (l *liveSteps) since(seq int64) (steps, failures, slowest []CompletedStepInfo, last int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, s := range l.steps {
+		if s.Seq > seq {
+			steps = append(steps, l.steps[i:]...)
+			break
+		}
+	}
+	failures = append(failures, l.failures...)
+	slowest = append(slowest, l.slowest...)
+	return steps, failures, slowest, l.seq
+}
+
+// summary returns at most n failures from start, the index of the
+// first returned failure, the number of failures and the slowest steps.
+// start is adjusted so it returns n failures if possible.
+func (l *liveSteps) summary(start, n int) (failures []CompletedStepInfo, fstart, nfailures int, slowest []CompletedStepInfo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	nfailures = len(l.failures)
+	fstart = max(min(start, nfailures-n), 0)
+	failures = append(failures, l.failures[fstart:min(fstart+n, nfailures)]...)
+	slowest = append(slowest, l.slowest...)
+	return failures, fstart, nfailures, slowest
+}
+
+func stepResultStatus(m *StepMetric) string {
+	switch {
+	case m.Cached:
+		return "cache"
+	case m.Fallback:
+		return "fallback"
+	case m.IsRemote:
+		return "remote"
+	case m.IsLocal:
+		return "local"
+	case m.NoExec:
+		return "noexec"
+	}
+	return ""
+}
+
+// LiveStatus returns live status of the build.
+// It returns completed steps whose seq is greater than since.
+func (b *Builder) LiveStatus(since int64) LiveStatus {
+	completed, failures, slowest, seq := b.live.since(since)
+	st := LiveStatus{
+		BuildID:     b.id,
+		Elapsed:     ui.FormatDuration(time.Since(b.start)),
+		Stats:       b.stats.stats(),
+		ActiveSteps: b.ActiveSteps(),
+		Completed:   completed,
+		Failures:    failures,
+		NumFailures: len(failures),
+		Slowest:     slowest,
+		Seq:         seq,
+	}
+	b.addSemaphores(&st)
+	return st
+}
+
+// LiveSummary returns live status of the build without completed steps,
+// and with at most n failures from start, for terminal UI.
+func (b *Builder) LiveSummary(start, n int) LiveStatus {
+	failures, fstart, nfailures, slowest := b.live.summary(start, n)
+	st := LiveStatus{
+		BuildID:       b.id,
+		Elapsed:       ui.FormatDuration(time.Since(b.start)),
+		Stats:         b.stats.stats(),
+		ActiveSteps:   b.ActiveSteps(),
+		Failures:      failures,
+		NumFailures:   nfailures,
+		FailuresStart: fstart,
+		Slowest:       slowest,
+	}
+	b.addSemaphores(&st)
+	return st
+}
+
+// addSemaphores adds utilization of semaphores to st.
+func (b *Builder) addSemaphores(st *LiveStatus) {
+	for _, s := range b.monitorSemas() {
+		st.Semaphores = append(st.Semaphores, SemaphoreInfo{
+			Name:     s.Name(),
+			Capacity: s.Capacity(),
+			Serv:     s.NumServs(),
+			Wait:     s.NumWaits(),
+		})
+	}
+}
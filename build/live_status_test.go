@@ -0,0 +1,74 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLiveSteps(t *testing.T) {
+	var l liveSteps
+	for i := range maxLiveSteps + 10 {
+		l.add(CompletedStepInfo{
+			Output:   fmt.Sprintf("out%d", i),
+			Err:      i%500 == 0,
+			Duration: time.Duration(i%100) * time.Second,
+		})
+	}
+	steps, failures, slowest, seq := l.since(0)
+	if seq != maxLiveSteps+10 {
+		t.Errorf("seq=%d; want %d", seq, maxLiveSteps+10)
+	}
+	if len(steps) != maxLiveSteps || steps[0].Seq != 11 || steps[len(steps)-1].Seq != seq {
+		t.Errorf("since(0)=%d steps [%d..]; want %d steps [11..%d]", len(steps), steps[0].Seq, maxLiveSteps, seq)
+	}
+	if len(failures) != 3 || failures[1].Output != "out500" {
+		t.Errorf("failures=%v; want out0, out500, out1000", failures)
+	}
+
+	if len(slowest) != maxSlowestSteps || slowest[0].Duration != 99*time.Second || slowest[len(slowest)-1].Duration != 98*time.Second {
+		t.Errorf("slowest=%d steps; want %d steps 99s..98s", len(slowest), maxSlowestSteps)
+	}
+
+	steps, _, _, _ = l.since(seq - 2)
+	if len(steps) != 2 || steps[0].Seq != seq-1 {
+		t.Errorf("since(%d)=%v; want 2 steps from %d", seq-2, steps, seq-1)
+	}
+	steps, _, _, _ = l.since(seq)
+	if len(steps) != 0 {
+		t.Errorf("since(%d)=%v; want no steps", seq, steps)
+	}
+}
+
+func TestLiveStepsSummary(t *testing.T) {
+	var l liveSteps
+	for i := range 10 {
+		l.add(CompletedStepInfo{
+			Output: fmt.Sprintf("out%d", i),
+			Err:    true,
+		})
+	}
+	for _, tc := range []struct {
+		start, n  int
+		wantStart int
+		wantLen   int
+	}{
+		{start: 0, n: 5, wantStart: 0, wantLen: 5},
+		{start: 3, n: 5, wantStart: 3, wantLen: 5},
+		{start: 8, n: 5, wantStart: 5, wantLen: 5},
+		{start: 0, n: 20, wantStart: 0, wantLen: 10},
+	} {
+		failures, fstart, nfailures, _ := l.summary(tc.start, tc.n)
+		if fstart != tc.wantStart || len(failures) != tc.wantLen || nfailures != 10 {
+			t.Errorf("summary(%d, %d)=%d failures from %d of %d; want %d failures from %d of 10", tc.start, tc.n, len(failures), fstart, nfailures, tc.wantLen, tc.wantStart)
+			continue
+		}
+		if want := fmt.Sprintf("out%d", fstart); failures[0].Output != want {
+			t.Errorf("summary(%d, %d) failures[0]=%q; want %q", tc.start, tc.n, failures[0].Output, want)
+		}
+	}
+}
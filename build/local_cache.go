@@ -11,9 +11,12 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
@@ -22,6 +25,7 @@ import (
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/proto"
 
+	"go.chromium.org/build/siso/hashfs"
 	"go.chromium.org/build/siso/o11y/clog"
 	"go.chromium.org/build/siso/o11y/iometrics"
 	"go.chromium.org/build/siso/o11y/trace"
@@ -33,6 +37,12 @@ import (
 type LocalCache struct {
 	dir string
 
+	// uncompressed stores contents uncompressed in "contents-raw".
+	uncompressed bool
+	// verified records size and mtime of raw content files
+	// whose digests were verified. cname -> rawContentStat.
+	verified sync.Map
+
 	singleflight singleflight.Group
 	m            *iometrics.IOMetrics
 	timestamp    time.Time
@@ -43,14 +53,30 @@ type LocalCache struct {
 // collection, which may not be for TTL.
 const localCacheTTL = 7 * 24 * time.Hour
 
+// LocalCacheOptions is options for local cache.
+type LocalCacheOptions struct {
+	// Uncompressed uses uncompressed content store layout,
+	// so cache-hit outputs can be materialized by reflink
+	// or hardlink, rather than writing a fresh copy.
+	// Contents are stored read-only to protect from editing
+	// hardlinked outputs in place.
+	Uncompressed bool
+}
+
 // NewLocalCache returns new local cache.
 func NewLocalCache(dir string) (*LocalCache, error) {
+	return NewLocalCacheWithOptions(dir, LocalCacheOptions{})
+}
+
+// NewLocalCacheWithOptions returns new local cache with the options.
+func NewLocalCacheWithOptions(dir string, opts LocalCacheOptions) (*LocalCache, error) {
 	if dir == "" {
 		return nil, errors.New("local cache is not configured")
 	}
 	return &LocalCache{
-		dir: dir,
-		m:   iometrics.New("local-cache"),
+		dir:          dir,
+		uncompressed: opts.Uncompressed,
+		m:            iometrics.New("local-cache"),
 		// Use the same timestamp throughout the build. Makes things simpler.
 		timestamp: time.Now(),
 	}, nil
@@ -70,10 +96,88 @@ func (c *LocalCache) actionCacheFilename(d digest.Digest) string {
 }
 
 func (c *LocalCache) contentCacheFilename(d digest.Digest) string {
+	if c.uncompressed {
+		name := fmt.Sprintf("%s-%d", d.Hash, d.SizeBytes)
+		return filepath.Join(c.dir, "contents-raw", name[:2], name[2:])
+	}
 	name := fmt.Sprintf("%s-%d.gz", d.Hash, d.SizeBytes)
 	return filepath.Join(c.dir, "contents", name[:2], name[2:])
 }
 
+// touch updates mtime of the cache file for garbage collection.
+// It doesn't update mtime of hardlinked file, as it would change
+// mtime of outputs linked to the file.
+func (c *LocalCache) touch(ctx context.Context, cname string) {
+	if fi, err := os.Lstat(cname); err == nil && hashfs.IsHardlink(fi) {
+		return
+	}
+	if err := os.Chtimes(cname, c.timestamp, c.timestamp); err != nil {
+		clog.Warningf(ctx, "Failed to update mtime for %s: %v", cname, err)
+	}
+}
+
+// rawContentStat is size and mtime of raw content file.
+type rawContentStat struct {
+	size  int64
+	mtime time.Time
+}
+
+// markVerified records raw content file of fi is verified.
+func (c *LocalCache) markVerified(cname string, fi fs.FileInfo) {
+	c.verified.Store(cname, rawContentStat{size: fi.Size(), mtime: fi.ModTime()})
+}
+
+// checkRawContent checks raw content file cname has the digest d.
+// Raw content file may be hardlinked to outputs, and
+// user may edit it in place (even if it is read-only),
+// so it verifies digest when it has hardlinks, unless its
+// size and mtime are the same as when it was stored or verified.
+// Corrupted content file is removed.
+func (c *LocalCache) checkRawContent(ctx context.Context, cname string, d digest.Digest) error {
+	fi, err := os.Lstat(cname)
+	c.m.OpsDone(err)
+	if err != nil {
+		return err
+	}
+	if fi.Size() == d.SizeBytes && !hashfs.IsHardlink(fi) {
+		return nil
+	}
+	if fi.Size() == d.SizeBytes {
+		if v, ok := c.verified.Load(cname); ok && v.(rawContentStat) == (rawContentStat{size: fi.Size(), mtime: fi.ModTime()}) {
+			return nil
+		}
+		data, err := digest.FromLocalFile(ctx, rawContentSource{cname: cname})
+		if err != nil {
+			return err
+		}
+		if data.Digest() == d {
+			c.markVerified(cname, fi)
+			return nil
+		}
+	}
+	c.verified.Delete(cname)
+	clog.Warningf(ctx, "corrupted cache content %s. remove", cname)
+	err = os.Remove(cname)
+	c.m.OpsDone(err)
+	return fmt.Errorf("corrupted cache content %s: %w", cname, fs.ErrNotExist)
+}
+
+// rawContentSource is a digest source of raw content file.
+type rawContentSource struct {
+	cname string
+}
+
+// IsLocal indicates rawContentSource is local file source.
+func (rawContentSource) IsLocal() {}
+
+func (s rawContentSource) Open(context.Context) (io.ReadCloser, error) {
+	return os.Open(s.cname)
+}
+
+func (s rawContentSource) String() string {
+	return s.cname
+}
+
 // GetActionResult gets the action result of the action identified by the digest.
 func (c *LocalCache) GetActionResult(ctx context.Context, d digest.Digest) (*rpb.ActionResult, error) {
 	if c == nil {
@@ -100,6 +204,39 @@ func (c *LocalCache) GetActionResult(ctx context.Context, d digest.Digest) (*rpb
 	return result, nil
 }
 
+// ActionDigests returns digests of actions that have action results
+// in the local cache.
+func (c *LocalCache) ActionDigests(ctx context.Context) ([]digest.Digest, error) {
+	var ds []digest.Digest
+	dir := filepath.Join(c.dir, "actions")
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// actions/<hash[:2]>/<hash[2:]>-<size>
+		name := filepath.Base(filepath.Dir(path)) + d.Name()
+		hash, size, ok := strings.Cut(name, "-")
+		if !ok {
+			return nil
+		}
+		sizeBytes, err := strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			// e.g. *.tmp
+			clog.Warningf(ctx, "unexpected file in local cache %s: %v", path, err)
+			return nil
+		}
+		ds = append(ds, digest.Digest{Hash: hash, SizeBytes: sizeBytes})
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return ds, err
+}
+
 // SetActionResult sets the action result of the action identified by the digest.
 // If a failing action is provided, caching will be skipped.
 func (c *LocalCache) SetActionResult(ctx context.Context, d digest.Digest, ar *rpb.ActionResult) error {
@@ -144,6 +281,19 @@ func (c *LocalCache) GetContent(ctx context.Context, d digest.Digest, _ string)
 	_, span := trace.NewSpan(ctx, "cache-get-content")
 	defer span.Close(nil)
 	cname := c.contentCacheFilename(d)
+	if c.uncompressed {
+		err := c.checkRawContent(ctx, cname, d)
+		if err != nil {
+			c.m.ReadDone(0, err)
+			return nil, err
+		}
+		buf, err := os.ReadFile(cname)
+		c.m.ReadDone(len(buf), err)
+		if err == nil {
+			c.touch(ctx, cname)
+		}
+		return buf, err
+	}
 	r, err := os.Open(cname)
 	if err != nil {
 		c.m.ReadDone(0, err)
@@ -160,9 +310,7 @@ func (c *LocalCache) GetContent(ctx context.Context, d digest.Digest, _ string)
 	// TODO(b/274060507): local cache metric: iometrics uses compressed size or uncompressed size?
 	c.m.ReadDone(len(buf), err)
 	if err == nil {
-		if err := os.Chtimes(cname, c.timestamp, c.timestamp); err != nil {
-			clog.Warningf(ctx, "Failed to update mtime for %s: %v", cname, err)
-		}
+		c.touch(ctx, cname)
 	}
 	return buf, err
 }
@@ -184,6 +332,21 @@ func (c *LocalCache) SetContent(ctx context.Context, d digest.Digest, fname stri
 		// Write to a temporary file first before renaming to perform an atomic
 		// write.
 		tmp := cname + ".tmp"
+		if c.uncompressed {
+			err = os.WriteFile(tmp, buf, 0444)
+			if err == nil {
+				err = os.Rename(tmp, cname)
+			}
+			c.m.WriteDone(len(buf), err)
+			if err != nil {
+				os.Remove(tmp)
+				return nil, err
+			}
+			if fi, err := os.Lstat(cname); err == nil {
+				c.markVerified(cname, fi)
+			}
+			return nil, nil
+		}
 		w, err := os.Create(tmp)
 		if err != nil {
 			c.m.WriteDone(0, err)
@@ -224,6 +387,12 @@ func (c *LocalCache) SetContent(ctx context.Context, d digest.Digest, fname stri
 	return err
 }
 
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
 type dataWriteCloser struct {
 	wc    io.WriteCloser
 	cname string
@@ -287,10 +456,32 @@ func (c *LocalCache) ContentSink(ctx context.Context, d digest.Digest, fname str
 		return nil, err
 	}
 	clog.Infof(ctx, "write cache content %s for %s", d, fname)
+	if c.uncompressed {
+		err = f.Chmod(0444)
+		if err != nil {
+			f.Close()
+			c.m.OpsDone(os.Remove(f.Name()))
+			return nil, err
+		}
+		return &dataWriteCloser{wc: nopWriteCloser{f}, f: f, cname: cname, m: c.m, d: d}, nil
+	}
 	gw := gzip.NewWriter(f)
 	return &dataWriteCloser{wc: gw, f: f, cname: cname, m: c.m, d: d}, nil
 }
 
+// linkableFile returns the filename of the raw content of the digest,
+// if the cache is uncompressed and has the valid content.
+func (c *LocalCache) linkableFile(ctx context.Context, d digest.Digest) (string, bool) {
+	if c == nil || !c.uncompressed || d.SizeBytes == 0 {
+		return "", false
+	}
+	cname := c.contentCacheFilename(d)
+	if c.checkRawContent(ctx, cname, d) != nil {
+		return "", false
+	}
+	return cname, true
+}
+
 // HasContent checks whether content of the digest exists in the local cache.
 func (c *LocalCache) HasContent(ctx context.Context, d digest.Digest) bool {
 	cname := c.contentCacheFilename(d)
@@ -362,6 +553,9 @@ func (c *LocalCache) garbageCollect(ctx context.Context, ttl time.Duration) {
 	// in durations.
 	threshold := c.timestamp.Add(-ttl)
 	nFiles, spaceReclaimed := garbageCollect(ctx, filepath.Join(c.dir, "contents"), threshold)
+	nRaws, sRaws := garbageCollect(ctx, filepath.Join(c.dir, "contents-raw"), threshold)
+	nFiles += nRaws
+	spaceReclaimed += sRaws
 	nActions, sActions := garbageCollect(ctx, filepath.Join(c.dir, "actions"), threshold)
 	nFiles += nActions
 	spaceReclaimed += sActions
@@ -426,9 +620,12 @@ func (s dataSource) Open(ctx context.Context) (io.ReadCloser, error) {
 	if s.c == nil || s.c.dir == "" {
 		return nil, errors.New("cache is not configured")
 	}
-	name := fmt.Sprintf("%s-%d.gz", s.d.Hash, s.d.SizeBytes)
-	cname := filepath.Join(s.c.dir, "contents", name[:2], name[2:])
-	r, err := os.Open(cname)
+	cname := s.c.contentCacheFilename(s.d)
+	var r *os.File
+	err := errors.ErrUnsupported
+	if !s.c.uncompressed || s.c.checkRawContent(ctx, cname, s.d) == nil {
+		r, err = os.Open(cname)
+	}
 	if err != nil {
 		var err2 error
 		r, err2 = os.Open(s.fname)
@@ -440,6 +637,9 @@ func (s dataSource) Open(ctx context.Context) (io.ReadCloser, error) {
 		clog.Infof(ctx, "use %s (failed to open cached-digest data %s: %v)", s.fname, s.d, err)
 		return &dataReadCloser{ReadCloser: r, m: s.m}, nil
 	}
+	if s.c.uncompressed {
+		return &dataReadCloser{ReadCloser: r, m: s.m}, nil
+	}
 	gr, err := gzip.NewReader(r)
 	if err != nil {
 		r.Close()
@@ -450,6 +650,13 @@ func (s dataSource) Open(ctx context.Context) (io.ReadCloser, error) {
 	return &dataReadCloser{ReadCloser: gr, f: r, m: s.m}, nil
 }
 
+// LinkableFile returns the filename of the raw content in
+// the uncompressed local cache, which can be materialized
+// by reflink or hardlink.
+func (s dataSource) LinkableFile(ctx context.Context) (string, bool) {
+	return s.c.linkableFile(ctx, s.d)
+}
+
 func (s dataSource) String() string {
 	return fmt.Sprintf("cache %s for %s", s.d, s.fname)
 }
@@ -5,12 +5,19 @@
 package build
 
 import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
 	"google.golang.org/protobuf/proto"
+
+	"go.chromium.org/build/siso/reapi/digest"
 )
 
 func TestActionResultCache(t *testing.T) {
@@ -88,3 +95,63 @@ func TestGarbageCollector(t *testing.T) {
 		t.Errorf("cache.HasContent() should return false after successful GC")
 	}
 }
+
+func TestLocalCache_Uncompressed(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no hardlink on windows")
+		return
+	}
+	ctx := t.Context()
+	dir := t.TempDir()
+	cache, err := NewLocalCacheWithOptions(filepath.Join(dir, "cache"), LocalCacheOptions{Uncompressed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("content")
+	d := digest.FromBytes("content", content).Digest()
+	if err := cache.SetContent(ctx, d, "content", content); err != nil {
+		t.Fatalf("cache.SetContent(%v)=%v; want nil", d, err)
+	}
+	got, err := cache.GetContent(ctx, d, "content")
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("cache.GetContent(%v)=%q, %v; want %q, nil", d, got, err, content)
+	}
+	cname, ok := cache.Source(ctx, d, "content").(dataSource).LinkableFile(ctx)
+	if !ok {
+		t.Fatalf("LinkableFile=%q, %t; want true", cname, ok)
+	}
+	fi, err := os.Stat(cname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm()&0222 != 0 {
+		t.Errorf("raw content mode=%v; want read-only", fi.Mode())
+	}
+
+	// edit hardlinked output in place.
+	out := filepath.Join(dir, "out")
+	if err := os.Link(cname, out); err != nil {
+		t.Fatal(err)
+	}
+	got, err = cache.GetContent(ctx, d, "content")
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("cache.GetContent(%v)=%q, %v after hardlinked; want %q, nil", d, got, err, content)
+	}
+	if err := os.Chmod(out, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(out, []byte("edited!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = cache.GetContent(ctx, d, "content")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("cache.GetContent(%v)=%q, %v; want ErrNotExist", d, got, err)
+	}
+	if cache.HasContent(ctx, d) {
+		t.Errorf("cache.HasContent(%v)=true after corrupted; want false", d)
+	}
+	got, err = os.ReadFile(out)
+	if err != nil || string(got) != "edited!" {
+		t.Errorf("ReadFile(%q)=%q, %v; want %q, nil", out, got, err, "edited!")
+	}
+}
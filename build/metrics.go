@@ -97,8 +97,18 @@ type StepMetric struct {
 	CacheWriteErr bool `json:"cache_write_err,omitempty"` // whether the action failed while using cache write feature.
 	Cached        bool `json:"cached,omitempty"`          // whether the action was a cache hit.
 	Fallback      bool `json:"fallback,omitempty"`        // whether the action failed remotely and was retried locally.
+	CircuitOpen   bool `json:"circuit_open,omitempty"`    // whether the action ran locally as the RE API circuit was open.
 	Err           bool `json:"err,omitempty"`             // whether the action failed.
 	RemoteRetry   int  `json:"remote_retry,omitempty"`    // count of remote retry
+	FlakyRetry    int  `json:"flaky_retry,omitempty"`     // count of retry by the step's retry policy
+	FlakySuccess  bool `json:"flaky_success,omitempty"`   // whether the action succeeded after retry by the step's retry policy
+
+	// PlatformEscalation is the platform escalation level used
+	// for remote execution. 0 is the step's platform.
+	// PlatformEscalated is whether the action escalated the platform
+	// in this build.
+	PlatformEscalation int  `json:"platform_escalation,omitempty"`
+	PlatformEscalated  bool `json:"platform_escalated,omitempty"`
 
 	// DepsScanTime is the time it took in calculating deps for cmd inputs.
 	// TODO: set in reproxy mode too
@@ -141,6 +151,12 @@ type StepMetric struct {
 	// the action completes.
 	ActionEndTime IntervalMetric `json:"action_end,omitempty"`
 
+	// Circuit is a state transition of the RE API circuit breaker,
+	// recorded as a metric without step_id.
+	// CircuitTime is the time since build start of the transition.
+	Circuit     string         `json:"circuit,omitempty"`
+	CircuitTime IntervalMetric `json:"circuit_time,omitempty"`
+
 	Inputs  int `json:"inputs,omitempty"`  // how many input files.
 	Outputs int `json:"outputs,omitempty"` // how many output files.
 
@@ -0,0 +1,163 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninjabuild
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+// CleanOptions is options for Clean.
+type CleanOptions struct {
+	// Targets are targets to clean. If ByRule is true,
+	// they are rule names, and must not be empty.
+	// If empty, cleans all built files.
+	Targets []string
+
+	// ByRule cleans all built files of the rules in Targets.
+	ByRule bool
+
+	// Generator cleans outputs of generator steps too.
+	// Targets are always cleaned transitively including generator
+	// steps, same as ninja.
+	Generator bool
+}
+
+// Clean cleans built files as `ninja -t clean`, i.e.
+// outputs, depfiles and rspfiles of selected steps.
+// It also removes deps log entries of the cleaned outputs.
+// It returns number of removed files.
+//
+// https://github.com/ninja-build/ninja/blob/a524bf3f6bacd1b4ad85d719eed2737d8562f27a/src/clean.cc
+func (g *Graph) Clean(ctx context.Context, opts CleanOptions) (int, error) {
+	started := time.Now()
+	edges, err := g.cleanEdges(opts)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Join(g.globals.path.ExecRoot, g.globals.path.Dir)
+	seen := make(map[string]bool)
+	// removed dirs are removed from the disk by RemoveAll,
+	// so only files need flush.
+	var removed, files, outputs []string
+	remove := func(fname string) error {
+		if fname == "" || seen[fname] {
+			return nil
+		}
+		seen[fname] = true
+		fi, err := g.globals.hashFS.Stat(ctx, dir, fname)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			err = g.globals.hashFS.RemoveAll(ctx, dir, fname)
+		} else {
+			err = g.globals.hashFS.Remove(ctx, dir, fname)
+			files = append(files, fname)
+		}
+		if err != nil {
+			return err
+		}
+		clog.Infof(ctx, "clean %s", fname)
+		removed = append(removed, fname)
+		return nil
+	}
+	for _, edge := range edges {
+		for _, out := range edge.Outputs() {
+			outputs = append(outputs, out.Path())
+			err := remove(out.Path())
+			if err != nil {
+				return len(removed), err
+			}
+		}
+		for _, name := range []string{"depfile", "rspfile"} {
+			err := remove(edge.UnescapedBinding(name))
+			if err != nil {
+				return len(removed), err
+			}
+		}
+	}
+	if len(files) > 0 {
+		err = g.globals.hashFS.Flush(ctx, dir, files)
+		if err != nil {
+			return len(removed), err
+		}
+	}
+	n, err := g.globals.depsLog.Remove(ctx, outputs)
+	if err != nil {
+		return len(removed), fmt.Errorf("failed to remove deps log: %w", err)
+	}
+	clog.Infof(ctx, "clean %d files, %d deps in %s", len(removed), n, time.Since(started))
+	return len(removed), nil
+}
+
+// cleanEdges returns edges to clean for opts.
+func (g *Graph) cleanEdges(opts CleanOptions) ([]*ninjautil.Edge, error) {
+	if opts.ByRule && len(opts.Targets) == 0 {
+		return nil, errors.New("expected a rule to clean")
+	}
+	var edges []*ninjautil.Edge
+	seen := make(map[*ninjautil.Edge]bool)
+	add := func(edge *ninjautil.Edge) {
+		seen[edge] = true
+		if edge.IsPhony() {
+			return
+		}
+		edges = append(edges, edge)
+	}
+	if len(opts.Targets) == 0 || opts.ByRule {
+		rules := make(map[string]bool)
+		for _, r := range opts.Targets {
+			rules[r] = false
+		}
+		for _, n := range g.globals.nstate.AllNodes() {
+			edge, ok := n.InEdge()
+			if !ok || seen[edge] {
+				continue
+			}
+			if opts.ByRule {
+				if _, ok := rules[edge.RuleName()]; !ok {
+					continue
+				}
+				rules[edge.RuleName()] = true
+			}
+			if !opts.Generator && edge.BindingBool("generator") {
+				continue
+			}
+			add(edge)
+		}
+		for _, r := range opts.Targets {
+			if !rules[r] {
+				return nil, fmt.Errorf("unknown rule %q", r)
+			}
+		}
+		return edges, nil
+	}
+	nodes, err := g.globals.nstate.Targets(opts.Targets)
+	if err != nil {
+		return nil, err
+	}
+	for len(nodes) > 0 {
+		n := nodes[0]
+		nodes = nodes[1:]
+		edge, ok := n.InEdge()
+		if !ok || seen[edge] {
+			continue
+		}
+		add(edge)
+		nodes = append(nodes, edge.Inputs()...)
+	}
+	return edges, nil
+}
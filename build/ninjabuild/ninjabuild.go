@@ -7,8 +7,11 @@ package ninjabuild
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"maps"
 	"os"
 	"path/filepath"
@@ -23,6 +26,7 @@ import (
 	"go.chromium.org/build/siso/build/buildconfig"
 	"go.chromium.org/build/siso/hashfs"
 	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/o11y/trace"
 	"go.chromium.org/build/siso/toolsupport/ninjautil"
 )
 
@@ -186,6 +190,8 @@ func updateFilegroups(ctx context.Context, config *buildconfig.Config, buildPath
 // Load loads build.ninja file specified by fname and returns parsed states.
 func Load(ctx context.Context, fname string, buildPath *build.Path) (*ninjautil.State, error) {
 	started := time.Now()
+	ctx, span := trace.NewSpan(ctx, "load-manifest")
+	defer span.Close(nil)
 	state := ninjautil.NewState()
 	state.AddBinding("exec_root", buildPath.ExecRoot)
 	state.AddBinding("working_directory", buildPath.Dir)
@@ -198,6 +204,97 @@ func Load(ctx context.Context, fname string, buildPath *build.Path) (*ninjautil.
 	return state, nil
 }
 
+// LoadWithCache loads fname like Load, but reuses the state cached
+// in cacheFile if digests of all ninja files recorded in the cache are
+// not changed (e.g. gn didn't regenerate build.ninja).
+// If the cache is stale or broken, it parses fname and updates cacheFile.
+// If verify is true, it also parses fname and reports differences
+// between the cached state and the fresh state.
+func LoadWithCache(ctx context.Context, fname string, buildPath *build.Path, hashFS *hashfs.HashFS, cacheFile string, verify bool) (*ninjautil.State, error) {
+	started := time.Now()
+	keyFunc := func(filenames []string) ([]byte, error) {
+		return manifestCacheKey(ctx, fname, buildPath, hashFS, filenames)
+	}
+	state, err := ninjautil.LoadStateCache(cacheFile, keyFunc)
+	if err == nil {
+		clog.Infof(ctx, "load %s from cache %s %s", fname, cacheFile, time.Since(started))
+		if verify {
+			err = verifyStateCache(ctx, fname, buildPath, state)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return state, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, ninjautil.ErrStaleStateCache) {
+		clog.Infof(ctx, "no valid ninja state cache: %v", err)
+	} else {
+		clog.Warningf(ctx, "failed to load ninja state cache: %v", err)
+	}
+	state, err = Load(ctx, fname, buildPath)
+	if err != nil {
+		return nil, err
+	}
+	started = time.Now()
+	key, err := keyFunc(state.Filenames())
+	if err == nil {
+		err = ninjautil.SaveStateCache(cacheFile, state, key)
+	}
+	if err != nil {
+		clog.Warningf(ctx, "failed to save ninja state cache %s: %v", cacheFile, err)
+		return state, nil
+	}
+	clog.Infof(ctx, "save ninja state cache %s %s", cacheFile, time.Since(started))
+	return state, nil
+}
+
+// manifestCacheKey computes the key of the ninja state cache from
+// digests of filenames.
+func manifestCacheKey(ctx context.Context, fname string, buildPath *build.Path, hashFS *hashfs.HashFS, filenames []string) ([]byte, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\n", fname, buildPath.ExecRoot, buildPath.Dir)
+	inputs := make([]string, 0, len(filenames))
+	for _, f := range filenames {
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(buildPath.ExecRoot, buildPath.Dir, f)
+		}
+		inputs = append(inputs, f)
+	}
+	ents, err := hashFS.Entries(ctx, "", inputs)
+	if err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string, len(ents))
+	for _, ent := range ents {
+		digests[ent.Name] = ent.Data.Digest().String()
+	}
+	for i, f := range inputs {
+		d, ok := digests[f]
+		if !ok {
+			return nil, fmt.Errorf("missing %s: %w", filenames[i], ninjautil.ErrStaleStateCache)
+		}
+		fmt.Fprintf(h, "%s\x00%s\n", filenames[i], d)
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyStateCache parses fname and compares it with the cached state.
+func verifyStateCache(ctx context.Context, fname string, buildPath *build.Path, cached *ninjautil.State) error {
+	fresh, err := Load(ctx, fname, buildPath)
+	if err != nil {
+		return err
+	}
+	diffs := ninjautil.DiffState(fresh, cached, 20)
+	if len(diffs) == 0 {
+		clog.Infof(ctx, "ninja state cache is consistent with %s", fname)
+		return nil
+	}
+	for _, diff := range diffs {
+		clog.Warningf(ctx, "ninja state cache diff: %s", diff)
+	}
+	return fmt.Errorf("ninja state cache is inconsistent with %s: %s", fname, strings.Join(diffs, "\n"))
+}
+
 // NewGraph creates new Graph from fname (usually "build.ninja") with stepConfig.
 func NewGraph(ctx context.Context, fname string, nstate *ninjautil.State, config *buildconfig.Config, p *build.Path, hashFS *hashfs.HashFS, stepConfig *StepConfig, depsLog *ninjautil.DepsLog) *Graph {
 	graph := &Graph{
@@ -268,6 +365,13 @@ func (g *Graph) Reset(ctx context.Context) error {
 	return nil
 }
 
+// Reuse resets graph status for the next build, assuming hashfs is
+// already refreshed by the caller.
+// It is used to keep the graph across builds in build server.
+func (g *Graph) Reuse(ctx context.Context) {
+	g.reset(ctx)
+}
+
 func (g *Graph) reset(ctx context.Context) {
 	g.visited = make(map[*ninjautil.Edge]*build.Edge)
 	g.globals.depsLog.Reset()
@@ -139,3 +139,112 @@ build all: phony exe
 		}
 	}
 }
+
+func TestLoadWithCache(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "out/siso"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(dir, "out/siso"))
+	path := build.NewPath(dir, "out/siso")
+	cacheFile := filepath.Join(t.TempDir(), ".siso_ninja_state")
+
+	writeFile := func(fname, content string) {
+		t.Helper()
+		err := os.WriteFile(filepath.Join(dir, "out/siso", fname), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// each build uses new hashfs.
+	newHashFS := func() *hashfs.HashFS {
+		t.Helper()
+		hashFS, err := hashfs.New(ctx, hashfs.Option{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashFS
+	}
+	// verify=true is used by `-d stategraph`.
+	load := func(verify bool) *ninjautil.State {
+		t.Helper()
+		state, err := LoadWithCache(ctx, "build.ninja", path, newHashFS(), cacheFile, verify)
+		if err != nil {
+			t.Fatalf("LoadWithCache(verify=%t)=%v; want nil err", verify, err)
+		}
+		return state
+	}
+	cacheSaved := func(prev os.FileInfo) (os.FileInfo, bool) {
+		t.Helper()
+		fi, err := os.Stat(cacheFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi, prev == nil || !os.SameFile(prev, fi)
+	}
+
+	writeFile("build.ninja", `
+rule cxx
+  command = clang++ -c ${in} -o ${out}
+subninja sub.ninja
+build all: phony foo.o
+`)
+	subNinja := `
+build foo.o: cxx ../../foo.cc
+`
+	writeFile("sub.ninja", subNinja)
+
+	state := load(true)
+	if _, ok := state.LookupNodeByPath("foo.o"); !ok {
+		t.Errorf("no foo.o in the state")
+	}
+	fi, saved := cacheSaved(nil)
+	if !saved {
+		t.Errorf("cache is not saved")
+	}
+
+	// no ninja file changes. use the cache.
+	state = load(true)
+	if _, ok := state.LookupNodeByPath("foo.o"); !ok {
+		t.Errorf("no foo.o in the cached state")
+	}
+	fi, saved = cacheSaved(fi)
+	if saved {
+		t.Errorf("cache is saved; want to use the cache")
+	}
+
+	// subninja is regenerated. the cache is stale.
+	writeFile("sub.ninja", subNinja+`
+build bar.o: cxx ../../bar.cc
+`)
+	state = load(true)
+	if _, ok := state.LookupNodeByPath("bar.o"); !ok {
+		t.Errorf("no bar.o in the state; cache is not invalidated")
+	}
+	_, saved = cacheSaved(fi)
+	if !saved {
+		t.Errorf("cache is not saved for new sub.ninja")
+	}
+
+	// cache is inconsistent with ninja files, but key matches.
+	// verify detects it.
+	writeFile("sub.ninja", subNinja)
+	key, err := manifestCacheKey(ctx, "build.ninja", path, newHashFS(), state.Filenames())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ninjautil.SaveStateCache(cacheFile, state, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadWithCache(ctx, "build.ninja", path, newHashFS(), cacheFile, true)
+	if err == nil {
+		t.Errorf("LoadWithCache(verify=true)=nil; want inconsistent error")
+	}
+	state = load(false)
+	if _, ok := state.LookupNodeByPath("bar.o"); !ok {
+		t.Errorf("no bar.o in the inconsistent cached state without verify")
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"go.chromium.org/build/siso/execute"
 	"go.chromium.org/build/siso/hashfs"
 	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/scandeps"
 	"go.chromium.org/build/siso/toolsupport/ninjautil"
 )
 
@@ -176,6 +177,14 @@ type StepRule struct {
 	// TODO: siso: prefix will not send to remote backend.
 	Platform map[string]string `json:"platform,omitempty"`
 
+	// PlatformEscalation is a ladder of platform references to
+	// escalate remote execution to, when the step fails remotely
+	// by OOM, resource exhausted or exec timeout.
+	// Each platform properties are overlaid on the platform of
+	// the previous level.
+	PlatformEscalation []string            `json:"platform_escalation,omitempty"`
+	platformEscalation []map[string]string `json:"-"`
+
 	// Remote marks the step is remote executable.
 	Remote bool `json:"remote,omitempty"`
 	// RemoteWrapper is a wrapper used in remote execution.
@@ -231,6 +240,11 @@ type StepRule struct {
 	// NoFastDeps disables fast-deps.
 	NoFastDeps bool `json:"no_fast_deps,omitempty"`
 
+	// ScandepsScanner specifies scandeps scanner to collect
+	// inputs of the step from its sources.
+	// e.g. "proto" for protoc, "shader" for glslc, dxc etc.
+	ScandepsScanner string `json:"scandeps_scanner,omitempty"`
+
 	// OutputLocal indicates to force to write output files to local disk
 	// for subsequent steps.
 	// TODO: better to have `require_local_inputs`=[<globs>] to reduce unnecessary downloads?
@@ -261,6 +275,10 @@ type StepRule struct {
 	// e.g. thin archive.
 	Accumulate bool `json:"accumulate,omitempty"`
 
+	// Retry specifies retry policy for flaky steps.
+	// It applies for both local and remote execution.
+	Retry *build.RetryConfig `json:"retry,omitempty"`
+
 	// Debug indicates to log debug information for the step.
 	Debug bool `json:"debug,omitempty"`
 }
@@ -285,7 +303,16 @@ func (r *StepRule) Init() error {
 		buf, err := json.Marshal(r)
 		return fmt.Errorf("no selector in rule %s: %w", buf, err)
 	}
+	if r.ScandepsScanner != "" {
+		if _, ok := scandeps.LookupScanner(r.ScandepsScanner); !ok {
+			return fmt.Errorf("rule %s: unknown scandeps_scanner %q: available %q", r.Name, r.ScandepsScanner, scandeps.Scanners())
+		}
+	}
 	sort.Strings(r.Inputs)
+	err := r.Retry.Init()
+	if err != nil {
+		return fmt.Errorf("rule %s: %w", r.Name, err)
+	}
 	return nil
 }
 
@@ -348,6 +375,11 @@ func (sc StepConfig) Init(ctx context.Context) error {
 			return fmt.Errorf("duplicate name in rule %s: %w", buf, err)
 		}
 		seen[rule.Name] = true
+		for _, ref := range rule.PlatformEscalation {
+			if _, ok := sc.Platforms[ref]; !ok {
+				return fmt.Errorf("unknown platform %q in platform_escalation of rule %q", ref, rule.Name)
+			}
+		}
 		err := rule.Init()
 		if err != nil {
 			clog.Errorf(ctx, "Failed to init rule %q: %v", rule.Name, err)
@@ -451,6 +483,10 @@ loop:
 			if rule.InputRootAbsolutePath {
 				rule.Platform["InputRootAbsolutePath"] = bpath.ExecRoot
 			}
+			rule.platformEscalation = nil
+			for _, ref := range rule.PlatformEscalation {
+				rule.platformEscalation = append(rule.platformEscalation, sc.Platforms[ref])
+			}
 		}
 
 		if bool(log.V(1)) || rule.Debug {
@@ -227,3 +227,100 @@ build build.ninja: phony
 		t.Errorf("Lookup(ctx, path, edge)=%v, %v; want (rule.Remote, true)", rule, ok)
 	}
 }
+
+func TestStepConfigLookup_PlatformEscalation(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	path := build.NewPath(dir, "out/siso")
+	err := os.MkdirAll(filepath.Join(dir, "out/siso"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "out/siso/build.ninja"), []byte(`
+rule link
+  command = ld -o ${out} ${in}
+
+build foo: link foo.o
+
+build build.ninja: phony
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	err = p.Load(ctx, filepath.Join(dir, "out/siso/build.ninja"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, ok := state.LookupNodeByPath("foo")
+	if !ok {
+		t.Fatalf("foo not found in build.ninja")
+	}
+	edge, ok := node.InEdge()
+	if !ok {
+		t.Fatalf("no inEdge for foo")
+	}
+
+	sc := StepConfig{
+		Platforms: map[string]map[string]string{
+			"default": {
+				"container-image": "docker://image",
+			},
+			"large": {
+				"dockerMemory": "64g",
+			},
+		},
+		Rules: []*StepRule{
+			{
+				Name:               "link",
+				ActionName:         "link",
+				Remote:             true,
+				PlatformEscalation: []string{"large"},
+			},
+		},
+	}
+	err = sc.Init(ctx)
+	if err != nil {
+		t.Fatalf("Init()=%v; want nil error", err)
+	}
+	rule, ok := sc.Lookup(ctx, path, edge)
+	if !ok {
+		t.Fatalf("Lookup(ctx, path, edge)=%v, %t; want true", rule, ok)
+	}
+	want := []map[string]string{
+		{"dockerMemory": "64g"},
+	}
+	if diff := cmp.Diff(want, rule.platformEscalation); diff != "" {
+		t.Errorf("platform escalation diff -want +got:\n%s", diff)
+	}
+
+	sc.Rules[0].PlatformEscalation = []string{"huge"}
+	err = sc.Init(ctx)
+	if err == nil {
+		t.Errorf("Init()=nil; want error for unknown platform")
+	}
+}
+
+func TestStepConfigInit_ScandepsScanner(t *testing.T) {
+	ctx := t.Context()
+	sc := StepConfig{
+		Rules: []*StepRule{
+			{
+				Name:            "protoc",
+				ActionName:      "proto_library",
+				ScandepsScanner: "proto",
+			},
+		},
+	}
+	err := sc.Init(ctx)
+	if err != nil {
+		t.Fatalf("Init()=%v; want nil error", err)
+	}
+
+	sc.Rules[0].ScandepsScanner = "idl"
+	err = sc.Init(ctx)
+	if err == nil {
+		t.Errorf("Init()=nil; want error for unknown scanner")
+	}
+}
@@ -273,6 +273,8 @@ func (s *StepDef) Binding(name string) string {
 			return "true"
 		}
 		return ""
+	case "scandeps_scanner":
+		return s.rule.ScandepsScanner
 	case "remote_wrapper":
 		return s.rule.RemoteWrapper
 	case "remote_command":
@@ -1240,3 +1242,13 @@ func uniqueFiles(files []string) []string {
 func (s *StepDef) REProxyConfig() *execute.REProxyConfig {
 	return s.rule.REProxyConfig
 }
+
+// PlatformEscalation returns platform escalation ladder for the step.
+func (s *StepDef) PlatformEscalation() []map[string]string {
+	return s.rule.platformEscalation
+}
+
+// Retry returns retry policy for the step.
+func (s *StepDef) Retry() *build.RetryConfig {
+	return s.rule.Retry
+}
@@ -0,0 +1,76 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"go.chromium.org/build/siso/o11y/trace"
+)
+
+// OperationStats collects operation counts and timing from trace spans
+// for `-d stats`.
+// It may be shared by several builds (e.g. manifest regeneration
+// and the main build), and trace contexts outside of builds
+// (e.g. loading fs state or build manifest).
+type OperationStats struct {
+	stats *traceStats
+}
+
+// NewOperationStats creates new operation stats.
+func NewOperationStats() *OperationStats {
+	return &OperationStats{
+		stats: newTraceStats(),
+	}
+}
+
+// Add adds spans in the trace context to the stats.
+func (s *OperationStats) Add(tc *trace.Context) {
+	if s == nil || tc == nil {
+		return
+	}
+	s.stats.update(tc)
+}
+
+// operationMetrics are metrics reported by `-d stats`
+// and span names to collect them.
+var operationMetrics = []struct {
+	name string
+	span string
+}{
+	{name: "manifest parse", span: "load-manifest"},
+	{name: "starlark init", span: "starlark-init"},
+	{name: "hashfs load", span: "fs-load"},
+	{name: "hashfs save", span: "fs-save"},
+	{name: "deps log load", span: "deps-log-load"},
+	{name: "scandeps", span: "scandeps"},
+	{name: "mtime check", span: "mtime-check"},
+	{name: "merkle tree", span: "merkle-tree"},
+	{name: "CAS upload", span: "upload-all"},
+	{name: "CAS download", span: "reapi-get"},
+	{name: "action cache lookup", span: "get-action-result"},
+}
+
+// WriteTable writes operation stats table to w, like ninja's `-d stats`.
+func (s *OperationStats) WriteTable(w io.Writer) error {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	tw := tabwriter.NewWriter(w, 10, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "metric\tcount\tavg (us)\ttotal (ms)\t\n")
+	for _, m := range operationMetrics {
+		var n int
+		var total, avg time.Duration
+		if ts, ok := s.stats.s[m.span]; ok && ts.N > 0 {
+			n = ts.N
+			total = ts.Total
+			avg = ts.Avg()
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1f\t\n", m.name, n, float64(avg)/float64(time.Microsecond), float64(total)/float64(time.Millisecond))
+	}
+	return tw.Flush()
+}
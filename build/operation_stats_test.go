@@ -0,0 +1,58 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"go.chromium.org/build/siso/o11y/trace"
+)
+
+func TestOperationStats(t *testing.T) {
+	ctx := t.Context()
+	tc := trace.New(ctx, uuid.New().String())
+	ctx = trace.NewContext(ctx, tc)
+	for _, name := range []string{"load-manifest", "mtime-check", "mtime-check", "unknown"} {
+		_, span := trace.NewSpan(ctx, name)
+		span.Close(nil)
+	}
+	s := NewOperationStats()
+	s.Add(tc)
+	var sb strings.Builder
+	err := s.WriteTable(&sb)
+	if err != nil {
+		t.Fatalf("WriteTable=%v; want nil error", err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	if len(lines) != len(operationMetrics)+1 {
+		t.Fatalf("WriteTable=%q; want %d lines", sb.String(), len(operationMetrics)+1)
+	}
+	for _, tc := range []struct {
+		name  string
+		count string
+	}{
+		{name: "manifest parse", count: "1"},
+		{name: "mtime check", count: "2"},
+		{name: "scandeps", count: "0"},
+	} {
+		found := false
+		for _, line := range lines {
+			if !strings.HasPrefix(line, tc.name+"  ") {
+				continue
+			}
+			found = true
+			fields := strings.Fields(strings.TrimPrefix(line, tc.name))
+			if len(fields) < 1 || fields[0] != tc.count {
+				t.Errorf("%s: %q; want count=%s", tc.name, line, tc.count)
+			}
+		}
+		if !found {
+			t.Errorf("%s not found in %q", tc.name, sb.String())
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/siso/execute/remoteexec"
+	"go.chromium.org/build/siso/o11y/clog"
+)
+
+// PlatformEscalations records platform escalation levels of steps,
+// so later builds start remote execution on the escalated platform.
+type PlatformEscalations struct {
+	mu      sync.Mutex
+	levels  map[string]int // first output -> escalation level
+	updated bool
+}
+
+// NewPlatformEscalations creates empty platform escalations.
+func NewPlatformEscalations() *PlatformEscalations {
+	return &PlatformEscalations{
+		levels: make(map[string]int),
+	}
+}
+
+// LoadPlatformEscalations loads platform escalations from fname.
+// It returns empty platform escalations if fname doesn't exist.
+func LoadPlatformEscalations(fname string) (*PlatformEscalations, error) {
+	p := NewPlatformEscalations()
+	buf, err := os.ReadFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = json.Unmarshal(buf, &p.levels)
+	if err != nil {
+		return NewPlatformEscalations(), fmt.Errorf("failed to parse %s: %w", fname, err)
+	}
+	return p, nil
+}
+
+// Save saves platform escalations in fname if updated.
+func (p *PlatformEscalations) Save(fname string) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.updated {
+		return nil
+	}
+	buf, err := json.MarshalIndent(p.levels, "", " ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(fname, buf, 0644)
+	if err != nil {
+		return err
+	}
+	p.updated = false
+	return nil
+}
+
+func (p *PlatformEscalations) level(key string) int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.levels[key]
+}
+
+func (p *PlatformEscalations) escalate(key string, level int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.levels[key] >= level {
+		return
+	}
+	p.levels[key] = level
+	p.updated = true
+}
+
+// needPlatformEscalation reports whether remote execution failed with err
+// would succeed on a larger platform, i.e. killed by OOM, resource
+// exhausted or exec timeout.
+// It returns false if ctx is done, since the deadline exceeded may be
+// caused by the caller's ctx, rather than by the exec timeout.
+func needPlatformEscalation(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	if exitCode, ok := cmdExitCode(err); ok {
+		// 137 = 128 + SIGKILL, likely killed by OOM killer.
+		return exitCode == 137
+	}
+	if errors.Is(err, remoteexec.ErrTimeout) {
+		// cmd.Timeout exceeded.
+		return true
+	}
+	switch status.Code(err) {
+	case codes.ResourceExhausted, codes.DeadlineExceeded:
+		// action result with the status, i.e. exec timeout
+		// on the server side.
+		return true
+	}
+	return false
+}
+
+// applyPlatformEscalation applies the platform escalation level
+// recorded in the previous builds, or by other attempt in this build
+// (e.g. fast-deps), to the step.
+func (b *Builder) applyPlatformEscalation(ctx context.Context, step *Step) {
+	ladder := step.def.PlatformEscalation()
+	if len(ladder) == 0 || len(step.cmd.Platform) == 0 || len(step.cmd.Outputs) == 0 {
+		return
+	}
+	cur := step.metrics.PlatformEscalation
+	level := min(b.platformEscalations.level(step.cmd.Outputs[0]), len(ladder))
+	if level <= cur {
+		return
+	}
+	platform := maps.Clone(step.cmd.Platform)
+	for _, p := range ladder[cur:level] {
+		maps.Copy(platform, p)
+	}
+	clog.Infof(ctx, "platform escalation level %d: %v", level, platform)
+	step.cmd.Platform = platform
+	step.metrics.PlatformEscalation = level
+}
+
+// escalatePlatform escalates the step's platform to the next level,
+// if remote execution failed with err needs platform escalation.
+// It returns true if escalated and remote execution should be retried.
+func (b *Builder) escalatePlatform(ctx context.Context, step *Step, err error) bool {
+	ladder := step.def.PlatformEscalation()
+	level := step.metrics.PlatformEscalation
+	if level >= len(ladder) || len(step.cmd.Outputs) == 0 || !needPlatformEscalation(ctx, err) {
+		return false
+	}
+	platform := maps.Clone(step.cmd.Platform)
+	maps.Copy(platform, ladder[level])
+	level++
+	clog.Warningf(ctx, "escalate platform to level %d/%d %v: %v", level, len(ladder), platform, err)
+	step.cmd.Platform = platform
+	step.metrics.PlatformEscalation = level
+	step.metrics.PlatformEscalated = true
+	b.platformEscalations.escalate(step.cmd.Outputs[0], level)
+	return true
+}
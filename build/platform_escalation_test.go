@@ -0,0 +1,147 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/siso/execute"
+	"go.chromium.org/build/siso/execute/remoteexec"
+)
+
+func TestNeedPlatformEscalation(t *testing.T) {
+	ctx := t.Context()
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: execute.ExitError{ExitCode: 1}, want: false},
+		{err: execute.ExitError{ExitCode: 137}, want: true},
+		{err: fmt.Errorf("remote exec: %w", execute.ExitError{ExitCode: 137}), want: true},
+		{err: status.Error(codes.ResourceExhausted, "oom"), want: true},
+		{err: status.Error(codes.DeadlineExceeded, "execution timeout exceeded"), want: true},
+		{err: fmt.Errorf("%w=1m0s: %w", remoteexec.ErrTimeout, context.DeadlineExceeded), want: true},
+		{err: fmt.Errorf("remote exec: %w", context.DeadlineExceeded), want: false},
+		{err: status.Error(codes.Unavailable, "unavailable"), want: false},
+		{err: errors.New("error"), want: false},
+	} {
+		if got := needPlatformEscalation(ctx, tc.err); got != tc.want {
+			t.Errorf("needPlatformEscalation(ctx, %v)=%t; want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestNeedPlatformEscalation_ParentDeadlineExceeded(t *testing.T) {
+	ctx, cancel := context.WithDeadline(t.Context(), time.Now().Add(-time.Second))
+	defer cancel()
+	for _, err := range []error{
+		context.Cause(ctx),
+		status.Error(codes.DeadlineExceeded, "context deadline exceeded"),
+		fmt.Errorf("%w=1m0s: %w", remoteexec.ErrTimeout, context.DeadlineExceeded),
+		execute.ExitError{ExitCode: 137},
+	} {
+		if needPlatformEscalation(ctx, err) {
+			t.Errorf("needPlatformEscalation(expired ctx, %v)=true; want false", err)
+		}
+	}
+
+	escalations := NewPlatformEscalations()
+	b := &Builder{platformEscalations: escalations}
+	step := &Step{
+		def: fakeStepDef{escalation: []map[string]string{{"dockerMemory": "16g"}}},
+		cmd: &execute.Cmd{
+			Outputs:  []string{"out/siso/foo.o"},
+			Platform: map[string]string{"dockerMemory": "8g"},
+		},
+	}
+	if b.escalatePlatform(ctx, step, status.Error(codes.DeadlineExceeded, "context deadline exceeded")) {
+		t.Errorf("escalatePlatform(expired ctx)=true; want false")
+	}
+	if escalations.updated || step.metrics.PlatformEscalation != 0 {
+		t.Errorf("escalations updated=%t level=%d; want false, 0", escalations.updated, step.metrics.PlatformEscalation)
+	}
+}
+
+func TestPlatformEscalation(t *testing.T) {
+	ctx := t.Context()
+	fname := filepath.Join(t.TempDir(), ".siso_platform_escalations")
+	escalations, err := LoadPlatformEscalations(fname)
+	if err != nil {
+		t.Fatalf("LoadPlatformEscalations(%q)=%v; want nil error", fname, err)
+	}
+	ladder := []map[string]string{
+		{"dockerMemory": "16g"},
+		{"dockerMemory": "64g", "pool": "large"},
+	}
+	newStep := func() *Step {
+		return &Step{
+			def: fakeStepDef{escalation: ladder},
+			cmd: &execute.Cmd{
+				Outputs: []string{"out/siso/foo.o"},
+				Platform: map[string]string{
+					"container-image": "docker://image",
+					"dockerMemory":    "8g",
+				},
+			},
+		}
+	}
+
+	b := &Builder{platformEscalations: escalations}
+	step := newStep()
+	oom := execute.ExitError{ExitCode: 137}
+	if b.escalatePlatform(ctx, step, execute.ExitError{ExitCode: 1}) {
+		t.Errorf("escalatePlatform(exit=1)=true; want false")
+	}
+	if !b.escalatePlatform(ctx, step, oom) {
+		t.Fatalf("escalatePlatform(oom)=false; want true")
+	}
+	if !b.escalatePlatform(ctx, step, oom) {
+		t.Fatalf("escalatePlatform(oom)=false; want true")
+	}
+	if b.escalatePlatform(ctx, step, oom) {
+		t.Errorf("escalatePlatform(oom)=true; want false at top of ladder")
+	}
+	want := map[string]string{
+		"container-image": "docker://image",
+		"dockerMemory":    "64g",
+		"pool":            "large",
+	}
+	if diff := cmp.Diff(want, step.cmd.Platform); diff != "" {
+		t.Errorf("platform diff -want +got:\n%s", diff)
+	}
+	if step.metrics.PlatformEscalation != 2 || !step.metrics.PlatformEscalated {
+		t.Errorf("metrics escalation=%d escalated=%t; want 2, true", step.metrics.PlatformEscalation, step.metrics.PlatformEscalated)
+	}
+
+	err = escalations.Save(fname)
+	if err != nil {
+		t.Fatalf("Save(%q)=%v; want nil error", fname, err)
+	}
+
+	// next build starts with the escalated platform.
+	escalations, err = LoadPlatformEscalations(fname)
+	if err != nil {
+		t.Fatalf("LoadPlatformEscalations(%q)=%v; want nil error", fname, err)
+	}
+	b = &Builder{platformEscalations: escalations}
+	step = newStep()
+	b.applyPlatformEscalation(ctx, step)
+	if diff := cmp.Diff(want, step.cmd.Platform); diff != "" {
+		t.Errorf("platform diff -want +got:\n%s", diff)
+	}
+	if step.metrics.PlatformEscalation != 2 || step.metrics.PlatformEscalated {
+		t.Errorf("metrics escalation=%d escalated=%t; want 2, false", step.metrics.PlatformEscalation, step.metrics.PlatformEscalated)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"go.chromium.org/build/siso/execute"
+	"go.chromium.org/build/siso/o11y/clog"
+)
+
+// RetryConfig is a retry policy for flaky steps.
+// A failed step is retried if it matches with any of exit codes or
+// stderr regexp.
+type RetryConfig struct {
+	// Max is max number of retries.
+	Max int `json:"max,omitempty"`
+
+	// ExitCodes are exit codes to retry.
+	ExitCodes []int `json:"exit_codes,omitempty"`
+
+	// StderrRegex is a regexp to match with stdout/stderr to retry.
+	// Note that remote execution merges stderr into stdout.
+	StderrRegex string         `json:"stderr_regex,omitempty"`
+	stderrRE    *regexp.Regexp `json:"-"`
+
+	// Backoff is a duration to wait before the first retry.
+	// It is doubled for each subsequent retry.
+	Backoff string        `json:"backoff,omitempty"` // duration format
+	backoff time.Duration `json:"-"`
+}
+
+// Init initializes the retry config.
+func (r *RetryConfig) Init() error {
+	if r == nil {
+		return nil
+	}
+	if r.Max < 0 {
+		return fmt.Errorf("retry max must not be negative: %d", r.Max)
+	}
+	if r.StderrRegex != "" {
+		var err error
+		r.stderrRE, err = regexp.Compile(r.StderrRegex)
+		if err != nil {
+			return fmt.Errorf("bad retry stderr_regex %q: %w", r.StderrRegex, err)
+		}
+	}
+	if r.Backoff != "" {
+		var err error
+		r.backoff, err = time.ParseDuration(r.Backoff)
+		if err != nil {
+			return fmt.Errorf("bad retry backoff %q: %w", r.Backoff, err)
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether the cmd failed with err should be retried.
+func (r *RetryConfig) shouldRetry(cmd *execute.Cmd, err error) bool {
+	if r == nil || err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	exitCode, ok := cmdExitCode(err)
+	if ok && slices.Contains(r.ExitCodes, exitCode) {
+		return true
+	}
+	if r.stderrRE != nil {
+		if r.stderrRE.Match(cmd.Stderr()) || r.stderrRE.Match(cmd.Stdout()) {
+			return true
+		}
+	}
+	return false
+}
+
+// cmdExitCode returns exit code in err, if err is an exit error.
+func cmdExitCode(err error) (int, bool) {
+	var eerr execute.ExitError
+	if errors.As(err, &eerr) {
+		return eerr.ExitCode, true
+	}
+	var eerrp *execute.ExitError
+	if errors.As(err, &eerrp) {
+		return eerrp.ExitCode, true
+	}
+	return 0, false
+}
+
+// runCmdWithRetry runs the step by runCmd, and retries it
+// if the step fails and matches with the step's retry policy.
+func (b *Builder) runCmdWithRetry(ctx context.Context, step *Step, runCmd func(context.Context, *Step) error) error {
+	retry := step.def.Retry()
+	backoff := time.Duration(0)
+	if retry != nil {
+		backoff = retry.backoff
+	}
+	for attempt := 0; ; attempt++ {
+		err := runCmd(ctx, step)
+		if retry == nil || attempt >= retry.Max || !retry.shouldRetry(step.cmd, err) {
+			if err == nil && attempt > 0 {
+				clog.Warningf(ctx, "flaky step succeeded after %d retries", attempt)
+				step.metrics.FlakySuccess = true
+			}
+			return err
+		}
+		step.metrics.FlakyRetry++
+		clog.Warningf(ctx, "retry flaky step %d/%d after %s: %v", attempt+1, retry.Max, backoff, err)
+		if backoff > 0 {
+			select {
+			case <-ctx.Done():
+				return context.Cause(ctx)
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+	}
+}
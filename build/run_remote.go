@@ -21,6 +21,7 @@ import (
 var errDepsLog = errors.New("failed to exec with deps log")
 var errNeedPreproc = errors.New("need to preproc")
 var errRemoteExecDisabled = errors.New("remote exec disabled")
+var errRemoteCircuitOpen = errors.New("remote exec circuit is open")
 
 // runRemote runs step with using remote apis.
 //
@@ -33,6 +34,7 @@ var errRemoteExecDisabled = errors.New("remote exec disabled")
 //
 // - Before each remote exec, it checks remote cache before running.
 // - The fallbacks can be disabled via experiment flags.
+// - While the RE API circuit is open, it runs locally after cache check.
 func (b *Builder) runRemote(ctx context.Context, step *Step) error {
 	var fastStep *Step
 	var fastOK, fastChecked bool
@@ -88,6 +90,9 @@ func (b *Builder) runRemote(ctx context.Context, step *Step) error {
 	}
 	if fastOK {
 		err := b.tryFastStep(ctx, step, fastStep, fastNeedCheckCache && cacheCheck)
+		if errors.Is(err, errRemoteCircuitOpen) {
+			return b.execLocalCircuitOpen(ctx, step)
+		}
 		if !errors.Is(err, errDepsLog) {
 			return err
 		}
@@ -103,6 +108,9 @@ func (b *Builder) runRemote(ctx context.Context, step *Step) error {
 		if errors.Is(err, errRemoteExecDisabled) {
 			return b.execLocal(ctx, step)
 		}
+		if errors.Is(err, errRemoteCircuitOpen) {
+			return b.execLocalCircuitOpen(ctx, step)
+		}
 		if errors.Is(err, context.Canceled) {
 			return err
 		}
@@ -173,6 +181,9 @@ func (b *Builder) tryFastStep(ctx context.Context, step, fastStep *Step, cacheCh
 	if errors.Is(err, reapi.ErrBadPlatformContainerImage) {
 		return err
 	}
+	if errors.Is(err, errRemoteCircuitOpen) {
+		return err
+	}
 	step.metrics.DepsLogErr = true
 	stats := b.stats.stats()
 	nFastDeps := stats.FastDepsSuccess + stats.FastDepsFailed + 1
@@ -216,5 +227,15 @@ func (b *Builder) runRemoteStep(ctx context.Context, step *Step, cacheCheck bool
 	if !b.reExecEnable {
 		return errRemoteExecDisabled
 	}
+	if !b.reapiclient.ExecAllowed() && b.localFallbackEnabled() {
+		return errRemoteCircuitOpen
+	}
 	return b.execRemote(ctx, step)
 }
+
+// execLocalCircuitOpen runs step locally as the RE API circuit is open.
+func (b *Builder) execLocalCircuitOpen(ctx context.Context, step *Step) error {
+	clog.Infof(ctx, "circuit open. run locally %s", step.cmd.Desc)
+	step.metrics.CircuitOpen = true
+	return b.execLocal(ctx, step)
+}
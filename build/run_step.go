@@ -89,9 +89,12 @@ func (b *Builder) runStep(ctx context.Context, step *Step) (err error) {
 			b.recordNinjaLogs(ctx, step)
 			b.recordCloudMonitoringActionMetrics(ctx, step, err)
 			b.stats.update(ctx, &step.metrics, step.cmd.Pure)
+			b.live.add(newCompletedStepInfo(step, duration, err))
 			b.finalizeTrace(ctx, tc)
 			b.outputFailureSummary(ctx, step, err)
 			b.outputFailedCommands(ctx, step, err)
+			b.recordJUnit(ctx, step, err)
+			b.recordDiagnostics(ctx, step, err)
 		}
 		// unref for GC to reclaim memory.
 		step.cmd = nil
@@ -180,7 +183,7 @@ func (b *Builder) runStep(ctx context.Context, step *Step) (err error) {
 	// local execution.
 	defer b.actionFinished(ctx, step)
 	runCmd := b.runStrategy(step)
-	err = runCmd(ctx, step)
+	err = b.runCmdWithRetry(ctx, step, runCmd)
 	clog.Infof(ctx, "done err=%v", err)
 	if err != nil {
 		if ctx.Err() != nil {
@@ -311,6 +314,9 @@ func (b *Builder) outputFailureSummary(ctx context.Context, step *Step, err erro
 		fmt.Fprint(&buf, ui.StripANSIEscapeCodes(string(stdout)))
 	}
 	fmt.Fprintf(&buf, "%v\n", err)
+	if fname := b.hungSteps.diagnosticsFile(step); fname != "" {
+		fmt.Fprintf(&buf, "hung step diagnostics: %s\n", fname)
+	}
 	_, err = b.failureSummaryWriter.Write(buf.Bytes())
 	if err != nil {
 		clog.Warningf(ctx, "failed to write failure_summary: %v", err)
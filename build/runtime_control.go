@@ -0,0 +1,60 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package build
+
+import "sync"
+
+// scheduling controls whether to schedule new steps.
+type scheduling struct {
+	mu sync.Mutex
+	// resume is non-nil while paused, and closed when resumed.
+	resume chan struct{}
+}
+
+func (s *scheduling) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case paused && s.resume == nil:
+		s.resume = make(chan struct{})
+	case !paused && s.resume != nil:
+		close(s.resume)
+		s.resume = nil
+	}
+}
+
+// resumed returns a channel closed when resumed if paused.
+// It returns nil if not paused.
+func (s *scheduling) resumed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resume == nil {
+		return nil
+	}
+	return s.resume
+}
+
+// SetPaused pauses or resumes scheduling new steps.
+// Running steps continue to run while paused.
+func (b *Builder) SetPaused(paused bool) {
+	b.scheduling.setPaused(paused)
+}
+
+// Paused reports whether scheduling is paused.
+func (b *Builder) Paused() bool {
+	return b.scheduling.resumed() != nil
+}
+
+// SetKeepGoing sets whether to keep going regardless of failures,
+// i.e. `-k 0`. If false, it uses failures allowed by the option,
+// or stops at the next failure if the option is `-k 0`.
+func (b *Builder) SetKeepGoing(keepGoing bool) {
+	b.failures.setKeepGoing(keepGoing)
+}
+
+// KeepGoing reports whether it keeps going regardless of failures.
+func (b *Builder) KeepGoing() bool {
+	return b.failures.isKeepGoing()
+}
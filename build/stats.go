@@ -51,6 +51,12 @@ func (s *stats) update(ctx context.Context, m *StepMetric, pure bool) {
 	if m.Fallback {
 		s.s.LocalFallback++
 	}
+	if m.PlatformEscalated {
+		s.s.Escalated++
+	}
+	if m.CircuitOpen {
+		s.s.CircuitOpen++
+	}
 	if m.CacheWrite {
 		s.s.CacheWrite++
 	}
@@ -61,6 +67,10 @@ func (s *stats) update(ctx context.Context, m *StepMetric, pure bool) {
 		s.s.Fail++
 	}
 	s.s.RemoteRetry += m.RemoteRetry
+	s.s.FlakyRetry += m.FlakyRetry
+	if m.FlakySuccess {
+		s.s.FlakySuccess++
+	}
 
 	if m.DepsLog {
 		s.s.FastDepsSuccess++
@@ -94,9 +104,13 @@ type Stats struct {
 	Local           int // locally executed actions
 	Remote          int // remote executed actions
 	LocalFallback   int // actions for which remote execution failed, and we did a local fallback
+	CircuitOpen     int // actions that ran locally, because the RE API circuit was open
+	Escalated       int // actions that escalated remote platform by OOM or timeout
 	CacheWrite      int // locally executed actions whose trusted results were uploaded directly to RE
 	CacheWriteErr   int // locally executed actions that failed uploading results directly to RE
 	RemoteRetry     int // accumulated remote retry counts
+	FlakyRetry      int // accumulated retry counts by step retry policy
+	FlakySuccess    int // actions that succeeded after retry by step retry policy
 	Total           int // total actions that ran during this build
 }
 
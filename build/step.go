@@ -89,6 +89,13 @@ type StepDef interface {
 	// REProxyConfig returns configuration options for using reproxy.
 	REProxyConfig() *execute.REProxyConfig
 
+	// Retry returns retry policy for the step, or nil if not retried.
+	Retry() *RetryConfig
+
+	// PlatformEscalation returns platform properties to escalate
+	// remote execution to, in order.
+	PlatformEscalation() []map[string]string
+
 	// CheckInputDeps checks dep can be found in its direct/indirect inputs.
 	// Returns true if it is unknown bad deps, false otherwise.
 	CheckInputDeps(context.Context, []string) (bool, error)
@@ -449,6 +456,7 @@ func (s *Step) init(ctx context.Context, b *Builder, stepManifest *stepManifest)
 		s.outputPaths = append(s.outputPaths, b.path.MaybeToWD(ctx, out))
 	}
 	s.cmd = newCmd(ctx, b, s.def, stepManifest)
+	b.applyPlatformEscalation(ctx, s)
 	clog.Infof(ctx, "cmdhash:%s", base64.StdEncoding.EncodeToString(s.cmd.CmdHash))
 }
 
@@ -19,6 +19,8 @@ type fakeStepDef struct {
 	command        string
 	outputs        []string
 	expandedInputs func(context.Context) []string
+	escalation     []map[string]string
+	scanner        string
 }
 
 func (f fakeStepDef) String() string { return fmt.Sprintf("%#v", f) }
@@ -37,6 +39,8 @@ func (f fakeStepDef) Binding(b string) string {
 	switch b {
 	case "command":
 		return f.command
+	case "scandeps_scanner":
+		return f.scanner
 	}
 	return ""
 }
@@ -64,6 +68,10 @@ func (f fakeStepDef) ExpandedInputs(ctx context.Context) []string {
 
 func (fakeStepDef) RemoteInputs() map[string]string       { return nil }
 func (fakeStepDef) REProxyConfig() *execute.REProxyConfig { return &execute.REProxyConfig{} }
+func (fakeStepDef) Retry() *RetryConfig                   { return nil }
+func (f fakeStepDef) PlatformEscalation() []map[string]string {
+	return f.escalation
+}
 
 func (fakeStepDef) CheckInputDeps(context.Context, []string) (bool, error) { return false, nil }
 
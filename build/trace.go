@@ -263,6 +263,11 @@ type traceEventObject struct {
 	// Used for "ph"="X".
 	Dur int64 `json:"dur,omitempty"`
 
+	// The scope of instant events. "g" (global), "p" (process)
+	// or "t" (thread; default).
+	// Used for "ph"="i".
+	S string `json:"s,omitempty"`
+
 	// Any arguments provided for the event.
 	Args map[string]any `json:"args,omitempty"`
 }
@@ -422,6 +427,21 @@ func (te *traceEvents) Add(ctx context.Context, tc *trace.Context) {
 	}
 }
 
+// AddInstant adds a global instant event at t, e.g. RE API
+// circuit transition.
+func (te *traceEvents) AddInstant(name, cat string, t time.Time, args map[string]any) {
+	te.q <- traceEventObject{
+		Name: name,
+		Cat:  cat,
+		Ph:   "i",
+		T:    t.Sub(te.start).Microseconds(),
+		Pid:  sisoPid,
+		Tid:  sisoTid,
+		S:    "g",
+		Args: args,
+	}
+}
+
 type spanEventAttr struct {
 	id          string
 	description string
@@ -26,6 +26,7 @@ import (
 
 	"go.chromium.org/build/siso/hashfs"
 	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/o11y/trace"
 	"go.chromium.org/build/siso/reapi"
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/reapi/merkletree"
@@ -219,6 +220,10 @@ type Cmd struct {
 	// OOMScoreAdj is value to set oom_score_adj on local exec (linux only)
 	OOMScoreAdj *int
 
+	// Pid holds the process id of the command while it is running
+	// on local exec. localexec only, and only if not nil.
+	Pid *atomic.Int32
+
 	// outfiles is outputs of the step in build graph.
 	// These outputs will be recorded with cmdhash.
 	// Other outputs in c.Outputs will be recorded without cmdhash.
@@ -571,6 +576,8 @@ func (c *Cmd) inputTree(ctx context.Context) ([]merkletree.Entry, error) {
 
 // treeDigest returns a digest for the Merkle tree entries.
 func treeDigest(ctx context.Context, subtrees []merkletree.TreeEntry, entries []merkletree.Entry, ds *digest.Store) (digest.Digest, error) {
+	ctx, span := trace.NewSpan(ctx, "merkle-tree")
+	defer span.Close(nil)
 	t := merkletree.New(ds)
 	for _, subtree := range subtrees {
 		if log.V(2) {
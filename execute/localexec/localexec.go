@@ -148,7 +148,13 @@ func run(ctx context.Context, cmd *execute.Cmd) (*rpb.ActionResult, error) {
 		if cmd.OOMScoreAdj != nil {
 			oomScoreAdj(ctx, c.Process.Pid, *cmd.OOMScoreAdj)
 		}
+		if cmd.Pid != nil {
+			cmd.Pid.Store(int32(c.Process.Pid))
+		}
 		err = c.Wait()
+		if cmd.Pid != nil {
+			cmd.Pid.Store(0)
+		}
 	}
 	if err == nil {
 		ru = rusage(c)
@@ -0,0 +1,139 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.1
+// source: signature.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// signature of action result to be stored
+// in ActionResult.execution_metadata.auxiliary_metatada.
+type ActionResultSignature struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// key_id identifies the public key to verify the signature.
+	KeyId string `protobuf:"bytes,1,opt,name=key_id,json=keyId,proto3" json:"key_id,omitempty"`
+	// ed25519 signature over the action digest and output digests.
+	Signature     []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ActionResultSignature) Reset() {
+	*x = ActionResultSignature{}
+	mi := &file_signature_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ActionResultSignature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ActionResultSignature) ProtoMessage() {}
+
+func (x *ActionResultSignature) ProtoReflect() protoreflect.Message {
+	mi := &file_signature_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ActionResultSignature.ProtoReflect.Descriptor instead.
+func (*ActionResultSignature) Descriptor() ([]byte, []int) {
+	return file_signature_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ActionResultSignature) GetKeyId() string {
+	if x != nil {
+		return x.KeyId
+	}
+	return ""
+}
+
+func (x *ActionResultSignature) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+var File_signature_proto protoreflect.FileDescriptor
+
+const file_signature_proto_rawDesc = "" +
+	"\n" +
+	"\x0fsignature.proto\x12\fsiso.execute\"L\n" +
+	"\x15ActionResultSignature\x12\x15\n" +
+	"\x06key_id\x18\x01 \x01(\tR\x05keyId\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\fR\tsignatureB*Z(go.chromium.org/build/siso/execute/protob\x06proto3"
+
+var (
+	file_signature_proto_rawDescOnce sync.Once
+	file_signature_proto_rawDescData []byte
+)
+
+func file_signature_proto_rawDescGZIP() []byte {
+	file_signature_proto_rawDescOnce.Do(func() {
+		file_signature_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_signature_proto_rawDesc), len(file_signature_proto_rawDesc)))
+	})
+	return file_signature_proto_rawDescData
+}
+
+var file_signature_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_signature_proto_goTypes = []any{
+	(*ActionResultSignature)(nil), // 0: siso.execute.ActionResultSignature
+}
+var file_signature_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_signature_proto_init() }
+func file_signature_proto_init() {
+	if File_signature_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_signature_proto_rawDesc), len(file_signature_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_signature_proto_goTypes,
+		DependencyIndexes: file_signature_proto_depIdxs,
+		MessageInfos:      file_signature_proto_msgTypes,
+	}.Build()
+	File_signature_proto = out.File
+	file_signature_proto_goTypes = nil
+	file_signature_proto_depIdxs = nil
+}
@@ -21,6 +21,7 @@ import (
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/reapi/merkletree"
 	_ "go.chromium.org/build/siso/reapi/proto" // for auxiliary metadata
+	"go.chromium.org/build/siso/reapi/signature"
 	"go.chromium.org/build/siso/runtimex"
 	"go.chromium.org/build/siso/sync/semaphore"
 )
@@ -28,9 +29,13 @@ import (
 // Semaphore enforces a limit on parallel digest calculations to prevent an OOM.
 var Semaphore = semaphore.New("remoteexec-digest", runtimex.NumCPU()*10)
 
+// ErrTimeout is an error when remote execution exceeds the cmd's timeout.
+var ErrTimeout = errors.New("remote exec timeout")
+
 // RemoteExec is executor with remote exec API.
 type RemoteExec struct {
-	client *reapi.Client
+	client   *reapi.Client
+	verifier *signature.Verifier
 }
 
 // New creates new remote executor.
@@ -40,6 +45,14 @@ func New(ctx context.Context, client *reapi.Client) *RemoteExec {
 	}
 }
 
+// SetVerifier sets verifier of cached results served by Execute.
+// Cached results that are not signed by trusted keys are treated
+// as cache misses, and the action is executed again without cache
+// lookup.
+func (re *RemoteExec) SetVerifier(v *signature.Verifier) {
+	re.verifier = v
+}
+
 func (re *RemoteExec) prepareInputs(ctx context.Context, cmd *execute.Cmd) (digest.Digest, error) {
 	var actionDigest digest.Digest
 	err := Semaphore.Do(ctx, func(ctx context.Context) error {
@@ -49,7 +62,7 @@ func (re *RemoteExec) prepareInputs(ctx context.Context, cmd *execute.Cmd) (dige
 		if err != nil {
 			return err
 		}
-		n, err := re.client.UploadAll(ctx, ds)
+		n, err := re.client.UploadExecInputs(ctx, ds)
 		if err != nil {
 			return fmt.Errorf("failed to upload all %s: %w", cmd, err)
 		}
@@ -67,18 +80,26 @@ func (re *RemoteExec) Run(ctx context.Context, cmd *execute.Cmd) error {
 	if err != nil {
 		return err
 	}
-
-	cctx, cspan := trace.NewSpan(ctx, "execute-and-wait")
-	if cmd.Timeout > 0 {
-		var cancel context.CancelFunc
-		cctx, cancel = context.WithTimeoutCause(cctx, cmd.Timeout, fmt.Errorf("remote exec timeout=%v: %w", cmd.Timeout, context.DeadlineExceeded))
-		defer cancel()
+	opName, resp, err := re.executeAndWait(ctx, cmd, actionDigest)
+	if missing := reapi.MissingBlobs(err); len(missing) > 0 {
+		// blobs may be evicted from the CAS while they are
+		// considered as present. upload them again and retry.
+		clog.Warningf(ctx, "digest: %s, missing %d blobs: %v", actionDigest, len(missing), err)
+		re.client.Forget(missing)
+		actionDigest, err = re.prepareInputs(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		opName, resp, err = re.executeAndWait(ctx, cmd, actionDigest)
+	}
+	if err == nil && resp.GetCachedResult() {
+		verr := re.verifier.Verify(actionDigest, resp.GetResult())
+		if verr != nil {
+			clog.Warningf(ctx, "digest: %s, unverifiable cached result. retry without cache lookup: %v", actionDigest, verr)
+			cmd.SkipCacheLookup = true
+			opName, resp, err = re.executeAndWait(ctx, cmd, actionDigest)
+		}
 	}
-	opName, resp, err := re.client.ExecuteAndWait(cctx, &rpb.ExecuteRequest{
-		ActionDigest:    actionDigest.Proto(),
-		SkipCacheLookup: cmd.SkipCacheLookup,
-	})
-	cspan.Close(nil)
 	clog.Infof(ctx, "digest: %s, skipCacheLookup:%t opName: %s", actionDigest, cmd.SkipCacheLookup, opName)
 	if log.V(1) {
 		clog.Infof(ctx, "response: %s", resp)
@@ -91,6 +112,25 @@ func (re *RemoteExec) Run(ctx context.Context, cmd *execute.Cmd) error {
 	return re.processResult(ctx, cmd, result, resp.GetCachedResult(), err)
 }
 
+func (re *RemoteExec) executeAndWait(ctx context.Context, cmd *execute.Cmd, actionDigest digest.Digest) (string, *rpb.ExecuteResponse, error) {
+	ctx, span := trace.NewSpan(ctx, "execute-and-wait")
+	defer span.Close(nil)
+	if cmd.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, cmd.Timeout, fmt.Errorf("%w=%v: %w", ErrTimeout, cmd.Timeout, context.DeadlineExceeded))
+		defer cancel()
+	}
+	opName, resp, err := re.client.ExecuteAndWait(ctx, &rpb.ExecuteRequest{
+		ActionDigest:    actionDigest.Proto(),
+		SkipCacheLookup: cmd.SkipCacheLookup,
+	})
+	if cause := context.Cause(ctx); err != nil && errors.Is(cause, ErrTimeout) {
+		// distinguish cmd's timeout from caller's ctx deadline.
+		err = fmt.Errorf("%w: %w", cause, err)
+	}
+	return opName, resp, err
+}
+
 func (re *RemoteExec) recordExecuteMetadata(ctx context.Context, result *rpb.ActionResult, cached bool, span *trace.Span) {
 	md := result.GetExecutionMetadata()
 	queue := trace.SpanData{
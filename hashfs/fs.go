@@ -131,6 +131,7 @@ func New(ctx context.Context, opt Option) (*HashFS, error) {
 	}
 	if opt.StateFile != "" {
 		start := time.Now()
+		_, span := trace.NewSpan(ctx, "fs-load")
 		journalFile := opt.StateFile + ".journal"
 
 		fstate, err := Load(ctx, opt)
@@ -153,6 +154,7 @@ func New(ctx context.Context, opt Option) (*HashFS, error) {
 			// is not removed, so recover last build updates from the journal.
 			reconciled := loadJournal(ctx, journalFile, fstate)
 			if err := fsys.SetState(ctx, fstate); err != nil {
+				span.Close(nil)
 				return nil, err
 			}
 			if reconciled {
@@ -174,6 +176,7 @@ func New(ctx context.Context, opt Option) (*HashFS, error) {
 		} else {
 			fsys.journal.w = f
 		}
+		span.Close(nil)
 	}
 	go fsys.digester.start()
 	return fsys, nil
@@ -244,6 +247,38 @@ func (hfs *HashFS) Close(ctx context.Context) error {
 	if hfs.opt.StateFile == "" {
 		return nil
 	}
+	_, err := hfs.save(ctx)
+	return err
+}
+
+// Checkpoint persists current state in opt.StateFile as Close does,
+// but keeps the HashFS usable, e.g. for next build in build server.
+func (hfs *HashFS) Checkpoint(ctx context.Context) error {
+	clog.Infof(ctx, "fs checkpoint")
+	if hfs.opt.StateFile == "" {
+		return nil
+	}
+	saved, err := hfs.save(ctx)
+	journalFile := hfs.opt.StateFile + ".journal"
+	// journal has updates since the last saved state, so reset it
+	// only if the state is saved. otherwise, append to it.
+	flag := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if saved {
+		flag |= os.O_TRUNC
+	}
+	f, jerr := os.OpenFile(journalFile, flag, 0644)
+	if jerr != nil {
+		clog.Warningf(ctx, "Failed to create fs state journal: %v", jerr)
+		return err
+	}
+	hfs.journal.mu.Lock()
+	hfs.journal.w = f
+	hfs.journal.mu.Unlock()
+	return err
+}
+
+// save saves the state in opt.StateFile, and reports whether it saved.
+func (hfs *HashFS) save(ctx context.Context) (bool, error) {
 	err := hfs.journal.Close()
 	if err != nil {
 		clog.Warningf(ctx, "Failed to close journal %v", err)
@@ -252,18 +287,20 @@ func (hfs *HashFS) Close(ctx context.Context) error {
 	if hfs.clean.Load() || !hfs.loaded.Load() || len(hfs.taintedFiles) > 0 {
 		// don't update fs state when there are tainted files.
 		clog.Warningf(ctx, "not save state clean=%t loaded=%t tainted:%d", hfs.clean.Load(), hfs.loaded.Load(), len(hfs.taintedFiles))
-		return nil
+		return false, nil
 	}
+	_, span := trace.NewSpan(ctx, "fs-save")
+	defer span.Close(nil)
 	err = Save(ctx, hfs.State(ctx), hfs.opt)
 	if err != nil {
 		clog.Errorf(ctx, "Failed to save fs state in %s: %v", hfs.opt.StateFile, err)
 		if rerr := os.Remove(hfs.opt.StateFile); rerr != nil && !errors.Is(rerr, fs.ErrNotExist) {
 			clog.Errorf(ctx, "Failed to remove stale fs state %s: %v", hfs.opt.StateFile, err)
 		}
-		return err
+		return false, err
 	}
 	clog.Infof(ctx, "Saved fs state in %s", hfs.opt.StateFile)
-	return nil
+	return true, nil
 }
 
 // IsClean returns whether hashfs is clean for buildTargets (i.e. sync with local disk).
@@ -1479,6 +1516,41 @@ func (hfs *HashFS) NeedFlush(ctx context.Context, execRoot, fname string) bool {
 	return hfs.opt.OutputLocal(ctx, makeFullpath(execRoot, fname))
 }
 
+// CopyHardlinks replaces hardlinked files under execRoot on the local disk
+// with their copies, so that local commands won't modify hardlinked
+// files in place, which may be shared with local cache (or other
+// checkouts). It keeps mtime, so hashfs entries remain valid.
+func (hfs *HashFS) CopyHardlinks(ctx context.Context, execRoot string, files []string) error {
+	for _, file := range files {
+		fname := makeFullpath(execRoot, file)
+		fi, err := hfs.OS.Lstat(ctx, fname)
+		if err != nil || !fi.Mode().IsRegular() || !IsHardlink(fi) {
+			continue
+		}
+		err = copyHardlink(ctx, hfs.OS, fname, fi)
+		clog.Infof(ctx, "copy hardlink %s: %v", fname, err)
+		if err != nil {
+			return fmt.Errorf("failed to copy hardlink %s: %w", fname, err)
+		}
+	}
+	return nil
+}
+
+func copyHardlink(ctx context.Context, ofs *osfs.OSFS, fname string, fi fs.FileInfo) error {
+	tmpname := filepath.Join(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
+	err := ofs.WriteDigestData(ctx, tmpname, ofs.FileSource(fname, fi.Size()), fi.Mode().Perm()|0200)
+	if err != nil {
+		_ = os.Remove(tmpname)
+		return err
+	}
+	err = ofs.Chtimes(ctx, tmpname, time.Time{}, fi.ModTime())
+	if err != nil {
+		_ = os.Remove(tmpname)
+		return err
+	}
+	return ofs.Rename(ctx, tmpname, fname)
+}
+
 // Flush flushes cached information for files under execRoot to local disk.
 func (hfs *HashFS) Flush(ctx context.Context, execRoot string, files []string) error {
 	ctx, cancel := context.WithCancel(ctx)
@@ -1565,6 +1637,9 @@ func (hfs *HashFS) Refresh(ctx context.Context, execRoot string) error {
 	// reset loaded as it reset entry data.
 	hfs.loaded.Store(false)
 	hfs.directory = &directory{isRoot: true}
+	// SetState collects them again for the state.
+	hfs.previouslyGeneratedFiles = nil
+	hfs.taintedFiles = nil
 	err := hfs.SetState(ctx, state)
 	werr := hfs.WaitReady(ctx)
 	if err != nil {
@@ -1944,7 +2019,7 @@ func (e *entry) flush(ctx context.Context, fname string, osfs *osfs.OSFS, timeou
 			clog.Infof(ctx, "flush %s: already exist", fname)
 			return nil
 		}
-		if isHardlink(fi) {
+		if IsHardlink(fi) {
 			removeReason = "hardlink"
 		} else if !fi.Mode().IsRegular() {
 			removeReason = fmt.Sprintf("non-regular file %s", fi.Mode())
@@ -1996,6 +2071,9 @@ func (e *entry) flush(ctx context.Context, fname string, osfs *osfs.OSFS, timeou
 		return nil
 	}
 	buf := e.buf
+	// keepMtime is set when fname is hardlinked to shared file,
+	// whose mtime should not be changed.
+	var keepMtime bool
 	removeBeforeWrite := func() {
 		if removeReason != "" {
 			err = osfs.Remove(ctx, fname)
@@ -2007,6 +2085,61 @@ func (e *entry) flush(ctx context.Context, fname string, osfs *osfs.OSFS, timeou
 			return fmt.Errorf("no data: retrieve %s: ", fname)
 		}
 		err = func() error {
+			// materialize from local file of the source
+			// (e.g. uncompressed local cache content)
+			// by reflink or hardlink, if possible.
+			type linkableSource interface {
+				LinkableFile(context.Context) (string, bool)
+			}
+			src := e.src
+			if data, ok := src.(digest.Data); ok {
+				src = data.Source()
+			}
+			if ls, ok := src.(linkableSource); ok {
+				if lname, ok := ls.LinkableFile(ctx); ok {
+					tmpname := filepath.Join(filepath.Dir(fname), "."+filepath.Base(fname)+".tmp")
+					_ = os.Remove(tmpname)
+					hardlink, err := osfs.LinkFile(ctx, lname, tmpname, e.mode)
+					if err == nil && hardlink {
+						// changing mtime of hardlinked file
+						// changes the shared inode's mtime,
+						// so keep its existing mtime, and
+						// use it only if it is not older than
+						// the entry's, otherwise the output
+						// would look older than its inputs.
+						var fi fs.FileInfo
+						fi, err = osfs.Lstat(ctx, tmpname)
+						e.mu.Lock()
+						mtime := e.mtime
+						e.mu.Unlock()
+						if err == nil && fi.ModTime().Before(mtime) {
+							clog.Infof(ctx, "flush %s %s: not hardlink from %s: mtime %s < %s", fname, d, lname, fi.ModTime(), mtime)
+							_ = os.Remove(tmpname)
+							err = errors.ErrUnsupported
+						}
+						if err == nil {
+							removeBeforeWrite()
+							clog.Infof(ctx, "flush %s %s link from %s hardlink=%t", fname, d, lname, hardlink)
+							err = osfs.Rename(ctx, tmpname, fname)
+							if err != nil {
+								return err
+							}
+							e.mu.Lock()
+							e.mtime = fi.ModTime()
+							e.mu.Unlock()
+							keepMtime = true
+							return nil
+						}
+					} else if err == nil {
+						removeBeforeWrite()
+						clog.Infof(ctx, "flush %s %s link from %s hardlink=%t", fname, d, lname, hardlink)
+						return osfs.Rename(ctx, tmpname, fname)
+					}
+					if !errors.Is(err, errors.ErrUnsupported) {
+						clog.Warningf(ctx, "link %s failed: %v", fname, err)
+					}
+				}
+			}
 			// check if hashfs entry is copy of local file,
 			// i.e. created by hashfs Copy method.
 			// if hashfs entry is set by remote action,
@@ -2069,6 +2202,9 @@ func (e *entry) flush(ctx context.Context, fname string, osfs *osfs.OSFS, timeou
 	if err != nil {
 		return err
 	}
+	if keepMtime {
+		return nil
+	}
 	err = osfs.Chtimes(ctx, fname, time.Time{}, mtime)
 	if err != nil {
 		return err
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -2473,3 +2474,141 @@ func TestEntries_NonExistentIntermediateDirectory(t *testing.T) {
 		t.Fatalf("len(ent)=%d; want 1", len(ents))
 	}
 }
+
+type linkableSource struct {
+	fname string
+}
+
+func (s linkableSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	return os.Open(s.fname)
+}
+
+func (s linkableSource) String() string {
+	return s.fname
+}
+
+func (s linkableSource) LinkableFile(ctx context.Context) (string, bool) {
+	return s.fname, true
+}
+
+func TestFlush_LinkableSource(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no hardlink on windows")
+		return
+	}
+	for _, tc := range []struct {
+		name string
+		// cacheAge is how old the cache file is than the entry.
+		cacheAge time.Duration
+	}{
+		{
+			name:     "old_cache",
+			cacheAge: time.Hour,
+		},
+		{
+			name:     "new_cache",
+			cacheAge: -time.Hour,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := t.Context()
+			dir := t.TempDir()
+			cacheFile := filepath.Join(dir, "cache/content")
+			err := os.MkdirAll(filepath.Dir(cacheFile), 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			content := []byte("cached content")
+			err = os.WriteFile(cacheFile, content, 0444)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mtime := time.Now().Truncate(time.Second)
+			cacheMtime := mtime.Add(-tc.cacheAge)
+			err = os.Chtimes(cacheFile, time.Time{}, cacheMtime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hashFS, err := hashfs.New(ctx, hashfs.Option{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer hashFS.Close(ctx)
+
+			d := digest.FromBytes("content", content).Digest()
+			err = update(ctx, hashFS, dir, []merkletree.Entry{
+				{
+					Name: "out/siso/gen/file",
+					Data: digest.NewData(linkableSource{fname: cacheFile}, d),
+				},
+			}, mtime, []byte("cmdhash"), digest.Digest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = hashFS.Flush(ctx, dir, []string{"out/siso/gen/file"})
+			if err != nil {
+				t.Fatalf("Flush=%v; want nil err", err)
+			}
+			fname := filepath.Join(dir, "out/siso/gen/file")
+			got, err := os.ReadFile(fname)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("ReadFile(%q)=%q, %v; want %q, nil", fname, got, err, content)
+			}
+			fi, err := os.Lstat(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfi, err := os.Lstat(cacheFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hardlinked := os.SameFile(fi, cfi)
+			t.Logf("hardlinked=%t", hardlinked)
+			if hardlinked && tc.cacheAge > 0 {
+				t.Errorf("%q is hardlinked to older %q", fname, cacheFile)
+			}
+			if hardlinked && fi.Mode().Perm()&0222 != 0 {
+				t.Errorf("hardlinked file mode=%v; want read-only", fi.Mode())
+			}
+			// output must not look older than when it was written.
+			if fi.ModTime().Before(mtime) {
+				t.Errorf("mtime=%v; want >= %v", fi.ModTime(), mtime)
+			}
+			// mtime of the cache file should not be changed.
+			if !cfi.ModTime().Equal(cacheMtime) {
+				t.Errorf("cache mtime=%v; want %v", cfi.ModTime(), cacheMtime)
+			}
+			hfi, err := hashFS.Stat(ctx, dir, "out/siso/gen/file")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !hfi.ModTime().Equal(fi.ModTime()) {
+				t.Errorf("hashfs mtime=%v; want %v", hfi.ModTime(), fi.ModTime())
+			}
+			wantMtime := fi.ModTime()
+
+			err = hashFS.CopyHardlinks(ctx, dir, []string{"out/siso/gen/file"})
+			if err != nil {
+				t.Fatalf("CopyHardlinks=%v; want nil err", err)
+			}
+			fi, err = os.Lstat(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if os.SameFile(fi, cfi) {
+				t.Errorf("%q is still hardlinked to %q", fname, cacheFile)
+			}
+			if !fi.ModTime().Equal(wantMtime) {
+				t.Errorf("mtime after copy=%v; want %v", fi.ModTime(), wantMtime)
+			}
+			err = os.WriteFile(fname, []byte("edited"), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err = os.ReadFile(cacheFile)
+			if err != nil || !bytes.Equal(got, content) {
+				t.Errorf("ReadFile(%q)=%q, %v; want %q, nil", cacheFile, got, err, content)
+			}
+		})
+	}
+}
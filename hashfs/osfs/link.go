@@ -0,0 +1,43 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package osfs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// LinkFile materializes dst from local file src without copying data.
+// It uses reflink (FICLONE on linux, clonefile on darwin) if the
+// filesystem supports it, and falls back to hardlink.
+// Hardlinked dst shares the inode with src, so src should be
+// read-only to protect from editing dst in place.
+// It returns true if dst is hardlinked to src.
+//
+// It doesn't support executable perm (to avoid ETXTBSY race, see
+// writeFile) and returns errors.ErrUnsupported, so caller should
+// copy the file in such case.
+func (ofs *OSFS) LinkFile(ctx context.Context, src, dst string, perm fs.FileMode) (bool, error) {
+	if perm&0111 != 0 {
+		return false, errors.ErrUnsupported
+	}
+	started := time.Now()
+	hardlink := false
+	err := reflink(src, dst)
+	if err == nil {
+		err = os.Chmod(dst, perm)
+	} else {
+		hardlink = true
+		err = os.Link(src, dst)
+	}
+	ofs.OpsDone(err)
+	if dur := time.Since(started); dur > 1*time.Minute {
+		logSlow(ctx, dst, dur, err)
+	}
+	return hardlink, err
+}
@@ -0,0 +1,13 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build !linux && !darwin
+
+package osfs
+
+import "errors"
+
+func reflink(src, dst string) error {
+	return errors.ErrUnsupported
+}
@@ -11,7 +11,8 @@ import (
 	"syscall"
 )
 
-func isHardlink(fi fs.FileInfo) bool {
+// IsHardlink reports whether fi is a file that has other hardlinks.
+func IsHardlink(fi fs.FileInfo) bool {
 	st, ok := fi.Sys().(*syscall.Stat_t)
 	if !ok {
 		return false
@@ -6,6 +6,7 @@ package hashfs
 
 import "io/fs"
 
-func isHardlink(fi fs.FileInfo) bool {
+// IsHardlink reports whether fi is a file that has other hardlinks.
+func IsHardlink(fi fs.FileInfo) bool {
 	return false
 }
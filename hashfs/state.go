@@ -917,6 +917,12 @@ func StateMap(s *pb.State) map[string]*pb.Entry {
 	return m
 }
 
+// LoadJournal merges entries in journal file fname into state.
+// It reports whether any entries are merged.
+func LoadJournal(ctx context.Context, fname string, state *pb.State) bool {
+	return loadJournal(ctx, fname, state)
+}
+
 func loadJournal(ctx context.Context, fname string, state *pb.State) bool {
 	started := time.Now()
 	b, err := os.ReadFile(fname)
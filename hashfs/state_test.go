@@ -645,3 +645,99 @@ func BenchmarkLoadState(b *testing.B) {
 		}
 	}
 }
+
+func TestCheckpoint(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := hashfs.Option{
+		StateFile:   filepath.Join(dir, ".siso_fs_state"),
+		KeepTainted: true,
+	}
+	journalFile := opts.StateFile + ".journal"
+	journalSize := func() int64 {
+		t.Helper()
+		fi, err := os.Stat(journalFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Size()
+	}
+
+	hashFS, err := hashfs.New(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hashFS.Close(ctx)
+	err = hashFS.WaitReady(ctx)
+	if err != nil {
+		t.Fatalf("WaitReady=%v; want nil", err)
+	}
+
+	mtime := time.Now().Add(-1 * time.Hour)
+	err = hashFS.WriteFile(ctx, dir, "gen1", []byte("gen1"), false, mtime, []byte("cmdhash1"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hashFS.Flush(ctx, dir, []string{"gen1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := journalSize(); got == 0 {
+		t.Errorf("journal size=0 after WriteFile; want >0")
+	}
+	err = hashFS.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint=%v; want nil", err)
+	}
+	if _, err := os.Stat(opts.StateFile); err != nil {
+		t.Errorf("state file is not saved: %v", err)
+	}
+	if got := journalSize(); got != 0 {
+		t.Errorf("journal size=%d after Checkpoint; want 0", got)
+	}
+
+	err = hashFS.WriteFile(ctx, dir, "gen2", []byte("gen2"), false, mtime, []byte("cmdhash2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := journalSize()
+	if size == 0 {
+		t.Errorf("journal size=0 after WriteFile; want >0")
+	}
+
+	// edit generated file manually.
+	err = os.WriteFile(filepath.Join(dir, "gen1"), []byte("edited"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hashFS.Refresh(ctx, dir)
+	if err != nil {
+		t.Fatalf("Refresh=%v; want nil", err)
+	}
+	if got := hashFS.TaintedFiles(); len(got) != 1 {
+		t.Errorf("TaintedFiles=%q; want 1 file", got)
+	}
+
+	// state is not saved for tainted files, so journal should be kept.
+	err = hashFS.Checkpoint(ctx)
+	if err != nil {
+		t.Fatalf("Checkpoint=%v; want nil", err)
+	}
+	if got := journalSize(); got != size {
+		t.Errorf("journal size=%d after Checkpoint without save; want %d", got, size)
+	}
+
+	// tainted file was kept with its mtime, so it is not
+	// tainted in next build.
+	err = hashFS.Refresh(ctx, dir)
+	if err != nil {
+		t.Fatalf("Refresh=%v; want nil", err)
+	}
+	if got := hashFS.TaintedFiles(); len(got) != 0 {
+		t.Errorf("TaintedFiles=%q; want no files", got)
+	}
+}
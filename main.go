@@ -24,6 +24,8 @@ import (
 	"go.chromium.org/build/siso/hashfs/osfs"
 	"go.chromium.org/build/siso/subcmd/alex313031"
 	"go.chromium.org/build/siso/subcmd/auth"
+	"go.chromium.org/build/siso/subcmd/cachecmd"
+	"go.chromium.org/build/siso/subcmd/configcmd"
 	"go.chromium.org/build/siso/subcmd/fetch"
 	"go.chromium.org/build/siso/subcmd/fscmd"
 	"go.chromium.org/build/siso/subcmd/isolate"
@@ -272,6 +274,7 @@ Use "siso flags" to display all flags.
 	subcommands.Register(isolate.Cmd(authOpts), "reapi")
 	subcommands.Register(proxy.Cmd(authOpts), "reapi")
 
+	subcommands.Register(cachecmd.Cmd(authOpts), "investigation")
 	subcommands.Register(fscmd.Cmd(authOpts), "investigation")
 	subcommands.Register(metricscmd.Cmd(), "investigation")
 	subcommands.Register(ps.Cmd(), "investigation")
@@ -284,6 +287,7 @@ Use "siso flags" to display all flags.
 	subcommands.Register(auth.LogoutCmd(authOpts), "auth")
 
 	subcommands.Register(ninjafrontend.Cmd(), "debugging")
+	subcommands.Register(configcmd.Cmd(), "debugging")
 	subcommands.Register(scandeps.Cmd(), "debugging")
 
 	subcommands.Register(osfs.HelperCmd(), "internal-helper")
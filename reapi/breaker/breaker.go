@@ -0,0 +1,330 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package breaker provides circuit breaker for remote APIs.
+//
+// A breaker tracks error rates and latencies of remote API calls
+// in a sliding window. When the remote service looks unhealthy,
+// the circuit trips (open), and callers should stop sending new
+// requests, e.g. run steps locally rather than burning through
+// retry backoff for each step. While open, periodic probe
+// requests check the service, and the circuit closes again when
+// a probe succeeds.
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// State is a state of circuit breaker.
+type State int32
+
+const (
+	// Closed is normal state. Requests are allowed.
+	Closed State = iota
+	// Open is tripped state. Requests should not be sent.
+	Open
+	// HalfOpen is a state while probing.
+	// Requests other than the probe should not be sent.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("state(%d)", int32(s))
+}
+
+// Options is options of circuit breaker.
+type Options struct {
+	// Window is the sliding window to compute error rate and latency.
+	Window time.Duration
+	// MinRequests is minimum number of requests in the window
+	// to trip the circuit.
+	MinRequests int
+	// FailureRatio is ratio of failed requests in the window
+	// to trip the circuit.
+	FailureRatio float64
+	// SlowThreshold is latency to consider a request slow.
+	// Zero disables latency check.
+	SlowThreshold time.Duration
+	// SlowRatio is ratio of slow requests in the window
+	// to trip the circuit.
+	SlowRatio float64
+	// ProbeInterval is initial interval to send probe requests
+	// while the circuit is open.
+	// It is doubled on each probe failure up to MaxProbeInterval.
+	ProbeInterval    time.Duration
+	MaxProbeInterval time.Duration
+}
+
+// DefaultOptions returns default options of circuit breaker.
+func DefaultOptions() Options {
+	return Options{
+		Window:           1 * time.Minute,
+		MinRequests:      20,
+		FailureRatio:     0.5,
+		SlowThreshold:    10 * time.Second,
+		SlowRatio:        0.5,
+		ProbeInterval:    30 * time.Second,
+		MaxProbeInterval: 5 * time.Minute,
+	}
+}
+
+// Transition is a state transition of circuit breaker.
+type Transition struct {
+	Name   string
+	From   State
+	To     State
+	Time   time.Time
+	Reason string
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%s: %s -> %s: %s", t.Name, t.From, t.To, t.Reason)
+}
+
+// numBuckets is number of buckets in the sliding window.
+const numBuckets = 60
+
+type bucket struct {
+	epoch  int64
+	total  int
+	failed int
+	slow   int
+}
+
+// Breaker is a circuit breaker.
+type Breaker struct {
+	name string
+	opts Options
+	now  func() time.Time
+
+	mu            sync.Mutex
+	state         State
+	buckets       [numBuckets]bucket
+	probeInterval time.Duration
+	nextProbe     time.Time
+	lastErr       error
+
+	subscribers map[int]func(Transition)
+	nextID      int
+}
+
+// New creates new circuit breaker.
+func New(name string, opts Options) *Breaker {
+	return &Breaker{
+		name:        name,
+		opts:        opts,
+		now:         time.Now,
+		subscribers: make(map[int]func(Transition)),
+	}
+}
+
+// Name returns name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State returns current state of the breaker.
+// nil breaker is always closed.
+func (b *Breaker) State() State {
+	if b == nil {
+		return Closed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether new requests are allowed.
+func (b *Breaker) Allow() bool {
+	return b.State() == Closed
+}
+
+// Subscribe registers f to be called on state transitions.
+// It returns a function to unsubscribe.
+func (b *Breaker) Subscribe(f func(Transition)) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = f
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers, id)
+	}
+}
+
+// IsUnhealthy reports whether err indicates the remote service
+// is unhealthy, rather than a request specific error
+// (e.g. not found, invalid argument) or canceled by caller.
+// Client side ctx deadline (e.g. step's timeout) is not counted,
+// as it doesn't mean the service is unhealthy.
+func IsUnhealthy(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable,
+		codes.DeadlineExceeded,
+		codes.ResourceExhausted,
+		codes.Internal,
+		codes.Unknown:
+		return true
+	}
+	return false
+}
+
+// Record records result of a request.
+// latency is zero if it is not measured, e.g. streaming calls.
+// It is ignored unless the circuit is closed, as the result is
+// for a request sent before the circuit tripped.
+func (b *Breaker) Record(err error, latency time.Duration) {
+	if b == nil {
+		return
+	}
+	var ts []Transition
+	b.mu.Lock()
+	if b.state != Closed {
+		b.mu.Unlock()
+		return
+	}
+	now := b.now()
+	gran := max(b.opts.Window/numBuckets, time.Millisecond)
+	epoch := now.UnixNano() / int64(gran)
+	bk := &b.buckets[epoch%numBuckets]
+	if bk.epoch != epoch {
+		*bk = bucket{epoch: epoch}
+	}
+	bk.total++
+	failed := IsUnhealthy(err)
+	if failed {
+		bk.failed++
+		b.lastErr = err
+	}
+	slow := b.opts.SlowThreshold > 0 && latency >= b.opts.SlowThreshold
+	if slow {
+		bk.slow++
+	}
+	if failed || slow {
+		var total, nfailed, nslow int
+		for _, bk := range b.buckets {
+			if epoch-bk.epoch >= numBuckets {
+				continue
+			}
+			total += bk.total
+			nfailed += bk.failed
+			nslow += bk.slow
+		}
+		var reason string
+		switch {
+		case total < b.opts.MinRequests:
+		case float64(nfailed) >= float64(total)*b.opts.FailureRatio:
+			reason = fmt.Sprintf("%d/%d requests failed in %s: %v", nfailed, total, b.opts.Window, b.lastErr)
+		case b.opts.SlowThreshold > 0 && float64(nslow) >= float64(total)*b.opts.SlowRatio:
+			reason = fmt.Sprintf("%d/%d requests slower than %s in %s", nslow, total, b.opts.SlowThreshold, b.opts.Window)
+		}
+		if reason != "" {
+			b.probeInterval = b.opts.ProbeInterval
+			ts = b.transitLocked(Open, now, reason)
+		}
+	}
+	b.mu.Unlock()
+	b.notify(ts)
+}
+
+// ProbeDue reports whether it is time to send a probe request.
+// If true, the breaker becomes half-open, and caller must call
+// ProbeDone with the result of the probe request.
+func (b *Breaker) ProbeDue() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	now := b.now()
+	if b.state != Open || now.Before(b.nextProbe) {
+		b.mu.Unlock()
+		return false
+	}
+	ts := b.transitLocked(HalfOpen, now, "probe")
+	b.mu.Unlock()
+	b.notify(ts)
+	return true
+}
+
+// ProbeDone records result of the probe request.
+// The circuit closes if err is nil, or opens again otherwise.
+func (b *Breaker) ProbeDone(err error) {
+	if b == nil {
+		return
+	}
+	var ts []Transition
+	b.mu.Lock()
+	if b.state == HalfOpen {
+		now := b.now()
+		if err == nil {
+			ts = b.transitLocked(Closed, now, "probe succeeded")
+		} else {
+			b.probeInterval = min(b.probeInterval*2, b.opts.MaxProbeInterval)
+			ts = b.transitLocked(Open, now, fmt.Sprintf("probe failed: %v", err))
+		}
+	}
+	b.mu.Unlock()
+	b.notify(ts)
+}
+
+// transitLocked changes state to s, and returns transition to notify.
+func (b *Breaker) transitLocked(s State, now time.Time, reason string) []Transition {
+	t := Transition{
+		Name:   b.name,
+		From:   b.state,
+		To:     s,
+		Time:   now,
+		Reason: reason,
+	}
+	b.state = s
+	switch s {
+	case Open:
+		b.nextProbe = now.Add(b.probeInterval)
+	case Closed:
+		b.buckets = [numBuckets]bucket{}
+		b.lastErr = nil
+	}
+	return []Transition{t}
+}
+
+func (b *Breaker) notify(ts []Transition) {
+	if len(ts) == 0 {
+		return
+	}
+	b.mu.Lock()
+	subscribers := make([]func(Transition), 0, len(b.subscribers))
+	for _, f := range b.subscribers {
+		subscribers = append(subscribers, f)
+	}
+	b.mu.Unlock()
+	for _, t := range ts {
+		for _, f := range subscribers {
+			f(t)
+		}
+	}
+}
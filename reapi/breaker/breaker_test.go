@@ -0,0 +1,205 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(t *testing.T) (*Breaker, *fakeClock, *[]Transition) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	b := New("test", Options{
+		Window:           time.Minute,
+		MinRequests:      4,
+		FailureRatio:     0.5,
+		SlowThreshold:    10 * time.Second,
+		SlowRatio:        0.5,
+		ProbeInterval:    30 * time.Second,
+		MaxProbeInterval: time.Minute,
+	})
+	b.now = clock.now
+	var ts []Transition
+	unsubscribe := b.Subscribe(func(t Transition) {
+		ts = append(ts, t)
+	})
+	t.Cleanup(unsubscribe)
+	return b, clock, &ts
+}
+
+func TestIsUnhealthy(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: status.Error(codes.NotFound, "not found"), want: false},
+		{err: status.Error(codes.InvalidArgument, "bad"), want: false},
+		{err: status.Error(codes.Canceled, "canceled"), want: false},
+		{err: context.Canceled, want: false},
+		{err: status.Error(codes.Unavailable, "unavailable"), want: true},
+		{err: status.Error(codes.DeadlineExceeded, "deadline"), want: true},
+		{err: status.Error(codes.ResourceExhausted, "quota"), want: true},
+		{err: context.DeadlineExceeded, want: false},
+		{err: fmt.Errorf("remote exec timeout: %w", context.DeadlineExceeded), want: false},
+		{err: errors.New("unknown"), want: true},
+	} {
+		if got := IsUnhealthy(tc.err); got != tc.want {
+			t.Errorf("IsUnhealthy(%v)=%t; want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestTripAndRecover(t *testing.T) {
+	b, clock, ts := newTestBreaker(t)
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	b.Record(nil, time.Second)
+	b.Record(nil, time.Second)
+	b.Record(unavailable, time.Second)
+	if got := b.State(); got != Closed {
+		t.Fatalf("state=%v; want %v (below MinRequests)", got, Closed)
+	}
+	b.Record(unavailable, time.Second)
+	if got := b.State(); got != Open {
+		t.Fatalf("state=%v; want %v", got, Open)
+	}
+	if b.Allow() {
+		t.Errorf("Allow()=true; want false while open")
+	}
+
+	// results of in-flight requests are ignored while open.
+	b.Record(nil, time.Second)
+	if got := b.State(); got != Open {
+		t.Errorf("state=%v; want %v", got, Open)
+	}
+
+	if b.ProbeDue() {
+		t.Fatalf("ProbeDue()=true; want false before probe interval")
+	}
+	clock.advance(30 * time.Second)
+	if !b.ProbeDue() {
+		t.Fatalf("ProbeDue()=false; want true after probe interval")
+	}
+	if got := b.State(); got != HalfOpen {
+		t.Fatalf("state=%v; want %v", got, HalfOpen)
+	}
+	b.ProbeDone(nil)
+	if got := b.State(); got != Closed {
+		t.Fatalf("state=%v; want %v", got, Closed)
+	}
+
+	var got []State
+	for _, tr := range *ts {
+		if tr.Name != "test" {
+			t.Errorf("transition name=%q; want %q", tr.Name, "test")
+		}
+		got = append(got, tr.To)
+	}
+	want := []State{Open, HalfOpen, Closed}
+	if len(got) != len(want) {
+		t.Fatalf("transitions=%v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transitions[%d]=%v; want %v", i, got[i], want[i])
+		}
+	}
+
+	// window is reset after recovery.
+	b.Record(unavailable, time.Second)
+	if got := b.State(); got != Closed {
+		t.Errorf("state=%v; want %v after recovery", got, Closed)
+	}
+}
+
+func TestTripBySlow(t *testing.T) {
+	b, _, _ := newTestBreaker(t)
+	b.Record(nil, time.Second)
+	b.Record(nil, time.Second)
+	b.Record(nil, 20*time.Second)
+	b.Record(nil, 20*time.Second)
+	if got := b.State(); got != Open {
+		t.Errorf("state=%v; want %v", got, Open)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	b, clock, _ := newTestBreaker(t)
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	b.Record(unavailable, time.Second)
+	b.Record(unavailable, time.Second)
+	b.Record(unavailable, time.Second)
+	clock.advance(2 * time.Minute)
+	// old failures are out of the window.
+	b.Record(nil, time.Second)
+	b.Record(nil, time.Second)
+	b.Record(nil, time.Second)
+	b.Record(unavailable, time.Second)
+	if got := b.State(); got != Closed {
+		t.Errorf("state=%v; want %v", got, Closed)
+	}
+}
+
+func TestProbeBackoff(t *testing.T) {
+	b, clock, _ := newTestBreaker(t)
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	for range 4 {
+		b.Record(unavailable, time.Second)
+	}
+	if got := b.State(); got != Open {
+		t.Fatalf("state=%v; want %v", got, Open)
+	}
+	clock.advance(30 * time.Second)
+	if !b.ProbeDue() {
+		t.Fatalf("ProbeDue()=false; want true")
+	}
+	b.ProbeDone(unavailable)
+	if got := b.State(); got != Open {
+		t.Fatalf("state=%v; want %v", got, Open)
+	}
+	// probe interval is doubled.
+	clock.advance(30 * time.Second)
+	if b.ProbeDue() {
+		t.Errorf("ProbeDue()=true; want false before doubled interval")
+	}
+	clock.advance(30 * time.Second)
+	if !b.ProbeDue() {
+		t.Fatalf("ProbeDue()=false; want true after doubled interval")
+	}
+	b.ProbeDone(unavailable)
+	// capped by MaxProbeInterval.
+	clock.advance(time.Minute)
+	if !b.ProbeDue() {
+		t.Errorf("ProbeDue()=false; want true after max probe interval")
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	b.Record(errors.New("error"), time.Second)
+	if !b.Allow() {
+		t.Errorf("Allow()=false; want true for nil breaker")
+	}
+	if b.ProbeDue() {
+		t.Errorf("ProbeDue()=true; want false for nil breaker")
+	}
+	b.ProbeDone(nil)
+}
@@ -11,15 +11,19 @@ import (
 
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
 	bpb "google.golang.org/genproto/googleapis/bytestream"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"go.chromium.org/build/siso/o11y/clog"
 	"go.chromium.org/build/siso/reapi/bytestreamio"
 	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/signature"
 )
 
 // CacheStore provides a thin wrapper around REAPI client that gets and uploads blobs and action results.
 type CacheStore struct {
-	client *Client
+	client   *Client
+	verifier *signature.Verifier
 }
 
 // CacheStore returns cache store of the client.
@@ -29,13 +33,33 @@ func (c *Client) CacheStore() CacheStore {
 	}
 }
 
+// WithVerifier returns cache store that verifies action results
+// with the verifier. Unverifiable action results are treated as
+// not found.
+func (c CacheStore) WithVerifier(v *signature.Verifier) CacheStore {
+	c.verifier = v
+	return c
+}
+
 func (c CacheStore) String() string {
 	return fmt.Sprintf("cachestore:reapi addr:%s instance:%s", c.client.opt.Address, c.client.opt.Instance)
 }
 
 // GetActionResult gets action result for the action identified by the digest.
 func (c CacheStore) GetActionResult(ctx context.Context, d digest.Digest) (*rpb.ActionResult, error) {
-	return c.client.GetActionResult(ctx, d)
+	if !c.client.CacheAllowed() {
+		return nil, status.Errorf(codes.NotFound, "action cache circuit is open")
+	}
+	ar, err := c.client.GetActionResult(ctx, d)
+	if err != nil {
+		return ar, err
+	}
+	err = c.verifier.Verify(d, ar)
+	if err != nil {
+		clog.Warningf(ctx, "unverifiable action result %s: %v", d, err)
+		return nil, status.Errorf(codes.NotFound, "unverifiable action result %s: %v", d, err)
+	}
+	return ar, nil
 }
 
 // SetActionResult sets action result for the action identified by the digest.
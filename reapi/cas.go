@@ -150,7 +150,8 @@ func (c *Client) newDecoder(r io.Reader, d digest.Digest) (io.ReadCloser, error)
 
 // Get fetches the content of blob from CAS by digest.
 // For small blobs, it uses BatchReadBlobs.
-// For large blobs, it uses Read method of the ByteStream API
+// For large blobs, it uses SplitBlob API if server supports it,
+// or Read method of the ByteStream API.
 func (c *Client) Get(ctx context.Context, d digest.Digest, name string) ([]byte, error) {
 	if c == nil {
 		return nil, fmt.Errorf("reapi is not configured")
@@ -163,6 +164,18 @@ func (c *Client) Get(ctx context.Context, d digest.Digest, name string) ([]byte,
 	defer span.Close(nil)
 	span.SetAttr("sizebytes", d.SizeBytes)
 
+	if c.useBlobSplit(d) {
+		buf, err := c.getWithSplitBlob(ctx, d, name)
+		if err == nil {
+			return buf, nil
+		}
+		clog.Warningf(ctx, "failed to get %s for %s by split blob. fallback to bytestream: %v", d, name, err)
+	}
+	return c.get(ctx, d, name)
+}
+
+// get fetches the content of blob by BatchReadBlobs or ByteStream API.
+func (c *Client) get(ctx context.Context, d digest.Digest, name string) ([]byte, error) {
 	if d.SizeBytes < bytestreamReadThreshold {
 		return c.getWithBatchReadBlobs(ctx, d, name)
 	}
@@ -285,6 +298,18 @@ func (c *Client) Missing(ctx context.Context, blobs []digest.Digest) ([]digest.D
 
 // UploadAll uploads all blobs specified in ds that are still missing in the CAS.
 func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded int, err error) {
+	return c.uploadAll(ctx, ds, false)
+}
+
+// UploadExecInputs is like UploadAll, but skips blobs confirmed to be
+// present in the presence cache.
+// It should be used only for inputs of Execute, which reports missing
+// blobs, so the caller can Forget them and upload again.
+func (c *Client) UploadExecInputs(ctx context.Context, ds *digest.Store) (numUploaded int, err error) {
+	return c.uploadAll(ctx, ds, true)
+}
+
+func (c *Client) uploadAll(ctx context.Context, ds *digest.Store, usePresence bool) (numUploaded int, err error) {
 	if c.casConn == nil {
 		return 0, status.Error(codes.FailedPrecondition, "conn is not configured")
 	}
@@ -298,7 +323,19 @@ func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded i
 	newBlobs := make(map[digest.Digest]*uploadOp)
 	pendingBlobs := make(map[digest.Digest]*uploadOp)
 	skippedBlobs := 0
+	// Blobs confirmed to be present in the CAS in previous builds
+	// don't need to be asked to the CAS.
+	// They are not recorded in knownDigests, so other callers
+	// will still check them.
+	cachedBlobs := 0
+	now := time.Now()
 	for _, d := range blobs {
+		if usePresence && c.presence.present(d, now) {
+			if _, ok := c.knownDigests.Load(d); !ok {
+				cachedBlobs++
+				continue
+			}
+		}
 		uop, loaded := c.knownDigests.LoadOrStore(d, newUploadOp())
 		if loaded {
 			switch v := uop.(type) {
@@ -325,6 +362,7 @@ func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded i
 			if uop.err == nil {
 				// uop.done(nil) was called
 				c.knownDigests.CompareAndSwap(d, uop, true)
+				c.presence.confirm(time.Now(), d)
 				continue
 			}
 			var s string
@@ -347,8 +385,8 @@ func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded i
 			// forget this digest, so next will try to upload again.
 			c.knownDigests.CompareAndDelete(d, uop)
 		}
-		clog.Infof(ctx, "upload all: blobs=%d -> {uploaded=%d, found=%d, pending=%d, skipped=%d}, timing: {find_missing=%s, upload=%s, wait_pending=%s}: %v",
-			len(blobs), len(newBlobs), len(foundBlobs), len(pendingBlobs), skippedBlobs,
+		clog.Infof(ctx, "upload all: blobs=%d -> {uploaded=%d, found=%d, pending=%d, skipped=%d, cached=%d}, timing: {find_missing=%s, upload=%s, wait_pending=%s}: %v",
+			len(blobs), len(newBlobs), len(foundBlobs), len(pendingBlobs), skippedBlobs, cachedBlobs,
 			durFindMissing.Round(time.Microsecond),
 			durUpload.Round(time.Microsecond),
 			durWaitPending.Round(time.Microsecond),
@@ -358,6 +396,7 @@ func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded i
 	span.SetAttr("upload", len(newBlobs))
 	span.SetAttr("pending", len(pendingBlobs))
 	span.SetAttr("skipped", skippedBlobs)
+	span.SetAttr("cached", cachedBlobs)
 
 	// For all "new" blobs, use FindMissingBlobs to ask the remote CAS which of them are
 	// really still missing - they might already be present and we just don't know about it yet.
@@ -388,6 +427,7 @@ func (c *Client) UploadAll(ctx context.Context, ds *digest.Store) (numUploaded i
 			newBlobs[d].done(nil)
 			delete(newBlobs, d)
 		}
+		c.presence.confirm(time.Now(), slices.Collect(maps.Keys(foundBlobs))...)
 		span.SetAttr("missing", len(missingBlobs))
 		span.SetAttr("founds", len(foundBlobs))
 		durFindMissing = time.Since(t)
@@ -461,6 +501,14 @@ func (c *Client) upload(ctx context.Context, ds *digest.Store, blobs []digest.Di
 		missing.Blobs = missingBlobs
 	}
 
+	// Upload large blobs with SpliceBlob API if server supports it.
+	// Blobs failed to splice will be uploaded with ByteStream API.
+	if len(larges) > 0 {
+		var missingBlobs []missingBlob
+		larges, missingBlobs = c.uploadWithSpliceBlob(ctx, larges, uploads, ds)
+		missing.Blobs = append(missing.Blobs, missingBlobs...)
+	}
+
 	// Upload large blobs with ByteStream API.
 	if len(larges) > 0 {
 		missingBlobs := c.uploadWithByteStream(ctx, larges, uploads, ds)
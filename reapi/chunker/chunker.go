@@ -0,0 +1,137 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package chunker provides content-defined chunking for
+// SplitBlob/SpliceBlob API.
+//
+// It uses gear hash based chunking with normalized chunk size, as FastCDC.
+// https://www.usenix.org/conference/atc16/technical-sessions/presentation/xia
+//
+// Chunk boundaries are decided by content, so small change in a blob
+// only changes chunks around the change, and other chunks can be
+// reused from CAS or local cache.
+package chunker
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// MinSize is minimum chunk size, except the last chunk.
+	MinSize = 128 * 1024
+
+	// AvgSize is expected average chunk size.
+	AvgSize = 512 * 1024
+
+	// MaxSize is maximum chunk size.
+	// It is less than bytestream read threshold,
+	// so chunks can be fetched by BatchReadBlobs.
+	MaxSize = 2*1024*1024 - 64*1024
+)
+
+const (
+	// maskS is used before AvgSize, to make cut point less likely.
+	// It uses higher bits as they depend on the last 64 bytes.
+	maskS = uint64(1<<21-1) << (64 - 21)
+	// maskL is used after AvgSize, to make cut point more likely.
+	maskL = uint64(1<<17-1) << (64 - 17)
+)
+
+// gear is gear hash table. It must be stable across siso versions
+// and platforms to reuse chunks, so it is generated by splitmix64
+// with fixed seed, rather than math/rand.
+var gear = func() [256]uint64 {
+	var t [256]uint64
+	x := uint64(0x5349534f) // "SISO"
+	for i := range t {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+}()
+
+// Split splits data into content-defined chunks.
+// Returned chunks share the underlying array with data.
+func Split(data []byte) [][]byte {
+	var chunks [][]byte
+	for len(data) > 0 {
+		n := cut(data)
+		chunks = append(chunks, data[:n:n])
+		data = data[n:]
+	}
+	return chunks
+}
+
+// Chunker splits data read from io.Reader into content-defined chunks.
+// It holds at most MaxSize bytes in memory, so large blobs can be
+// split without reading all content in memory.
+// Chunks are the same as Split for the same data.
+type Chunker struct {
+	r   io.Reader
+	buf []byte
+	// buf[start:end] is data not returned yet.
+	start, end int
+	eof        bool
+}
+
+// New returns a new Chunker that reads data from r.
+func New(r io.Reader) *Chunker {
+	return &Chunker{
+		r:   r,
+		buf: make([]byte, MaxSize),
+	}
+}
+
+// Next returns the next chunk. It returns io.EOF if no more chunks.
+// Returned chunk is valid until the next call of Next.
+func (c *Chunker) Next() ([]byte, error) {
+	n := copy(c.buf, c.buf[c.start:c.end])
+	c.start, c.end = 0, n
+	for !c.eof && c.end < len(c.buf) {
+		n, err := c.r.Read(c.buf[c.end:])
+		c.end += n
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.end == 0 {
+		return nil, io.EOF
+	}
+	n = cut(c.buf[:c.end])
+	c.start = n
+	return c.buf[:n:n], nil
+}
+
+// cut returns the size of the first chunk in data.
+func cut(data []byte) int {
+	n := len(data)
+	if n <= MinSize {
+		return n
+	}
+	n = min(n, MaxSize)
+	normal := min(n, AvgSize)
+	var h uint64
+	i := MinSize
+	for ; i < normal; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		h = (h << 1) + gear[data[i]]
+		if h&maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
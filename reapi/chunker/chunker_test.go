@@ -0,0 +1,93 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package chunker_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+
+	"go.chromium.org/build/siso/reapi/chunker"
+)
+
+func TestSplit(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 16*1024*1024)
+	r.Read(data)
+
+	chunks := chunker.Split(data)
+	if got := bytes.Join(chunks, nil); !bytes.Equal(got, data) {
+		t.Fatalf("joined chunks differ from data")
+	}
+	for i, c := range chunks {
+		if len(c) > chunker.MaxSize {
+			t.Errorf("chunks[%d] size=%d > %d", i, len(c), chunker.MaxSize)
+		}
+		if i < len(chunks)-1 && len(c) < chunker.MinSize {
+			t.Errorf("chunks[%d] size=%d < %d", i, len(c), chunker.MinSize)
+		}
+	}
+
+	// insert a few bytes in the middle.
+	// chunks other than the ones around the change should be the same.
+	mid := len(data) / 2
+	modified := append(append(append([]byte(nil), data[:mid]...), "siso"...), data[mid:]...)
+	mchunks := chunker.Split(modified)
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		seen[string(c)] = true
+	}
+	var changed int
+	for _, c := range mchunks {
+		if !seen[string(c)] {
+			changed++
+		}
+	}
+	if changed == 0 || changed > 2 {
+		t.Errorf("changed chunks=%d out of %d; want 1 or 2", changed, len(mchunks))
+	}
+}
+
+func TestSplit_Small(t *testing.T) {
+	data := []byte("small data")
+	chunks := chunker.Split(data)
+	if len(chunks) != 1 || !bytes.Equal(chunks[0], data) {
+		t.Errorf("Split(%q)=%q; want single chunk", data, chunks)
+	}
+	if chunks := chunker.Split(nil); len(chunks) != 0 {
+		t.Errorf("Split(nil)=%q; want no chunks", chunks)
+	}
+}
+
+func TestChunker(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	data := make([]byte, 8*1024*1024+123)
+	r.Read(data)
+
+	want := chunker.Split(data)
+	ck := chunker.New(iotest.HalfReader(bytes.NewReader(data)))
+	var got [][]byte
+	for {
+		chunk, err := ck.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next()=%v", err)
+		}
+		got = append(got, bytes.Clone(chunk))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Chunker returns %d chunks; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("chunks[%d] differs: size=%d; want %d", i, len(got[i]), len(want[i]))
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package reapi
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/reapi/breaker"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+// circuitConn is a grpc conn that records health of calls in
+// circuit breakers.
+type circuitConn struct {
+	grpcClientConn
+	c *Client
+}
+
+// breakerFor returns circuit breaker for the method.
+// Execution service is tracked by exec breaker, and
+// ActionCache, CAS and ByteStream are tracked by cache breaker.
+func (c *Client) breakerFor(method string) *breaker.Breaker {
+	switch {
+	case strings.HasPrefix(method, "/build.bazel.remote.execution.v2.Execution/"):
+		return c.execBreaker
+	case strings.HasPrefix(method, "/build.bazel.remote.execution.v2.Capabilities/"):
+		return nil
+	}
+	return c.cacheBreaker
+}
+
+// latencyExempt reports whether latency of the method depends on
+// size of blobs, so it should not be used to detect slow service.
+// ByteStream calls are streaming, whose latency is not recorded.
+func latencyExempt(method string) bool {
+	switch method {
+	case rpb.ContentAddressableStorage_BatchUpdateBlobs_FullMethodName,
+		rpb.ContentAddressableStorage_BatchReadBlobs_FullMethodName,
+		rpb.ContentAddressableStorage_SpliceBlob_FullMethodName:
+		return true
+	}
+	return false
+}
+
+func (cc circuitConn) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := cc.grpcClientConn.Invoke(ctx, method, args, reply, opts...)
+	if ctx.Err() != nil {
+		// canceled or deadline exceeded on client side.
+		// it is not the status returned by the service.
+		return err
+	}
+	latency := time.Since(start)
+	if latencyExempt(method) {
+		latency = 0
+	}
+	cc.c.breakerFor(method).Record(err, latency)
+	return err
+}
+
+func (cc circuitConn) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	b := cc.c.breakerFor(method)
+	s, err := cc.grpcClientConn.NewStream(ctx, desc, method, opts...)
+	if err != nil {
+		if ctx.Err() == nil {
+			b.Record(err, 0)
+		}
+		return nil, err
+	}
+	return &circuitStream{ClientStream: s, ctx: ctx, b: b}, nil
+}
+
+// circuitStream records health of streaming call when it finishes.
+// Latency is not recorded, as it depends on the stream
+// (e.g. Execute waits for action completion).
+// The stream finished by the client side ctx (e.g. cmd's timeout
+// on Execute) is not recorded.
+type circuitStream struct {
+	grpc.ClientStream
+	// ctx is the caller's ctx. Context() of the stream is
+	// canceled when the stream finishes.
+	ctx  context.Context
+	b    *breaker.Breaker
+	once sync.Once
+}
+
+func (s *circuitStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			switch {
+			case err == io.EOF:
+				s.b.Record(nil, 0)
+			case s.ctx.Err() != nil:
+			default:
+				s.b.Record(err, 0)
+			}
+		})
+	}
+	return err
+}
+
+// enableCircuitBreakers enables circuit breakers on the client,
+// and starts probe loop while the circuit is open.
+func (c *Client) enableCircuitBreakers(ctx context.Context) {
+	opts := breaker.DefaultOptions()
+	c.execBreaker = breaker.New("exec", opts)
+	c.cacheBreaker = breaker.New("cache", opts)
+	rawConn, rawCASConn := c.conn, c.casConn
+	c.conn = circuitConn{grpcClientConn: rawConn, c: c}
+	c.casConn = circuitConn{grpcClientConn: rawCASConn, c: c}
+	c.quit = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.quit:
+				return
+			case <-ticker.C:
+			}
+			if c.execBreaker.ProbeDue() {
+				go func() {
+					err := probeExec(ctx, rawConn, c.opt.Instance)
+					clog.Infof(ctx, "circuit probe exec: %v", err)
+					c.execBreaker.ProbeDone(err)
+				}()
+			}
+			if c.cacheBreaker.ProbeDue() {
+				go func() {
+					err := probeCache(ctx, rawCASConn, c.opt.Instance)
+					clog.Infof(ctx, "circuit probe cache: %v", err)
+					c.cacheBreaker.ProbeDone(err)
+				}()
+			}
+		}
+	}()
+}
+
+// probeTimeout is timeout of a probe request.
+const probeTimeout = 10 * time.Second
+
+// probeExec checks the execution service is available by
+// GetCapabilities, which is served by the same frontend.
+func probeExec(ctx context.Context, conn grpcClientConn, instance string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	_, err := rpb.NewCapabilitiesClient(conn).GetCapabilities(ctx, &rpb.GetCapabilitiesRequest{
+		InstanceName: instance,
+	})
+	return err
+}
+
+// probeCache checks the action cache is available by
+// GetActionResult for an action that won't exist.
+func probeCache(ctx context.Context, conn grpcClientConn, instance string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	d := digest.FromBytes("probe", []byte("siso circuit breaker probe")).Digest()
+	_, err := rpb.NewActionCacheClient(conn).GetActionResult(ctx, &rpb.GetActionResultRequest{
+		InstanceName: instance,
+		ActionDigest: d.Proto(),
+	})
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+// CircuitBreakers returns circuit breakers of the client.
+// It returns nil if circuit breakers are disabled.
+func (c *Client) CircuitBreakers() []*breaker.Breaker {
+	if c == nil || c.execBreaker == nil {
+		return nil
+	}
+	return []*breaker.Breaker{c.execBreaker, c.cacheBreaker}
+}
+
+// ExecAllowed reports whether remote execution is allowed by
+// circuit breakers. Remote execution needs both execution and
+// CAS services.
+func (c *Client) ExecAllowed() bool {
+	if c == nil {
+		return true
+	}
+	return c.execBreaker.Allow() && c.cacheBreaker.Allow()
+}
+
+// CacheAllowed reports whether action cache lookup is allowed by
+// circuit breaker.
+func (c *Client) CacheAllowed() bool {
+	if c == nil {
+		return true
+	}
+	return c.cacheBreaker.Allow()
+}
@@ -146,6 +146,11 @@ func (d Data) Open(ctx context.Context) (io.ReadCloser, error) {
 	return d.source.Open(ctx)
 }
 
+// Source returns the data source.
+func (d Data) Source() Source {
+	return d.source
+}
+
 // String returns the digest and the source in string format.
 func (d Data) String() string {
 	return fmt.Sprintf("%v %v", d.digest, d.source)
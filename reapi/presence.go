@@ -0,0 +1,277 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package reapi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+// presenceCacheMagic is a magic header of presence cache file.
+// the file format is
+//
+//	magic (8 bytes)
+//	key length (uvarint) + key
+//	entries of
+//	  sha256 hash (32 bytes)
+//	  size bytes (uvarint)
+//	  last confirmed unix time in seconds (varint)
+const presenceCacheMagic = "SISOCAS1"
+
+// PresenceCache is an on-disk cache of digests that are confirmed to be
+// present in the CAS, so UploadExecInputs doesn't need to ask the CAS with
+// FindMissingBlobs for digests confirmed within TTL.
+//
+// TTL should be shorter than the CAS retention of the server, as
+// digests that were skipped to check won't extend their lifetime in
+// the CAS. If the CAS evicted a blob within TTL, Execute will fail with
+// missing inputs, and Forget should be called for missing blobs.
+type PresenceCache struct {
+	key string
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[digest.Digest]int64 // digest -> last confirmed unix time
+	updated bool
+}
+
+// NewPresenceCache creates an empty presence cache for key with ttl.
+// key identifies the CAS, e.g. address and instance name.
+func NewPresenceCache(key string, ttl time.Duration) *PresenceCache {
+	return &PresenceCache{
+		key:     key,
+		ttl:     ttl,
+		entries: make(map[digest.Digest]int64),
+	}
+}
+
+// LoadPresenceCache loads presence cache for key with ttl from fname.
+// It returns empty presence cache if fname doesn't exist, or it was
+// saved for other key.
+func LoadPresenceCache(fname, key string, ttl time.Duration) (*PresenceCache, error) {
+	p := NewPresenceCache(key, ttl)
+	buf, err := os.ReadFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	err = p.decode(buf, time.Now())
+	if err != nil {
+		return NewPresenceCache(key, ttl), fmt.Errorf("failed to parse %s: %w", fname, err)
+	}
+	return p, nil
+}
+
+func (p *PresenceCache) decode(buf []byte, now time.Time) error {
+	if !bytes.HasPrefix(buf, []byte(presenceCacheMagic)) {
+		return errors.New("bad magic")
+	}
+	buf = buf[len(presenceCacheMagic):]
+	n, sz := binary.Uvarint(buf)
+	if sz <= 0 || uint64(len(buf)-sz) < n {
+		return errors.New("bad key")
+	}
+	key := string(buf[sz : sz+int(n)])
+	buf = buf[sz+int(n):]
+	if key != p.key {
+		// cache for other CAS.
+		return nil
+	}
+	for len(buf) > 0 {
+		if len(buf) < 32 {
+			return errors.New("truncated hash")
+		}
+		hash := hex.EncodeToString(buf[:32])
+		buf = buf[32:]
+		size, sz := binary.Uvarint(buf)
+		if sz <= 0 {
+			return errors.New("bad size")
+		}
+		buf = buf[sz:]
+		t, sz := binary.Varint(buf)
+		if sz <= 0 {
+			return errors.New("bad time")
+		}
+		buf = buf[sz:]
+		if !p.valid(t, now) {
+			continue
+		}
+		p.entries[digest.Digest{Hash: hash, SizeBytes: int64(size)}] = t
+	}
+	return nil
+}
+
+// Save saves presence cache in fname if updated.
+// Expired entries are not saved.
+func (p *PresenceCache) Save(fname string) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.updated {
+		return nil
+	}
+	now := time.Now()
+	buf := []byte(presenceCacheMagic)
+	buf = binary.AppendUvarint(buf, uint64(len(p.key)))
+	buf = append(buf, p.key...)
+	for d, t := range p.entries {
+		if !p.valid(t, now) {
+			continue
+		}
+		h, err := hex.DecodeString(d.Hash)
+		if err != nil || len(h) != 32 {
+			continue
+		}
+		buf = append(buf, h...)
+		buf = binary.AppendUvarint(buf, uint64(d.SizeBytes))
+		buf = binary.AppendVarint(buf, t)
+	}
+	tmpname := fname + ".tmp"
+	err := os.WriteFile(tmpname, buf, 0644)
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	err = os.Rename(tmpname, fname)
+	if err != nil {
+		return err
+	}
+	p.updated = false
+	return nil
+}
+
+// Len returns number of entries in the presence cache.
+func (p *PresenceCache) Len() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+func (p *PresenceCache) valid(t int64, now time.Time) bool {
+	return now.Sub(time.Unix(t, 0)) < p.ttl
+}
+
+// present reports whether d was confirmed to be present within TTL.
+func (p *PresenceCache) present(d digest.Digest, now time.Time) bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.entries[d]
+	return ok && p.valid(t, now)
+}
+
+// confirm records digests were confirmed to be present at now.
+func (p *PresenceCache) confirm(now time.Time, digests ...digest.Digest) {
+	if p == nil || len(digests) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, d := range digests {
+		p.entries[d] = now.Unix()
+	}
+	p.updated = true
+}
+
+// forget removes digests from the presence cache.
+func (p *PresenceCache) forget(digests ...digest.Digest) {
+	if p == nil || len(digests) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, d := range digests {
+		if _, ok := p.entries[d]; ok {
+			delete(p.entries, d)
+			p.updated = true
+		}
+	}
+}
+
+// LoadPresenceCache loads presence cache for the client's CAS from fname,
+// and uses it in UploadExecInputs.
+// It returns nil if the client is nil or presence cache is disabled.
+func (c *Client) LoadPresenceCache(fname string) (*PresenceCache, error) {
+	if c == nil || c.opt.CASPresenceTTL <= 0 {
+		return nil, nil
+	}
+	addr := c.opt.CASAddress
+	if addr == "" {
+		addr = c.opt.Address
+	}
+	p, err := LoadPresenceCache(fname, addr+"/"+c.opt.Instance, c.opt.CASPresenceTTL)
+	c.presence = p
+	return p, err
+}
+
+// Forget forgets digests known to be present in the CAS,
+// so next UploadExecInputs will check and upload them again.
+// It should be called for missing blobs reported by Execute.
+func (c *Client) Forget(digests []digest.Digest) {
+	if c == nil {
+		return
+	}
+	for _, d := range digests {
+		// don't forget the digest being uploaded.
+		c.knownDigests.CompareAndDelete(d, true)
+	}
+	c.presence.forget(digests...)
+}
+
+// MissingBlobs returns digests of missing blobs reported in
+// FAILED_PRECONDITION error by Execute.
+func MissingBlobs(err error) []digest.Digest {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.FailedPrecondition {
+		return nil
+	}
+	var digests []digest.Digest
+	for _, detail := range st.Details() {
+		pf, ok := detail.(*errdetails.PreconditionFailure)
+		if !ok {
+			continue
+		}
+		for _, v := range pf.GetViolations() {
+			if v.GetType() != "MISSING" {
+				continue
+			}
+			// subject is "blobs/{hash}/{size}".
+			hash, size, ok := strings.Cut(strings.TrimPrefix(v.GetSubject(), "blobs/"), "/")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseInt(size, 10, 64)
+			if err != nil {
+				continue
+			}
+			digests = append(digests, digest.Digest{Hash: hash, SizeBytes: n})
+		}
+	}
+	return digests
+}
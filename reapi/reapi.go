@@ -34,6 +34,7 @@ import (
 	"go.chromium.org/build/siso/auth/cred"
 	"go.chromium.org/build/siso/o11y/clog"
 	"go.chromium.org/build/siso/o11y/iometrics"
+	"go.chromium.org/build/siso/reapi/breaker"
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/version"
 )
@@ -64,6 +65,9 @@ type Option struct {
 	// compressor for ByteStream Read/Write APIs.
 	compressor rpb.Compressor_Value
 
+	// use SplitBlob/SpliceBlob APIs if server supports them and size is bigger than this.
+	ChunkedBlob int64
+
 	// Enables GRPC compression. If enabled, blob-level compression will be
 	// forcibly disabled.
 	EnableGRPCCompression bool
@@ -81,6 +85,15 @@ type Option struct {
 	// default to use high api version advertised by the server
 	// capabilities.
 	REAPIVersion string
+
+	// CircuitBreaker enables circuit breakers to stop sending
+	// new requests while RE API service is unhealthy.
+	CircuitBreaker bool
+
+	// CASPresenceTTL is TTL of digests in the presence cache,
+	// which should be shorter than the CAS retention of the server.
+	// 0 disables the presence cache.
+	CASPresenceTTL time.Duration
 }
 
 // Envs returns environment flags for reapi.
@@ -124,9 +137,13 @@ func (o *Option) RegisterFlags(fs *flag.FlagSet, envs map[string]string) {
 
 	fs.Int64Var(&o.CompressedBlob, o.Prefix+"_compress_blob", 1024, "use compressed blobs if server supports compressed blobs and size is bigger than this. specify 0 to disable blob-level compression."+purpose)
 
+	fs.Int64Var(&o.ChunkedBlob, o.Prefix+"_chunk_blob", 8*1024*1024, "use SplitBlob/SpliceBlob APIs to transfer only changed chunks if server supports them and size is bigger than this. specify 0 to disable."+purpose)
+
 	fs.BoolVar(&o.EnableGRPCCompression, o.Prefix+"_enable_grpc_compression", false, "enable grpc compression.  if enabled, blob-level compression will be forcibly disabled."+purpose)
 
 	fs.BoolVar(&o.KeepExecStream, o.Prefix+"_keep_exec_stream", false, "keep Execute stream open as long as possible")
+	fs.BoolVar(&o.CircuitBreaker, o.Prefix+"_circuit_breaker", true, "stop remote requests while the service is unhealthy, and probe periodically to resume")
+	fs.DurationVar(&o.CASPresenceTTL, o.Prefix+"_cas_presence_ttl", 0, "skip FindMissingBlobs of remote exec inputs for digests confirmed to be present in CAS within this duration. should be shorter than CAS retention of the server. 0 disables"+purpose)
 
 	fs.IntVar(&o.ConnPool, o.Prefix+"_grpc_conn_pool", 25, "grpc connection pool")
 
@@ -247,7 +264,20 @@ type Client struct {
 
 	knownDigests sync.Map // key:digest.Digest, value: *uploadOp or true
 
+	// chunkCache stores chunks fetched by SplitBlob API.
+	chunkCache ChunkCache
+
+	// presence caches digests present in the CAS across builds.
+	// nil if disabled.
+	presence *PresenceCache
+
 	m *iometrics.IOMetrics
+
+	// circuit breakers for execution and cache services.
+	// nil if disabled.
+	execBreaker  *breaker.Breaker
+	cacheBreaker *breaker.Breaker
+	quit         chan struct{}
 }
 
 // serviceConfig is gRPC service config for RE API.
@@ -466,6 +496,10 @@ func NewFromConn(ctx context.Context, opt Option, conn, casConn grpcClientConn)
 			clog.Infof(ctx, "compressed-blobs is not supported")
 		}
 	}
+	if opt.ChunkedBlob > 0 {
+		cacheCapa := capa.GetCacheCapabilities()
+		clog.Infof(ctx, "split-blob=%t splice-blob=%t for > %d", cacheCapa.GetBlobSplitSupport(), cacheCapa.GetBlobSpliceSupport(), opt.ChunkedBlob)
+	}
 	var apiVersion *semverpb.SemVer
 	if opt.REAPIVersion != "" {
 		var major, minor int32
@@ -496,11 +530,17 @@ func NewFromConn(ctx context.Context, opt Option, conn, casConn grpcClientConn)
 		m:            iometrics.New("reapi"),
 	}
 	c.knownDigests.Store(digest.Empty, true)
+	if opt.CircuitBreaker {
+		c.enableCircuitBreakers(ctx)
+	}
 	return c, nil
 }
 
 // Close closes the client.
 func (c *Client) Close() error {
+	if c.quit != nil {
+		close(c.quit)
+	}
 	return c.conn.Close()
 }
 
@@ -7,23 +7,35 @@ package reapi_test
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 
 	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/chunker"
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/reapi/reapitest"
+	"go.chromium.org/build/siso/reapi/signature"
 )
 
 type fakeCAS struct {
@@ -243,3 +255,397 @@ func TestUploadAllWithCompression(t *testing.T) {
 		t.Errorf("cl.Get()=_,%v: want _,nil", err)
 	}
 }
+
+type fakeChunkCache struct {
+	mu     sync.Mutex
+	chunks map[digest.Digest][]byte
+}
+
+func (c *fakeChunkCache) GetContent(ctx context.Context, d digest.Digest, _ string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.chunks[d]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "not found")
+	}
+	return b, nil
+}
+
+func (c *fakeChunkCache) SetContent(ctx context.Context, d digest.Digest, _ string, b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chunks[d] = b
+	return nil
+}
+
+func TestBlobSplit(t *testing.T) {
+	ctx := t.Context()
+	fakere := &reapitest.Fake{
+		BlobSplit: true,
+	}
+	opt := reapi.Option{
+		ChunkedBlob: 4 * 1024 * 1024,
+	}
+	cl := reapitest.NewWithOption(ctx, t, fakere, opt)
+	cache := &fakeChunkCache{chunks: make(map[digest.Digest][]byte)}
+	cl.SetChunkCache(cache)
+
+	r := rand.New(rand.NewSource(1))
+	blob := make([]byte, 16*1024*1024)
+	r.Read(blob)
+
+	// Upload the large blob with SpliceBlob RPC.
+	ds := digest.NewStore()
+	bd := digest.FromBytes("large", blob)
+	ds.Set(bd)
+	n, err := cl.UploadAll(ctx, ds)
+	if err != nil || n != 1 {
+		t.Fatalf("UploadAll()=%d,%v: want 1,nil", n, err)
+	}
+	if got := fakere.SpliceBlobCalls.Load(); got != 1 {
+		t.Errorf("SpliceBlob calls=%d; want 1", got)
+	}
+	b, err := fakere.Fetch(ctx, bd.Digest().Proto())
+	if err != nil || !bytes.Equal(b, blob) {
+		t.Errorf("fake.Fetch(%s)=_,%v; want blob,nil", bd.Digest(), err)
+	}
+
+	// Upload the large blob in a file with SpliceBlob RPC.
+	// chunks are read from the file by seek.
+	fblob := append([]byte("file"), blob...)
+	fname := filepath.Join(t.TempDir(), "large")
+	err = os.WriteFile(fname, fblob, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := digest.NewData(fileSource{fname: fname}, digest.FromBytes(fname, fblob).Digest())
+	ds = digest.NewStore()
+	ds.Set(fd)
+	n, err = cl.UploadAll(ctx, ds)
+	if err != nil || n != 1 {
+		t.Fatalf("UploadAll(%s)=%d,%v: want 1,nil", fname, n, err)
+	}
+	if got := fakere.SpliceBlobCalls.Load(); got != 2 {
+		t.Errorf("SpliceBlob calls=%d; want 2", got)
+	}
+	b, err = fakere.Fetch(ctx, fd.Digest().Proto())
+	if err != nil || !bytes.Equal(b, fblob) {
+		t.Errorf("fake.Fetch(%s)=_,%v; want file blob,nil", fd.Digest(), err)
+	}
+
+	// Download the large blob with SplitBlob RPC.
+	b, err = cl.Get(ctx, bd.Digest(), bd.String())
+	if err != nil || !bytes.Equal(b, blob) {
+		t.Errorf("cl.Get(%s)=_,%v: want blob,nil", bd.Digest(), err)
+	}
+	if got := fakere.SplitBlobCalls.Load(); got != 1 {
+		t.Errorf("SplitBlob calls=%d; want 1", got)
+	}
+
+	// Modify a few bytes in the middle.
+	// Only changed chunks should be fetched.
+	modified := append([]byte(nil), blob...)
+	copy(modified[len(modified)/2:], "siso")
+	mdpb, err := fakere.Put(ctx, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := digest.FromProto(mdpb)
+	before := cl.IOMetrics().Stats().RBytes
+	b, err = cl.Get(ctx, md, "modified")
+	if err != nil || !bytes.Equal(b, modified) {
+		t.Errorf("cl.Get(%s)=_,%v: want modified,nil", md, err)
+	}
+	if got := cl.IOMetrics().Stats().RBytes - before; got <= 0 || got > 2*chunker.MaxSize {
+		t.Errorf("read bytes=%d; want 0 < n <= %d", got, 2*chunker.MaxSize)
+	}
+}
+
+// fileSource is a source of a local file.
+type fileSource struct {
+	fname string
+}
+
+func (s fileSource) Open(context.Context) (io.ReadCloser, error) {
+	return os.Open(s.fname)
+}
+
+func (s fileSource) String() string {
+	return s.fname
+}
+
+func TestUploadAll_PresenceCache(t *testing.T) {
+	ctx := t.Context()
+	fname := filepath.Join(t.TempDir(), ".siso_cas_presence")
+	opt := reapi.Option{
+		// same key for both fake servers.
+		CASAddress:     "cas.example.com:443",
+		CASPresenceTTL: time.Hour,
+	}
+	blob := digest.FromBytes("blob", []byte("foo"))
+
+	fakere1 := &reapitest.Fake{}
+	cl1 := reapitest.NewWithOption(ctx, t, fakere1, opt)
+	presence1, err := cl1.LoadPresenceCache(fname)
+	if err != nil {
+		t.Fatalf("LoadPresenceCache=%v; want nil err", err)
+	}
+	ds := digest.NewStore()
+	ds.Set(blob)
+	n, err := cl1.UploadAll(ctx, ds)
+	if err != nil || n != 1 {
+		t.Fatalf("UploadAll()=%d,%v: want 1,nil", n, err)
+	}
+	err = presence1.Save(fname)
+	if err != nil {
+		t.Fatalf("Save=%v; want nil err", err)
+	}
+
+	// The blob is confirmed in the presence cache, so the second
+	// client won't check nor upload it even if the CAS doesn't have it.
+	fakere2 := &reapitest.Fake{}
+	cl2 := reapitest.NewWithOption(ctx, t, fakere2, opt)
+	presence2, err := cl2.LoadPresenceCache(fname)
+	if err != nil {
+		t.Fatalf("LoadPresenceCache=%v; want nil err", err)
+	}
+	if got := presence2.Len(); got != 1 {
+		t.Errorf("presence.Len()=%d; want 1", got)
+	}
+	n, err = cl2.UploadExecInputs(ctx, ds)
+	if err != nil || n != 0 {
+		t.Fatalf("UploadExecInputs()=%d,%v: want 0,nil", n, err)
+	}
+	if _, err := fakere2.Fetch(ctx, blob.Digest().Proto()); err == nil {
+		t.Errorf("fake.Fetch(%s)=_,nil; want err", blob.Digest())
+	}
+
+	// Execute reports the blob is missing.
+	cl2.Forget([]digest.Digest{blob.Digest()})
+	if got := presence2.Len(); got != 0 {
+		t.Errorf("presence.Len()=%d after forget; want 0", got)
+	}
+	n, err = cl2.UploadExecInputs(ctx, ds)
+	if err != nil || n != 1 {
+		t.Fatalf("UploadExecInputs()=%d,%v: want 1,nil", n, err)
+	}
+	if _, err := fakere2.Fetch(ctx, blob.Digest().Proto()); err != nil {
+		t.Errorf("fake.Fetch(%s)=_,%v; want nil err", blob.Digest(), err)
+	}
+
+	// UploadAll doesn't use the presence cache, as its callers
+	// can't recover from missing blobs.
+	fakere4 := &reapitest.Fake{}
+	cl4 := reapitest.NewWithOption(ctx, t, fakere4, opt)
+	presence4, err := cl4.LoadPresenceCache(fname)
+	if err != nil {
+		t.Fatalf("LoadPresenceCache=%v; want nil err", err)
+	}
+	if got := presence4.Len(); got != 1 {
+		t.Errorf("presence.Len()=%d; want 1", got)
+	}
+	n, err = cl4.UploadAll(ctx, ds)
+	if err != nil || n != 1 {
+		t.Fatalf("UploadAll()=%d,%v: want 1,nil", n, err)
+	}
+	if _, err := fakere4.Fetch(ctx, blob.Digest().Proto()); err != nil {
+		t.Errorf("fake.Fetch(%s)=_,%v; want nil err", blob.Digest(), err)
+	}
+
+	// Other CAS doesn't use the presence cache.
+	fakere3 := &reapitest.Fake{}
+	cl3 := reapitest.NewWithOption(ctx, t, fakere3, reapi.Option{CASPresenceTTL: time.Hour})
+	presence3, err := cl3.LoadPresenceCache(fname)
+	if err != nil {
+		t.Fatalf("LoadPresenceCache=%v; want nil err", err)
+	}
+	if got := presence3.Len(); got != 0 {
+		t.Errorf("presence.Len()=%d for other CAS; want 0", got)
+	}
+}
+
+func TestLoadPresenceCache_Corrupt(t *testing.T) {
+	const key = "cas.example.com:443/instance"
+	header := binary.AppendUvarint([]byte("SISOCAS1"), uint64(len(key)))
+	header = append(header, key...)
+	entry := bytes.Repeat([]byte{0xab}, 32)
+	entry = binary.AppendUvarint(entry, 3)
+	entry = binary.AppendVarint(entry, time.Now().Unix())
+	valid := append(slices.Clip(header), entry...)
+
+	for _, tc := range []struct {
+		name    string
+		content []byte
+		wantErr bool
+		wantLen int
+	}{
+		{
+			name:    "valid",
+			content: valid,
+			wantLen: 1,
+		},
+		{
+			name:    "empty",
+			content: nil,
+			wantErr: true,
+		},
+		{
+			name:    "bad_magic",
+			content: append([]byte("SISOCAS0"), valid[8:]...),
+			wantErr: true,
+		},
+		{
+			name:    "truncated_key",
+			content: header[:len(header)-1],
+			wantErr: true,
+		},
+		{
+			name:    "huge_key_length",
+			content: binary.AppendUvarint([]byte("SISOCAS1"), 1<<62),
+			wantErr: true,
+		},
+		{
+			name:    "truncated_hash",
+			content: valid[:len(header)+16],
+			wantErr: true,
+		},
+		{
+			name:    "truncated_size",
+			content: valid[:len(header)+32],
+			wantErr: true,
+		},
+		{
+			name:    "truncated_time",
+			content: valid[:len(header)+33],
+			wantErr: true,
+		},
+		{
+			name:    "other_key",
+			content: append(binary.AppendUvarint([]byte("SISOCAS1"), 5), "other"...),
+			wantLen: 0,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fname := filepath.Join(t.TempDir(), ".siso_cas_presence")
+			err := os.WriteFile(fname, tc.content, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := reapi.LoadPresenceCache(fname, key, time.Hour)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("LoadPresenceCache=_,%v; want err=%t", err, tc.wantErr)
+			}
+			if p == nil {
+				t.Fatalf("LoadPresenceCache=nil; want non-nil")
+			}
+			if got := p.Len(); got != tc.wantLen {
+				t.Errorf("Len()=%d; want %d", got, tc.wantLen)
+			}
+		})
+	}
+}
+
+func TestMissingBlobs(t *testing.T) {
+	st, err := status.New(codes.FailedPrecondition, "missing inputs").WithDetails(&errdetails.PreconditionFailure{
+		Violations: []*errdetails.PreconditionFailure_Violation{
+			{Type: "MISSING", Subject: "blobs/1234/5"},
+			{Type: "OTHER", Subject: "blobs/5678/9"},
+			{Type: "MISSING", Subject: "blobs/bad"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reapi.MissingBlobs(fmt.Errorf("execute: %w", st.Err()))
+	want := []digest.Digest{{Hash: "1234", SizeBytes: 5}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MissingBlobs diff -want +got:\n%s", diff)
+	}
+	if got := reapi.MissingBlobs(status.Error(codes.Unavailable, "unavailable")); got != nil {
+		t.Errorf("MissingBlobs(unavailable)=%v; want nil", got)
+	}
+}
+
+func TestCircuitBreaker_ClientDeadline(t *testing.T) {
+	ctx := t.Context()
+	client := reapitest.NewWithOption(ctx, t, &reapitest.Fake{}, reapi.Option{CircuitBreaker: true})
+	defer client.Close()
+
+	// calls canceled by client side deadline, e.g. step's timeout,
+	// should not trip the circuit.
+	dctx, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+	defer cancel()
+	d := digest.FromBytes("blob", []byte("blob")).Digest()
+	for range 30 {
+		_, err := client.Missing(dctx, []digest.Digest{d})
+		if err != nil {
+			t.Logf("Missing(expired ctx)=%v", err)
+		}
+	}
+	if !client.CacheAllowed() {
+		t.Errorf("CacheAllowed()=false; want true after client side deadlines")
+	}
+}
+
+func TestCacheStore_Verifier(t *testing.T) {
+	ctx := t.Context()
+	fake := &reapitest.Fake{}
+	client := reapitest.New(ctx, t, fake)
+	defer client.Close()
+	// action cache needs the action in CAS.
+	putAction := func(salt string) digest.Digest {
+		t.Helper()
+		buf, err := proto.Marshal(&rpb.Action{Salt: []byte(salt)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := fake.Put(ctx, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return digest.FromProto(d)
+	}
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := func() *rpb.ActionResult {
+		return &rpb.ActionResult{
+			OutputFiles: []*rpb.OutputFile{
+				{
+					Path:   "out",
+					Digest: digest.Empty.Proto(),
+				},
+			},
+		}
+	}
+	unsigned := putAction("unsigned")
+	err = client.UpdateActionResult(ctx, unsigned, result())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := putAction("signed")
+	ar := result()
+	err = signature.NewSigner(priv).Sign(signed, ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateActionResult(ctx, signed, ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cs := client.CacheStore().WithVerifier(signature.NewVerifier(pub))
+	_, err = cs.GetActionResult(ctx, unsigned)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetActionResult(unsigned)=%v; want %v", err, codes.NotFound)
+	}
+	_, err = cs.GetActionResult(ctx, signed)
+	if err != nil {
+		t.Errorf("GetActionResult(signed)=%v; want nil err", err)
+	}
+	_, err = client.CacheStore().GetActionResult(ctx, unsigned)
+	if err != nil {
+		t.Errorf("GetActionResult(unsigned) without verifier=%v; want nil err", err)
+	}
+}
@@ -13,10 +13,12 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/bazelbuild/remote-apis-sdks/go/pkg/digest"
 	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	bpb "google.golang.org/genproto/googleapis/bytestream"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
@@ -38,6 +40,14 @@ type Fake struct {
 	CAS *blobstore.ContentAddressableStorage
 
 	ExecuteFunc func(*Fake, *rpb.Action) (*rpb.ActionResult, error)
+
+	// BlobSplit enables SplitBlob and SpliceBlob API.
+	BlobSplit bool
+
+	// SplitBlobCalls and SpliceBlobCalls are number of calls
+	// of SplitBlob and SpliceBlob.
+	SplitBlobCalls  atomic.Int64
+	SpliceBlobCalls atomic.Int64
 }
 
 // Execute runs command on fake reapi.
@@ -78,7 +88,11 @@ func newServer(t *testing.T, fake *Fake) *server {
 
 	dir := t.TempDir()
 	serv := grpc.NewServer()
-	capabilities.Register(serv)
+	if fake.BlobSplit {
+		rpb.RegisterCapabilitiesServer(serv, &splitCapabilities{Service: capabilities.NewService()})
+	} else {
+		capabilities.Register(serv)
+	}
 
 	casDir := filepath.Join(dir, "cas")
 	cas, err := blobstore.New(casDir)
@@ -88,9 +102,18 @@ func newServer(t *testing.T, fake *Fake) *server {
 	fake.CAS = cas
 
 	uploadDir := filepath.Join(casDir, "tmp")
-	err = blobstore.Register(serv, cas, uploadDir)
-	if err != nil {
-		t.Fatal(err)
+	if fake.BlobSplit {
+		casService, err := blobstore.NewService(cas, uploadDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bpb.RegisterByteStreamServer(serv, casService)
+		rpb.RegisterContentAddressableStorageServer(serv, &splitCAS{Service: casService, fake: fake})
+	} else {
+		err = blobstore.Register(serv, cas, uploadDir)
+		if err != nil {
+			t.Fatal(err)
+		}
 	}
 	acDir := filepath.Join(dir, "ac")
 	ac, err := actioncache.New(acDir)
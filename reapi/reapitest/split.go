@@ -0,0 +1,93 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package reapitest
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/bazelbuild/remote-apis-sdks/go/pkg/digest"
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/kajiya/blobstore"
+	"go.chromium.org/build/kajiya/capabilities"
+
+	"go.chromium.org/build/siso/reapi/chunker"
+)
+
+// splitCapabilities advertises SplitBlob and SpliceBlob support.
+type splitCapabilities struct {
+	*capabilities.Service
+}
+
+func (s *splitCapabilities) GetCapabilities(ctx context.Context, req *rpb.GetCapabilitiesRequest) (*rpb.ServerCapabilities, error) {
+	capa, err := s.Service.GetCapabilities(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	capa.CacheCapabilities.BlobSplitSupport = true
+	capa.CacheCapabilities.BlobSpliceSupport = true
+	return capa, nil
+}
+
+// splitCAS implements SplitBlob and SpliceBlob on top of kajiya's CAS.
+type splitCAS struct {
+	*blobstore.Service
+	fake *Fake
+}
+
+func (s *splitCAS) SplitBlob(ctx context.Context, req *rpb.SplitBlobRequest) (*rpb.SplitBlobResponse, error) {
+	s.fake.SplitBlobCalls.Add(1)
+	d, err := digest.NewFromProto(req.GetBlobDigest())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "bad blob digest: %v", err)
+	}
+	b, err := s.fake.CAS.Get(d)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "blob %s: %v", d, err)
+	}
+	resp := &rpb.SplitBlobResponse{}
+	for _, chunk := range chunker.Split(b) {
+		cd, err := s.fake.CAS.Put(chunk)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "put chunk: %v", err)
+		}
+		resp.ChunkDigests = append(resp.ChunkDigests, cd.ToProto())
+	}
+	return resp, nil
+}
+
+func (s *splitCAS) SpliceBlob(ctx context.Context, req *rpb.SpliceBlobRequest) (*rpb.SpliceBlobResponse, error) {
+	s.fake.SpliceBlobCalls.Add(1)
+	var buf bytes.Buffer
+	var missing []*rpb.Digest
+	for _, cpb := range req.GetChunkDigests() {
+		cd, err := digest.NewFromProto(cpb)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad chunk digest: %v", err)
+		}
+		b, err := s.fake.CAS.Get(cd)
+		if err != nil {
+			missing = append(missing, cpb)
+			continue
+		}
+		buf.Write(b)
+	}
+	if len(missing) > 0 {
+		return nil, status.Errorf(codes.NotFound, "missing chunks: %v", missing)
+	}
+	d, err := s.fake.CAS.Put(buf.Bytes())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "put blob: %v", err)
+	}
+	if bd := req.GetBlobDigest(); bd != nil && (d.Hash != bd.Hash || d.Size != bd.SizeBytes) {
+		return nil, status.Errorf(codes.InvalidArgument, "digest mismatch: got=%s want=%s", d, bd)
+	}
+	return &rpb.SpliceBlobResponse{
+		BlobDigest: d.ToProto(),
+	}, nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package signature signs and verifies action results, to protect
+// shared action caches from poisoning by untrusted writers.
+//
+// A signature is ed25519 signature over the action digest and
+// the digests of the outputs, and is stored as
+// ActionResultSignature in
+// ActionResult.execution_metadata.auxiliary_metadata.
+package signature
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	epb "go.chromium.org/build/siso/execute/proto"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+var (
+	// ErrNotSigned is an error when action result has no signature.
+	ErrNotSigned = errors.New("action result is not signed")
+
+	// ErrUntrustedKey is an error when action result is signed by
+	// a key not in trusted keys.
+	ErrUntrustedKey = errors.New("action result is signed by untrusted key")
+
+	// ErrInvalidSignature is an error when signature doesn't match
+	// with the action result.
+	ErrInvalidSignature = errors.New("invalid action result signature")
+)
+
+// KeyID returns key id of the public key.
+func KeyID(pub ed25519.PublicKey) string {
+	h := sha256.Sum256(pub)
+	return hex.EncodeToString(h[:8])
+}
+
+// Signer signs action results.
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewSigner creates new signer with the private key.
+func NewSigner(key ed25519.PrivateKey) *Signer {
+	return &Signer{
+		keyID: KeyID(key.Public().(ed25519.PublicKey)),
+		key:   key,
+	}
+}
+
+// LoadSigner loads PKCS #8 PEM encoded ed25519 private key from fname,
+// e.g. generated by `openssl genpkey -algorithm ed25519`.
+func LoadSigner(fname string) (*Signer, error) {
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("no PEM private key in %s", fname)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key in %s: %w", fname, err)
+	}
+	pkey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key in %s is %T, not ed25519", fname, key)
+	}
+	return NewSigner(pkey), nil
+}
+
+// KeyID returns key id of the signer.
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign signs the action result of the action digest d.
+// It replaces an existing signature in the action result, if any.
+// It must be called after all outputs, stdout and stderr digests
+// are set in the action result.
+func (s *Signer) Sign(d digest.Digest, ar *rpb.ActionResult) error {
+	sig := &epb.ActionResultSignature{
+		KeyId:     s.keyID,
+		Signature: ed25519.Sign(s.key, message(d, ar)),
+	}
+	p, err := anypb.New(sig)
+	if err != nil {
+		return err
+	}
+	if ar.ExecutionMetadata == nil {
+		ar.ExecutionMetadata = &rpb.ExecutedActionMetadata{}
+	}
+	md := ar.ExecutionMetadata
+	auxes := md.AuxiliaryMetadata[:0:0]
+	for _, aux := range md.AuxiliaryMetadata {
+		if aux.MessageIs(sig) {
+			continue
+		}
+		auxes = append(auxes, aux)
+	}
+	md.AuxiliaryMetadata = append(auxes, p)
+	return nil
+}
+
+// Verifier verifies action results with trusted keys.
+type Verifier struct {
+	keys map[string]ed25519.PublicKey
+}
+
+// NewVerifier creates new verifier with the trusted public keys.
+func NewVerifier(keys ...ed25519.PublicKey) *Verifier {
+	v := &Verifier{
+		keys: make(map[string]ed25519.PublicKey),
+	}
+	for _, k := range keys {
+		v.keys[KeyID(k)] = k
+	}
+	return v
+}
+
+// LoadVerifier loads PEM encoded ed25519 public keys from fname,
+// e.g. generated by `openssl pkey -pubout`.
+// The file may contain multiple public keys.
+func LoadVerifier(fname string) (*Verifier, error) {
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var keys []ed25519.PublicKey
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key in %s: %w", fname, err)
+		}
+		pkey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key in %s is %T, not ed25519", fname, key)
+		}
+		keys = append(keys, pkey)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM public key in %s", fname)
+	}
+	return NewVerifier(keys...), nil
+}
+
+// Verify verifies the action result of the action digest d is
+// signed by one of trusted keys.
+// It returns nil if v is nil, i.e. no verification is configured.
+func (v *Verifier) Verify(d digest.Digest, ar *rpb.ActionResult) error {
+	if v == nil {
+		return nil
+	}
+	var msg []byte
+	err := ErrNotSigned
+	for _, aux := range ar.GetExecutionMetadata().GetAuxiliaryMetadata() {
+		sig := &epb.ActionResultSignature{}
+		if aux.UnmarshalTo(sig) != nil {
+			continue
+		}
+		key, ok := v.keys[sig.KeyId]
+		if !ok {
+			err = fmt.Errorf("%w: key_id=%s", ErrUntrustedKey, sig.KeyId)
+			continue
+		}
+		if msg == nil {
+			msg = message(d, ar)
+		}
+		if !ed25519.Verify(key, msg, sig.Signature) {
+			err = fmt.Errorf("%w: key_id=%s", ErrInvalidSignature, sig.KeyId)
+			continue
+		}
+		return nil
+	}
+	return err
+}
+
+// message returns the message to sign for the action result.
+// It covers the action digest, exit code, stdout/stderr and outputs,
+// but not execution metadata, which is not used to reproduce outputs.
+func message(d digest.Digest, ar *rpb.ActionResult) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "siso-action-result-signature-v1\n")
+	fmt.Fprintf(&buf, "action %s\n", d)
+	fmt.Fprintf(&buf, "exit_code %d\n", ar.GetExitCode())
+	fmt.Fprintf(&buf, "stdout %s\n", outputDigest("stdout", ar.GetStdoutDigest(), ar.GetStdoutRaw()))
+	fmt.Fprintf(&buf, "stderr %s\n", outputDigest("stderr", ar.GetStderrDigest(), ar.GetStderrRaw()))
+
+	var lines []string
+	for _, f := range ar.GetOutputFiles() {
+		lines = append(lines, fmt.Sprintf("file %q %s %t\n", f.GetPath(), digest.FromProto(f.GetDigest()), f.GetIsExecutable()))
+	}
+	for _, s := range ar.GetOutputSymlinks() {
+		lines = append(lines, fmt.Sprintf("symlink %q %q\n", s.GetPath(), s.GetTarget()))
+	}
+	for _, s := range ar.GetOutputFileSymlinks() {
+		lines = append(lines, fmt.Sprintf("symlink %q %q\n", s.GetPath(), s.GetTarget()))
+	}
+	for _, s := range ar.GetOutputDirectorySymlinks() {
+		lines = append(lines, fmt.Sprintf("symlink %q %q\n", s.GetPath(), s.GetTarget()))
+	}
+	for _, dir := range ar.GetOutputDirectories() {
+		lines = append(lines, fmt.Sprintf("dir %q %s\n", dir.GetPath(), digest.FromProto(dir.GetTreeDigest())))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
+
+// outputDigest returns digest of stdout/stderr, which may be stored
+// in raw or digest.
+func outputDigest(name string, d *rpb.Digest, raw []byte) digest.Digest {
+	if d == nil && len(raw) > 0 {
+		return digest.FromBytes(name, raw).Digest()
+	}
+	return digest.FromProto(d)
+}
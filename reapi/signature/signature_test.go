@@ -0,0 +1,236 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package signature
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	epb "go.chromium.org/build/siso/execute/proto"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+func newActionResult() *rpb.ActionResult {
+	return &rpb.ActionResult{
+		OutputFiles: []*rpb.OutputFile{
+			{
+				Path:   "out/b.o",
+				Digest: digest.FromBytes("b.o", []byte("b.o")).Digest().Proto(),
+			},
+			{
+				Path:   "out/a.o",
+				Digest: digest.FromBytes("a.o", []byte("a.o")).Digest().Proto(),
+			},
+		},
+		StdoutRaw: []byte("warning"),
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := digest.FromBytes("action", []byte("action")).Digest()
+
+	ar := newActionResult()
+	ru, err := anypb.New(&epb.Rusage{MaxRss: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar.ExecutionMetadata = &rpb.ExecutedActionMetadata{
+		AuxiliaryMetadata: []*anypb.Any{ru},
+	}
+	signer := NewSigner(priv)
+	err = signer.Sign(d, ar)
+	if err != nil {
+		t.Fatalf("Sign(...)=%v; want nil", err)
+	}
+	// re-sign replaces the signature.
+	err = signer.Sign(d, ar)
+	if err != nil {
+		t.Fatalf("Sign(...)=%v; want nil", err)
+	}
+	if got := len(ar.ExecutionMetadata.AuxiliaryMetadata); got != 2 {
+		t.Errorf("len(auxiliary_metadata)=%d; want 2", got)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		verifier *Verifier
+		d        digest.Digest
+		ar       func() *rpb.ActionResult
+		want     error
+	}{
+		{
+			name:     "ok",
+			verifier: NewVerifier(otherPub, pub),
+			d:        d,
+			ar:       func() *rpb.ActionResult { return ar },
+		},
+		{
+			name: "nil verifier",
+			d:    d,
+			ar:   newActionResult,
+		},
+		{
+			name:     "not signed",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar:       newActionResult,
+			want:     ErrNotSigned,
+		},
+		{
+			name:     "untrusted",
+			verifier: NewVerifier(otherPub),
+			d:        d,
+			ar:       func() *rpb.ActionResult { return ar },
+			want:     ErrUntrustedKey,
+		},
+		{
+			name:     "other action",
+			verifier: NewVerifier(pub),
+			d:        digest.FromBytes("other", []byte("other")).Digest(),
+			ar:       func() *rpb.ActionResult { return ar },
+			want:     ErrInvalidSignature,
+		},
+		{
+			name:     "output modified",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar: func() *rpb.ActionResult {
+				mar := proto.Clone(ar).(*rpb.ActionResult)
+				mar.OutputFiles[0].Digest = digest.FromBytes("evil", []byte("evil")).Digest().Proto()
+				return mar
+			},
+			want: ErrInvalidSignature,
+		},
+		{
+			name:     "stdout modified",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar: func() *rpb.ActionResult {
+				mar := proto.Clone(ar).(*rpb.ActionResult)
+				mar.StdoutRaw = []byte("evil")
+				return mar
+			},
+			want: ErrInvalidSignature,
+		},
+		{
+			name:     "stdout in digest",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar: func() *rpb.ActionResult {
+				mar := proto.Clone(ar).(*rpb.ActionResult)
+				mar.StdoutDigest = digest.FromBytes("stdout", mar.StdoutRaw).Digest().Proto()
+				mar.StdoutRaw = nil
+				return mar
+			},
+		},
+		{
+			name:     "output order",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar: func() *rpb.ActionResult {
+				mar := proto.Clone(ar).(*rpb.ActionResult)
+				mar.OutputFiles[0], mar.OutputFiles[1] = mar.OutputFiles[1], mar.OutputFiles[0]
+				return mar
+			},
+		},
+		{
+			name:     "signed by other",
+			verifier: NewVerifier(pub),
+			d:        d,
+			ar: func() *rpb.ActionResult {
+				mar := newActionResult()
+				err := NewSigner(otherPriv).Sign(d, mar)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return mar
+			},
+			want: ErrUntrustedKey,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.verifier.Verify(tc.d, tc.ar())
+			if !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Errorf("Verify(...)=%v; want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeys(t *testing.T) {
+	dir := t.TempDir()
+	var pubPEM []byte
+	var privs []ed25519.PrivateKey
+	for range 2 {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		b, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubPEM = append(pubPEM, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: b})...)
+	}
+	pubFile := filepath.Join(dir, "trusted.pem")
+	err := os.WriteFile(pubFile, pubPEM, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(privs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	privFile := filepath.Join(dir, "key.pem")
+	err = os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := LoadSigner(privFile)
+	if err != nil {
+		t.Fatalf("LoadSigner(%q)=%v; want nil err", privFile, err)
+	}
+	verifier, err := LoadVerifier(pubFile)
+	if err != nil {
+		t.Fatalf("LoadVerifier(%q)=%v; want nil err", pubFile, err)
+	}
+	if len(verifier.keys) != 2 {
+		t.Errorf("LoadVerifier(%q): %d keys; want 2", pubFile, len(verifier.keys))
+	}
+	d := digest.FromBytes("action", []byte("action")).Digest()
+	ar := newActionResult()
+	err = signer.Sign(d, ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = verifier.Verify(d, ar)
+	if err != nil {
+		t.Errorf("Verify(...)=%v; want nil", err)
+	}
+
+	_, err = LoadVerifier(privFile)
+	if err == nil {
+		t.Errorf("LoadVerifier(%q)=nil; want error", privFile)
+	}
+}
@@ -0,0 +1,296 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package reapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"golang.org/x/sync/errgroup"
+
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/o11y/trace"
+	"go.chromium.org/build/siso/reapi/chunker"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/retry"
+)
+
+// ChunkCache is a cache to store chunks of blobs, so that
+// SplitBlob API only needs to fetch chunks that are not in the cache.
+// build.LocalCache implements this interface.
+type ChunkCache interface {
+	// GetContent gets the content of the chunk identified by the digest.
+	GetContent(context.Context, digest.Digest, string) ([]byte, error)
+	// SetContent sets the content of the chunk identified by the digest.
+	SetContent(context.Context, digest.Digest, string, []byte) error
+}
+
+// SetChunkCache sets chunk cache used by SplitBlob API.
+func (c *Client) SetChunkCache(cache ChunkCache) {
+	if c == nil {
+		return
+	}
+	c.chunkCache = cache
+}
+
+// maxConcurrentChunkReads is max number of concurrent chunk reads
+// for a blob.
+const maxConcurrentChunkReads = 8
+
+// useBlobSplit reports whether to use SplitBlob API to read the blob.
+func (c *Client) useBlobSplit(d digest.Digest) bool {
+	if c.opt.ChunkedBlob <= 0 || d.SizeBytes < c.opt.ChunkedBlob {
+		return false
+	}
+	return c.capabilities.GetCacheCapabilities().GetBlobSplitSupport()
+}
+
+// useBlobSplice reports whether to use SpliceBlob API to write the blob.
+func (c *Client) useBlobSplice(d digest.Digest) bool {
+	if c.opt.ChunkedBlob <= 0 || d.SizeBytes < c.opt.ChunkedBlob {
+		return false
+	}
+	return c.capabilities.GetCacheCapabilities().GetBlobSpliceSupport()
+}
+
+// getWithSplitBlob fetches the content of blob using SplitBlob rpc of CAS.
+// It fetches only chunks that are not in the chunk cache,
+// and stores fetched chunks in the chunk cache.
+func (c *Client) getWithSplitBlob(ctx context.Context, d digest.Digest, name string) ([]byte, error) {
+	ctx, span := trace.NewSpan(ctx, "reapi-split-blob")
+	defer span.Close(nil)
+	started := time.Now()
+	casClient := rpb.NewContentAddressableStorageClient(c.casConn)
+	var resp *rpb.SplitBlobResponse
+	err := retry.Do(ctx, func() error {
+		var err error
+		resp, err = casClient.SplitBlob(ctx, &rpb.SplitBlobRequest{
+			InstanceName:   c.opt.Instance,
+			BlobDigest:     d.Proto(),
+			DigestFunction: rpb.DigestFunction_SHA256,
+		})
+		return err
+	})
+	c.m.OpsDone(err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to split blob %s for %s: %w", d, name, err)
+	}
+	chunkDigests := make([]digest.Digest, 0, len(resp.GetChunkDigests()))
+	var size int64
+	for _, cd := range resp.GetChunkDigests() {
+		chunkDigests = append(chunkDigests, digest.FromProto(cd))
+		size += cd.GetSizeBytes()
+	}
+	if size != d.SizeBytes {
+		return nil, fmt.Errorf("failed to split blob %s for %s: chunks size mismatch got=%d", d, name, size)
+	}
+	span.SetAttr("chunks", len(chunkDigests))
+
+	chunks := make([][]byte, len(chunkDigests))
+	var fetched []int
+	for i, cd := range chunkDigests {
+		if c.chunkCache != nil {
+			b, err := c.chunkCache.GetContent(ctx, cd, name)
+			if err == nil && int64(len(b)) == cd.SizeBytes {
+				chunks[i] = b
+				continue
+			}
+		}
+		fetched = append(fetched, i)
+	}
+	span.SetAttr("fetched", len(fetched))
+
+	var eg errgroup.Group
+	eg.SetLimit(maxConcurrentChunkReads)
+	for _, i := range fetched {
+		eg.Go(func() error {
+			cd := chunkDigests[i]
+			b, err := c.get(ctx, cd, name)
+			if err != nil {
+				return fmt.Errorf("chunk %d %s: %w", i, cd, err)
+			}
+			chunks[i] = b
+			if c.chunkCache != nil {
+				err := c.chunkCache.SetContent(ctx, cd, name, b)
+				if err != nil {
+					clog.Warningf(ctx, "failed to cache chunk %s for %s: %v", cd, name, err)
+				}
+			}
+			return nil
+		})
+	}
+	err = eg.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks of %s for %s in %s: %w", d, name, time.Since(started), err)
+	}
+	buf := make([]byte, 0, d.SizeBytes)
+	for _, b := range chunks {
+		buf = append(buf, b...)
+	}
+	if got := digest.FromBytes(name, buf).Digest(); got != d {
+		return nil, fmt.Errorf("failed to splice chunks of %s for %s: digest mismatch got=%s", d, name, got)
+	}
+	clog.Infof(ctx, "get %s for %s by split blob: chunks=%d fetched=%d in %s", d, name, len(chunks), len(fetched), time.Since(started))
+	return buf, nil
+}
+
+// chunkSource is a source of a chunk in the blob data.
+// It reads the chunk from the data when opened, so chunks
+// don't need to be kept in memory.
+type chunkSource struct {
+	name   string
+	data   digest.Data
+	offset int64
+	size   int64
+}
+
+func (s chunkSource) Open(ctx context.Context) (io.ReadCloser, error) {
+	r, err := s.data.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if sr, ok := r.(io.Seeker); ok {
+		_, err = sr.Seek(s.offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, r, s.offset)
+	}
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to skip to chunk %s: %w", s.name, err)
+	}
+	return chunkReader{r: io.LimitReader(r, s.size), c: r}, nil
+}
+
+func (s chunkSource) String() string {
+	return s.name
+}
+
+type chunkReader struct {
+	r io.Reader
+	c io.Closer
+}
+
+func (r chunkReader) Read(buf []byte) (int, error) {
+	return r.r.Read(buf)
+}
+
+func (r chunkReader) Close() error {
+	return r.c.Close()
+}
+
+// splitChunks splits the data into content-defined chunks.
+// It streams the data, and returned chunks read the data when opened.
+func splitChunks(ctx context.Context, data digest.Data) ([]digest.Data, error) {
+	var chunks []digest.Data
+	err := retry.Do(ctx, func() error {
+		chunks = nil
+		r, err := data.Open(ctx)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		ck := chunker.New(r)
+		var offset int64
+		for {
+			chunk, err := ck.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			cd := digest.FromBytes("", chunk).Digest()
+			chunks = append(chunks, digest.NewData(chunkSource{
+				name:   fmt.Sprintf("%s chunk#%d", data, len(chunks)),
+				data:   data,
+				offset: offset,
+				size:   cd.SizeBytes,
+			}, cd))
+			offset += cd.SizeBytes
+		}
+	})
+	return chunks, err
+}
+
+// uploadWithSpliceBlob uploads blobs using SpliceBlob rpc of CAS.
+// It splits blobs into content-defined chunks, uploads chunks
+// missing in CAS, and then splices them into the blob.
+// It streams blob content, so large blobs are not read in memory.
+// It returns blobs that are not uploaded by SpliceBlob, which
+// need to be uploaded by other APIs.
+func (c *Client) uploadWithSpliceBlob(ctx context.Context, digests []digest.Digest, uploads map[digest.Digest]*uploadOp, ds *digest.Store) ([]digest.Digest, []missingBlob) {
+	var remains []digest.Digest
+	var missingBlobs []missingBlob
+	casClient := rpb.NewContentAddressableStorageClient(c.casConn)
+	for _, d := range digests {
+		if !c.useBlobSplice(d) {
+			remains = append(remains, d)
+			continue
+		}
+		started := time.Now()
+		data, ok := ds.Get(d)
+		if !ok {
+			clog.Warningf(ctx, "Not found %s in store", d)
+			missingBlobs = append(missingBlobs, missingBlob{
+				Digest: d,
+				Err:    errBlobNotInReq,
+			})
+			continue
+		}
+		var chunks []digest.Data
+		err := FileSemaphore.Do(ctx, func(ctx context.Context) error {
+			var err error
+			chunks, err = splitChunks(ctx, data)
+			return err
+		})
+		if err != nil {
+			clog.Warningf(ctx, "read %s to upload: %v", data, err)
+			missingBlobs = append(missingBlobs, missingBlob{
+				Digest: d,
+				Err:    err,
+			})
+			uploads[d].done(err)
+			continue
+		}
+		if len(chunks) <= 1 {
+			remains = append(remains, d)
+			continue
+		}
+		chunkStore := digest.NewStore()
+		chunkDigests := make([]*rpb.Digest, 0, len(chunks))
+		for _, cd := range chunks {
+			chunkStore.Set(cd)
+			chunkDigests = append(chunkDigests, cd.Digest().Proto())
+		}
+		n, err := c.UploadAll(ctx, chunkStore)
+		if err != nil {
+			clog.Warningf(ctx, "Failed to upload chunks of %s. fallback to bytestream: %v", data, err)
+			remains = append(remains, d)
+			continue
+		}
+		err = retry.Do(ctx, func() error {
+			_, err := casClient.SpliceBlob(ctx, &rpb.SpliceBlobRequest{
+				InstanceName:   c.opt.Instance,
+				BlobDigest:     d.Proto(),
+				ChunkDigests:   chunkDigests,
+				DigestFunction: rpb.DigestFunction_SHA256,
+			})
+			return err
+		})
+		c.m.OpsDone(err)
+		if err != nil {
+			clog.Warningf(ctx, "Failed to splice %s. fallback to bytestream: %v", data, err)
+			remains = append(remains, d)
+			continue
+		}
+		uploads[d].done(nil)
+		clog.Infof(ctx, "uploaded by splice %s: chunks=%d uploaded=%d in %s", data, len(chunks), n, time.Since(started))
+	}
+	return remains, missingBlobs
+}
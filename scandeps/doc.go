@@ -34,4 +34,8 @@
 // in the dir.  Rather using minimum sets of include dirs,
 // it may use more files, but can use precomputed merkletree
 // to improve performance in digest calculation for action inputs.
+//
+// Other than C/C++, it scans sources by the Scanner specified in
+// the request (e.g. "proto" for protobuf `import`, "shader" for
+// GLSL/HLSL `#include`). Scanners are registered by RegisterScanner.
 package scandeps
@@ -29,6 +29,10 @@ type filesystem struct {
 	dirs  sync.Map // basename -> dir -> []dirents
 	files sync.Map // basename -> files -> *scanresult
 
+	// scanner name -> basename -> files -> *scanresult
+	// for scanners other than C/C++.
+	scanFiles sync.Map
+
 	dircache sync.Map // dir -> base -> bool
 
 	hmaps sync.Map // hmap path -> *hmapresult
@@ -108,6 +112,24 @@ func (fsys *filesystem) forgetFile(fname string) {
 		m := v.(*sync.Map)
 		m.Delete(fname)
 	}
+	fsys.scanFiles.Range(func(_, v any) bool {
+		files := v.(*sync.Map)
+		v, ok := files.Load(base)
+		if ok {
+			m := v.(*sync.Map)
+			m.Delete(fname)
+		}
+		return true
+	})
+}
+
+// filesFor returns scan results cache for scanner.
+func (fsys *filesystem) filesFor(scanner string) *sync.Map {
+	if scanner == "" || scanner == CPPScanner {
+		return &fsys.files
+	}
+	v, _ := fsys.scanFiles.LoadOrStore(scanner, new(sync.Map))
+	return v.(*sync.Map)
 }
 
 func (fsys *filesystem) markDirExists(dname string) bool {
@@ -214,8 +236,8 @@ func (fsys *filesystem) setDir(execRoot, dname string, exist bool) {
 	m.Store(filepath.ToSlash(filepath.Join(execRoot, dname)), exist)
 }
 
-func (fsys *filesystem) getFile(execRoot, fname string) (*scanResult, bool) {
-	v, ok := fsys.files.Load(filepath.Base(fname))
+func (fsys *filesystem) getFile(execRoot, scanner, fname string) (*scanResult, bool) {
+	v, ok := fsys.filesFor(scanner).Load(filepath.Base(fname))
 	if !ok {
 		return nil, false
 	}
@@ -228,8 +250,8 @@ func (fsys *filesystem) getFile(execRoot, fname string) (*scanResult, bool) {
 	return sr, true
 }
 
-func (fsys *filesystem) setFile(execRoot, fname string, sr *scanResult) {
-	v, _ := fsys.files.LoadOrStore(filepath.Base(fname), new(sync.Map))
+func (fsys *filesystem) setFile(execRoot, scanner, fname string, sr *scanResult) {
+	v, _ := fsys.filesFor(scanner).LoadOrStore(filepath.Base(fname), new(sync.Map))
 	m := v.(*sync.Map)
 	m.Store(filepath.ToSlash(filepath.Join(execRoot, fname)), sr)
 }
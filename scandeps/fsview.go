@@ -33,6 +33,10 @@ type fsview struct {
 	execRoot  string
 	inputDeps map[string][]string
 
+	// scanner to scan files.
+	scannerName string
+	scanner     Scanner
+
 	// precomputed trees for this include dirs (framework, sysroots).
 	precomputedTrees []string
 
@@ -192,7 +196,7 @@ func (fv *fsview) scanFile(ctx context.Context, fname string) (*scanResult, erro
 	var defines map[string][]string
 	err = cppScanSema.Do(ctx, func(ctx context.Context) error {
 		var err error
-		includes, defines, err = CPPScan(ctx, fname, buf)
+		includes, defines, err = fv.scanner.ScanFile(ctx, fname, buf)
 		return err
 	})
 	sr.err = err
@@ -319,7 +323,7 @@ func (fv *fsview) getFile(fname string) (*scanResult, bool) {
 	if ok {
 		return sr, ok
 	}
-	sr, ok = fv.fs.getFile(fv.execRoot, fname)
+	sr, ok = fv.fs.getFile(fv.execRoot, fv.scannerName, fname)
 	if !ok {
 		return nil, false
 	}
@@ -329,7 +333,7 @@ func (fv *fsview) getFile(fname string) (*scanResult, bool) {
 
 func (fv *fsview) setFile(fname string, sr *scanResult) {
 	fv.files[fname] = sr
-	fv.fs.setFile(fv.execRoot, fname, sr)
+	fv.fs.setFile(fv.execRoot, fv.scannerName, fname, sr)
 }
 
 func (fv *fsview) markVisited(visits ...string) {
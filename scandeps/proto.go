@@ -0,0 +1,121 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package scandeps
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+
+	log "github.com/golang/glog"
+
+	"go.chromium.org/build/siso/o11y/clog"
+)
+
+// protoScanner is a scanner for protocol buffers sources.
+type protoScanner struct{}
+
+// Request returns a request for protoc command line.
+// It supports `-I<dir>`, `-I <dir>`, `--proto_path=<dir>` and
+// `--proto_path <dir>` for search dirs, and `*.proto` for sources.
+func (protoScanner) Request(ctx context.Context, args []string) (Request, error) {
+	var req Request
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-I", arg == "--proto_path":
+			i++
+			if i < len(args) {
+				req.Dirs = addProtoPath(ctx, req.Dirs, args[i])
+			}
+		case strings.HasPrefix(arg, "-I"):
+			req.Dirs = addProtoPath(ctx, req.Dirs, strings.TrimPrefix(arg, "-I"))
+		case strings.HasPrefix(arg, "--proto_path="):
+			req.Dirs = addProtoPath(ctx, req.Dirs, strings.TrimPrefix(arg, "--proto_path="))
+		case strings.HasPrefix(arg, "-"):
+		case strings.HasSuffix(arg, ".proto"):
+			req.Sources = append(req.Sources, arg)
+		}
+	}
+	if len(req.Dirs) == 0 {
+		// protoc uses current dir if no proto path is given.
+		req.Dirs = append(req.Dirs, ".")
+	}
+	return req, nil
+}
+
+func addProtoPath(ctx context.Context, dirs []string, dir string) []string {
+	if strings.Contains(dir, "=") {
+		// virtual path mapping (e.g. `--proto_path=virtual=physical`)
+		// is not supported.
+		clog.Warningf(ctx, "unsupported proto path %q", dir)
+		return dirs
+	}
+	for _, d := range filepath.SplitList(dir) {
+		dirs = append(dirs, filepath.ToSlash(d))
+	}
+	return dirs
+}
+
+// ScanFile scans `import "path.proto";` in buf.
+// It also accepts `import public` and `import weak`.
+func (protoScanner) ScanFile(ctx context.Context, fname string, buf []byte) ([]string, map[string][]string, error) {
+	var includes []string
+	inComment := false
+	for len(buf) > 0 {
+		var line []byte
+		line, buf, _ = bytes.Cut(buf, []byte("\n"))
+		line = bytes.TrimSpace(line)
+		if inComment {
+			_, after, found := bytes.Cut(line, []byte("*/"))
+			if !found {
+				continue
+			}
+			inComment = false
+			line = bytes.TrimSpace(after)
+		}
+		if bytes.HasPrefix(line, []byte("/*")) {
+			if !bytes.Contains(line[2:], []byte("*/")) {
+				inComment = true
+			}
+			continue
+		}
+		name, ok := protoImport(line)
+		if !ok {
+			continue
+		}
+		if log.V(1) {
+			clog.Infof(ctx, "proto import %s in %s", name, fname)
+		}
+		// imports are resolved only in proto paths.
+		includes = append(includes, "<"+name+">")
+	}
+	return includes, nil, nil
+}
+
+// protoImport returns imported path of the import statement line.
+func protoImport(line []byte) (string, bool) {
+	line, ok := bytes.CutPrefix(line, []byte("import"))
+	if !ok || len(line) == 0 || (line[0] != ' ' && line[0] != '\t') {
+		return "", false
+	}
+	line = bytes.TrimSpace(line)
+	for _, modifier := range []string{"public", "weak"} {
+		rest, ok := bytes.CutPrefix(line, []byte(modifier))
+		if ok && len(rest) > 0 && (rest[0] == ' ' || rest[0] == '\t') {
+			line = bytes.TrimSpace(rest)
+			break
+		}
+	}
+	if len(line) < 2 || (line[0] != '"' && line[0] != '\'') {
+		return "", false
+	}
+	i := bytes.IndexByte(line[1:], line[0])
+	if i <= 0 {
+		return "", false
+	}
+	return string(line[1 : 1+i]), true
+}
@@ -87,13 +87,15 @@ type scanResult struct {
 	err error
 }
 
-func (fsys *filesystem) scanner(ctx context.Context, execRoot string, inputDeps map[string][]string, precomputedTrees []string) *scanner {
+func (fsys *filesystem) scanner(ctx context.Context, execRoot string, inputDeps map[string][]string, precomputedTrees []string, scannerName string, fileScanner Scanner) *scanner {
 	s := &scanner{
 		pt: NewPathTable(),
 		fsview: &fsview{
 			fs:               fsys,
 			execRoot:         execRoot,
 			inputDeps:        inputDeps,
+			scannerName:      scannerName,
+			scanner:          fileScanner,
 			precomputedTrees: precomputedTrees,
 			visited:          make(map[string]bool),
 			dirs:             make(map[string]bool),
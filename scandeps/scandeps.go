@@ -19,7 +19,9 @@ import (
 	"go.chromium.org/build/siso/o11y/trace"
 )
 
-// ScanDeps is a simple C/C++ dependency scanner.
+// ScanDeps is a simple dependency scanner.
+// It scans C/C++ sources by default, and other sources
+// by the scanner specified in the request.
 type ScanDeps struct {
 	fs *filesystem
 
@@ -79,13 +81,22 @@ type Request struct {
 
 	// To mitigate scanning that does not terminate.
 	Timeout time.Duration
+
+	// Scanner is a name of the scanner to scan files.
+	// Empty means C/C++ scanner.
+	Scanner string
 }
 
-// Scan scans C/C++ source/header files for req to get C/C++ dependencies.
+// Scan scans source files for req to get dependencies.
+// It uses the scanner specified by req.Scanner.
 func (s *ScanDeps) Scan(ctx context.Context, execRoot string, req Request) ([]string, error) {
 	if errForTest != nil {
 		return nil, errForTest
 	}
+	fileScanner, ok := LookupScanner(req.Scanner)
+	if !ok {
+		return nil, fmt.Errorf("unknown scanner %q", req.Scanner)
+	}
 	ctx, span := trace.NewSpan(ctx, "scandeps")
 	defer span.Close(nil)
 
@@ -97,7 +108,7 @@ func (s *ScanDeps) Scan(ctx context.Context, execRoot string, req Request) ([]st
 	// framework, or some system include dirs may also use precomputed tree
 	// if precomputed tree is defined for the dir (in addDir later).
 
-	scanner := s.fs.scanner(ctx, execRoot, s.inputDeps, precomputedTrees)
+	scanner := s.fs.scanner(ctx, execRoot, s.inputDeps, precomputedTrees, req.Scanner, fileScanner)
 	scanner.setMacros(req.Defines)
 
 	for _, s := range req.Includes {
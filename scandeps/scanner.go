@@ -0,0 +1,89 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package scandeps
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Scanner is a dependency scanner for a kind of source files.
+type Scanner interface {
+	// Request returns a scandeps request for the command line args.
+	// Paths in the request are relative to the working directory
+	// of the command.
+	Request(ctx context.Context, args []string) (Request, error)
+
+	// ScanFile scans buf of fname, and returns names of dependencies
+	// and macro definitions.
+	// Each name has the form of `"name"` (searched in the dir of
+	// fname, then search dirs) or `<name>` (searched in search dirs),
+	// or is a macro name defined in macro definitions.
+	ScanFile(ctx context.Context, fname string, buf []byte) ([]string, map[string][]string, error)
+}
+
+// CPPScanner is the name of the C/C++ scanner, used when
+// Request.Scanner is empty.
+const CPPScanner = "cpp"
+
+var (
+	scannersMu sync.RWMutex
+	scanners   = map[string]Scanner{
+		CPPScanner: cppScanner{},
+		"proto":    protoScanner{},
+		"shader":   shaderScanner{},
+	}
+)
+
+// RegisterScanner registers scanner as name.
+// It returns error if name is already registered.
+func RegisterScanner(name string, scanner Scanner) error {
+	scannersMu.Lock()
+	defer scannersMu.Unlock()
+	if _, ok := scanners[name]; ok {
+		return fmt.Errorf("scanner %q already registered", name)
+	}
+	scanners[name] = scanner
+	return nil
+}
+
+// LookupScanner returns scanner registered as name.
+// Empty name is the C/C++ scanner.
+func LookupScanner(name string) (Scanner, bool) {
+	if name == "" {
+		name = CPPScanner
+	}
+	scannersMu.RLock()
+	defer scannersMu.RUnlock()
+	s, ok := scanners[name]
+	return s, ok
+}
+
+// Scanners returns names of registered scanners.
+func Scanners() []string {
+	scannersMu.RLock()
+	defer scannersMu.RUnlock()
+	names := make([]string, 0, len(scanners))
+	for name := range scanners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cppScanner is a scanner for C/C++ sources.
+// Requests for C/C++ are extracted by gccutil or msvcutil,
+// so it doesn't support Request.
+type cppScanner struct{}
+
+func (cppScanner) Request(ctx context.Context, args []string) (Request, error) {
+	return Request{}, fmt.Errorf("cpp scanner: use gccutil or msvcutil to extract request")
+}
+
+func (cppScanner) ScanFile(ctx context.Context, fname string, buf []byte) ([]string, map[string][]string, error) {
+	return CPPScan(ctx, fname, buf)
+}
@@ -0,0 +1,179 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package scandeps
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"go.chromium.org/build/siso/hashfs"
+)
+
+func TestScanDeps_Proto(t *testing.T) {
+	ctx := t.Context()
+	dir := tempDir(t)
+
+	for fname, content := range map[string]string{
+		"components/foo/foo.proto": `
+syntax = "proto3";
+
+package foo;
+
+import "components/foo/bar.proto";
+import public 'components/foo/baz.proto';
+// import "components/foo/commented.proto";
+/*
+import "components/foo/commented.proto";
+*/
+import "google/protobuf/timestamp.proto";
+`,
+		"components/foo/bar.proto": `
+syntax = "proto3";
+import weak "google/protobuf/any.proto";
+`,
+		"components/foo/baz.proto":                                 "",
+		"components/foo/commented.proto":                           "",
+		"third_party/protobuf/src/google/protobuf/timestamp.proto": "",
+		"third_party/protobuf/src/google/protobuf/any.proto":       "",
+	} {
+		fname := filepath.Join(dir, fname)
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanDeps := New(hashFS, nil, nil)
+
+	scanner, ok := LookupScanner("proto")
+	if !ok {
+		t.Fatalf("LookupScanner(%q)=_, false; want true", "proto")
+	}
+	req, err := scanner.Request(ctx, []string{
+		"protoc",
+		"--proto_path=.",
+		"-Ithird_party/protobuf/src",
+		"--cpp_out=gen",
+		"components/foo/foo.proto",
+	})
+	if err != nil {
+		t.Fatalf("Request=%v; want nil err", err)
+	}
+	req.Scanner = "proto"
+
+	got, err := scanDeps.Scan(ctx, dir, req)
+	if err != nil {
+		t.Errorf("scandeps()=%v, %v; want nil err", got, err)
+	}
+
+	want := []string{
+		".",
+		"components/foo",
+		"components/foo/bar.proto",
+		"components/foo/baz.proto",
+		"components/foo/foo.proto",
+		"third_party/protobuf/src",
+		"third_party/protobuf/src/google/protobuf",
+		"third_party/protobuf/src/google/protobuf/any.proto",
+		"third_party/protobuf/src/google/protobuf/timestamp.proto",
+	}
+	if diff := cmp.Diff(want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("scandeps diff -want +got:\n%s", diff)
+	}
+}
+
+func TestScanDeps_Shader(t *testing.T) {
+	ctx := t.Context()
+	dir := tempDir(t)
+
+	for fname, content := range map[string]string{
+		"shaders/blit.frag": `
+#version 450
+#extension GL_GOOGLE_include_directive : require
+#include "common.glsl"
+#include <lib/color.glsl>
+`,
+		"shaders/common.glsl":        "",
+		"third_party/lib/color.glsl": "",
+	} {
+		fname := filepath.Join(dir, fname)
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hashFS, err := hashfs.New(ctx, hashfs.Option{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanDeps := New(hashFS, nil, nil)
+
+	scanner, ok := LookupScanner("shader")
+	if !ok {
+		t.Fatalf("LookupScanner(%q)=_, false; want true", "shader")
+	}
+	req, err := scanner.Request(ctx, []string{
+		"glslc",
+		"-I", "third_party",
+		"-DMODE=1",
+		"-o", "gen/blit.frag.spv",
+		"shaders/blit.frag",
+	})
+	if err != nil {
+		t.Fatalf("Request=%v; want nil err", err)
+	}
+	req.Scanner = "shader"
+
+	got, err := scanDeps.Scan(ctx, dir, req)
+	if err != nil {
+		t.Errorf("scandeps()=%v, %v; want nil err", got, err)
+	}
+
+	want := []string{
+		"shaders",
+		"shaders/blit.frag",
+		"shaders/common.glsl",
+		"third_party",
+		"third_party/lib",
+		"third_party/lib/color.glsl",
+	}
+	if diff := cmp.Diff(want, got, cmpopts.SortSlices(func(a, b string) bool { return a < b })); diff != "" {
+		t.Errorf("scandeps diff -want +got:\n%s", diff)
+	}
+}
+
+func TestRegisterScanner(t *testing.T) {
+	err := RegisterScanner("proto", protoScanner{})
+	if err == nil {
+		t.Errorf("RegisterScanner(%q)=nil; want error for duplicate", "proto")
+	}
+	if _, ok := LookupScanner(""); !ok {
+		t.Errorf("LookupScanner(%q)=_, false; want true", "")
+	}
+	if _, ok := LookupScanner("unknown"); ok {
+		t.Errorf("LookupScanner(%q)=_, true; want false", "unknown")
+	}
+	want := []string{"cpp", "proto", "shader"}
+	if diff := cmp.Diff(want, Scanners()); diff != "" {
+		t.Errorf("Scanners() diff -want +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package scandeps
+
+import (
+	"context"
+	"path"
+	"strings"
+)
+
+// shaderExts are extensions of shader sources for glslc,
+// glslangValidator, dxc and fxc.
+var shaderExts = map[string]bool{
+	".glsl":  true,
+	".vert":  true,
+	".frag":  true,
+	".geom":  true,
+	".comp":  true,
+	".tesc":  true,
+	".tese":  true,
+	".mesh":  true,
+	".task":  true,
+	".rgen":  true,
+	".rint":  true,
+	".rahit": true,
+	".rchit": true,
+	".rmiss": true,
+	".rcall": true,
+	".hlsl":  true,
+	".fx":    true,
+}
+
+// shaderScanner is a scanner for GLSL/HLSL shader sources.
+type shaderScanner struct{}
+
+// Request returns a request for shader compiler command line.
+// It supports `-I<dir>`, `-I <dir>`, `/I <dir>` for search dirs,
+// `-D<macro>=<value>`, `-D <macro>=<value>`, `/D <macro>=<value>`
+// for macros, and shader source files.
+func (shaderScanner) Request(ctx context.Context, args []string) (Request, error) {
+	req := Request{
+		Defines: make(map[string]string),
+	}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-I", arg == "/I":
+			i++
+			if i < len(args) {
+				req.Dirs = append(req.Dirs, args[i])
+			}
+		case arg == "-D", arg == "/D":
+			i++
+			if i < len(args) {
+				shaderDefine(req.Defines, args[i])
+			}
+		case strings.HasPrefix(arg, "-I"):
+			req.Dirs = append(req.Dirs, strings.TrimPrefix(arg, "-I"))
+		case strings.HasPrefix(arg, "-D"):
+			shaderDefine(req.Defines, strings.TrimPrefix(arg, "-D"))
+		case strings.HasPrefix(arg, "-"):
+		case shaderExts[path.Ext(arg)]:
+			req.Sources = append(req.Sources, arg)
+		}
+	}
+	return req, nil
+}
+
+func shaderDefine(defines map[string]string, arg string) {
+	macro, value, ok := strings.Cut(arg, "=")
+	if !ok || value == "" {
+		return
+	}
+	switch value[0] {
+	case '<', '"':
+		defines[macro] = value
+	}
+}
+
+// ScanFile scans `#include` in buf.
+// GLSL (GL_GOOGLE_include_directive) and HLSL use the same
+// form of `#include` as C preprocessor.
+func (shaderScanner) ScanFile(ctx context.Context, fname string, buf []byte) ([]string, map[string][]string, error) {
+	return CPPScan(ctx, fname, buf)
+}
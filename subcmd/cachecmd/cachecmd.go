@@ -0,0 +1,52 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package cachecmd provides cache subcommand.
+package cachecmd
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/auth/cred"
+)
+
+// Cmd returns the Command for the `cache` subcommand provided by this package.
+func Cmd(authOpts cred.Options) *Command {
+	return &Command{
+		authOpts: authOpts,
+	}
+}
+
+// Command implements cache subcommand.
+type Command struct {
+	authOpts cred.Options
+}
+
+func (*Command) Name() string {
+	return "cache"
+}
+
+func (*Command) Synopsis() string {
+	return "command group to access siso caches"
+}
+
+func (*Command) Usage() string {
+	return `command group to access siso caches
+
+Use "siso cache" to display subcommands.
+Use "siso cache help [subcommand]" for more information about a subcommand.
+`
+}
+
+func (*Command) SetFlags(flagSet *flag.FlagSet) {}
+
+func (c *Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	commander := subcommands.NewCommander(flagSet, c.Name())
+	commander.Register(&verifyCommand{authOpts: c.authOpts}, "")
+	commander.Register(commander.HelpCommand(), "command-help")
+	return commander.Execute(ctx)
+}
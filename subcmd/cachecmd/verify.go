@@ -0,0 +1,288 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cachecmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.chromium.org/build/siso/auth/cred"
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/build/cachestore"
+	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/signature"
+	"go.chromium.org/build/siso/signals"
+)
+
+const verifyUsage = `verify signatures of action results in caches.
+
+ $ siso cache verify -trusted_keys <keys.pem> [-cache_dir <dir>]
+ $ siso cache verify -trusted_keys <keys.pem> -local=false \
+      -project <project> -reapi_instance <instance> \
+      [-metrics <siso_metrics.json>] [<action digest>...]
+
+It checks all action results in the local cache, and action results
+in the remote action cache for the action digests given by args or
+recorded in siso_metrics.json, are signed by one of the trusted keys
+in <keys.pem>.
+It reports unverifiable action results, which are treated as cache
+miss by siso ninja with --re_cache_trusted_keys, and exits with
+failure if any.
+Note that results cached by remote execution workers are not signed,
+so siso ninja with --re_cache_trusted_keys executes such actions
+again without cache lookup.
+`
+
+func (*verifyCommand) Name() string {
+	return "verify"
+}
+
+func (*verifyCommand) Synopsis() string {
+	return "verify signatures of action results in caches"
+}
+
+func (*verifyCommand) Usage() string {
+	return verifyUsage
+}
+
+type verifyCommand struct {
+	Flags       *flag.FlagSet
+	authOpts    cred.Options
+	trustedKeys string
+	local       bool
+	cacheDir    string
+	projectID   string
+	reopt       *reapi.Option
+	metricsFile string
+}
+
+func (c *verifyCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.trustedKeys, "trusted_keys", "", "PEM ed25519 public keys file of trusted signers")
+	flagSet.BoolVar(&c.local, "local", true, "verify action results in the local cache")
+	flagSet.StringVar(&c.cacheDir, "cache_dir", defaultCacheDir(), "local cache directory")
+	flagSet.StringVar(&c.projectID, "project", os.Getenv("SISO_PROJECT"), "cloud project ID. can be set by $SISO_PROJECT")
+	c.reopt = new(reapi.Option)
+	c.reopt.RegisterFlags(flagSet, reapi.Envs("REAPI"))
+	flagSet.StringVar(&c.metricsFile, "metrics", "", "siso_metrics.json to get action digests to verify in the remote action cache")
+}
+
+func (c *verifyCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	c.Flags = flagSet
+	err := c.run(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, verifyUsage)
+			return subcommands.ExitUsageError
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func defaultCacheDir() string {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		log.Warningf("Failed to get user cache dir: %v", err)
+		return ""
+	}
+	return filepath.Join(d, "siso")
+}
+
+func (c *verifyCommand) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer signals.HandleInterrupt(ctx, cancel)()
+
+	if c.trustedKeys == "" {
+		return fmt.Errorf("no -trusted_keys: %w", flag.ErrHelp)
+	}
+	verifier, err := signature.LoadVerifier(c.trustedKeys)
+	if err != nil {
+		return err
+	}
+	var unverified int
+	if c.local && c.cacheDir != "" {
+		cache, err := build.NewLocalCache(c.cacheDir)
+		if err != nil {
+			return err
+		}
+		ds, err := cache.ActionDigests(ctx)
+		if err != nil {
+			return err
+		}
+		st, err := verifyActions(ctx, os.Stdout, cache, ds, verifier)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("local cache %s: %s\n", c.cacheDir, st)
+		unverified += st.unverified()
+	}
+
+	ds, err := c.remoteActionDigests()
+	if err != nil {
+		return err
+	}
+	if len(ds) > 0 {
+		c.reopt.UpdateProjectID(c.projectID)
+		err = c.reopt.CheckValid()
+		if err != nil {
+			return fmt.Errorf("reapi option is invalid: %w", err)
+		}
+		var credential cred.Cred
+		if c.reopt.NeedCred() {
+			credential, err = cred.New(ctx, c.reopt.ServiceURI(), c.authOpts)
+			if err != nil {
+				return err
+			}
+		}
+		client, err := reapi.New(ctx, credential, *c.reopt)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		st, err := verifyActions(ctx, os.Stdout, client.CacheStore(), ds, verifier)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("remote cache %s: %s\n", c.reopt.Instance, st)
+		unverified += st.unverified()
+	}
+	if unverified > 0 {
+		return fmt.Errorf("%d unverifiable action results", unverified)
+	}
+	return nil
+}
+
+// remoteActionDigests returns action digests to verify in
+// the remote action cache, given by args and -metrics.
+func (c *verifyCommand) remoteActionDigests() ([]digest.Digest, error) {
+	var ds []digest.Digest
+	seen := make(map[digest.Digest]bool)
+	add := func(s string) error {
+		d, err := digest.Parse(s)
+		if err != nil {
+			return err
+		}
+		if !seen[d] {
+			seen[d] = true
+			ds = append(ds, d)
+		}
+		return nil
+	}
+	for _, arg := range c.Flags.Args() {
+		err := add(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.metricsFile == "" {
+		return ds, nil
+	}
+	f, err := os.Open(c.metricsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	for {
+		var m build.StepMetric
+		err := dec.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse error in %s:%d: %w", c.metricsFile, dec.InputOffset(), err)
+		}
+		if m.Digest == "" {
+			continue
+		}
+		err = add(m.Digest)
+		if err != nil {
+			return nil, fmt.Errorf("bad digest in %s: %w", c.metricsFile, err)
+		}
+	}
+	return ds, nil
+}
+
+// verifyStats is stats of verified action results.
+type verifyStats struct {
+	verified   int
+	notSigned  int
+	untrusted  int
+	invalid    int
+	notFound   int
+	fetchError int
+}
+
+func (s verifyStats) unverified() int {
+	return s.notSigned + s.untrusted + s.invalid
+}
+
+func (s verifyStats) String() string {
+	return fmt.Sprintf("verified=%d not-signed=%d untrusted=%d invalid=%d not-found=%d error=%d", s.verified, s.notSigned, s.untrusted, s.invalid, s.notFound, s.fetchError)
+}
+
+// verifyActions verifies action results of ds in the store,
+// and reports unverifiable action results to w.
+func verifyActions(ctx context.Context, w io.Writer, store cachestore.CacheStore, ds []digest.Digest, verifier *signature.Verifier) (verifyStats, error) {
+	var mu sync.Mutex
+	var st verifyStats
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(100)
+	for _, d := range ds {
+		eg.Go(func() error {
+			ar, err := store.GetActionResult(ctx, d)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				err = verifier.Verify(d, ar)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				st.verified++
+				return nil
+			case errors.Is(err, signature.ErrNotSigned):
+				st.notSigned++
+			case errors.Is(err, signature.ErrUntrustedKey):
+				st.untrusted++
+			case errors.Is(err, signature.ErrInvalidSignature):
+				st.invalid++
+			case isNotFound(err):
+				st.notFound++
+				return nil
+			default:
+				st.fetchError++
+			}
+			fmt.Fprintf(w, "%s: %v\n", d, err)
+			return nil
+		})
+	}
+	err := eg.Wait()
+	return st, err
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || status.Code(err) == codes.NotFound
+}
@@ -0,0 +1,111 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package cachecmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/signature"
+)
+
+func TestVerifyActions(t *testing.T) {
+	ctx := t.Context()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := build.NewLocalCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	action := func(name string) digest.Digest {
+		return digest.FromBytes(name, []byte(name)).Digest()
+	}
+	result := func(name string) *rpb.ActionResult {
+		return &rpb.ActionResult{
+			OutputFiles: []*rpb.OutputFile{
+				{
+					Path:   name + ".o",
+					Digest: digest.FromBytes(name, []byte(name)).Digest().Proto(),
+				},
+			},
+		}
+	}
+	for name, signer := range map[string]*signature.Signer{
+		"signed":    signature.NewSigner(priv),
+		"untrusted": signature.NewSigner(otherPriv),
+		"unsigned":  nil,
+	} {
+		ar := result(name)
+		if signer != nil {
+			err := signer.Sign(action(name), ar)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := cache.SetActionResult(ctx, action(name), ar)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// modified after signed.
+	ar := result("modified")
+	err = signature.NewSigner(priv).Sign(action("modified"), ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar.OutputFiles[0].Digest = digest.FromBytes("evil", []byte("evil")).Digest().Proto()
+	err = cache.SetActionResult(ctx, action("modified"), ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ds, err := cache.ActionDigests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ds) != 4 {
+		t.Errorf("ActionDigests()=%v; want 4 digests", ds)
+	}
+	ds = append(ds, action("missing"))
+
+	var buf bytes.Buffer
+	st, err := verifyActions(ctx, &buf, cache, ds, signature.NewVerifier(pub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := verifyStats{
+		verified:  1,
+		notSigned: 1,
+		untrusted: 1,
+		invalid:   1,
+		notFound:  1,
+	}
+	if st != want {
+		t.Errorf("verifyActions(...)=%s; want %s", st, want)
+	}
+	if got := st.unverified(); got != 3 {
+		t.Errorf("unverified()=%d; want 3", got)
+	}
+	for _, name := range []string{"unsigned", "untrusted", "modified"} {
+		if !strings.Contains(buf.String(), action(name).String()) {
+			t.Errorf("output doesn't report %s\n%s", name, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), action("signed").String()) {
+		t.Errorf("output reports signed\n%s", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package configcmd provides config subcommand.
+package configcmd
+
+import (
+	"context"
+	"flag"
+
+	"github.com/google/subcommands"
+)
+
+// Cmd returns the Command for the `config` subcommand provided by this package.
+func Cmd() *Command {
+	return &Command{}
+}
+
+// Command implements config subcommand.
+type Command struct{}
+
+func (*Command) Name() string {
+	return "config"
+}
+
+func (*Command) Synopsis() string {
+	return "command group for siso starlark build config"
+}
+
+func (*Command) Usage() string {
+	return `command group for siso starlark build config
+
+Use "siso config" to display subcommands.
+Use "siso config help [subcommand]" for more information about a subcommand.
+`
+}
+
+func (*Command) SetFlags(flagSet *flag.FlagSet) {}
+
+func (c *Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	commander := subcommands.NewCommander(flagSet, c.Name())
+	commander.Register(&testCommand{}, "")
+	commander.Register(commander.HelpCommand(), "command-help")
+	return commander.Execute(ctx)
+}
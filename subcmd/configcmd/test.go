@@ -0,0 +1,196 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package configcmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/build/buildconfig"
+)
+
+const testUsage = `run unit tests of starlark build config.
+
+ $ siso config test [-C <dir>] [-run <regexp>] [<file>_test.star...]
+
+It discovers *_test.star files in -config_repo_dir if no files are
+given, and runs test_* functions defined in them.
+A test function takes a testing object t, e.g.
+
+  def test_init(t):
+      cfg = t.init(flags = {"config": "remote"})
+      t.eq(len(cfg.step_config["rules"]) > 0, True)
+
+  def test_stamp(t):
+      cfg = t.init()
+      cmd = t.cmd(outputs = ["out/siso/foo.stamp"])
+      cfg.handle("stamp", cmd)
+      t.eq(cmd.exit_status, 0)
+
+Files written by tests and handlers are kept in memory, and are not
+written to the disk.
+See docs/starlark_config.md for details.
+`
+
+type testCommand struct {
+	dir           string
+	configRepoDir string
+	run           string
+	verbose       bool
+}
+
+func (*testCommand) Name() string {
+	return "test"
+}
+
+func (*testCommand) Synopsis() string {
+	return "run unit tests of starlark build config"
+}
+
+func (*testCommand) Usage() string {
+	return testUsage
+}
+
+func (c *testCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory")
+	flagSet.StringVar(&c.configRepoDir, "config_repo_dir", "build/config/siso", "config repo directory (relative to exec root)")
+	flagSet.StringVar(&c.run, "run", "", "run only tests matching the regexp")
+	flagSet.BoolVar(&c.verbose, "v", false, "print all test results")
+}
+
+func (c *testCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	err := c.doTest(ctx, flagSet.Args())
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, testUsage)
+			return subcommands.ExitUsageError
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *testCommand) doTest(ctx context.Context, files []string) error {
+	var match func(string) bool
+	if c.run != "" {
+		re, err := regexp.Compile(c.run)
+		if err != nil {
+			return fmt.Errorf("bad -run %q: %w: %w", c.run, err, flag.ErrHelp)
+		}
+		match = re.MatchString
+	}
+	dir, err := filepath.Abs(c.dir)
+	if err != nil {
+		return err
+	}
+	execRoot := dir
+	configRepoDir := c.configRepoDir
+	if !filepath.IsAbs(configRepoDir) {
+		execRoot, err = build.DetectExecRoot(dir, configRepoDir)
+		if err != nil {
+			return err
+		}
+		configRepoDir = filepath.Join(execRoot, configRepoDir)
+	}
+	rdir, err := filepath.Rel(execRoot, dir)
+	if err != nil {
+		return err
+	}
+	rdir = filepath.ToSlash(rdir)
+	if len(files) == 0 {
+		files, err = findTestFiles(configRepoDir)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return fmt.Errorf("no *_test.star in %s", configRepoDir)
+		}
+	}
+
+	opt := buildconfig.TestOption{
+		Repos: map[string]fs.FS{
+			"config":           os.DirFS(configRepoDir),
+			"config_overrides": os.DirFS(filepath.Join(execRoot, ".siso_remote")),
+		},
+		Flags: map[string]string{
+			"dir": rdir,
+		},
+		Path:  build.NewPath(execRoot, rdir),
+		Match: match,
+	}
+
+	var npass, nfail int
+	started := time.Now()
+	for _, fname := range files {
+		fname = "@config//" + strings.TrimPrefix(filepath.ToSlash(fname), "@config//")
+		results, err := buildconfig.RunTests(ctx, fname, opt)
+		if err != nil {
+			fmt.Printf("FAIL\t%s\n%v\n", fname, err)
+			nfail++
+			continue
+		}
+		for _, r := range results {
+			if !r.Failed() {
+				npass++
+				if c.verbose {
+					fmt.Printf("--- PASS: %s (%.2fs)\n", r.Name, r.Duration.Seconds())
+				}
+				continue
+			}
+			nfail++
+			fmt.Printf("--- FAIL: %s (%.2fs)\n", r.Name, r.Duration.Seconds())
+			for _, e := range r.Errors {
+				fmt.Printf("    %s\n", strings.ReplaceAll(e, "\n", "\n    "))
+			}
+		}
+		status := "ok"
+		for _, r := range results {
+			if r.Failed() {
+				status = "FAIL"
+				break
+			}
+		}
+		fmt.Printf("%s\t%s\t%d tests\n", status, fname, len(results))
+	}
+	fmt.Printf("%d passed, %d failed in %s\n", npass, nfail, time.Since(started).Round(time.Millisecond))
+	if nfail > 0 {
+		return fmt.Errorf("%d tests failed", nfail)
+	}
+	return nil
+}
+
+// findTestFiles returns *_test.star files in dir, relative to dir.
+func findTestFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(pathname string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), "_test.star") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, pathname)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
@@ -52,6 +52,9 @@ func (c *Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any)
 	commander.Register(&exportCommand{}, "")
 	commander.Register(&flushCommand{authOpts: c.authOpts}, "")
 	commander.Register(&importCommand{}, "")
+	commander.Register(&reproAuditCommand{}, "")
+	commander.Register(&seedCommand{authOpts: c.authOpts}, "")
+	commander.Register(&snapshotCommand{authOpts: c.authOpts}, "")
 	commander.Register(commander.HelpCommand(), "command-help")
 	return commander.Execute(ctx)
 }
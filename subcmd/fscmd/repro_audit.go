@@ -0,0 +1,470 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/google/subcommands"
+	"google.golang.org/protobuf/proto"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+const reproAuditUsage = `audit reproducibility between two clean builds.
+
+ $ siso fs repro-audit -C <dir> \
+     [-fs_state .siso_fs_state] \
+     [-base_dir <base_dir>] [-fs_state_base .siso_fs_state.0] \
+     [-metrics siso_metrics.json -metrics_base siso_metrics.json.0]
+
+It compares generated outputs in .siso_fs_state (-fs_state) of <dir>
+with .siso_fs_state.0 (-fs_state_base) of <base_dir> (default <dir>).
+Paths are compared relative to each build directory, so two builds
+may run in different checkouts.
+
+An output whose digest differs while its cmdhash and the digests of all
+its inputs are equal is nondeterministic. Other differing outputs are
+propagated from nondeterministic outputs or from changed commands.
+Inputs of a step are taken from build.ninja (-f) and the deps log
+(-deps_log) in <dir>. Phony inputs are expanded to their inputs.
+
+Nondeterministic outputs are reported grouped by rule, with the
+outputs that differ only because of them. The rule is taken from
+siso_metrics.json (-metrics) if given, otherwise ninja's rule name.
+If both -metrics and -metrics_base are given, steps whose cmdhash
+differs in siso_metrics.json are also treated as changed commands.
+`
+
+func (*reproAuditCommand) Name() string {
+	return "repro-audit"
+}
+
+func (*reproAuditCommand) Synopsis() string {
+	return "audit reproducibility between two clean builds"
+}
+
+func (*reproAuditCommand) Usage() string {
+	return reproAuditUsage
+}
+
+type reproAuditCommand struct {
+	dir           string
+	baseDir       string
+	stateFile     string
+	stateFileBase string
+	fname         string
+	depsLogFile   string
+	metrics       string
+	metricsBase   string
+	format        string
+}
+
+func (c *reproAuditCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory")
+	flagSet.StringVar(&c.baseDir, "base_dir", "", "ninja running directory of the base build. default is the same as -C")
+	flagSet.StringVar(&c.stateFile, "fs_state", stateFile, "fs_state filename (relative to -C)")
+	flagSet.StringVar(&c.stateFileBase, "fs_state_base", stateFile+".0", "fs_state filename for the base build (relative to -base_dir)")
+	flagSet.StringVar(&c.fname, "f", "build.ninja", "input build filename (relative to -C)")
+	flagSet.StringVar(&c.depsLogFile, "deps_log", ".siso_deps", "deps log filename (relative to -C)")
+	flagSet.StringVar(&c.metrics, "metrics", "", "siso_metrics.json of the build (relative to -C)")
+	flagSet.StringVar(&c.metricsBase, "metrics_base", "", "siso_metrics.json of the base build (relative to -base_dir)")
+	flagSet.StringVar(&c.format, "format", "text", "output format. text or json")
+}
+
+func (c *reproAuditCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	report, err := c.run(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, reproAuditUsage)
+			return subcommands.ExitUsageError
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	switch c.format {
+	case "json":
+		buf, err := json.MarshalIndent(report, "", " ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "marshal error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		fmt.Printf("%s\n", buf)
+	default:
+		report.writeText(os.Stdout)
+	}
+	if len(report.Rules) > 0 {
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *reproAuditCommand) run(ctx context.Context) (*reproReport, error) {
+	switch c.format {
+	case "text", "json":
+	default:
+		return nil, fmt.Errorf("unknown format %q: %w", c.format, flag.ErrHelp)
+	}
+	if c.baseDir == "" {
+		c.baseDir = c.dir
+	}
+	dir, err := filepath.Abs(c.dir)
+	if err != nil {
+		return nil, err
+	}
+	baseDir, err := filepath.Abs(c.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	// fs state records paths with symlinks resolved.
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	baseDir, err = filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := loadRelState(ctx, dir, c.stateFile)
+	if err != nil {
+		return nil, err
+	}
+	base, err := loadRelState(ctx, baseDir, c.stateFileBase)
+	if err != nil {
+		return nil, err
+	}
+
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	p.SetWd(dir)
+	err = p.Load(ctx, c.fname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", c.fname, err)
+	}
+	depsLog, err := ninjautil.NewDepsLog(ctx, filepath.Join(dir, c.depsLogFile))
+	if err != nil {
+		return nil, err
+	}
+	defer depsLog.Close()
+
+	var rules map[string]string
+	cmdChanged := make(map[string]bool)
+	if c.metrics != "" {
+		curMetrics, err := loadStepMetrics(filepath.Join(dir, c.metrics))
+		if err != nil {
+			return nil, err
+		}
+		rules = make(map[string]string)
+		for _, m := range curMetrics {
+			rules[m.Output] = m.Rule
+		}
+		if c.metricsBase != "" {
+			baseMetrics, err := loadStepMetrics(filepath.Join(baseDir, c.metricsBase))
+			if err != nil {
+				return nil, err
+			}
+			baseCmdHash := make(map[string]string)
+			for _, m := range baseMetrics {
+				baseCmdHash[m.Output] = m.CmdHash
+			}
+			for _, m := range curMetrics {
+				if h, ok := baseCmdHash[m.Output]; ok && h != m.CmdHash {
+					cmdChanged[m.Output] = true
+				}
+			}
+		}
+	}
+
+	return auditRepro(cur, base, reproProducer(ctx, state, depsLog, rules, cmdChanged)), nil
+}
+
+// reproProducer returns a func to get the step that generates an output
+// in the ninja state.
+// rules and cmdChanged are keyed by output, taken from siso_metrics.json.
+func reproProducer(ctx context.Context, state *ninjautil.State, depsLog *ninjautil.DepsLog, rules map[string]string, cmdChanged map[string]bool) func(string) (reproStep, bool) {
+	return func(output string) (reproStep, bool) {
+		node, ok := state.LookupNodeByPath(output)
+		if !ok {
+			return reproStep{}, false
+		}
+		edge, ok := node.InEdge()
+		if !ok || edge.IsPhony() {
+			return reproStep{}, false
+		}
+		step := reproStep{
+			rule: edge.RuleName(),
+		}
+		for _, out := range edge.Outputs() {
+			if r, ok := rules[out.Path()]; ok {
+				step.rule = r
+			}
+			if cmdChanged[out.Path()] {
+				step.cmdChanged = true
+			}
+		}
+		step.inputs = appendTriggerInputs(step.inputs, edge, make(map[*ninjautil.Edge]bool))
+		deps, _, err := depsLog.RetrievePaths(ctx, edge.Outputs()[0].Path())
+		if err == nil {
+			step.inputs = append(step.inputs, deps...)
+		}
+		return step, true
+	}
+}
+
+// appendTriggerInputs appends paths of trigger inputs of edge to inputs.
+// phony inputs are expanded with seen, as they have no digest in fs state.
+func appendTriggerInputs(inputs []string, edge *ninjautil.Edge, seen map[*ninjautil.Edge]bool) []string {
+	for _, in := range edge.TriggerInputs() {
+		if inEdge, ok := in.InEdge(); ok && inEdge.IsPhony() {
+			if seen[inEdge] {
+				continue
+			}
+			seen[inEdge] = true
+			inputs = appendTriggerInputs(inputs, inEdge, seen)
+			continue
+		}
+		inputs = append(inputs, in.Path())
+	}
+	return inputs
+}
+
+// loadRelState loads fs state in dir and returns entries keyed by
+// paths relative to dir.
+func loadRelState(ctx context.Context, dir, fname string) (map[string]*pb.Entry, error) {
+	st, err := hashfs.Load(ctx, hashfs.Option{StateFile: filepath.Join(dir, fname)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", fname, err)
+	}
+	m := make(map[string]*pb.Entry)
+	for _, ent := range st.Entries {
+		rel, err := filepath.Rel(dir, filepath.FromSlash(ent.Name))
+		if err != nil {
+			continue
+		}
+		m[filepath.ToSlash(rel)] = ent
+	}
+	return m, nil
+}
+
+func loadStepMetrics(fname string) ([]build.StepMetric, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d := json.NewDecoder(f)
+	var metrics []build.StepMetric
+	for {
+		var m build.StepMetric
+		err := d.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse error in %s:%d: %w", fname, d.InputOffset(), err)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// reproStep is a step that generates an output.
+type reproStep struct {
+	rule       string
+	inputs     []string
+	cmdChanged bool
+}
+
+// reproReport is a result of reproducibility audit.
+type reproReport struct {
+	// Rules are nondeterministic outputs grouped by rule.
+	Rules []reproRule `json:"rules,omitempty"`
+	// CmdChanged are outputs whose command differs.
+	CmdChanged []string `json:"cmd_changed,omitempty"`
+	// Unknown are differing outputs whose producer is unknown.
+	Unknown []string `json:"unknown,omitempty"`
+}
+
+type reproRule struct {
+	Rule    string            `json:"rule"`
+	Outputs []nondetermOutput `json:"outputs"`
+}
+
+// nondetermOutput is a nondeterministic output, i.e. the first
+// nondeterministic producer in a chain of differing outputs.
+type nondetermOutput struct {
+	Output string `json:"output"`
+	Digest string `json:"digest"`
+	Base   string `json:"base_digest"`
+	// Propagated are outputs that differ because of this output.
+	Propagated []string `json:"propagated,omitempty"`
+}
+
+// auditRepro finds nondeterministic outputs between cur and base.
+func auditRepro(cur, base map[string]*pb.Entry, producer func(string) (reproStep, bool)) *reproReport {
+	digestDiffers := func(name string) bool {
+		c, b := cur[name], base[name]
+		if c == nil || b == nil {
+			return c != b
+		}
+		return !proto.Equal(c.Digest, b.Digest) || c.Target != b.Target
+	}
+
+	report := &reproReport{}
+	// roots[output] is nondeterministic outputs that output depends on.
+	roots := make(map[string][]string)
+	var visit func(output string) []string
+	visit = func(output string) []string {
+		if r, ok := roots[output]; ok {
+			return r
+		}
+		roots[output] = nil
+		step, ok := producer(output)
+		if !ok {
+			return nil
+		}
+		seen := make(map[string]bool)
+		var r []string
+		for _, in := range step.inputs {
+			if !digestDiffers(in) {
+				continue
+			}
+			for _, root := range visit(in) {
+				if !seen[root] {
+					seen[root] = true
+					r = append(r, root)
+				}
+			}
+		}
+		roots[output] = r
+		return r
+	}
+
+	var outputs []string
+	for name, c := range cur {
+		if _, ok := base[name]; !ok || len(c.CmdHash) == 0 || !digestDiffers(name) {
+			continue
+		}
+		outputs = append(outputs, name)
+	}
+	sort.Strings(outputs)
+
+	nondeterms := make(map[string]*nondetermOutput)
+	rules := make(map[string][]*nondetermOutput)
+	isRoot := make(map[string]bool)
+	for _, name := range outputs {
+		step, ok := producer(name)
+		if !ok {
+			report.Unknown = append(report.Unknown, name)
+			continue
+		}
+		if step.cmdChanged || !bytes.Equal(cur[name].CmdHash, base[name].CmdHash) {
+			report.CmdChanged = append(report.CmdChanged, name)
+			// changed command is not attributed to upstream.
+			roots[name] = nil
+			continue
+		}
+		inputsDiffer := false
+		for _, in := range step.inputs {
+			if digestDiffers(in) {
+				inputsDiffer = true
+				break
+			}
+		}
+		if inputsDiffer {
+			continue
+		}
+		isRoot[name] = true
+		n := &nondetermOutput{
+			Output: name,
+			Digest: digestString(cur[name].Digest),
+			Base:   digestString(base[name].Digest),
+		}
+		nondeterms[name] = n
+		rules[step.rule] = append(rules[step.rule], n)
+	}
+	// nondeterministic outputs are roots of their chain.
+	for name := range isRoot {
+		roots[name] = []string{name}
+	}
+	for _, name := range outputs {
+		if isRoot[name] {
+			continue
+		}
+		for _, root := range visit(name) {
+			n, ok := nondeterms[root]
+			if !ok {
+				continue
+			}
+			n.Propagated = append(n.Propagated, name)
+		}
+	}
+	for rule, outs := range rules {
+		r := reproRule{Rule: rule}
+		for _, o := range outs {
+			r.Outputs = append(r.Outputs, *o)
+		}
+		report.Rules = append(report.Rules, r)
+	}
+	sort.Slice(report.Rules, func(i, j int) bool {
+		if len(report.Rules[i].Outputs) != len(report.Rules[j].Outputs) {
+			return len(report.Rules[i].Outputs) > len(report.Rules[j].Outputs)
+		}
+		return report.Rules[i].Rule < report.Rules[j].Rule
+	})
+	return report
+}
+
+func digestString(d *pb.Digest) string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%d", d.Hash, d.SizeBytes)
+}
+
+func (r *reproReport) writeText(w io.Writer) {
+	if len(r.Rules) == 0 {
+		fmt.Fprintf(w, "no nondeterministic outputs\n")
+	}
+	for _, rule := range r.Rules {
+		fmt.Fprintf(w, "rule %s: %d nondeterministic outputs\n", rule.Rule, len(rule.Outputs))
+		for _, o := range rule.Outputs {
+			fmt.Fprintf(w, "  %s\n", o.Output)
+			fmt.Fprintf(w, "    digest: %s base: %s\n", o.Digest, o.Base)
+			if len(o.Propagated) > 0 {
+				fmt.Fprintf(w, "    propagated to %d outputs\n", len(o.Propagated))
+				for _, p := range o.Propagated {
+					fmt.Fprintf(w, "      %s\n", p)
+				}
+			}
+		}
+	}
+	if len(r.CmdChanged) > 0 {
+		fmt.Fprintf(w, "%d outputs with changed command\n", len(r.CmdChanged))
+		for _, name := range r.CmdChanged {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+	if len(r.Unknown) > 0 {
+		fmt.Fprintf(w, "%d outputs with unknown producer\n", len(r.Unknown))
+		for _, name := range r.Unknown {
+			fmt.Fprintf(w, "  %s\n", name)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+func TestAuditRepro(t *testing.T) {
+	ent := func(hash string, cmdhash string) *pb.Entry {
+		e := &pb.Entry{
+			Digest: &pb.Digest{Hash: hash, SizeBytes: 1},
+		}
+		if cmdhash != "" {
+			e.CmdHash = []byte(cmdhash)
+		}
+		return e
+	}
+	// src.cc -> a.o (nondeterministic) -> lib.a -> app
+	// gen.cc -> b.o (command changed)
+	// src2.cc -> c.o (same)
+	cur := map[string]*pb.Entry{
+		"src.cc":  ent("s", ""),
+		"src2.cc": ent("s2", ""),
+		"gen.cc":  ent("g", ""),
+		"a.o":     ent("a1", "cc"),
+		"b.o":     ent("b1", "cc2"),
+		"c.o":     ent("c", "cc"),
+		"lib.a":   ent("l1", "ar"),
+		"app":     ent("x1", "link"),
+	}
+	base := map[string]*pb.Entry{
+		"src.cc":  ent("s", ""),
+		"src2.cc": ent("s2", ""),
+		"gen.cc":  ent("g", ""),
+		"a.o":     ent("a0", "cc"),
+		"b.o":     ent("b0", "cc1"),
+		"c.o":     ent("c", "cc"),
+		"lib.a":   ent("l0", "ar"),
+		"app":     ent("x0", "link"),
+	}
+	steps := map[string]reproStep{
+		"a.o":   {rule: "cxx", inputs: []string{"src.cc"}},
+		"b.o":   {rule: "cxx", inputs: []string{"gen.cc"}},
+		"c.o":   {rule: "cxx", inputs: []string{"src2.cc"}},
+		"lib.a": {rule: "alink", inputs: []string{"a.o", "c.o"}},
+		"app":   {rule: "link", inputs: []string{"lib.a", "b.o"}},
+	}
+	got := auditRepro(cur, base, func(output string) (reproStep, bool) {
+		s, ok := steps[output]
+		return s, ok
+	})
+	want := &reproReport{
+		Rules: []reproRule{
+			{
+				Rule: "cxx",
+				Outputs: []nondetermOutput{
+					{
+						Output:     "a.o",
+						Digest:     "a1/1",
+						Base:       "a0/1",
+						Propagated: []string{"app", "lib.a"},
+					},
+				},
+			},
+		},
+		CmdChanged: []string{"b.o"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("auditRepro(...) diff -want +got:\n%s", diff)
+	}
+}
+
+func TestAuditRepro_PhonyInputs(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "build.ninja"), []byte(`
+rule gen
+  command = gen $out
+rule cc
+  command = cc -c $in -o $out
+build gen/a.h: gen
+build gen_headers: phony gen/a.h
+build all_headers: phony gen_headers gen_headers
+build foo.o: cc ../foo.c | all_headers
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	p.SetWd(dir)
+	err = p.Load(ctx, "build.ninja")
+	if err != nil {
+		t.Fatal(err)
+	}
+	depsLog, err := ninjautil.NewDepsLog(ctx, filepath.Join(dir, ".siso_deps"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer depsLog.Close()
+
+	ent := func(hash string, cmdhash string) *pb.Entry {
+		e := &pb.Entry{
+			Digest: &pb.Digest{Hash: hash, SizeBytes: 1},
+		}
+		if cmdhash != "" {
+			e.CmdHash = []byte(cmdhash)
+		}
+		return e
+	}
+	// gen/a.h (nondeterministic) -> gen_headers -> all_headers -> foo.o
+	cur := map[string]*pb.Entry{
+		"../foo.c": ent("f", ""),
+		"gen/a.h":  ent("h1", "gen"),
+		"foo.o":    ent("o1", "cc"),
+	}
+	base := map[string]*pb.Entry{
+		"../foo.c": ent("f", ""),
+		"gen/a.h":  ent("h0", "gen"),
+		"foo.o":    ent("o0", "cc"),
+	}
+	got := auditRepro(cur, base, reproProducer(ctx, state, depsLog, nil, nil))
+	want := &reproReport{
+		Rules: []reproRule{
+			{
+				Rule: "gen",
+				Outputs: []nondetermOutput{
+					{
+						Output:     "gen/a.h",
+						Digest:     "h1/1",
+						Base:       "h0/1",
+						Propagated: []string{"foo.o"},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("auditRepro(...) diff -want +got:\n%s", diff)
+	}
+}
@@ -0,0 +1,637 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sync/errgroup"
+
+	"go.chromium.org/build/siso/auth/cred"
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/hashfs/osfs"
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/runtimex"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+const seedUsage = `seed local out dir from other build's fs state.
+
+ $ siso fs seed -C <dir> -from <file of .siso_fs_state> [-from_deps <file of .siso_deps>]
+ $ siso fs seed -project <projectID> -C <dir> -from <hash>/<size>
+
+It adopts generated file entries of other build (e.g. CI build of
+the same revision with the same GN args) into local .siso_fs_state.
+-from is a file of fs state, or digest of fs state in CAS.
+
+A generated file is adopted when its cmdhash matches with the step
+in local build.ninja (-f), and all inputs of the step match with the
+local tree, i.e. source files have the same digests, and generated
+inputs are also adopted.
+Inputs only discovered by deps (e.g. headers) are recorded in deps log
+(.siso_deps) of other build, given by -from_deps. Outputs of steps
+using deps=gcc/msvc are adopted only when all deps in -from_deps match
+with the local tree, and their deps are recorded in local deps log
+(-deps_log), so the build doesn't need to run them to get deps.
+Without -from_deps, they are not adopted and reported.
+Outputs of steps using depfile are adopted with the depfile only when
+all mismatched source files are explicit inputs in build.ninja.
+
+Adopted files are recorded as remote-only entries, and fetched
+lazily by the build, or by "siso fs flush".
+Existing local entries and files are kept, unless -force is given.
+
+Paths are mapped relative to the build directory. The build directory
+of other build is detected from build.ninja outputs, or set by -from_dir.
+`
+
+func (*seedCommand) Name() string {
+	return "seed"
+}
+
+func (*seedCommand) Synopsis() string {
+	return "seed local out dir from other build's fs state"
+}
+
+func (*seedCommand) Usage() string {
+	return seedUsage
+}
+
+type seedCommand struct {
+	Flags     *flag.FlagSet
+	authOpts  cred.Options
+	dir       string
+	stateFile string
+	depsLog   string
+	fname     string
+	from      string
+	fromDir   string
+	fromDeps  string
+	projectID string
+	reopt     *reapi.Option
+	force     bool
+}
+
+func (c *seedCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory")
+	flagSet.StringVar(&c.stateFile, "fs_state", stateFile, "fs_state filename")
+	flagSet.StringVar(&c.depsLog, "deps_log", ".siso_deps", "deps log filename to record deps of adopted steps")
+	flagSet.StringVar(&c.fname, "f", "build.ninja", "input build manifest filename (relative to -C)")
+	flagSet.StringVar(&c.from, "from", "", "fs state to seed from. file name, or digest in CAS")
+	flagSet.StringVar(&c.fromDir, "from_dir", "", "build directory of the fs state to seed from. detected if empty")
+	flagSet.StringVar(&c.fromDeps, "from_deps", "", "deps log file of the build to seed from. steps using deps are not adopted if empty")
+	flagSet.StringVar(&c.projectID, "project", os.Getenv("SISO_PROJECT"), "cloud project ID. can be set by $SISO_PROJECT")
+	c.reopt = new(reapi.Option)
+	c.reopt.RegisterFlags(flagSet, reapi.Envs("REAPI"))
+	flagSet.BoolVar(&c.force, "force", false, "overwrite existing local entries and files")
+}
+
+func (c *seedCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	c.Flags = flagSet
+	err := c.run(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, seedUsage)
+			return subcommands.ExitUsageError
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *seedCommand) run(ctx context.Context) error {
+	if c.from == "" {
+		return fmt.Errorf("no -from: %w", flag.ErrHelp)
+	}
+	from, err := c.loadFrom(ctx)
+	if err != nil {
+		return err
+	}
+	err = os.Chdir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to chdir %s: %w", c.dir, err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	// fs state records paths with symlinks resolved.
+	wd, err = filepath.EvalSymlinks(wd)
+	if err != nil {
+		return err
+	}
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	p.SetWd(wd)
+	err = p.Load(ctx, c.fname)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", c.fname, err)
+	}
+	local, err := hashfs.Load(ctx, hashfs.Option{StateFile: c.stateFile})
+	if errors.Is(err, fs.ErrNotExist) {
+		local = &pb.State{}
+	} else if err != nil {
+		return fmt.Errorf("failed to load %s: %w", c.stateFile, err)
+	}
+	hashfs.LoadJournal(ctx, c.stateFile+".journal", local)
+
+	started := time.Now()
+	s := &seeder{
+		state:  state,
+		wd:     wd,
+		ofs:    osfs.New(ctx, "fs", osfs.Option{}),
+		now:    time.Now(),
+		force:  c.force,
+		adopts: make(map[string]bool),
+	}
+	if c.fromDeps != "" {
+		// NewDepsLog creates the file if it doesn't exist.
+		if _, err := os.Stat(c.fromDeps); err != nil {
+			return fmt.Errorf("failed to load -from_deps: %w", err)
+		}
+		s.fromDeps, err = ninjautil.NewDepsLog(ctx, c.fromDeps)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", c.fromDeps, err)
+		}
+		defer s.fromDeps.Close()
+	}
+	n, err := s.seed(ctx, from, local, c.fromDir)
+	if err != nil {
+		return err
+	}
+	s.reportExcluded()
+	if n == 0 {
+		fmt.Printf("no entries to seed in %s\n", time.Since(started))
+		return nil
+	}
+	if len(s.seededDeps) > 0 {
+		err = s.recordDeps(ctx, c.depsLog)
+		if err != nil {
+			return fmt.Errorf("failed to record deps in %s: %w", c.depsLog, err)
+		}
+	}
+	err = saveSeeded(ctx, c.stateFile, local)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("seeded %d entries (deps of %d steps) from %s in %s\n", n, len(s.seededDeps), c.from, time.Since(started))
+	return nil
+}
+
+// saveSeeded saves seeded fs state in stateFile.
+// The journal was already merged in st, so it is removed not to be
+// applied over the seeded entries by the next build.
+func saveSeeded(ctx context.Context, stateFile string, st *pb.State) error {
+	err := hashfs.Save(ctx, st, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %w", stateFile, err)
+	}
+	// hashfs.Save removes it, but make sure it is gone.
+	err = os.Remove(stateFile + ".journal")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// loadFrom loads fs state specified by -from,
+// from local file or from CAS.
+func (c *seedCommand) loadFrom(ctx context.Context) (*pb.State, error) {
+	if _, err := os.Stat(c.from); err == nil {
+		st, err := hashfs.Load(ctx, hashfs.Option{StateFile: c.from})
+		if err != nil {
+			return nil, fmt.Errorf("failed to load %s: %w", c.from, err)
+		}
+		return st, nil
+	}
+	d, err := digest.Parse(c.from)
+	if err != nil {
+		return nil, fmt.Errorf("-from %q is neither file nor digest: %w", c.from, err)
+	}
+	c.reopt.UpdateProjectID(c.projectID)
+	err = c.reopt.CheckValid()
+	if err != nil {
+		return nil, fmt.Errorf("reapi option is invalid: %w", err)
+	}
+	var credential cred.Cred
+	if c.reopt.NeedCred() {
+		credential, err = cred.New(ctx, c.reopt.ServiceURI(), c.authOpts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	client, err := reapi.New(ctx, credential, *c.reopt)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	b, err := client.Get(ctx, d, stateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", d, err)
+	}
+	// hashfs.Load reads from file, to handle compressed state.
+	f, err := os.CreateTemp("", "siso_fs_state.*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	st, err := hashfs.Load(ctx, hashfs.Option{StateFile: f.Name()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fs state %s: %w", d, err)
+	}
+	return st, nil
+}
+
+// seeder adopts generated file entries of other build into local state.
+type seeder struct {
+	state *ninjautil.State
+	wd    string
+	ofs   *osfs.OSFS
+	now   time.Time
+	force bool
+
+	// entries of other build, keyed by path relative to build dir.
+	from map[string]*pb.Entry
+
+	// mismatched source files.
+	mismatched map[string]bool
+	// whether mismatched source files include ones
+	// not known in build.ninja, e.g. headers.
+	unknownMismatched bool
+
+	// adopts memoizes adopt results. it is false while checking
+	// the output, so cycle doesn't adopt.
+	adopts map[string]bool
+
+	// fromDeps is deps log of other build.
+	fromDeps *ninjautil.DepsLog
+	// deps are deps of adoptable outputs of steps using deps.
+	deps map[string][]string
+	// depfiles are depfiles of adoptable outputs of steps using depfile.
+	depfiles map[string]string
+	// excluded are outputs of steps not adopted for missing deps info,
+	// and its reason.
+	excluded map[string]string
+
+	// seededDeps are deps of seeded outputs, keyed by output
+	// relative to build dir, to record in local deps log.
+	seededDeps map[string][]string
+}
+
+// seed adopts entries in from into local, and returns
+// the number of adopted entries.
+func (s *seeder) seed(ctx context.Context, from, local *pb.State, fromDir string) (int, error) {
+	if fromDir == "" {
+		fromDir = s.detectFromDir(from)
+		if fromDir == "" {
+			return 0, errors.New("failed to detect build directory of -from. use -from_dir")
+		}
+	}
+	s.from = make(map[string]*pb.Entry)
+	for _, ent := range from.Entries {
+		rel, err := filepath.Rel(filepath.FromSlash(fromDir), filepath.FromSlash(ent.Name))
+		if err != nil {
+			continue
+		}
+		s.from[filepath.ToSlash(rel)] = ent
+	}
+	err := s.checkSources(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.deps = make(map[string][]string)
+	s.depfiles = make(map[string]string)
+	s.excluded = make(map[string]string)
+	s.seededDeps = make(map[string][]string)
+	localMap := hashfs.StateMap(local)
+	var rels []string
+	for rel, ent := range s.from {
+		if len(ent.CmdHash) == 0 || ent.Digest.GetHash() == "" {
+			continue
+		}
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	var n int
+	for _, rel := range rels {
+		if !s.adopt(ctx, rel) {
+			continue
+		}
+		if !s.add(localMap, rel) {
+			continue
+		}
+		n++
+		if depfile, ok := s.depfiles[rel]; ok && s.add(localMap, depfile) {
+			n++
+		}
+		if deps, ok := s.deps[rel]; ok {
+			s.seededDeps[rel] = deps
+		}
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	names := make([]string, 0, len(localMap))
+	for name := range localMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	local.Entries = make([]*pb.Entry, 0, len(names))
+	for _, name := range names {
+		local.Entries = append(local.Entries, localMap[name])
+	}
+	return n, nil
+}
+
+// add adds entry of rel in other build into localMap,
+// unless local entry or file exists without force.
+func (s *seeder) add(localMap map[string]*pb.Entry, rel string) bool {
+	name := filepath.ToSlash(filepath.Join(s.wd, rel))
+	if !s.force {
+		if _, ok := localMap[name]; ok {
+			return false
+		}
+		if _, err := os.Lstat(name); err == nil {
+			return false
+		}
+	}
+	ent := s.from[rel]
+	localMap[name] = &pb.Entry{
+		Id:           &pb.FileID{ModTime: s.now.UnixNano()},
+		Name:         name,
+		Digest:       ent.Digest,
+		IsExecutable: ent.IsExecutable,
+		Target:       ent.Target,
+		CmdHash:      ent.CmdHash,
+		Action:       ent.Action,
+		UpdatedTime:  s.now.UnixNano(),
+		EdgeHash:     ent.EdgeHash,
+	}
+	return true
+}
+
+// recordDeps records deps of seeded outputs in local deps log.
+// deps time is the same as mtime of seeded entries,
+// so the deps log is valid for them.
+func (s *seeder) recordDeps(ctx context.Context, fname string) error {
+	depsLog, err := ninjautil.NewDepsLog(ctx, fname)
+	if err != nil {
+		return err
+	}
+	outs := make([]string, 0, len(s.seededDeps))
+	for out := range s.seededDeps {
+		outs = append(outs, out)
+	}
+	sort.Strings(outs)
+	for _, out := range outs {
+		_, err := depsLog.Record(ctx, out, s.now, s.seededDeps[out])
+		if err != nil {
+			depsLog.Close()
+			return err
+		}
+	}
+	return depsLog.Close()
+}
+
+// reportExcluded reports outputs not adopted for missing deps info.
+func (s *seeder) reportExcluded() {
+	if len(s.excluded) == 0 {
+		return
+	}
+	rels := make([]string, 0, len(s.excluded))
+	for rel := range s.excluded {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	fmt.Printf("%d outputs of steps using deps are not adopted, and will be rebuilt\n", len(rels))
+	const maxReports = 10
+	for i, rel := range rels {
+		if i == maxReports {
+			fmt.Printf(" ...\n")
+			break
+		}
+		fmt.Printf(" %s: %s\n", rel, s.excluded[rel])
+	}
+}
+
+// detectFromDir detects build directory of from,
+// by matching generated files with outputs in build.ninja.
+func (s *seeder) detectFromDir(from *pb.State) string {
+	for _, ent := range from.Entries {
+		if len(ent.CmdHash) == 0 {
+			continue
+		}
+		name := ent.Name
+		var dir string
+		for i := strings.Index(name, "/"); i >= 0; {
+			node, ok := s.state.LookupNodeByPath(name[i+1:])
+			if ok {
+				if _, ok := node.InEdge(); ok {
+					dir = name[:i]
+				}
+			}
+			j := strings.Index(name[i+1:], "/")
+			if j < 0 {
+				break
+			}
+			i += j + 1
+		}
+		if dir != "" {
+			return dir
+		}
+	}
+	return ""
+}
+
+// checkSources checks source files of other build
+// match with local tree.
+func (s *seeder) checkSources(ctx context.Context) error {
+	var mu sync.Mutex
+	s.mismatched = make(map[string]bool)
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(runtimex.NumCPU())
+	for rel, ent := range s.from {
+		if len(ent.CmdHash) > 0 || ent.Digest.GetHash() == "" {
+			continue
+		}
+		eg.Go(func() error {
+			if s.sourceMatch(ctx, rel, ent) {
+				return nil
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			s.mismatched[rel] = true
+			if _, ok := s.state.LookupNodeByPath(rel); !ok {
+				s.unknownMismatched = true
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+func (s *seeder) sourceMatch(ctx context.Context, rel string, ent *pb.Entry) bool {
+	fi, err := os.Stat(filepath.Join(s.wd, rel))
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() != ent.Digest.SizeBytes {
+		return false
+	}
+	d, err := digest.FromLocalFile(ctx, s.ofs.FileSource(filepath.Join(s.wd, rel), fi.Size()))
+	if err != nil {
+		return false
+	}
+	return d.Digest().Hash == ent.Digest.Hash
+}
+
+// adopt reports whether generated file rel can be adopted.
+func (s *seeder) adopt(ctx context.Context, rel string) bool {
+	if ok, checked := s.adopts[rel]; checked {
+		return ok
+	}
+	s.adopts[rel] = false
+	ok := s.checkAdopt(ctx, rel)
+	s.adopts[rel] = ok
+	return ok
+}
+
+func (s *seeder) checkAdopt(ctx context.Context, rel string) bool {
+	ent, ok := s.from[rel]
+	if !ok || len(ent.CmdHash) == 0 {
+		return false
+	}
+	node, ok := s.state.LookupNodeByPath(rel)
+	if !ok {
+		return false
+	}
+	edge, ok := node.InEdge()
+	if !ok || edge.IsPhony() {
+		return false
+	}
+	if !bytes.Equal(edge.CmdHash(), ent.CmdHash) {
+		return false
+	}
+	switch edge.Binding("deps") {
+	case "gcc", "msvc":
+		// the build needs deps log for the output.
+		if !s.inputsMatch(ctx, edge, make(map[*ninjautil.Edge]bool)) {
+			return false
+		}
+		deps, ok := s.depsMatch(ctx, rel)
+		if !ok {
+			return false
+		}
+		s.deps[rel] = deps
+		return true
+	case "":
+		depfile := edge.UnescapedBinding("depfile")
+		if depfile == "" {
+			break
+		}
+		if s.unknownMismatched {
+			return false
+		}
+		if !s.inputsMatch(ctx, edge, make(map[*ninjautil.Edge]bool)) {
+			return false
+		}
+		// the build reads the depfile for deps.
+		ent, ok := s.from[depfile]
+		if !ok || ent.Digest.GetHash() == "" {
+			s.excluded[rel] = fmt.Sprintf("no depfile %s in -from", depfile)
+			return false
+		}
+		s.depfiles[rel] = depfile
+		return true
+	}
+	return s.inputsMatch(ctx, edge, make(map[*ninjautil.Edge]bool))
+}
+
+// depsMatch reports whether all deps of rel in deps log of other build
+// match with local tree, and returns the deps.
+func (s *seeder) depsMatch(ctx context.Context, rel string) ([]string, bool) {
+	if s.fromDeps == nil {
+		s.excluded[rel] = "no -from_deps"
+		return nil, false
+	}
+	deps, _, err := s.fromDeps.RetrievePaths(ctx, rel)
+	if err != nil {
+		s.excluded[rel] = fmt.Sprintf("no deps in -from_deps: %v", err)
+		return nil, false
+	}
+	for _, dep := range deps {
+		dep = path.Clean(filepath.ToSlash(dep))
+		ent, ok := s.from[dep]
+		if !ok {
+			// not recorded in other build, so can't tell it matches.
+			return nil, false
+		}
+		if len(ent.CmdHash) > 0 {
+			if !s.adopt(ctx, dep) {
+				return nil, false
+			}
+			continue
+		}
+		if s.mismatched[dep] {
+			return nil, false
+		}
+	}
+	return deps, true
+}
+
+// inputsMatch reports whether all inputs of edge match with local tree.
+// phony inputs are expanded with seen.
+func (s *seeder) inputsMatch(ctx context.Context, edge *ninjautil.Edge, seen map[*ninjautil.Edge]bool) bool {
+	for _, in := range edge.TriggerInputs() {
+		rel := in.Path()
+		if inEdge, ok := in.InEdge(); ok && inEdge.IsPhony() {
+			if seen[inEdge] {
+				continue
+			}
+			seen[inEdge] = true
+			if !s.inputsMatch(ctx, inEdge, seen) {
+				return false
+			}
+			continue
+		}
+		ent, ok := s.from[rel]
+		if !ok {
+			// not used in other build, so can't tell it matches.
+			return false
+		}
+		if len(ent.CmdHash) > 0 {
+			if !s.adopt(ctx, rel) {
+				return false
+			}
+			continue
+		}
+		if s.mismatched[rel] {
+			return false
+		}
+	}
+	return true
+}
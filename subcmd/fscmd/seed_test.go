@@ -0,0 +1,253 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/hashfs/osfs"
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+func TestSeed(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	wd := filepath.Join(root, "out")
+	for name, content := range map[string]string{
+		"a.c": "a",
+		"b.c": "b local",
+		"c.c": "c",
+		"c.h": "c header",
+		"out/build.ninja": `
+rule cc
+  command = cc -c $in -o $out
+  deps = gcc
+  depfile = $out.d
+rule gen
+  command = gen $in $out
+rule link
+  command = link $in -o $out
+build a.o: cc ../a.c
+build b.o: cc ../b.c
+build c.txt: gen ../c.c
+build all.exe: link a.o b.o
+build a.exe: link a.o
+build all: phony a.exe all.exe c.txt
+`,
+	} {
+		fname := filepath.Join(root, name)
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	p.SetWd(wd)
+	err := p.Load(ctx, "build.ninja")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdhash := func(output string) []byte {
+		t.Helper()
+		node, ok := state.LookupNodeByPath(output)
+		if !ok {
+			t.Fatalf("no node for %q", output)
+		}
+		edge, _ := node.InEdge()
+		return edge.CmdHash()
+	}
+	ent := func(name, content string, cmdhash []byte) *pb.Entry {
+		d := digest.FromBytes(name, []byte(content)).Digest()
+		return &pb.Entry{
+			Id:      &pb.FileID{ModTime: 1},
+			Name:    name,
+			Digest:  &pb.Digest{Hash: d.Hash, SizeBytes: d.SizeBytes},
+			CmdHash: cmdhash,
+		}
+	}
+	from := &pb.State{
+		Entries: []*pb.Entry{
+			ent("/ci/src/a.c", "a", nil),
+			ent("/ci/src/b.c", "b", nil),
+			ent("/ci/src/c.c", "c", nil),
+			ent("/ci/src/c.h", "c header", nil),
+			ent("/ci/src/out/a.o", "a.o", cmdhash("a.o")),
+			ent("/ci/src/out/b.o", "b.o", cmdhash("b.o")),
+			ent("/ci/src/out/c.txt", "c.txt", cmdhash("c.txt")),
+			ent("/ci/src/out/all.exe", "all.exe", cmdhash("all.exe")),
+			ent("/ci/src/out/a.exe", "a.exe", []byte("other cmdhash")),
+		},
+	}
+	local := &pb.State{}
+	now := time.Now()
+	s := &seeder{
+		state:  state,
+		wd:     wd,
+		ofs:    osfs.New(ctx, "fs", osfs.Option{}),
+		now:    now,
+		adopts: make(map[string]bool),
+	}
+	n, err := s.seed(ctx, from, local, "")
+	if err != nil {
+		t.Fatalf("seed(...)=%d, %v; want nil err", n, err)
+	}
+	var got []string
+	for _, ent := range local.Entries {
+		rel, err := filepath.Rel(wd, filepath.FromSlash(ent.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+		if ent.Id.ModTime != now.UnixNano() || ent.Local {
+			t.Errorf("%s: mtime=%d local=%t; want mtime=%d local=false", rel, ent.Id.ModTime, ent.Local, now.UnixNano())
+		}
+	}
+	sort.Strings(got)
+	// a.o: no deps log. b.o: b.c mismatch.
+	// all.exe: b.o is not adopted.
+	// a.exe: cmdhash mismatch.
+	want := []string{"c.txt"}
+	if diff := cmp.Diff(want, got); diff != "" || n != len(want) {
+		t.Errorf("seed(...)=%d; diff -want +got:\n%s", n, diff)
+	}
+	if diff := cmp.Diff(map[string]string{"a.o": "no -from_deps"}, s.excluded); diff != "" {
+		t.Errorf("excluded diff -want +got:\n%s", diff)
+	}
+
+	// with deps log of other build.
+	fromDepsFile := filepath.Join(t.TempDir(), ".siso_deps")
+	fromDeps, err := ninjautil.NewDepsLog(ctx, fromDepsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for out, deps := range map[string][]string{
+		"a.o": {"../a.c", "../c.h"},
+		"b.o": {"../b.c"},
+	} {
+		_, err := fromDeps.Record(ctx, out, now, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = fromDeps.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.fromDeps, err = ninjautil.NewDepsLog(ctx, fromDepsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.fromDeps.Close()
+	local = &pb.State{}
+	s.adopts = make(map[string]bool)
+	_, err = s.seed(ctx, from, local, "/ci/src/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, ent := range local.Entries {
+		got = append(got, filepath.Base(ent.Name))
+	}
+	want = []string{"a.o", "c.txt"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("seed(...) with deps log; diff -want +got:\n%s", diff)
+	}
+	depsFile := filepath.Join(wd, ".siso_deps")
+	err = s.recordDeps(ctx, depsFile)
+	if err != nil {
+		t.Fatalf("recordDeps=%v; want nil err", err)
+	}
+	depsLog, err := ninjautil.NewDepsLog(ctx, depsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer depsLog.Close()
+	deps, depsTime, err := depsLog.RetrievePaths(ctx, "a.o")
+	if err != nil || !depsTime.Equal(now) {
+		t.Errorf("RetrievePaths(a.o)=_, %v, %v; want %v, nil", depsTime, err, now)
+	}
+	if diff := cmp.Diff([]string{"../a.c", "../c.h"}, deps); diff != "" {
+		t.Errorf("deps of a.o diff -want +got:\n%s", diff)
+	}
+
+	// header mismatch makes steps using the header not adoptable.
+	err = os.WriteFile(filepath.Join(root, "c.h"), []byte("c header local"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local = &pb.State{}
+	s.adopts = make(map[string]bool)
+	_, err = s.seed(ctx, from, local, "/ci/src/out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, ent := range local.Entries {
+		got = append(got, filepath.Base(ent.Name))
+	}
+	want = []string{"c.txt"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("seed(...) with header mismatch; diff -want +got:\n%s", diff)
+	}
+}
+
+func TestSaveSeeded(t *testing.T) {
+	ctx := t.Context()
+	stateFile := filepath.Join(t.TempDir(), ".siso_fs_state")
+	ent := func(content string) *pb.Entry {
+		d := digest.FromBytes("c.txt", []byte(content)).Digest()
+		return &pb.Entry{
+			Id:      &pb.FileID{ModTime: 1},
+			Name:    "/src/out/c.txt",
+			Digest:  &pb.Digest{Hash: d.Hash, SizeBytes: d.SizeBytes},
+			CmdHash: []byte("cmdhash"),
+		}
+	}
+	// journal of the last local build, already merged in seeded state.
+	var journal bytes.Buffer
+	err := hashfs.JournalEntry(&journal, ent("c.txt local"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(stateFile+".journal", journal.Bytes(), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeded := &pb.State{Entries: []*pb.Entry{ent("c.txt")}}
+	err = saveSeeded(ctx, stateFile, seeded)
+	if err != nil {
+		t.Fatalf("saveSeeded=%v; want nil err", err)
+	}
+	if _, err := os.Stat(stateFile + ".journal"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("journal stat=%v; want %v", err, fs.ErrNotExist)
+	}
+	got, err := hashfs.Load(ctx, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashfs.LoadJournal(ctx, stateFile+".journal", got)
+	if diff := cmp.Diff(seeded.Entries, got.Entries, protocmp.Transform()); diff != "" {
+		t.Errorf("loaded entries diff -want +got:\n%s", diff)
+	}
+}
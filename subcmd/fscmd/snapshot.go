@@ -0,0 +1,422 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/auth/cred"
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/hashfs/osfs"
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+const snapshotUsage = `save or restore named snapshot of out-dir state.
+
+ $ siso fs snapshot -C <dir> save <name>
+ $ siso fs snapshot -C <dir> [-project <projectID>] restore <name>
+ $ siso fs snapshot -C <dir> list
+
+save copies .siso_fs_state (-fs_state) and .siso_deps (-deps_log)
+in -state_dir into .siso_snapshots/<name> in -state_dir.
+
+restore copies them back from the snapshot.
+Generated files on the disk that don't match the snapshot are removed,
+and recorded as remote-only entries with digests, so the next build
+after switching back is near no-op.
+Removed files are fetched lazily by the build, or by "siso fs flush".
+Only files whose content is available in the local cache (-cache_dir)
+or in CAS are removed. Entries of other files are dropped from the
+restored state, so the next build reruns their steps.
+`
+
+// snapshotDir is a directory name for snapshots in state dir.
+const snapshotDir = ".siso_snapshots"
+
+func (*snapshotCommand) Name() string {
+	return "snapshot"
+}
+
+func (*snapshotCommand) Synopsis() string {
+	return "save or restore named snapshot of out-dir state"
+}
+
+func (*snapshotCommand) Usage() string {
+	return snapshotUsage
+}
+
+type snapshotCommand struct {
+	authOpts    cred.Options
+	dir         string
+	stateDir    string
+	stateFile   string
+	depsLogFile string
+	cacheDir    string
+	projectID   string
+	reopt       *reapi.Option
+}
+
+func (c *snapshotCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory")
+	flagSet.StringVar(&c.stateDir, "state_dir", ".", "state directory (relative to -C)")
+	flagSet.StringVar(&c.stateFile, "fs_state", stateFile, "fs_state filename (relative to -state_dir)")
+	flagSet.StringVar(&c.depsLogFile, "deps_log", ".siso_deps", "deps log filename (relative to -state_dir)")
+	flagSet.StringVar(&c.cacheDir, "cache_dir", defaultCacheDir(), "local cache directory to check contents of restored files")
+	flagSet.StringVar(&c.projectID, "project", os.Getenv("SISO_PROJECT"), "cloud project ID. can be set by $SISO_PROJECT")
+	c.reopt = new(reapi.Option)
+	c.reopt.RegisterFlags(flagSet, reapi.Envs("REAPI"))
+}
+
+func defaultCacheDir() string {
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(d, "siso")
+}
+
+func (c *snapshotCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	err := c.run(ctx, flagSet.Args())
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, snapshotUsage)
+			return subcommands.ExitUsageError
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *snapshotCommand) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no snapshot operation: %w", flag.ErrHelp)
+	}
+	err := os.Chdir(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to chdir %s: %w", c.dir, err)
+	}
+	op, args := args[0], args[1:]
+	if op == "list" {
+		names, err := listSnapshots(c.stateDir)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("%s needs snapshot name: %w", op, flag.ErrHelp)
+	}
+	name := args[0]
+	if name == "" || name == "." || name == ".." || filepath.Base(name) != name {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	sdir := filepath.Join(c.stateDir, snapshotDir, name)
+	stateFile := filepath.Join(c.stateDir, c.stateFile)
+	depsLogFile := filepath.Join(c.stateDir, c.depsLogFile)
+	switch op {
+	case "save":
+		err := saveSnapshot(ctx, stateFile, depsLogFile, sdir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("saved snapshot %q\n", name)
+		return nil
+	case "restore":
+		started := time.Now()
+		missing, done, err := c.missingBlobs(ctx)
+		if err != nil {
+			return err
+		}
+		defer done()
+		n, dropped, err := restoreSnapshot(ctx, stateFile, depsLogFile, sdir, missing)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("restored snapshot %q: %d files to be fetched lazily, %d steps to rebuild in %s\n", name, n, dropped, time.Since(started))
+		return nil
+	default:
+		return fmt.Errorf("unknown snapshot operation %q: %w", op, flag.ErrHelp)
+	}
+}
+
+// missingFunc returns digests whose contents are not available.
+type missingFunc func(context.Context, []digest.Digest) ([]digest.Digest, error)
+
+// missingBlobs returns missingFunc to check contents in the local cache
+// and in CAS, if configured, and func to release resources.
+func (c *snapshotCommand) missingBlobs(ctx context.Context) (missingFunc, func(), error) {
+	var caches []*build.LocalCache
+	if c.cacheDir != "" {
+		// contents may be stored in either layout.
+		for _, uncompressed := range []bool{false, true} {
+			cache, err := build.NewLocalCacheWithOptions(c.cacheDir, build.LocalCacheOptions{Uncompressed: uncompressed})
+			if err != nil {
+				return nil, nil, err
+			}
+			caches = append(caches, cache)
+		}
+	}
+	var client *reapi.Client
+	c.reopt.UpdateProjectID(c.projectID)
+	if c.reopt.CheckValid() == nil {
+		var credential cred.Cred
+		var err error
+		if c.reopt.NeedCred() {
+			credential, err = cred.New(ctx, c.reopt.ServiceURI(), c.authOpts)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		client, err = reapi.New(ctx, credential, *c.reopt)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	missing := func(ctx context.Context, ds []digest.Digest) ([]digest.Digest, error) {
+		var remains []digest.Digest
+	loop:
+		for _, d := range ds {
+			for _, cache := range caches {
+				if cache.HasContent(ctx, d) {
+					continue loop
+				}
+			}
+			remains = append(remains, d)
+		}
+		if client == nil || len(remains) == 0 {
+			return remains, nil
+		}
+		return client.Missing(ctx, remains)
+	}
+	done := func() {
+		if client != nil {
+			client.Close()
+		}
+	}
+	return missing, done, nil
+}
+
+// listSnapshots returns names of snapshots in state dir.
+func listSnapshots(stateDir string) ([]string, error) {
+	ents, err := os.ReadDir(filepath.Join(stateDir, snapshotDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, ent := range ents {
+		if ent.IsDir() {
+			names = append(names, ent.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// saveSnapshot saves fs state merged with its journal, and deps log in sdir.
+func saveSnapshot(ctx context.Context, stateFile, depsLogFile, sdir string) error {
+	st, err := hashfs.Load(ctx, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", stateFile, err)
+	}
+	hashfs.LoadJournal(ctx, stateFile+".journal", st)
+	tmpdir := sdir + ".tmp"
+	err = os.RemoveAll(tmpdir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(tmpdir, 0755)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpdir)
+	err = hashfs.Save(ctx, st, hashfs.Option{StateFile: filepath.Join(tmpdir, filepath.Base(stateFile))})
+	if err != nil {
+		return fmt.Errorf("failed to save fs state: %w", err)
+	}
+	err = copyFile(depsLogFile, filepath.Join(tmpdir, filepath.Base(depsLogFile)))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to save %s: %w", depsLogFile, err)
+	}
+	err = os.RemoveAll(sdir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpdir, sdir)
+}
+
+// restoreSnapshot restores fs state and deps log from sdir.
+// Generated files on the disk that don't match with the snapshot
+// are removed, so hashfs treats them as remote-only entries.
+// It returns the number of such files, and the number of entries
+// dropped because their contents are missing.
+func restoreSnapshot(ctx context.Context, stateFile, depsLogFile, sdir string, missing missingFunc) (int, int, error) {
+	snapStateFile := filepath.Join(sdir, filepath.Base(stateFile))
+	st, err := hashfs.Load(ctx, hashfs.Option{StateFile: snapStateFile})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load snapshot %s: %w", snapStateFile, err)
+	}
+	ofs := osfs.New(ctx, "fs", osfs.Option{})
+	n, dropped, err := reconcileSnapshot(ctx, ofs, st, missing)
+	if err != nil {
+		return n, dropped, err
+	}
+	err = hashfs.Save(ctx, st, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		return n, dropped, fmt.Errorf("failed to save %s: %w", stateFile, err)
+	}
+	// journal has entries of the last build on the other branch,
+	// which would be applied over the restored state by the next
+	// build. hashfs.Save removes it, but make sure it is gone.
+	err = os.Remove(stateFile + ".journal")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return n, dropped, fmt.Errorf("failed to remove journal: %w", err)
+	}
+	snapDepsLog := filepath.Join(sdir, filepath.Base(depsLogFile))
+	err = copyFile(snapDepsLog, depsLogFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = os.Remove(depsLogFile)
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return n, dropped, fmt.Errorf("failed to restore %s: %w", depsLogFile, err)
+	}
+	return n, dropped, nil
+}
+
+// reconcileSnapshot makes generated files on the disk consistent
+// with entries in st.
+// If a generated file has the same content as the entry,
+// its mtime is updated to the entry's mtime.
+// Otherwise, the file is removed and the entry becomes remote-only,
+// if its content is available. Entries whose contents are missing
+// are dropped, and their files are kept, so the steps will rerun.
+// It returns the number of remote-only entries and dropped entries.
+func reconcileSnapshot(ctx context.Context, ofs *osfs.OSFS, st *pb.State, missing missingFunc) (int, int, error) {
+	var fetches []*pb.Entry
+	var digests []digest.Digest
+	for _, ent := range st.Entries {
+		if len(ent.CmdHash) == 0 || ent.Digest == nil || ent.Digest.Hash == "" {
+			continue
+		}
+		fi, err := os.Lstat(ent.Name)
+		if errors.Is(err, fs.ErrNotExist) {
+			fetches = append(fetches, ent)
+			continue
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+		mtime := time.Unix(0, ent.Id.GetModTime())
+		if fi.Mode().IsRegular() && fi.ModTime().Equal(mtime) {
+			continue
+		}
+		if fi.Mode().IsRegular() && fi.Size() == ent.Digest.SizeBytes {
+			d, err := digest.FromLocalFile(ctx, ofs.FileSource(ent.Name, fi.Size()))
+			if err == nil && d.Digest().Hash == ent.Digest.Hash {
+				err = os.Chtimes(ent.Name, time.Now(), mtime)
+				if err == nil {
+					continue
+				}
+			}
+		}
+		// type mismatch (e.g. directory) or content mismatch.
+		fetches = append(fetches, ent)
+	}
+	for _, ent := range fetches {
+		digests = append(digests, entryDigest(ent))
+	}
+	var missings []digest.Digest
+	if len(digests) > 0 {
+		var err error
+		missings, err = missing(ctx, digests)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to check contents of restored files: %w", err)
+		}
+	}
+	missingSet := make(map[digest.Digest]bool)
+	for _, d := range missings {
+		missingSet[d] = true
+	}
+	drops := make(map[*pb.Entry]bool)
+	var n int
+	for _, ent := range fetches {
+		if missingSet[entryDigest(ent)] {
+			drops[ent] = true
+			continue
+		}
+		err := os.RemoveAll(ent.Name)
+		if err != nil {
+			return n, 0, err
+		}
+		ent.Local = false
+		n++
+	}
+	if len(drops) > 0 {
+		st.Entries = slices.DeleteFunc(st.Entries, func(ent *pb.Entry) bool {
+			return drops[ent]
+		})
+	}
+	return n, len(drops), nil
+}
+
+func entryDigest(ent *pb.Entry) digest.Digest {
+	return digest.Digest{
+		Hash:      ent.Digest.GetHash(),
+		SizeBytes: ent.Digest.GetSizeBytes(),
+	}
+}
+
+func copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	fi, err := r.Stat()
+	if err != nil {
+		return err
+	}
+	tmpname := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	w, err := os.OpenFile(tmpname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	cerr := w.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return os.Rename(tmpname, dst)
+}
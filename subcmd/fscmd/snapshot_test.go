@@ -0,0 +1,181 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package fscmd
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.chromium.org/build/siso/hashfs"
+	pb "go.chromium.org/build/siso/hashfs/proto"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+func TestSnapshot(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stateFile := filepath.Join(dir, stateFile)
+	depsLogFile := filepath.Join(dir, ".siso_deps")
+	sdir := filepath.Join(dir, snapshotDir, "main")
+
+	mtime := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
+	writeFile := func(t *testing.T, name, content string, mtime time.Time) *pb.Entry {
+		t.Helper()
+		fname := filepath.ToSlash(filepath.Join(dir, name))
+		err := os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.Chtimes(fname, mtime, mtime)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := digest.FromBytes(name, []byte(content)).Digest()
+		return &pb.Entry{
+			Id:      &pb.FileID{ModTime: mtime.UnixNano()},
+			Name:    fname,
+			Digest:  &pb.Digest{Hash: d.Hash, SizeBytes: d.SizeBytes},
+			CmdHash: []byte("cmdhash"),
+		}
+	}
+	// state on "main" branch.
+	st := &pb.State{
+		Entries: []*pb.Entry{
+			writeFile(t, "same.o", "same", mtime),
+			writeFile(t, "touched.o", "touched", mtime),
+			writeFile(t, "changed.o", "main", mtime),
+			writeFile(t, "removed.o", "removed", mtime),
+			writeFile(t, "evicted.o", "evicted", mtime),
+		},
+	}
+	st.Entries[2].Local = true
+	err := hashfs.Save(ctx, st, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(depsLogFile, []byte("main deps"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = saveSnapshot(ctx, stateFile, depsLogFile, sdir)
+	if err != nil {
+		t.Fatalf("saveSnapshot(...)=%v; want nil err", err)
+	}
+
+	// build on other branch.
+	later := mtime.Add(30 * time.Minute)
+	writeFile(t, "touched.o", "touched", later)
+	writeFile(t, "changed.o", "branch", later)
+	writeFile(t, "evicted.o", "evicted-branch", later)
+	err = os.Remove(filepath.Join(dir, "removed.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(depsLogFile, []byte("branch deps"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hashfs.Save(ctx, &pb.State{}, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// journal is left by the last build on the branch.
+	hashFS, err := hashfs.New(ctx, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hashFS.WriteFile(ctx, dir, "changed.o", []byte("branch"), false, later, []byte("cmdhash"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal, err := os.ReadFile(stateFile + ".journal")
+	if err != nil || len(journal) == 0 {
+		t.Fatalf("journal=%d bytes, %v; want non empty journal", len(journal), err)
+	}
+	err = hashFS.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(stateFile+".journal", journal, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := digest.FromBytes("evicted.o", []byte("evicted")).Digest()
+	var checked []digest.Digest
+	missing := func(ctx context.Context, ds []digest.Digest) ([]digest.Digest, error) {
+		checked = append(checked, ds...)
+		var missings []digest.Digest
+		for _, d := range ds {
+			if d == evicted {
+				missings = append(missings, d)
+			}
+		}
+		return missings, nil
+	}
+	n, dropped, err := restoreSnapshot(ctx, stateFile, depsLogFile, sdir, missing)
+	if err != nil || n != 2 || dropped != 1 {
+		t.Fatalf("restoreSnapshot(...)=%d, %d, %v; want 2, 1, nil", n, dropped, err)
+	}
+	if len(checked) != 3 {
+		t.Errorf("checked %d digests; want 3 for changed.o, removed.o and evicted.o", len(checked))
+	}
+	_, err = os.Stat(stateFile + ".journal")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("journal: stat err=%v; want %v", err, fs.ErrNotExist)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "evicted.o"))
+	if err != nil || string(buf) != "evicted-branch" {
+		t.Errorf("evicted.o=%q, %v; want local file kept", buf, err)
+	}
+
+	for _, name := range []string{"same.o", "touched.o"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: stat=%v, %v; want mtime=%v", name, fi, err, mtime)
+		}
+	}
+	_, err = os.Stat(filepath.Join(dir, "changed.o"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("changed.o: stat err=%v; want %v", err, fs.ErrNotExist)
+	}
+	buf, err = os.ReadFile(depsLogFile)
+	if err != nil || string(buf) != "main deps" {
+		t.Errorf("deps log=%q, %v; want %q, nil", buf, err, "main deps")
+	}
+	// next build should see the restored state.
+	hashFS, err = hashfs.New(ctx, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = hashFS.Close(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := hashfs.Load(ctx, hashfs.Option{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := hashfs.StateMap(got)
+	if len(m) != 4 {
+		t.Errorf("restored entries=%d; want 4", len(m))
+	}
+	if ent := m[filepath.ToSlash(filepath.Join(dir, "changed.o"))]; ent == nil || ent.Local || ent.Digest.SizeBytes != 4 {
+		t.Errorf("changed.o entry=%v; want remote-only entry", ent)
+	}
+	if ent := m[filepath.ToSlash(filepath.Join(dir, "evicted.o"))]; ent != nil && len(ent.CmdHash) > 0 {
+		t.Errorf("evicted.o entry=%v; want dropped", ent)
+	}
+
+	names, err := listSnapshots(dir)
+	if err != nil || len(names) != 1 || names[0] != "main" {
+		t.Errorf("listSnapshots(%q)=%q, %v; want [main], nil", dir, names, err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"crypto/ed25519"
+	"sync/atomic"
+	"testing"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/reapi/digest"
+	"go.chromium.org/build/siso/reapi/reapitest"
+	"go.chromium.org/build/siso/reapi/signature"
+)
+
+// This test checks cached results served by remote execution are
+// verified with trusted keys.
+// Results cached by remote execution workers are not signed, so they
+// are executed again without cache lookup.
+func TestBuild_CacheVerify(t *testing.T) {
+	ctx := t.Context()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := signature.NewVerifier(pub)
+
+	var executed atomic.Int64
+	fakere := &reapitest.Fake{
+		ExecuteFunc: func(fakere *reapitest.Fake, action *rpb.Action) (*rpb.ActionResult, error) {
+			executed.Add(1)
+			return &rpb.ActionResult{
+				ExitCode: 0,
+				OutputFiles: []*rpb.OutputFile{
+					{
+						Path:   "out",
+						Digest: digest.Empty.Proto(),
+					},
+				},
+			}, nil
+		},
+	}
+	var ds dataSource
+	defer func() {
+		err := ds.Close(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	ds.client = reapitest.New(ctx, t, fakere)
+
+	ninja := func(t *testing.T, verifier *signature.Verifier) (build.Stats, error) {
+		t.Helper()
+		dir := tempDir(t)
+		setupFiles(t, dir, "TestBuild_RemoteWorkDir", nil)
+		ds.cache = ds.client.CacheStore().WithVerifier(verifier)
+		opt, graph, cleanup := setupBuild(ctx, t, dir, hashfs.Option{
+			StateFile:  ".siso_fs_state",
+			DataSource: ds,
+		})
+		defer cleanup()
+		opt.REAPIClient = ds.client
+		opt.RECacheEnableRead = true
+		opt.RECacheVerifier = verifier
+		return runNinja(ctx, "out", graph, opt, nil, runNinjaOpts{})
+	}
+
+	t.Logf("-- first build executes the action")
+	stats, err := ninja(t, verifier)
+	if err != nil {
+		t.Fatalf("ninja %v: want nil err", err)
+	}
+	if stats.Done != stats.Total || stats.Remote != 1 || stats.CacheHit != 0 || executed.Load() != 1 {
+		t.Errorf("done=%d total=%d remote=%d cache=%d executed=%d; want done=total remote=1 cache=0 executed=1", stats.Done, stats.Total, stats.Remote, stats.CacheHit, executed.Load())
+	}
+
+	t.Logf("-- second build doesn't trust unsigned cached result")
+	stats, err = ninja(t, verifier)
+	if err != nil {
+		t.Fatalf("ninja %v: want nil err", err)
+	}
+	if stats.Done != stats.Total || stats.Remote != 1 || stats.CacheHit != 0 || executed.Load() != 2 {
+		t.Errorf("done=%d total=%d remote=%d cache=%d executed=%d; want done=total remote=1 cache=0 executed=2", stats.Done, stats.Total, stats.Remote, stats.CacheHit, executed.Load())
+	}
+
+	t.Logf("-- third build without verifier uses cached result")
+	stats, err = ninja(t, nil)
+	if err != nil {
+		t.Fatalf("ninja %v: want nil err", err)
+	}
+	if stats.Done != stats.Total || stats.CacheHit != 1 || executed.Load() != 2 {
+		t.Errorf("done=%d total=%d remote=%d cache=%d executed=%d; want done=total cache=1 executed=2", stats.Done, stats.Total, stats.Remote, stats.CacheHit, executed.Load())
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+func TestBuild_Clean(t *testing.T) {
+	ctx := t.Context()
+	dir := tempDir(t)
+
+	ninja := func(t *testing.T, nopts runNinjaOpts, args ...string) error {
+		t.Helper()
+		opt, graph, cleanup := setupBuild(ctx, t, dir, hashfs.Option{
+			StateFile: ".siso_fs_state",
+		})
+		defer cleanup()
+		_, err := runNinja(ctx, "build.ninja", graph, opt, args, nopts)
+		return err
+	}
+
+	checkFiles := func(t *testing.T, exists, removed []string) {
+		t.Helper()
+		st, err := hashfs.Load(ctx, hashfs.Option{StateFile: filepath.Join(dir, "out/siso/.siso_fs_state")})
+		if err != nil {
+			t.Fatalf("hashfs.Load=%v; want nil err", err)
+		}
+		m := hashfs.StateMap(st)
+		for _, fname := range exists {
+			_, err := os.Stat(filepath.Join(dir, fname))
+			if err != nil {
+				t.Errorf("stat(%q)=%v; want nil error", fname, err)
+			}
+		}
+		for _, fname := range removed {
+			_, err := os.Stat(filepath.Join(dir, fname))
+			if !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("stat(%q)=%v; want %v", fname, err, fs.ErrNotExist)
+			}
+			if _, ok := m[filepath.ToSlash(filepath.Join(dir, fname))]; ok {
+				t.Errorf("%q exists in .siso_fs_state; want removed", fname)
+			}
+		}
+	}
+
+	checkDepsLog := func(t *testing.T, output string, want bool) {
+		t.Helper()
+		depsLog, err := ninjautil.NewDepsLog(ctx, filepath.Join(dir, "out/siso/.siso_deps"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer depsLog.Close()
+		_, _, err = depsLog.RetrievePaths(ctx, output)
+		if got := err == nil; got != want {
+			t.Errorf("deps log for %q: %v; want exists=%t", output, err, want)
+		}
+	}
+
+	t.Logf("setup workspace")
+	setupFiles(t, dir, t.Name(), nil)
+
+	t.Logf("first build")
+	err := ninja(t, runNinjaOpts{})
+	if err != nil {
+		t.Fatalf("ninja err: %v", err)
+	}
+	checkFiles(t, []string{
+		"out/siso/gen/cache/data",
+		"out/siso/gen/foo.h",
+		"out/siso/gen/bar.h",
+		"out/siso/gen/info.txt",
+		"out/siso/obj/foo.o",
+		"out/siso/obj/bar.o",
+		"out/siso/target",
+	}, nil)
+	checkDepsLog(t, "obj/foo.o", true)
+
+	t.Logf("clean obj/foo.o transitively")
+	err = ninja(t, runNinjaOpts{subtool: "clean"}, "obj/foo.o")
+	if err != nil {
+		t.Fatalf("clean err: %v", err)
+	}
+	checkFiles(t, []string{
+		"out/siso/gen/bar.h",
+		"out/siso/gen/info.txt",
+		"out/siso/obj/bar.o",
+		"out/siso/target",
+	}, []string{
+		"out/siso/gen/cache",
+		"out/siso/gen/foo.h",
+		"out/siso/obj/foo.o",
+	})
+	checkDepsLog(t, "obj/foo.o", false)
+	checkDepsLog(t, "obj/bar.o", true)
+
+	t.Logf("clean by rule")
+	err = ninja(t, runNinjaOpts{subtool: "clean", cleanByRule: true}, "link")
+	if err != nil {
+		t.Fatalf("clean err: %v", err)
+	}
+	checkFiles(t, []string{
+		"out/siso/gen/bar.h",
+		"out/siso/obj/bar.o",
+	}, []string{
+		"out/siso/target",
+	})
+
+	err = ninja(t, runNinjaOpts{subtool: "clean", cleanByRule: true}, "nosuchrule")
+	if err == nil {
+		t.Errorf("clean unknown rule: nil err; want err")
+	}
+
+	err = ninja(t, runNinjaOpts{subtool: "clean", cleanByRule: true})
+	if err == nil {
+		t.Errorf("clean by rule without rules: nil err; want err")
+	}
+	checkFiles(t, []string{
+		"out/siso/gen/bar.h",
+		"out/siso/obj/bar.o",
+	}, nil)
+
+	t.Logf("clean all, except generator outputs")
+	err = ninja(t, runNinjaOpts{subtool: "clean"})
+	if err != nil {
+		t.Fatalf("clean err: %v", err)
+	}
+	checkFiles(t, []string{
+		"out/siso/gen/info.txt",
+	}, []string{
+		"out/siso/gen/bar.h",
+		"out/siso/obj/bar.o",
+	})
+	checkDepsLog(t, "obj/bar.o", false)
+
+	t.Logf("clean all with generator outputs")
+	err = ninja(t, runNinjaOpts{subtool: "clean", cleanGenerator: true})
+	if err != nil {
+		t.Fatalf("clean err: %v", err)
+	}
+	checkFiles(t, nil, []string{
+		"out/siso/gen/info.txt",
+	})
+}
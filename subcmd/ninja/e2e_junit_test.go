@@ -0,0 +1,111 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"bytes"
+	"encoding/xml"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
+)
+
+func TestBuild_JUnitXML(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh not available on windows")
+		return
+	}
+	ctx := t.Context()
+
+	type testcase struct {
+		Name       string `xml:"name,attr"`
+		Classname  string `xml:"classname,attr"`
+		Properties []struct {
+			Name  string `xml:"name,attr"`
+			Value string `xml:"value,attr"`
+		} `xml:"properties>property"`
+		Failure *struct {
+			Text string `xml:",chardata"`
+		} `xml:"failure"`
+	}
+	type report struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Suites   []struct {
+			Cases []testcase `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+
+	ninja := func(t *testing.T, compact bool) report {
+		t.Helper()
+		dir := tempDir(t)
+		setupFiles(t, dir, "TestBuild_JUnitXML", nil)
+		opt, graph, cleanup := setupBuild(ctx, t, dir, hashfs.Option{
+			StateFile: ".siso_fs_state",
+		})
+		defer cleanup()
+		junit := build.NewJUnitReport(compact)
+		opt.JUnitReport = junit
+		opt.FailuresAllowed = 2
+		_, err := runNinja(ctx, "build.ninja", graph, opt, []string{"all"}, runNinjaOpts{})
+		if err == nil {
+			t.Errorf("ninja succeeded; want err")
+		}
+		var buf bytes.Buffer
+		err = junit.WriteXML(&buf)
+		if err != nil {
+			t.Fatalf("WriteXML=%v", err)
+		}
+		var r report
+		err = xml.Unmarshal(buf.Bytes(), &r)
+		if err != nil {
+			t.Fatalf("xml.Unmarshal=%v\n%s", err, buf.String())
+		}
+		return r
+	}
+
+	t.Run("full", func(t *testing.T) {
+		r := ninja(t, false)
+		if r.Tests != 2 || r.Failures != 1 {
+			t.Fatalf("tests=%d failures=%d; want tests=2 failures=1: %#v", r.Tests, r.Failures, r)
+		}
+		for _, tc := range r.Suites[0].Cases {
+			switch tc.Name {
+			case "gen/ok.out":
+				if tc.Failure != nil {
+					t.Errorf("%s: failure=%q; want nil", tc.Name, tc.Failure.Text)
+				}
+			case "gen/fail.out":
+				if tc.Classname != "fail" {
+					t.Errorf("%s: classname=%q; want %q", tc.Name, tc.Classname, "fail")
+				}
+				if tc.Failure == nil || !strings.Contains(tc.Failure.Text, "error: broken") {
+					t.Errorf("%s: failure=%v; want stderr in failure", tc.Name, tc.Failure)
+				}
+			default:
+				t.Errorf("unexpected testcase %q", tc.Name)
+			}
+			var status string
+			for _, p := range tc.Properties {
+				if p.Name == "status" {
+					status = p.Value
+				}
+			}
+			if status != "local" {
+				t.Errorf("%s: status=%q; want local", tc.Name, status)
+			}
+		}
+	})
+
+	t.Run("compact", func(t *testing.T) {
+		r := ninja(t, true)
+		if r.Tests != 1 || r.Failures != 1 || r.Suites[0].Cases[0].Name != "gen/fail.out" {
+			t.Errorf("tests=%d failures=%d; want only gen/fail.out: %#v", r.Tests, r.Failures, r)
+		}
+	})
+}
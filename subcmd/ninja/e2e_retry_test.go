@@ -0,0 +1,61 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/hashfs"
+)
+
+func TestBuild_Retry(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh not available on windows")
+		return
+	}
+	ctx := t.Context()
+	dir := tempDir(t)
+
+	ninja := func(t *testing.T, targets ...string) (build.Stats, error) {
+		t.Helper()
+		opt, graph, cleanup := setupBuild(ctx, t, dir, hashfs.Option{
+			StateFile: ".siso_fs_state",
+		})
+		defer cleanup()
+		return runNinja(ctx, "build.ninja", graph, opt, targets, runNinjaOpts{})
+	}
+
+	setupFiles(t, dir, t.Name(), nil)
+
+	t.Logf("-- flaky step succeeds after retry")
+	stats, err := ninja(t, "gen/flaky.out")
+	if err != nil {
+		t.Fatalf("ninja err: %v", err)
+	}
+	if stats.Local != 1 || stats.FlakyRetry != 1 || stats.FlakySuccess != 1 {
+		t.Errorf("local=%d flaky_retry=%d flaky_success=%d; want local=1 flaky_retry=1 flaky_success=1: %#v", stats.Local, stats.FlakyRetry, stats.FlakySuccess, stats)
+	}
+
+	t.Logf("-- failing step is retried up to max")
+	stats, err = ninja(t, "gen/fail.out")
+	if err == nil {
+		t.Fatalf("ninja succeeded; want err")
+	}
+	if stats.FlakyRetry != 2 || stats.FlakySuccess != 0 || stats.Fail != 1 {
+		t.Errorf("flaky_retry=%d flaky_success=%d fail=%d; want flaky_retry=2 flaky_success=0 fail=1: %#v", stats.FlakyRetry, stats.FlakySuccess, stats.Fail, stats)
+	}
+	buf, err := os.ReadFile(filepath.Join(dir, "out/siso/gen/fail.out.attempt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(buf), "attempt"); got != 3 {
+		t.Errorf("attempts=%d; want 3", got)
+	}
+}
@@ -63,6 +63,7 @@ import (
 	"go.chromium.org/build/siso/reapi"
 	"go.chromium.org/build/siso/reapi/digest"
 	"go.chromium.org/build/siso/reapi/merkletree"
+	"go.chromium.org/build/siso/reapi/signature"
 	"go.chromium.org/build/siso/signals"
 	"go.chromium.org/build/siso/toolsupport/artfsutil"
 	"go.chromium.org/build/siso/toolsupport/cogutil"
@@ -70,6 +71,7 @@ import (
 	"go.chromium.org/build/siso/toolsupport/soongutil"
 	"go.chromium.org/build/siso/toolsupport/watchmanutil"
 	"go.chromium.org/build/siso/ui"
+	"go.chromium.org/build/siso/ui/tui"
 	"go.chromium.org/build/siso/version"
 )
 
@@ -125,10 +127,12 @@ type Command struct {
 	fastLocal       bool
 	fastLastFailure bool // TODO: Always prioritize last failed targets.
 	fastExit        bool
+	ninjaStateCache bool
 
 	quiet           bool
 	verbose         bool
 	verboseFailures bool
+	tui             bool
 
 	dryRun          bool
 	clobber         bool
@@ -144,9 +148,10 @@ type Command struct {
 	localJobs  int
 	fname      string
 
-	cacheDir         string
-	localCacheEnable bool
-	cacheEnableRead  bool
+	cacheDir               string
+	localCacheEnable       bool
+	localCacheUncompressed bool
+	cacheEnableRead        bool
 	// cacheEnableWrite bool
 
 	configRepoDir  string
@@ -163,12 +168,20 @@ type Command struct {
 	frontendFile       string
 	failureSummaryFile string
 	failedCommandsFile string
+	junitXMLFile       string
+	junitXMLCompact    bool
+	diagnosticsFile    string
+	diagnosticsFormat  string
 	outputLogFile      string
 	explainFile        string
 	localexecLogFile   string
 	metricsJSON        string
 	traceJSON          string
 	buildPprof         string
+	hungStepLog        string
+	hungStepFactor     float64
+	hungStepMin        time.Duration
+	hungStepSigquit    bool
 	// uploadBuildPprof bool
 
 	fsopt              *hashfs.Option
@@ -176,6 +189,8 @@ type Command struct {
 	reExecEnable       bool
 	reCacheEnableRead  bool
 	reCacheEnableWrite bool
+	reCacheSigningKey  string
+	reCacheTrustedKeys string
 	reproxyAddr        string
 
 	artfsDir      string
@@ -194,14 +209,26 @@ type Command struct {
 	traceThreshold              time.Duration
 	traceSpanThreshold          time.Duration
 
-	subtool    string
-	cleandead  bool
-	debugMode  debugMode
-	adjustWarn string
+	// operationStats collects operation counts and timing for `-d stats`.
+	operationStats *build.OperationStats
+
+	subtool        string
+	cleandead      bool
+	cleanGenerator bool
+	cleanByRule    bool
+	debugMode      debugMode
+	adjustWarn     string
 
 	sisoInfoLog string // abs or relative to logDir
 	startDir    string
 
+	buildServer            bool
+	buildServerServe       bool
+	buildServerIdleTimeout time.Duration
+
+	// warm holds resources kept across builds in build server.
+	warm *warmState
+
 	resultstoreUploader *resultstore.Uploader
 }
 
@@ -225,6 +252,12 @@ func (c *Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any)
 		ui.Default.Errorf("%v\n", err)
 		return subcommands.ExitUsageError
 	}
+	if c.buildServerServe {
+		return c.serve(ctx)
+	}
+	if c.buildServer {
+		return c.runClient(ctx)
+	}
 	if c.stateDir == "" {
 		c.stateDir = filepath.Dir(c.fname)
 	}
@@ -423,6 +456,15 @@ func (errInterrupted) Is(target error) bool { return target == context.Canceled
 const (
 	// relative to -state_dir
 	failedTargetsFile = ".siso_failed_targets"
+
+	// relative to -state_dir
+	platformEscalationsFile = ".siso_platform_escalations"
+
+	// relative to -state_dir
+	ninjaStateCacheFile = ".siso_ninja_state"
+
+	// relative to -state_dir
+	casPresenceFile = ".siso_cas_presence"
 )
 
 type batchFlag struct {
@@ -483,6 +525,11 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	if err != nil {
 		return stats, flagError{err: err}
 	}
+	switch c.diagnosticsFormat {
+	case "sarif", "json":
+	default:
+		return stats, flagError{err: fmt.Errorf("unknown -diagnostics_format %q", c.diagnosticsFormat)}
+	}
 	switch c.subtool {
 	case "":
 	case "list":
@@ -491,7 +538,9 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
   commands   Use "siso query commands" instead
   deps       Use "siso query deps" instead
   inputs     Use "siso query inputs" instead
+  missingdeps  Use "siso query missingdeps" instead
   targets    Use "siso query targets" instead
+  clean      clean built files. -t clean [-g] [-r] [targets...|rules...]
   cleandead  clean built files that are no longer produced by the manifest`),
 		}
 	case "commands":
@@ -506,11 +555,19 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 		return stats, flagError{
 			err: errors.New("use `siso query inputs` instead"),
 		}
+	case "missingdeps":
+		return stats, flagError{
+			err: errors.New("use `siso query missingdeps` instead"),
+		}
 	case "targets":
 		return stats, flagError{
 			err: errors.New("use `siso query targets` instead"),
 		}
 
+	case "clean":
+		if c.cleanByRule && c.Flags.NArg() == 0 {
+			return stats, flagError{err: errors.New("-t clean -r: expected a rule to clean")}
+		}
 	case "cleandead":
 		c.cleandead = true
 	default:
@@ -570,6 +627,7 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	if c.stateDir != "." && c.fsopt.StateFile != "" {
 		c.fsopt.StateFile = filepath.Join(c.stateDir, c.fsopt.StateFile)
 	}
+	c.warm.check(ctx, c.warmKey(execRoot))
 	lockFilename := filepath.Join(c.stateDir, ".siso_lock")
 	if !c.dryRun {
 		lock, err := newLockFile(ctx, lockFilename)
@@ -670,11 +728,31 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	if len(c.jobID) > 1024 {
 		return stats, flagError{err: fmt.Errorf("-job_id length must be less than 1024")}
 	}
+	c.operationStats = nil
+	if c.debugMode.Stats {
+		// spans outside of build steps (e.g. loading fs state,
+		// build manifest) are recorded in this trace context.
+		tc := trace.New(ctx, c.buildID)
+		ctx = trace.NewContext(ctx, tc)
+		c.operationStats = build.NewOperationStats()
+		defer func() {
+			c.operationStats.Add(tc)
+			var sb strings.Builder
+			err := c.operationStats.WriteTable(&sb)
+			if err != nil {
+				clog.Warningf(ctx, "failed to write stats: %v", err)
+				return
+			}
+			ui.Default.PrintLines("\n", sb.String())
+		}()
+	}
 
 	projectID := c.reopt.UpdateProjectID(c.projectID)
 
 	var credential cred.Cred
-	if !c.offline && (c.reopt.NeedCred() || c.enableCloudLogging || c.enableResultstore || c.enableCloudProfiler || c.enableCloudTrace || c.enableCloudMonitoring) {
+	if c.warm != nil && c.warm.credential != nil {
+		credential = *c.warm.credential
+	} else if !c.offline && (c.reopt.NeedCred() || c.enableCloudLogging || c.enableResultstore || c.enableCloudProfiler || c.enableCloudTrace || c.enableCloudMonitoring) {
 		// TODO: can be async until cred is needed?
 		spin := ui.Default.NewSpinner()
 		spin.Start("init credentials by %q", c.authOpts.Type)
@@ -684,6 +762,9 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 			return stats, err
 		}
 		spin.Stop(nil)
+		if c.warm != nil {
+			c.warm.credential = &credential
+		}
 	}
 	if c.enableCloudLogging {
 		spin := ui.Default.NewSpinner()
@@ -828,7 +909,7 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	// upload build pprof
 
 	targets := c.Flags.Args()
-	config, err := c.initConfig(ctx, execRoot, targets)
+	config, err := c.loadConfig(ctx, execRoot, targets)
 	if err != nil {
 		return stats, err
 	}
@@ -838,11 +919,18 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	var eg errgroup.Group
 	var localDepsLog *ninjautil.DepsLog
 	eg.Go(func() error {
+		if c.warm != nil && c.warm.depsLog != nil {
+			localDepsLog = c.warm.depsLog
+			return nil
+		}
 		depsLog, err := c.initDepsLog(ctx)
 		if err != nil {
 			return err
 		}
 		localDepsLog = depsLog
+		if c.warm != nil {
+			c.warm.depsLog = depsLog
+		}
 		return nil
 	})
 
@@ -856,16 +944,25 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 			return stats, flagError{err: fmt.Errorf("no reapi specified, but remote is requested as --remote_jobs=%d: %w", c.remoteJobs, err)}
 		}
 	}
-	ds, err := c.initDataSource(ctx, credential)
-	if err != nil {
-		return stats, err
-	}
-	defer func() {
-		err := ds.Close(ctx)
+	var ds dataSource
+	if c.warm != nil && c.warm.ds != nil {
+		ds = *c.warm.ds
+	} else {
+		ds, err = c.initDataSource(ctx, credential)
 		if err != nil {
-			clog.Errorf(ctx, "close datasource: %v", err)
+			return stats, err
 		}
-	}()
+		if c.warm != nil {
+			c.warm.ds = &ds
+		} else {
+			defer func() {
+				err := ds.Close(ctx)
+				if err != nil {
+					clog.Errorf(ctx, "close datasource: %v", err)
+				}
+			}()
+		}
+	}
 	c.fsopt.DataSource = ds
 	c.fsopt.OutputLocal, err = c.initOutputLocal()
 	if err != nil {
@@ -953,7 +1050,7 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 
 	spin.Start("loading fs state")
 
-	hashFS, err := hashfs.New(ctx, *c.fsopt)
+	hashFS, err := c.newHashFS(ctx, execRoot)
 	spin.Stop(err)
 	if err != nil {
 		return stats, err
@@ -1002,6 +1099,13 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	}()
 	defer func() {
 		hashFS.SetBuildTargets(ctx, targets, !c.dryRun && c.subtool == "" && !c.prepare && err == nil)
+		if c.warm != nil {
+			err := hashFS.Checkpoint(ctx)
+			if err != nil {
+				clog.Errorf(ctx, "checkpoint hashfs: %v", err)
+			}
+			return
+		}
 		err := hashFS.Close(ctx)
 		if err != nil {
 			clog.Errorf(ctx, "close hashfs: %v", err)
@@ -1020,7 +1124,7 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	// and won't match with .siso_fs_state.
 	// in this case, don't shortcut noop build, but better to check
 	// build graph again.
-	if !c.clobber && c.fastNop && !c.dryRun && !c.debugMode.Explain && c.subtool != "cleandead" && !c.prepare && hashFSErr == nil && isClean && !lastFailed {
+	if !c.clobber && c.fastNop && !c.dryRun && !c.debugMode.Explain && c.subtool == "" && !c.prepare && hashFSErr == nil && isClean && !lastFailed {
 		// TODO: better to check digest of .siso_fs_state?
 		return stats, errNothingToDo
 	}
@@ -1062,7 +1166,7 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	spin.Start("loading/recompacting deps log")
 	err = eg.Wait()
 	spin.Stop(err)
-	if localDepsLog != nil {
+	if localDepsLog != nil && c.warm == nil {
 		defer localDepsLog.Close()
 	}
 	// TODO(b/286501388): init concurrently for .siso_config/.siso_filegroups, build.ninja.
@@ -1079,22 +1183,31 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 
 	c.checkBuildNinja(ctx, buildPath, config, hashFS, localDepsLog, bopts)
 
-	spin.Start("load siso config")
-	stepConfig, err := ninjabuild.NewStepConfig(ctx, config, buildPath, hashFS, c.fname, c.stateDir)
-	if err != nil {
-		spin.Stop(err)
-		return stats, err
-	}
-	spin.Stop(nil)
-	spin.Start(fmt.Sprintf("load %s", c.fname))
-	nstate, err := ninjabuild.Load(ctx, c.fname, buildPath)
-	if err != nil {
-		spin.Stop(errors.New(""))
-		return stats, err
-	}
-	spin.Stop(nil)
+	graph := c.warm.reuseGraph(ctx, config)
+	if graph == nil {
+		spin.Start("load siso config")
+		stepConfig, err := ninjabuild.NewStepConfig(ctx, config, buildPath, hashFS, c.fname, c.stateDir)
+		if err != nil {
+			spin.Stop(err)
+			return stats, err
+		}
+		spin.Stop(nil)
+		spin.Start(fmt.Sprintf("load %s", c.fname))
+		var nstate *ninjautil.State
+		if c.ninjaStateCache {
+			nstate, err = ninjabuild.LoadWithCache(ctx, c.fname, buildPath, hashFS, filepath.Join(c.stateDir, ninjaStateCacheFile), c.debugMode.Stategraph)
+		} else {
+			nstate, err = ninjabuild.Load(ctx, c.fname, buildPath)
+		}
+		if err != nil {
+			spin.Stop(errors.New(""))
+			return stats, err
+		}
+		spin.Stop(nil)
 
-	graph := ninjabuild.NewGraph(ctx, c.fname, nstate, config, buildPath, hashFS, stepConfig, localDepsLog)
+		graph = ninjabuild.NewGraph(ctx, c.fname, nstate, config, buildPath, hashFS, stepConfig, localDepsLog)
+		c.warm.setGraph(ctx, graph)
+	}
 
 	var lastFailedTargets []string
 	if c.fastLastFailure && !c.clobber {
@@ -1109,6 +1222,31 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 		clog.Warningf(ctx, "failed to remove %s: %v", failedTargetsFilename, err)
 	}
 
+	platformEscalationsFilename := filepath.Join(c.stateDir, platformEscalationsFile)
+	bopts.PlatformEscalations, err = build.LoadPlatformEscalations(platformEscalationsFilename)
+	if err != nil {
+		clog.Warningf(ctx, "failed to load platform escalations: %v", err)
+	}
+	defer func() {
+		err := bopts.PlatformEscalations.Save(platformEscalationsFilename)
+		if err != nil {
+			clog.Warningf(ctx, "failed to save platform escalations: %v", err)
+		}
+	}()
+
+	casPresenceFilename := filepath.Join(c.stateDir, casPresenceFile)
+	casPresence, err := ds.client.LoadPresenceCache(casPresenceFilename)
+	if err != nil {
+		clog.Warningf(ctx, "failed to load cas presence cache: %v", err)
+	}
+	clog.Infof(ctx, "cas presence cache: %d digests", casPresence.Len())
+	defer func() {
+		err := casPresence.Save(casPresenceFilename)
+		if err != nil {
+			clog.Warningf(ctx, "failed to save cas presence cache: %v", err)
+		}
+	}()
+
 	sisoMetadata := SisoMetadata{
 		SisoVersion:   c.version,
 		StartTime:     c.started,
@@ -1125,9 +1263,12 @@ func (c *Command) run(ctx context.Context) (stats build.Stats, err error) {
 	}
 
 	return runNinja(ctx, c.fname, graph, bopts, targets, runNinjaOpts{
-		cleandead:     c.cleandead,
-		subtool:       c.subtool,
-		enableStatusz: true,
+		cleandead:      c.cleandead,
+		cleanGenerator: c.cleanGenerator,
+		cleanByRule:    c.cleanByRule,
+		subtool:        c.subtool,
+		enableStatusz:  true,
+		tui:            c.tui,
 	})
 }
 
@@ -1178,12 +1319,21 @@ type runNinjaOpts struct {
 	// whether to perform cleandead or not.
 	cleandead bool
 
+	// whether `-t clean` cleans outputs of generator steps too.
+	cleanGenerator bool
+
+	// whether `-t clean` interprets targets as rule names.
+	cleanByRule bool
+
 	// subtool name.
-	// if "cleandead", it returns after cleandead performed.
+	// if "cleandead" or "clean", it returns after the subtool performed.
 	subtool string
 
 	// enable statusz (for `siso ps`)
 	enableStatusz bool
+
+	// enable full-screen terminal UI.
+	tui bool
 }
 
 func runNinja(ctx context.Context, fname string, graph *ninjabuild.Graph, bopts build.Options, targets []string, nopts runNinjaOpts) (build.Stats, error) {
@@ -1265,6 +1415,7 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 	flagSet.BoolVar(&c.fastLocal, "fast_local", ui.IsTerminal(), "enable fast local")
 	flagSet.BoolVar(&c.fastLastFailure, "fast_last_failure", ui.IsTerminal(), "enable fast last failure check")
 	flagSet.BoolVar(&c.fastExit, "fast_exit", ui.IsTerminal(), "enable fast exit")
+	flagSet.BoolVar(&c.ninjaStateCache, "ninja_state_cache", true, "cache parsed build.ninja in -state_dir, and reuse it while ninja files are unchanged. use '-d stategraph' to verify the cache")
 	batch := &batchFlag{c: c}
 	flagSet.Var(batch, "batch", "batch mode. prefer thoughput over low latency for build failures. disable -fast_nop, -fast_local -fast_last_failure -fast_exit")
 
@@ -1272,6 +1423,7 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 	flagSet.BoolVar(&c.verbose, "verbose", false, "show all command lines while building")
 	flagSet.BoolVar(&c.verbose, "v", false, "show all command lines while building (alias of --verbose)")
 	flagSet.BoolVar(&c.verboseFailures, "verbose_failures", true, "show failed command lines")
+	flagSet.BoolVar(&c.tui, "tui", false, "use full-screen interactive terminal UI")
 	flagSet.BoolVar(&c.dryRun, "n", false, "dry run")
 	flagSet.BoolVar(&c.clobber, "clobber", false, "clobber build")
 	flagSet.BoolVar(&c.prepare, "prepare", false, "build inputs of targets, but not build target itself.")
@@ -1287,6 +1439,7 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 
 	flagSet.StringVar(&c.cacheDir, "cache_dir", defaultCacheDir(), "cache directory")
 	flagSet.BoolVar(&c.localCacheEnable, "local_cache_enable", false, "local cache enable")
+	flagSet.BoolVar(&c.localCacheUncompressed, "local_cache_uncompressed", false, "use uncompressed content store in -cache_dir, and materialize cache-hit outputs by reflink or read-only hardlink if possible")
 	flagSet.BoolVar(&c.cacheEnableRead, "cache_enable_read", true, "cache enable read")
 
 	flagSet.StringVar(&c.configRepoDir, "config_repo_dir", "build/config/siso", "config repo directory (relative to exec root)")
@@ -1307,12 +1460,20 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 		c.failedCommandsFile = "siso_failed_commands.bat"
 	}
 	flagSet.StringVar(&c.failedCommandsFile, "failed_commands", c.failedCommandsFile, "script file to rerun the last failed commands")
+	flagSet.StringVar(&c.junitXMLFile, "junit_xml", "", "filename for JUnit XML report of executed steps (relative to -log_dir)")
+	flagSet.BoolVar(&c.junitXMLCompact, "junit_xml_compact", false, "report only failed steps in -junit_xml")
+	flagSet.StringVar(&c.diagnosticsFile, "diagnostics_file", "", "filename for compiler diagnostics extracted from step outputs (relative to -log_dir)")
+	flagSet.StringVar(&c.diagnosticsFormat, "diagnostics_format", "sarif", "format of -diagnostics_file. sarif or json (JSON lines)")
 	flagSet.StringVar(&c.outputLogFile, "output_log", "siso_output", "output log filename (relative to -log_dir")
 	flagSet.StringVar(&c.explainFile, "explain_log", "siso_explain", "explain log filename (relative to -log_dir")
 	flagSet.StringVar(&c.localexecLogFile, "localexec_log", "siso_localexec", "localexec log filename (relative to -log_dir")
 	flagSet.StringVar(&c.metricsJSON, "metrics_json", "siso_metrics.json", "metrics JSON filename (relative to -log_dir)")
 	flagSet.StringVar(&c.traceJSON, "trace_json", "siso_trace.json", "trace JSON filename (relative to -log_dir)")
 	flagSet.StringVar(&c.buildPprof, "build_pprof", "siso_build.pprof", "build pprof filename (relative to -log_dir)")
+	flagSet.StringVar(&c.hungStepLog, "hung_step_log", "siso_hung_steps.txt", "filename for diagnostics of hung local steps (relative to -log_dir)")
+	flagSet.Float64Var(&c.hungStepFactor, "hung_step_factor", 0, "flag local steps running longer than this multiple of their duration in previous -metrics_json as hung, and capture diagnostics. 0 disables (default)")
+	flagSet.DurationVar(&c.hungStepMin, "hung_step_min", 5*time.Minute, "minimum duration to flag local steps as hung")
+	flagSet.BoolVar(&c.hungStepSigquit, "hung_step_sigquit", false, "send SIGQUIT to java processes of hung steps to dump threads (linux only)")
 
 	c.fsopt = new(hashfs.Option)
 	c.fsopt.StateFile = ".siso_fs_state"
@@ -1323,6 +1484,8 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 	flagSet.BoolVar(&c.reExecEnable, "re_exec_enable", true, "remote exec enable")
 	flagSet.BoolVar(&c.reCacheEnableRead, "re_cache_enable_read", true, "remote exec cache enable read")
 	flagSet.BoolVar(&c.reCacheEnableWrite, "re_cache_enable_write", false, "remote exec cache allow local trusted uploads")
+	flagSet.StringVar(&c.reCacheSigningKey, "re_cache_signing_key", "", "PEM ed25519 private key file to sign action results uploaded by --re_cache_enable_write")
+	flagSet.StringVar(&c.reCacheTrustedKeys, "re_cache_trusted_keys", "", "PEM ed25519 public keys file to verify cached action results. action results not signed by the keys are treated as cache miss. it includes results cached by remote execution workers, which are not signed, so such actions are executed again without cache lookup. cache lookup via reproxy is disabled")
 	// reclient_helper.py sets the RBE_server_address
 	// https://chromium.googlesource.com/chromium/tools/depot_tools.git/+/e13840bd9a04f464e3bef22afac1976fc15a96a0/reclient_helper.py#138
 	c.reproxyAddr = os.Getenv("RBE_server_address")
@@ -1345,8 +1508,14 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 
 	flagSet.StringVar(&c.subtool, "t", "", "run a subtool (use '-t list' to list subtools)")
 	flagSet.BoolVar(&c.cleandead, "cleandead", false, "clean built files that are no longer produced by the manifest")
+	flagSet.BoolVar(&c.cleanGenerator, "g", false, "for -t clean: also clean files marked as ninja generator output")
+	flagSet.BoolVar(&c.cleanByRule, "r", false, "for -t clean: interpret targets as a list of rules to clean")
 	flagSet.Var(&c.debugMode, "d", "enable debugging (use '-d list' to list modes)")
 	flagSet.StringVar(&c.adjustWarn, "w", "", "adjust warnings. not supported b/288807840")
+
+	flagSet.BoolVar(&c.buildServer, "build_server", false, "run build in build server for the out dir, which keeps fs state, build graph and config between builds. start the server if not running.")
+	flagSet.BoolVar(&c.buildServerServe, "build_server_serve", false, "run as build server. internal use for -build_server.")
+	flagSet.DurationVar(&c.buildServerIdleTimeout, "build_server_idle_timeout", 1*time.Hour, "build server exits when it is idle for the duration.")
 }
 
 func (c *Command) initWorkdirs(ctx context.Context) (string, error) {
@@ -1606,7 +1775,79 @@ func (c *Command) initConfig(ctx context.Context, execRoot string, targets []str
 	return config, nil
 }
 
+// loadConfig returns build config, reusing the config in build server
+// if it is not changed.
+func (c *Command) loadConfig(ctx context.Context, execRoot string, targets []string) (*buildconfig.Config, error) {
+	if c.warm == nil {
+		return c.initConfig(ctx, execRoot, targets)
+	}
+	flags := c.initFlags(targets)
+	// build_id and job_id are unique for each build.
+	delete(flags, "build_id")
+	delete(flags, "job_id")
+	buf, err := json.Marshal(flags)
+	if err != nil {
+		return nil, err
+	}
+	key := string(buf)
+	if config := c.warm.reuseConfig(ctx, key); config != nil {
+		clog.Infof(ctx, "build server: reuse config")
+		return config, nil
+	}
+	config, err := c.initConfig(ctx, execRoot, targets)
+	if err != nil {
+		return nil, err
+	}
+	files := configFiles(c.configRepoDir, filepath.Join(execRoot, ".siso_remote"))
+	files = append(files, "args.gn")
+	c.warm.setConfig(ctx, key, config, files)
+	return config, nil
+}
+
+// newHashFS returns hashfs, reusing hashfs in build server
+// after refreshing it with local disk.
+func (c *Command) newHashFS(ctx context.Context, execRoot string) (*hashfs.HashFS, error) {
+	if c.warm == nil {
+		return hashfs.New(ctx, *c.fsopt)
+	}
+	if c.warm.hashFS != nil {
+		clog.Infof(ctx, "build server: refresh hashfs")
+		err := c.warm.hashFS.Refresh(ctx, execRoot)
+		if err != nil {
+			return nil, err
+		}
+		return c.warm.hashFS, nil
+	}
+	hashFS, err := hashfs.New(ctx, *c.fsopt)
+	if err != nil {
+		return nil, err
+	}
+	c.warm.hashFS = hashFS
+	return hashFS, nil
+}
+
+// warmKey returns a key of resources kept in build server.
+func (c *Command) warmKey(execRoot string) string {
+	return strings.Join([]string{
+		execRoot,
+		c.dir,
+		c.fname,
+		c.stateDir,
+		c.fsopt.StateFile,
+		c.depsLogFile,
+		c.cacheDir,
+		c.outputLocalStrategy,
+		strconv.FormatBool(c.offline),
+		strconv.FormatBool(c.localCacheEnable),
+		strconv.FormatBool(c.localCacheUncompressed),
+		c.reopt.String(),
+		c.authOpts.Type,
+	}, "\x00")
+}
+
 func (c *Command) initDepsLog(ctx context.Context) (*ninjautil.DepsLog, error) {
+	ctx, span := trace.NewSpan(ctx, "deps-log-load")
+	defer span.Close(nil)
 	depsLogFile := filepath.Join(c.stateDir, c.depsLogFile)
 	err := os.MkdirAll(filepath.Dir(depsLogFile), 0755)
 	if err != nil {
@@ -1671,6 +1912,44 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 	fmt.Fprintf(failedCommandsWriter, "cd %s%s", filepath.Join(buildPath.ExecRoot, buildPath.Dir), newline)
 	// TODO: for reproxy mode, may need to run reproxy for rewrapper commands.
 
+	junitXMLWriter, done, err := c.logWriter(ctx, c.junitXMLFile)
+	if err != nil {
+		return bopts, nil, err
+	}
+	dones = append(dones, done)
+	var junitReport *build.JUnitReport
+	if junitXMLWriter != nil {
+		junitReport = build.NewJUnitReport(c.junitXMLCompact)
+		dones = append(dones, func(errp *error) {
+			err := junitReport.WriteXML(junitXMLWriter)
+			if err != nil {
+				clog.Warningf(ctx, "failed to write junit xml: %v", err)
+			}
+		})
+	}
+
+	diagnosticsWriter, done, err := c.logWriter(ctx, c.diagnosticsFile)
+	if err != nil {
+		return bopts, nil, err
+	}
+	dones = append(dones, done)
+	var diagnosticsReport *build.DiagnosticsReport
+	if diagnosticsWriter != nil {
+		diagnosticsReport = build.NewDiagnosticsReport()
+		dones = append(dones, func(errp *error) {
+			var err error
+			switch c.diagnosticsFormat {
+			case "json":
+				err = diagnosticsReport.WriteJSON(diagnosticsWriter)
+			default:
+				err = diagnosticsReport.WriteSARIF(diagnosticsWriter, buildPath.ExecRoot)
+			}
+			if err != nil {
+				clog.Warningf(ctx, "failed to write diagnostics: %v", err)
+			}
+		})
+	}
+
 	outputLogWriter, done, err := c.logWriter(ctx, c.outputLogFile)
 	if err != nil {
 		return bopts, nil, err
@@ -1700,6 +1979,7 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 		return bopts, nil, err
 	}
 	dones = append(dones, done)
+	hungStep := c.hungStepOptions(ctx)
 
 	if !filepath.IsAbs(c.traceJSON) {
 		c.traceJSON = filepath.Join(c.logDir, c.traceJSON)
@@ -1716,6 +1996,15 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 		rotateFiles(ctx, c.traceJSON)
 	}
 
+	var signer *signature.Signer
+	if c.reCacheSigningKey != "" {
+		signer, err = signature.LoadSigner(c.reCacheSigningKey)
+		if err != nil {
+			return bopts, nil, fmt.Errorf("failed to load --re_cache_signing_key: %w", err)
+		}
+		clog.Infof(ctx, "sign action results with key_id=%s", signer.KeyID())
+	}
+
 	cache, err := build.NewCache(ctx, build.CacheOptions{
 		Store:      ds.cache,
 		EnableRead: c.cacheEnableRead,
@@ -1735,12 +2024,17 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 		REExecEnable:          c.reExecEnable,
 		RECacheEnableRead:     c.reCacheEnableRead,
 		RECacheEnableWrite:    c.reCacheEnableWrite,
+		RECacheSigner:         signer,
+		RECacheVerifier:       ds.verifier,
 		ReproxyAddr:           c.reproxyAddr,
 		ActionSalt:            actionSaltBytes,
 		OutputLocal:           build.OutputLocalFunc(c.fsopt.OutputLocal),
 		Cache:                 cache,
 		FailureSummaryWriter:  failureSummaryWriter,
 		FailedCommandsWriter:  failedCommandsWriter,
+		JUnitReport:           junitReport,
+		DiagnosticsReport:     diagnosticsReport,
+		OperationStats:        c.operationStats,
 		OutputLogWriter:       outputLogWriter,
 		ExplainWriter:         explainWriter,
 		LocalexecLogWriter:    localexecLogWriter,
@@ -1761,6 +2055,7 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 		KeepDepfile:           c.debugMode.Keepdepfile,
 		Limits:                limits,
 		UploadBuildNinjaFiles: c.enableBuildNinjaFilesUpload,
+		HungStep:              hungStep,
 	}
 	return bopts, func(err *error) {
 		for i := len(dones) - 1; i >= 0; i-- {
@@ -1769,6 +2064,42 @@ func (c *Command) initBuildOpts(ctx context.Context, projectID string, buildPath
 	}, nil
 }
 
+// hungStepHistory is the number of rotated -metrics_json files
+// to load historical step durations from.
+const hungStepHistory = 3
+
+// hungStepOptions returns options of the hung step watchdog with
+// step durations in previous builds.
+// It must be called after -metrics_json is rotated.
+func (c *Command) hungStepOptions(ctx context.Context) build.HungStepOptions {
+	opts := build.HungStepOptions{
+		Factor:      c.hungStepFactor,
+		MinDuration: c.hungStepMin,
+		SigQuit:     c.hungStepSigquit,
+	}
+	if c.hungStepFactor <= 0 || c.hungStepLog == "" || c.metricsJSON == "" {
+		return opts
+	}
+	opts.DiagnosticsFile = c.logFilename(c.hungStepLog, "")
+	rotateFiles(ctx, opts.DiagnosticsFile)
+
+	fname := c.logFilename(c.metricsJSON, "")
+	ext := filepath.Ext(fname)
+	fnameBase := strings.TrimSuffix(fname, ext)
+	var fnames []string
+	for i := range hungStepHistory {
+		fnames = append(fnames, fmt.Sprintf("%s.%d%s", fnameBase, i, ext))
+	}
+	started := time.Now()
+	var err error
+	opts.Durations, err = build.LoadStepDurations(fnames...)
+	if err != nil {
+		clog.Warningf(ctx, "failed to load step durations: %v", err)
+	}
+	clog.Infof(ctx, "loaded %d step durations in %s", len(opts.Durations), time.Since(started))
+	return opts
+}
+
 // logFilename returns siso's log filename relative to startDir, or absolute path.
 func (c *Command) logFilename(fname, startDir string) string {
 	if fname == "" {
@@ -1886,6 +2217,25 @@ func doBuild(ctx context.Context, graph *ninjabuild.Graph, bopts build.Options,
 		}
 		spin.Stop(nil)
 	}
+	if nopts.subtool == "clean" {
+		spin := ui.Default.NewSpinner()
+		spin.Start("cleaning")
+		if bopts.DryRun {
+			spin.Done("dry run")
+			return stats, nil
+		}
+		n, err := graph.Clean(ctx, ninjabuild.CleanOptions{
+			Targets:   args,
+			ByRule:    nopts.cleanByRule,
+			Generator: nopts.cleanGenerator,
+		})
+		if err != nil {
+			spin.Stop(err)
+			return stats, err
+		}
+		spin.Done("%d files", n)
+		return stats, nil
+	}
 
 	b, err := build.New(ctx, graph, bopts)
 	if err != nil {
@@ -1908,9 +2258,20 @@ func doBuild(ctx context.Context, graph *ninjabuild.Graph, bopts build.Options,
 			clog.Warningf(ctx, "failed to close builder: %v", cerr)
 		}
 	}(ctx)
+	var t *tui.TUI
+	if nopts.tui {
+		t, err = tui.Start(hctx, b)
+		if err != nil {
+			clog.Warningf(ctx, "tui: %v", err)
+			ui.Default.PrintLines("\n", fmt.Sprintf("-tui is ignored: %v\n", err))
+		}
+		// restore terminal even if build panics.
+		defer t.Stop()
+	}
 	// prof := newCPUProfiler(ctx, "build")
 	err = b.Build(ctx, "build", args...)
 	// prof.stop(ctx)
+	t.Stop()
 
 	if err != nil {
 		if errors.As(err, &build.MissingSourceError{}) {
@@ -2093,29 +2454,51 @@ func (c *Command) logSymlink(ctx context.Context) error {
 type dataSource struct {
 	cache  cachestore.CacheStore
 	client *reapi.Client
+
+	// verifier verifies action results in cache, if set.
+	verifier *signature.Verifier
 }
 
 func (c *Command) initDataSource(ctx context.Context, credential cred.Cred) (dataSource, error) {
 	layeredCache := build.NewLayeredCache()
+	var localCache *build.LocalCache
 	if c.localCacheEnable {
-		cache, err := build.NewLocalCache(c.cacheDir)
+		cache, err := build.NewLocalCacheWithOptions(c.cacheDir, build.LocalCacheOptions{
+			Uncompressed: c.localCacheUncompressed,
+		})
 		if err != nil {
 			clog.Warningf(ctx, "failed to create local cache - no local cache enabled: %v", err)
 		} else {
 			layeredCache.AddLayer(cache)
 			cache.GarbageCollectIfRequired(ctx)
+			localCache = cache
 		}
 	} else {
 		c.cacheDir = ""
 	}
+	var verifier *signature.Verifier
+	if c.reCacheTrustedKeys != "" {
+		var err error
+		verifier, err = signature.LoadVerifier(c.reCacheTrustedKeys)
+		if err != nil {
+			return dataSource{}, fmt.Errorf("failed to load --re_cache_trusted_keys: %w", err)
+		}
+		layeredCache.SetVerifier(verifier)
+	}
 	var ds dataSource
+	ds.verifier = verifier
 	err := c.reopt.CheckValid()
 	if err == nil {
 		ds.client, err = reapi.New(ctx, credential, *c.reopt)
 		if err != nil {
 			return ds, err
 		}
-		layeredCache.AddLayer(ds.client.CacheStore())
+		layeredCache.AddLayer(ds.client.CacheStore().WithVerifier(verifier))
+		if localCache != nil {
+			// store chunks of large blobs, so only changed chunks
+			// are fetched by SplitBlob API.
+			ds.client.SetChunkCache(localCache)
+		}
 	}
 	ds.cache = layeredCache
 	return ds, nil
@@ -9,8 +9,6 @@ import (
 	"fmt"
 	"io"
 	"strings"
-
-	"go.chromium.org/build/siso/ui"
 )
 
 // ninja compatible debug mode.
@@ -19,21 +17,20 @@ type debugMode struct {
 	Explain     bool // explain what caused a command to execute
 	Keepdepfile bool // don't delete depfiles after they're read by ninja
 	Keeprsp     bool // don't delete @response files on success
+	Stategraph  bool // verify cached manifest state with fresh parse
 	List        bool // lists modes
 }
 
 func (m *debugMode) check() error {
 	if m.List {
 		return errors.New(`debugging modes
-  stats        not implemented: print operation counts/timing info
+  stats        print operation counts/timing info
   explain      explain what caused a command to execute
   keepdepfile  don't delete depfiles after they're read by ninja
   keeprsp      don't delete @response files on success
+  stategraph   verify cached manifest state with fresh parse (siso specific)
 multiple modes can be enabled via -d FOO -d BAR`)
 	}
-	if m.Stats {
-		ui.Default.Warningf("WARNING: `-d stats` is not implemented yet\n")
-	}
 	return nil
 }
 
@@ -52,6 +49,9 @@ func (m *debugMode) String() string {
 	if m.Keeprsp {
 		modes = append(modes, "keeprsp")
 	}
+	if m.Stategraph {
+		modes = append(modes, "stategraph")
+	}
 	return strings.Join(modes, ",")
 }
 
@@ -67,6 +67,8 @@ func (m *debugMode) Set(v string) error {
 			m.Keepdepfile = true
 		case "keeprsp":
 			m.Keeprsp = true
+		case "stategraph":
+			m.Stategraph = true
 		case "list":
 			m.List = true
 		default:
@@ -0,0 +1,725 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/golang/glog"
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/auth/cred"
+	"go.chromium.org/build/siso/build/buildconfig"
+	"go.chromium.org/build/siso/build/ninjabuild"
+	"go.chromium.org/build/siso/hashfs"
+	"go.chromium.org/build/siso/o11y/clog"
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+	"go.chromium.org/build/siso/ui"
+)
+
+// Build server keeps hashfs, build graph, step config and reapi client
+// alive between builds for an out dir, so `siso ninja -build_server`
+// doesn't need to reload .siso_fs_state, reparse build.ninja and
+// re-evaluate starlark config every time.
+//
+// The client forwards its flags, targets, working directory and
+// environment to the server via unix domain socket, and streams
+// stdout/stderr of the build back.
+// The server exits when it is idle for -build_server_idle_timeout, or
+// when the siso binary is updated.
+
+const (
+	// relative to -state_dir
+	serverLogFile = "siso_server.log"
+)
+
+// serverRequest is a request sent by the client at the beginning of
+// the connection.
+type serverRequest struct {
+	Args       []string `json:"args"`
+	Dir        string   `json:"dir"`
+	Env        []string `json:"env"`
+	IsTerminal bool     `json:"is_terminal"`
+	Binary     string   `json:"binary"`
+}
+
+// terminalFlags are flags whose defaults depend on whether the client
+// is on a terminal.
+var terminalFlags = []string{"fast_nop", "fast_local", "fast_last_failure", "fast_exit"}
+
+// serverCancel is sent by the client to interrupt the build.
+type serverCancel struct {
+	Cancel bool `json:"cancel"`
+}
+
+// serverMessage is a message sent by the server.
+type serverMessage struct {
+	Stdout  []byte `json:"stdout,omitempty"`
+	Stderr  []byte `json:"stderr,omitempty"`
+	Exit    *int   `json:"exit,omitempty"`
+	Restart bool   `json:"restart,omitempty"`
+}
+
+// serverSocket returns unix domain socket path for the state dir.
+// It uses a hashed name in temp dir, since socket path length is
+// limited (e.g. 108 bytes on linux).
+func serverSocket(stateDir string) (string, error) {
+	absStateDir, err := filepath.Abs(stateDir)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256([]byte(absStateDir))
+	return filepath.Join(os.TempDir(), fmt.Sprintf("siso-%s.sock", hex.EncodeToString(h[:8]))), nil
+}
+
+// binaryID identifies the siso binary to detect binary update.
+func binaryID() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return exe
+	}
+	return fmt.Sprintf("%s:%d:%d", exe, fi.Size(), fi.ModTime().UnixNano())
+}
+
+// serverArgs returns args to forward to the build server.
+func (c *Command) serverArgs() []string {
+	var args []string
+	c.Flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "build_server", "build_server_serve", "build_server_idle_timeout", "batch":
+			return
+		}
+		args = append(args, fmt.Sprintf("-%s=%s", f.Name, f.Value.String()))
+	})
+	return append(args, c.Flags.Args()...)
+}
+
+// runClient runs the build in the build server, starting the server
+// if it is not running.
+func (c *Command) runClient(ctx context.Context) subcommands.ExitStatus {
+	stateDir := c.stateDir
+	if stateDir == "" {
+		stateDir = filepath.Dir(c.fname)
+	}
+	stateDir = filepath.Join(c.dir, stateDir)
+	sock, err := serverSocket(stateDir)
+	if err != nil {
+		ui.Default.Errorf("failed to get build server socket: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		ui.Default.Errorf("failed to get working directory: %v\n", err)
+		return subcommands.ExitFailure
+	}
+	req := serverRequest{
+		Args:       c.serverArgs(),
+		Dir:        wd,
+		Env:        os.Environ(),
+		IsTerminal: ui.IsTerminal(),
+		Binary:     binaryID(),
+	}
+	for range 2 {
+		conn, err := c.dialServer(ctx, sock, stateDir)
+		if errors.Is(err, errors.ErrUnsupported) {
+			ui.Default.Warningf("build server is not supported. build without build server\n")
+			c.buildServer = false
+			return c.Execute(ctx, c.Flags)
+		}
+		if err != nil {
+			ui.Default.Errorf("failed to connect build server: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		exitCode, restart, err := c.requestBuild(ctx, conn, req)
+		cerr := conn.Close()
+		if cerr != nil {
+			log.Warningf("close build server connection: %v", cerr)
+		}
+		if err != nil {
+			ui.Default.Errorf("build server error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+		if restart {
+			ui.Default.Infof("build server was stale. restarting\n")
+			waitServerExit(ctx, sock)
+			continue
+		}
+		return subcommands.ExitStatus(exitCode)
+	}
+	ui.Default.Errorf("failed to restart build server\n")
+	return subcommands.ExitFailure
+}
+
+// dialServer connects to the build server for stateDir.
+// It starts new server if no server is running.
+func (c *Command) dialServer(ctx context.Context, sock, stateDir string) (net.Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", sock)
+	if err == nil {
+		return conn, nil
+	}
+	err = os.MkdirAll(stateDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	logFile, err := os.Create(filepath.Join(stateDir, serverLogFile))
+	if err != nil {
+		return nil, err
+	}
+	defer logFile.Close()
+	args := []string{"ninja", "-build_server_serve", "-C", c.dir, "-f", c.fname, "-build_server_idle_timeout", c.buildServerIdleTimeout.String()}
+	if c.stateDir != "" {
+		args = append(args, "-state_dir", c.stateDir)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	err = detachServer(cmd)
+	if err != nil {
+		return nil, err
+	}
+	spin := ui.Default.NewSpinner()
+	spin.Start("starting build server")
+	err = cmd.Start()
+	if err != nil {
+		spin.Stop(err)
+		return nil, err
+	}
+	go func() {
+		// reap the process if it exits while this client is alive.
+		_ = cmd.Wait()
+	}()
+	timeout := time.After(30 * time.Second)
+	for {
+		conn, err = d.DialContext(ctx, "unix", sock)
+		if err == nil {
+			spin.Stop(nil)
+			return conn, nil
+		}
+		select {
+		case <-ctx.Done():
+			spin.Stop(context.Cause(ctx))
+			return nil, context.Cause(ctx)
+		case <-timeout:
+			err = fmt.Errorf("build server didn't start: %w. see %s", err, filepath.Join(stateDir, serverLogFile))
+			spin.Stop(err)
+			return nil, err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// waitServerExit waits for the stale server to remove its socket.
+func waitServerExit(ctx context.Context, sock string) {
+	timeout := time.After(30 * time.Second)
+	for {
+		_, err := os.Stat(sock)
+		if errors.Is(err, fs.ErrNotExist) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-timeout:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// requestBuild sends req to the server and streams outputs.
+// It returns exit code of the build, or restart=true if the server
+// is stale and exiting.
+func (c *Command) requestBuild(ctx context.Context, conn net.Conn, req serverRequest) (int, bool, error) {
+	enc := json.NewEncoder(conn)
+	err := enc.Encode(req)
+	if err != nil {
+		return 0, false, err
+	}
+	sigch := make(chan os.Signal, 2)
+	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigch)
+	go func() {
+		for range sigch {
+			// server cancels the build, and will report
+			// the exit code.
+			err := enc.Encode(serverCancel{Cancel: true})
+			if err != nil {
+				log.Warningf("failed to send cancel: %v", err)
+			}
+		}
+	}()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	for {
+		var msg serverMessage
+		err := dec.Decode(&msg)
+		if err != nil {
+			return 0, false, fmt.Errorf("connection lost: %w", err)
+		}
+		if msg.Restart {
+			return 0, true, nil
+		}
+		if len(msg.Stdout) > 0 {
+			os.Stdout.Write(msg.Stdout)
+		}
+		if len(msg.Stderr) > 0 {
+			os.Stderr.Write(msg.Stderr)
+		}
+		if msg.Exit != nil {
+			return *msg.Exit, false, nil
+		}
+	}
+}
+
+// serve runs the build server.
+func (c *Command) serve(ctx context.Context) subcommands.ExitStatus {
+	stateDir := c.stateDir
+	if stateDir == "" {
+		stateDir = filepath.Dir(c.fname)
+	}
+	stateDir = filepath.Join(c.dir, stateDir)
+	sock, err := serverSocket(stateDir)
+	if err != nil {
+		log.Errorf("failed to get socket: %v", err)
+		return subcommands.ExitFailure
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", sock)
+	if err == nil {
+		conn.Close()
+		log.Errorf("build server is already running at %s", sock)
+		return subcommands.ExitFailure
+	}
+	// remove stale socket.
+	err = os.Remove(sock)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Warningf("failed to remove stale socket %s: %v", sock, err)
+	}
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "unix", sock)
+	if err != nil {
+		log.Errorf("failed to listen %s: %v", sock, err)
+		return subcommands.ExitFailure
+	}
+	startDir, err := os.Getwd()
+	if err != nil {
+		log.Errorf("failed to get working directory: %v", err)
+		return subcommands.ExitFailure
+	}
+	s := &buildServer{
+		authOpts:    c.authOpts,
+		version:     c.version,
+		binary:      binaryID(),
+		startDir:    startDir,
+		idleTimeout: c.buildServerIdleTimeout,
+		warm:        &warmState{},
+	}
+	fmt.Printf("build server listening at %s pid=%d\n", sock, os.Getpid())
+	s.run(ctx, listener)
+	// listener.Close removes the socket file.
+	s.warm.close(ctx)
+	fmt.Printf("build server exit\n")
+	return subcommands.ExitSuccess
+}
+
+// buildServer is a build server for an out dir.
+type buildServer struct {
+	authOpts    cred.Options
+	version     string
+	binary      string
+	startDir    string
+	idleTimeout time.Duration
+
+	warm *warmState
+
+	mu         sync.Mutex
+	lastActive time.Time
+}
+
+func (s *buildServer) run(ctx context.Context, listener net.Listener) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.lastActive = time.Now()
+	connch := make(chan net.Conn)
+	go func() {
+		defer close(connch)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Infof("accept: %v", err)
+				return
+			}
+			select {
+			case connch <- conn:
+			case <-ctx.Done():
+				conn.Close()
+				return
+			}
+		}
+	}()
+	defer listener.Close()
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(s.lastActive) > s.idleTimeout {
+				fmt.Printf("idle for %s\n", time.Since(s.lastActive))
+				return
+			}
+			if binaryID() != s.binary {
+				fmt.Printf("siso binary updated\n")
+				return
+			}
+		case conn, ok := <-connch:
+			if !ok {
+				return
+			}
+			restart := s.handle(ctx, conn)
+			s.lastActive = time.Now()
+			if restart {
+				fmt.Printf("siso binary updated\n")
+				return
+			}
+		}
+	}
+}
+
+// handle handles a build request on conn.
+// It returns true if the server should exit for restart.
+func (s *buildServer) handle(ctx context.Context, conn net.Conn) bool {
+	defer conn.Close()
+	dec := json.NewDecoder(bufio.NewReader(conn))
+	var req serverRequest
+	err := dec.Decode(&req)
+	if err != nil {
+		log.Warningf("failed to decode request: %v", err)
+		return false
+	}
+	w := &serverWriter{enc: json.NewEncoder(conn)}
+	if req.Binary != s.binary || binaryID() != s.binary {
+		w.send(serverMessage{Restart: true})
+		return true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		// cancel the build by cancel request or disconnect.
+		for {
+			var c serverCancel
+			err := dec.Decode(&c)
+			if err != nil || c.Cancel {
+				cancel()
+				return
+			}
+		}
+	}()
+	exitCode := s.build(ctx, req, w)
+	w.send(serverMessage{Exit: &exitCode})
+	return false
+}
+
+// build runs a build for req, and streams outputs to w.
+func (s *buildServer) build(ctx context.Context, req serverRequest, w *serverWriter) int {
+	restore, err := w.capture()
+	if err != nil {
+		msg := fmt.Sprintf("failed to capture output: %v\n", err)
+		w.send(serverMessage{Stderr: []byte(msg)})
+		return int(subcommands.ExitFailure)
+	}
+	origUI := ui.Default
+	origEnv := os.Environ()
+	defer func() {
+		ui.Default = origUI
+		restore()
+		resetEnv(origEnv)
+		err := os.Chdir(s.startDir)
+		if err != nil {
+			log.Warningf("failed to chdir %s: %v", s.startDir, err)
+		}
+	}()
+	resetEnv(req.Env)
+	// outputs are sent to the client through pipes, which can't
+	// handle terminal control, so use log UI even if the client
+	// is on a terminal.
+	ui.Default = &ui.LogUI{}
+	err = os.Chdir(req.Dir)
+	if err != nil {
+		ui.Default.Errorf("failed to chdir %s: %v\n", req.Dir, err)
+		return int(subcommands.ExitFailure)
+	}
+	c := Cmd(s.authOpts, s.version)
+	flagSet := flag.NewFlagSet("ninja", flag.ContinueOnError)
+	flagSet.SetOutput(os.Stderr)
+	c.SetFlags(flagSet)
+	// defaults of these flags depend on whether it is on a terminal,
+	// so use the client's.
+	for _, name := range terminalFlags {
+		err = flagSet.Set(name, strconv.FormatBool(req.IsTerminal))
+		if err != nil {
+			ui.Default.Errorf("failed to set -%s: %v\n", name, err)
+			return int(subcommands.ExitFailure)
+		}
+	}
+	err = flagSet.Parse(req.Args)
+	if err != nil {
+		return int(subcommands.ExitUsageError)
+	}
+	c.warm = s.warm
+	return int(c.Execute(ctx, flagSet))
+}
+
+func resetEnv(env []string) {
+	os.Clearenv()
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		_ = os.Setenv(k, v) // ignore error for invalid env key.
+	}
+}
+
+// serverWriter sends serverMessage to the client.
+type serverWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *serverWriter) send(msg serverMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.enc.Encode(msg)
+	if err != nil {
+		log.Warningf("failed to send message: %v", err)
+	}
+}
+
+// capture redirects os.Stdout and os.Stderr to the client.
+// It returns a function to restore them.
+func (w *serverWriter) capture() (func(), error) {
+	origStdout, origStderr := os.Stdout, os.Stderr
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		stdoutR.Close()
+		stdoutW.Close()
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	pump := func(r io.ReadCloser, msg func([]byte) serverMessage) {
+		defer wg.Done()
+		defer r.Close()
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				w.send(msg(slices.Clone(buf[:n])))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	wg.Add(2)
+	go pump(stdoutR, func(b []byte) serverMessage { return serverMessage{Stdout: b} })
+	go pump(stderrR, func(b []byte) serverMessage { return serverMessage{Stderr: b} })
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	return func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		stdoutW.Close()
+		stderrW.Close()
+		wg.Wait()
+	}, nil
+}
+
+// warmState holds resources kept alive across builds in build server.
+// nil *warmState means no build server, i.e. all resources are
+// created and released in each build.
+type warmState struct {
+	// key identifies the out dir and options of the resources.
+	key string
+
+	credential *cred.Cred
+	ds         *dataSource
+	hashFS     *hashfs.HashFS
+	depsLog    *ninjautil.DepsLog
+
+	configKey   string
+	config      *buildconfig.Config
+	configFiles map[string]time.Time
+
+	graph      *ninjabuild.Graph
+	graphFiles map[string]time.Time
+}
+
+// check checks key matches with the current resources.
+// If not, it releases all resources.
+func (w *warmState) check(ctx context.Context, key string) {
+	if w == nil || w.key == key {
+		return
+	}
+	if w.key != "" {
+		clog.Infof(ctx, "build server: options changed. drop warm state")
+	}
+	w.close(ctx)
+	*w = warmState{key: key}
+}
+
+func (w *warmState) close(ctx context.Context) {
+	if w.hashFS != nil {
+		err := w.hashFS.Close(ctx)
+		if err != nil {
+			clog.Warningf(ctx, "close hashfs: %v", err)
+		}
+	}
+	if w.depsLog != nil {
+		err := w.depsLog.Close()
+		if err != nil {
+			clog.Warningf(ctx, "close deps log: %v", err)
+		}
+	}
+	if w.ds != nil {
+		err := w.ds.Close(ctx)
+		if err != nil {
+			clog.Warningf(ctx, "close datasource: %v", err)
+		}
+	}
+	*w = warmState{}
+}
+
+// reuseConfig returns config if it can be reused for key.
+func (w *warmState) reuseConfig(ctx context.Context, key string) *buildconfig.Config {
+	if w == nil || w.config == nil {
+		return nil
+	}
+	if w.configKey != key || filesChanged(w.configFiles) {
+		clog.Infof(ctx, "build server: config changed")
+		w.config = nil
+		w.graph = nil
+		return nil
+	}
+	return w.config
+}
+
+// setConfig sets config for key, which depends on files.
+func (w *warmState) setConfig(ctx context.Context, key string, config *buildconfig.Config, files []string) {
+	if w == nil {
+		return
+	}
+	w.configKey = key
+	w.config = config
+	w.configFiles = fileMtimes(files)
+	w.graph = nil
+	clog.Infof(ctx, "build server: set config %d files", len(w.configFiles))
+}
+
+// reuseGraph returns graph for the next build if it can be reused.
+func (w *warmState) reuseGraph(ctx context.Context, config *buildconfig.Config) *ninjabuild.Graph {
+	if w == nil || w.graph == nil {
+		return nil
+	}
+	// graph holds step config from init, which may depend on
+	// files and envs accessed by init.
+	if w.config != config || config.EnvChanged() || filesChanged(w.graphFiles) {
+		clog.Infof(ctx, "build server: build graph changed")
+		w.graph = nil
+		return nil
+	}
+	w.graph.Reuse(ctx)
+	return w.graph
+}
+
+// setGraph sets graph for the next build.
+func (w *warmState) setGraph(ctx context.Context, graph *ninjabuild.Graph) {
+	if w == nil {
+		return
+	}
+	w.graph = graph
+	files := graph.Filenames()
+	if w.config != nil {
+		files = slices.Concat(files, w.config.DepFiles())
+	}
+	w.graphFiles = fileMtimes(files)
+	clog.Infof(ctx, "build server: set graph %d files", len(w.graphFiles))
+}
+
+// configFiles returns files that config depends on.
+func configFiles(dirs ...string) []string {
+	var files []string
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// ignore missing dir, such as .siso_remote.
+				return nil
+			}
+			// check dirs too to detect added files.
+			files = append(files, path)
+			return nil
+		})
+	}
+	return files
+}
+
+func fileMtimes(files []string) map[string]time.Time {
+	m := make(map[string]time.Time)
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			m[f] = time.Time{}
+			continue
+		}
+		m[f] = fi.ModTime()
+	}
+	return m
+}
+
+func filesChanged(m map[string]time.Time) bool {
+	for _, f := range slices.Sorted(maps.Keys(m)) {
+		var mtime time.Time
+		fi, err := os.Stat(f)
+		if err == nil {
+			mtime = fi.ModTime()
+		}
+		if !mtime.Equal(m[f]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninja
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/ui"
+)
+
+func TestServerArgs(t *testing.T) {
+	c := &Command{}
+	flagSet := flag.NewFlagSet("ninja", flag.ContinueOnError)
+	c.SetFlags(flagSet)
+	err := flagSet.Parse([]string{"-C", "out/siso", "-build_server", "-build_server_idle_timeout=1h", "-k", "0", "chrome"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Flags = flagSet
+	got := c.serverArgs()
+	want := []string{"-C=out/siso", "-k=0", "chrome"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("serverArgs diff -want +got:\n%s", diff)
+	}
+}
+
+func TestFilesChanged(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "file")
+	err := os.WriteFile(fname, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := fileMtimes([]string{fname, filepath.Join(dir, "missing")})
+	if filesChanged(m) {
+		t.Errorf("filesChanged=true; want false")
+	}
+	err = os.Chtimes(fname, time.Time{}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filesChanged(m) {
+		t.Errorf("filesChanged=false after update; want true")
+	}
+}
+
+// serverTestConn returns serverWriter to the client conn, and
+// channel of messages received by the client.
+func serverTestConn(t *testing.T) (*serverWriter, <-chan serverMessage) {
+	t.Helper()
+	sconn, cconn := net.Pipe()
+	t.Cleanup(func() {
+		sconn.Close()
+		cconn.Close()
+	})
+	ch := make(chan serverMessage)
+	go func() {
+		defer close(ch)
+		dec := json.NewDecoder(bufio.NewReader(cconn))
+		for {
+			var msg serverMessage
+			err := dec.Decode(&msg)
+			if err != nil {
+				return
+			}
+			ch <- msg
+		}
+	}()
+	return &serverWriter{enc: json.NewEncoder(sconn)}, ch
+}
+
+func TestServerHandle_Restart(t *testing.T) {
+	ctx := t.Context()
+	sconn, cconn := net.Pipe()
+	defer cconn.Close()
+	s := &buildServer{binary: "new"}
+	restartch := make(chan bool, 1)
+	go func() {
+		restartch <- s.handle(ctx, sconn)
+	}()
+	c := &Command{}
+	exitCode, restart, err := c.requestBuild(ctx, cconn, serverRequest{Binary: "old"})
+	if err != nil || !restart {
+		t.Errorf("requestBuild=%d, %t, %v; want restart", exitCode, restart, err)
+	}
+	if !<-restartch {
+		t.Errorf("handle=false; want true for binary update")
+	}
+}
+
+func TestServerBuild(t *testing.T) {
+	ctx := t.Context()
+	startDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	origUI := ui.Default
+	s := &buildServer{startDir: startDir}
+	w, ch := serverTestConn(t)
+	var stderr strings.Builder
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range ch {
+			stderr.Write(msg.Stderr)
+			if msg.Exit != nil {
+				return
+			}
+		}
+	}()
+	exitCode := s.build(ctx, serverRequest{
+		Dir:        filepath.Join(t.TempDir(), "nonexistent"),
+		Env:        os.Environ(),
+		IsTerminal: true,
+	}, w)
+	w.send(serverMessage{Exit: &exitCode})
+	<-done
+	if exitCode != int(subcommands.ExitFailure) {
+		t.Errorf("build=%d; want %d", exitCode, subcommands.ExitFailure)
+	}
+	if ui.Default != origUI {
+		t.Errorf("ui.Default=%T is not restored; want %T", ui.Default, origUI)
+	}
+	wd, err := os.Getwd()
+	if err != nil || wd != startDir {
+		t.Errorf("Getwd=%q, %v; want %q", wd, err, startDir)
+	}
+	// log UI doesn't emit terminal control sequences to the pipe.
+	got := stderr.String()
+	if !strings.Contains(got, "failed to chdir") || strings.Contains(got, "\033[") {
+		t.Errorf("stderr=%q; want chdir error without escape sequences", got)
+	}
+}
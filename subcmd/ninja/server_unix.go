@@ -0,0 +1,21 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build unix
+
+package ninja
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachServer sets cmd to run the build server in a new session,
+// so it keeps running after the client exits.
+func detachServer(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setsid: true,
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build windows
+
+package ninja
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// detachServer is not supported on windows yet.
+func detachServer(cmd *exec.Cmd) error {
+	return errors.ErrUnsupported
+}
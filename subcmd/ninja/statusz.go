@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"go.chromium.org/build/siso/build"
 	"go.chromium.org/build/siso/o11y/clog"
@@ -33,6 +34,27 @@ func newStatuszServer(ctx context.Context, b *build.Builder, dir string) error {
 			clog.Warningf(ctx, "failed to write response: %v", err)
 		}
 	}))
+	mux.Handle("/api/live_status", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var since int64
+		if v := req.FormValue("since"); v != "" {
+			var err error
+			since, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("bad since %q: %v", v, err), http.StatusBadRequest)
+				return
+			}
+		}
+		buf, err := json.Marshal(b.LiveStatus(since))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to json marshal: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Add("Context-Type", "text/json")
+		_, err = w.Write(buf)
+		if err != nil {
+			clog.Warningf(ctx, "failed to write response: %v", err)
+		}
+	}))
 	s := &http.Server{
 		Handler: mux,
 	}
@@ -0,0 +1,283 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package query
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+const missingdepsUsage = `check deps log dependencies on generated files
+
+ $ siso query missingdeps -C <dir> [<targets>]
+
+reports deps log entries (i.e. dependencies discovered by depfile)
+on generated files, whose generator is not reachable through declared
+inputs or order-only deps of the step in the build graph.
+Such a step works only by luck of scheduling, and may fail when it is
+built alone or not late enough in a clean output directory.
+
+----
+Missing dep: <target> uses <generated file> (generated by <rule>)
+  ...
+
+Processed <n> nodes.
+Error: There are <n> missing dependency paths.
+...
+----
+
+If targets are given, checks deps log entries of the targets and
+their transitive inputs. If no targets are given, checks all deps log
+entries.
+It exits with failure if missing deps are found.
+`
+
+func (*missingdepsCommand) Name() string {
+	return "missingdeps"
+}
+
+func (*missingdepsCommand) Synopsis() string {
+	return "check deps log dependencies on generated files"
+}
+
+func (*missingdepsCommand) Usage() string {
+	return missingdepsUsage
+}
+
+type missingdepsCommand struct {
+	w io.Writer
+
+	dir         string
+	stateDir    string
+	fname       string
+	depsLogFile string
+}
+
+func (c *missingdepsCommand) SetFlags(flagSet *flag.FlagSet) {
+	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory to find build.ninja and deps log")
+	flagSet.StringVar(&c.stateDir, "state_dir", ".", "state directory (relative to -C)")
+	flagSet.StringVar(&c.fname, "f", "build.ninja", "input build filename (relative to -C)")
+	flagSet.StringVar(&c.depsLogFile, "deps_log", ".siso_deps", "deps log filename (relative to -C, -state_dir)")
+}
+
+// errMissingDeps is returned when missing deps are found.
+var errMissingDeps = errors.New("missing deps found")
+
+func (c *missingdepsCommand) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
+	if c.w == nil {
+		c.w = os.Stdout
+	}
+	err := c.run(ctx, flagSet.Args())
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			fmt.Fprintf(os.Stderr, "%v\n%s\n", err, missingdepsUsage)
+			return subcommands.ExitUsageError
+		case errors.Is(err, errMissingDeps):
+			return subcommands.ExitFailure
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *missingdepsCommand) run(ctx context.Context, args []string) error {
+	err := os.Chdir(c.dir)
+	if err != nil {
+		return err
+	}
+	depsLog, err := ninjautil.NewDepsLog(ctx, filepath.Join(c.stateDir, c.depsLogFile))
+	if err != nil {
+		return err
+	}
+	defer depsLog.Close()
+
+	state := ninjautil.NewState()
+	p := ninjautil.NewManifestParser(state)
+	err = p.Load(ctx, c.fname)
+	if err != nil {
+		return err
+	}
+	var nodes []*ninjautil.Node
+	if len(args) > 0 {
+		nodes, err = state.Targets(args)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, target := range depsLog.RecordedTargets() {
+			n, ok := state.LookupNodeByPath(target)
+			if !ok {
+				// stale entry, not in current build graph.
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	s := &missingDepsScanner{
+		state:     state,
+		seen:      make(map[*ninjautil.Edge]bool),
+		adjacency: make(map[*ninjautil.Edge]map[*ninjautil.Edge]bool),
+	}
+	for _, n := range nodes {
+		err := s.check(ctx, depsLog, n)
+		if err != nil {
+			return err
+		}
+	}
+	s.report(c.w)
+	if len(s.missings) > 0 {
+		return errMissingDeps
+	}
+	return nil
+}
+
+// missingDep is a deps log entry on generated file without
+// dependency path to its generator in the build graph.
+type missingDep struct {
+	target string
+	dep    string
+	rule   string
+}
+
+// missingDepsScanner finds missing deps.
+// https://github.com/ninja-build/ninja/blob/a524bf3f6bacd1b4ad85d719eed2737d8562f27a/src/missing_deps.cc
+type missingDepsScanner struct {
+	state    *ninjautil.State
+	seen     map[*ninjautil.Edge]bool
+	nodes    int
+	missings []missingDep
+
+	// adjacency memoizes pathExists results.
+	// generator edge -> edge -> path exists.
+	adjacency map[*ninjautil.Edge]map[*ninjautil.Edge]bool
+}
+
+// check checks deps log entries of node and its transitive inputs.
+func (s *missingDepsScanner) check(ctx context.Context, depsLog *ninjautil.DepsLog, node *ninjautil.Node) error {
+	nodes := []*ninjautil.Node{node}
+	for len(nodes) > 0 {
+		node := nodes[len(nodes)-1]
+		nodes = nodes[:len(nodes)-1]
+		edge, ok := node.InEdge()
+		if !ok || s.seen[edge] {
+			continue
+		}
+		s.seen[edge] = true
+		nodes = append(nodes, edge.Inputs()...)
+		for _, out := range edge.Outputs() {
+			err := s.checkDeps(ctx, depsLog, edge, out)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkDeps checks deps log entries of node generated by edge.
+func (s *missingDepsScanner) checkDeps(ctx context.Context, depsLog *ninjautil.DepsLog, edge *ninjautil.Edge, node *ninjautil.Node) error {
+	deps, _, err := depsLog.RetrievePaths(ctx, node.Path())
+	if errors.Is(err, ninjautil.ErrNoDepsLog) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("deps log for %s: %w", node.Path(), err)
+	}
+	s.nodes++
+	for _, dep := range deps {
+		depNode, ok := s.state.LookupNodeByPath(dep)
+		if !ok {
+			continue
+		}
+		gen, ok := depNode.InEdge()
+		if !ok || gen == edge {
+			continue
+		}
+		if s.pathExists(gen, edge) {
+			continue
+		}
+		s.missings = append(s.missings, missingDep{
+			target: node.Path(),
+			dep:    dep,
+			rule:   gen.RuleName(),
+		})
+	}
+	return nil
+}
+
+// pathExists reports whether edge to depends on edge from through
+// its inputs, including implicit and order-only inputs.
+// Results are memoized, so it is linear in the number of edges
+// for each generator edge.
+func (s *missingDepsScanner) pathExists(from, to *ninjautil.Edge) bool {
+	m, ok := s.adjacency[from]
+	if !ok {
+		m = make(map[*ninjautil.Edge]bool)
+		s.adjacency[from] = m
+	}
+	if found, ok := m[to]; ok {
+		return found
+	}
+	// mark as not found while checking, to stop at cycle.
+	m[to] = false
+	found := false
+	for _, in := range to.Inputs() {
+		e, ok := in.InEdge()
+		if ok && (e == from || s.pathExists(from, e)) {
+			found = true
+			break
+		}
+	}
+	m[to] = found
+	return found
+}
+
+// report writes found missing deps to w.
+func (s *missingDepsScanner) report(w io.Writer) {
+	slices.SortFunc(s.missings, func(a, b missingDep) int {
+		if c := strings.Compare(a.target, b.target); c != 0 {
+			return c
+		}
+		return strings.Compare(a.dep, b.dep)
+	})
+	targets := make(map[string]bool)
+	deps := make(map[string]bool)
+	rules := make(map[string]bool)
+	for _, m := range s.missings {
+		fmt.Fprintf(w, "Missing dep: %s uses %s (generated by %s)\n", m.target, m.dep, m.rule)
+		targets[m.target] = true
+		deps[m.dep] = true
+		rules[m.rule] = true
+	}
+	fmt.Fprintf(w, "Processed %d nodes.\n", s.nodes)
+	if len(s.missings) == 0 {
+		fmt.Fprintf(w, "No missing dependencies on generated files found.\n")
+		return
+	}
+	ruleNames := make([]string, 0, len(rules))
+	for r := range rules {
+		ruleNames = append(ruleNames, r)
+	}
+	sort.Strings(ruleNames)
+	fmt.Fprintf(w, "Error: There are %d missing dependency paths.\n", len(s.missings))
+	fmt.Fprintf(w, "%d targets had depfile dependencies on %d distinct generated inputs (from %d rules) without a non-depfile dep path to the generator.\n", len(targets), len(deps), len(rules))
+	fmt.Fprintf(w, "Missing dep generator rules: %s\n", strings.Join(ruleNames, " "))
+	fmt.Fprintf(w, "There might be build flakiness if any of the targets listed above are built alone, or not late enough, in a clean output directory.\n")
+}
@@ -0,0 +1,126 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package query
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/build/siso/toolsupport/ninjautil"
+)
+
+func TestMissingDeps(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	err := os.WriteFile("build.ninja", []byte(`
+rule cxx
+  command = cxx ${in} ${out}
+  deps = gcc
+  depfile = ${out}.d
+rule gen
+  command = gen ${out}
+
+build gen/ok.h: gen
+build gen/order.h: gen
+build gen/missing.h: gen
+build gen/indirect.h: gen
+build gen/stamp: gen || gen/indirect.h
+
+build ok.o: cxx ok.cc | gen/ok.h
+build order.o: cxx order.cc || gen/order.h
+build indirect.o: cxx indirect.cc || gen/stamp
+build missing.o: cxx missing.cc
+build all: phony ok.o order.o indirect.o missing.o
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depsLog, err := ninjautil.NewDepsLog(ctx, ".siso_deps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Unix(1, 0)
+	for out, deps := range map[string][]string{
+		"ok.o":       {"ok.cc", "gen/ok.h"},
+		"order.o":    {"order.cc", "gen/order.h"},
+		"indirect.o": {"indirect.cc", "gen/indirect.h"},
+		"missing.o":  {"missing.cc", "gen/missing.h", "gen/ok.h"},
+	} {
+		_, err := depsLog.Record(ctx, out, mtime, deps)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = depsLog.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "all",
+			want: `Missing dep: missing.o uses gen/missing.h (generated by gen)
+Missing dep: missing.o uses gen/ok.h (generated by gen)
+Processed 4 nodes.
+Error: There are 2 missing dependency paths.
+1 targets had depfile dependencies on 2 distinct generated inputs (from 1 rules) without a non-depfile dep path to the generator.
+Missing dep generator rules: gen
+There might be build flakiness if any of the targets listed above are built alone, or not late enough, in a clean output directory.
+`,
+			wantErr: errMissingDeps,
+		},
+		{
+			name: "transitive",
+			args: []string{"all"},
+			want: `Missing dep: missing.o uses gen/missing.h (generated by gen)
+Missing dep: missing.o uses gen/ok.h (generated by gen)
+Processed 4 nodes.
+Error: There are 2 missing dependency paths.
+1 targets had depfile dependencies on 2 distinct generated inputs (from 1 rules) without a non-depfile dep path to the generator.
+Missing dep generator rules: gen
+There might be build flakiness if any of the targets listed above are built alone, or not late enough, in a clean output directory.
+`,
+			wantErr: errMissingDeps,
+		},
+		{
+			name: "targets",
+			args: []string{"ok.o", "indirect.o"},
+			want: `Processed 2 nodes.
+No missing dependencies on generated files found.
+`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			c := &missingdepsCommand{w: &buf}
+			flagSet := flag.NewFlagSet("missingdeps", flag.ContinueOnError)
+			c.SetFlags(flagSet)
+			err := flagSet.Parse(tc.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.run(ctx, flagSet.Args())
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("run=%v; want %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, buf.String()); diff != "" {
+				t.Errorf("query missingdeps diff -want +got:\n%s", diff)
+			}
+		})
+	}
+}
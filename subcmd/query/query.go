@@ -45,6 +45,7 @@ func (c Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) s
 	commander.Register(&digraphCommand{}, "advanced")
 	commander.Register(&ideAnalysisCommand{}, "advanced")
 	commander.Register(&inputsCommand{}, "")
+	commander.Register(&missingdepsCommand{}, "")
 	commander.Register(&ruleCommand{}, "")
 	commander.Register(&targetsCommand{}, "")
 	commander.Register(commander.HelpCommand(), "command-help")
@@ -0,0 +1,247 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package recall
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	log "github.com/golang/glog"
+
+	"go.chromium.org/build/siso/reapi"
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+const (
+	// outputsFile lists digests of outputs in the cached action result.
+	outputsFile = "outputs.txt"
+
+	// resultDir is a directory to store contents of the cached
+	// outputs for text diff.
+	resultDir = "result"
+
+	// maxTextDiffSize is the max size of outputs to show text diff.
+	maxTextDiffSize = 64 * 1024
+)
+
+// outputEntry is an output file in the cached action result.
+type outputEntry struct {
+	path string
+	d    digest.Digest
+}
+
+// fetchResultOutputs writes digests of all output files in the action
+// result into outputs.txt, and fetches small outputs into result/
+// so text diff is available without REAPI access later.
+func fetchResultOutputs(ctx context.Context, client *reapi.Client, result *rpb.ActionResult) error {
+	var outputs []outputEntry
+	for _, f := range result.GetOutputFiles() {
+		outputs = append(outputs, outputEntry{
+			path: f.GetPath(),
+			d:    digest.FromProto(f.GetDigest()),
+		})
+	}
+	for _, d := range result.GetOutputDirectories() {
+		tree := &rpb.Tree{}
+		err := client.Proto(ctx, digest.FromProto(d.GetTreeDigest()), tree)
+		if err != nil {
+			return fmt.Errorf("failed to get tree for %s: %w", d.GetPath(), err)
+		}
+		outputs = append(outputs, treeOutputs(d.GetPath(), tree)...)
+	}
+	var buf bytes.Buffer
+	for _, o := range outputs {
+		fmt.Fprintf(&buf, "%s %s\n", o.d, o.path)
+		if o.d.SizeBytes > maxTextDiffSize {
+			continue
+		}
+		b, err := client.Get(ctx, o.d, o.path)
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %w", o.path, err)
+		}
+		fname := filepath.Join(resultDir, filepath.FromSlash(o.path))
+		err = os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fname, b, 0644)
+		if err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(outputsFile, buf.Bytes(), 0644)
+}
+
+// treeOutputs returns output files in the tree under dir.
+func treeOutputs(dir string, tree *rpb.Tree) []outputEntry {
+	children := make(map[digest.Digest]*rpb.Directory)
+	for _, c := range tree.GetChildren() {
+		data, err := digest.FromProtoMessage(c)
+		if err != nil {
+			log.Warningf("failed to compute digest of %s: %v", c, err)
+			continue
+		}
+		children[data.Digest()] = c
+	}
+	var outputs []outputEntry
+	var walk func(dir string, d *rpb.Directory)
+	walk = func(dir string, d *rpb.Directory) {
+		for _, f := range d.GetFiles() {
+			outputs = append(outputs, outputEntry{
+				path: path.Join(dir, f.GetName()),
+				d:    digest.FromProto(f.GetDigest()),
+			})
+		}
+		for _, sd := range d.GetDirectories() {
+			c, ok := children[digest.FromProto(sd.GetDigest())]
+			if !ok {
+				log.Warningf("missing child %s in tree %s", sd.GetName(), dir)
+				continue
+			}
+			walk(path.Join(dir, sd.GetName()), c)
+		}
+	}
+	walk(dir, tree.GetRoot())
+	return outputs
+}
+
+// loadOutputs loads output entries from outputs.txt.
+func loadOutputs(fname string) ([]outputEntry, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var outputs []outputEntry
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		ds, p, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			return nil, fmt.Errorf("wrong format in %s: %q", fname, s.Text())
+		}
+		d, err := digest.Parse(ds)
+		if err != nil {
+			return nil, fmt.Errorf("wrong digest in %s: %w", fname, err)
+		}
+		outputs = append(outputs, outputEntry{path: p, d: d})
+	}
+	return outputs, s.Err()
+}
+
+// diffOutputs compares outputs of the command run in wd with
+// the cached action result, and reports differences to w.
+// It returns the number of differing outputs.
+func diffOutputs(wd string, result *rpb.ActionResult, outputs []outputEntry, exitCode int, w io.Writer) int {
+	ndiff := 0
+	if exitCode != int(result.GetExitCode()) {
+		fmt.Fprintf(w, "exit: local=%d cached=%d\n", exitCode, result.GetExitCode())
+		ndiff++
+	}
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].path < outputs[j].path
+	})
+	for _, o := range outputs {
+		fname := filepath.Join(wd, filepath.FromSlash(o.path))
+		b, err := os.ReadFile(fname)
+		if err != nil {
+			fmt.Fprintf(w, "missing %s: %v\n", o.path, err)
+			ndiff++
+			continue
+		}
+		d := digest.FromBytes(o.path, b).Digest()
+		if d == o.d {
+			fmt.Fprintf(w, "same    %s %s\n", o.path, d)
+			continue
+		}
+		ndiff++
+		fmt.Fprintf(w, "differ  %s local=%s cached=%s\n", o.path, d, o.d)
+		cached, err := os.ReadFile(filepath.Join(resultDir, filepath.FromSlash(o.path)))
+		if err != nil {
+			continue
+		}
+		if !isText(b) || !isText(cached) {
+			continue
+		}
+		for _, line := range lineDiff(string(cached), string(b)) {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	return ndiff
+}
+
+// isText reports whether b is small text content.
+func isText(b []byte) bool {
+	return len(b) <= maxTextDiffSize && utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+// maxLineDiffCells is the max size of the LCS table in lineDiff,
+// i.e. the number of differing lines in a times those in b.
+const maxLineDiffCells = 1 << 20
+
+// lineDiff returns lines that differ between a and b, prefixed with
+// "-" for lines only in a and "+" for lines only in b.
+// If the differing region is too large, it returns a single line
+// that reports the number of differing lines instead.
+func lineDiff(a, b string) []string {
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+	// common prefix and suffix don't need the LCS table.
+	for len(al) > 0 && len(bl) > 0 && al[0] == bl[0] {
+		al, bl = al[1:], bl[1:]
+	}
+	for len(al) > 0 && len(bl) > 0 && al[len(al)-1] == bl[len(bl)-1] {
+		al, bl = al[:len(al)-1], bl[:len(bl)-1]
+	}
+	if len(al)*len(bl) > maxLineDiffCells {
+		return []string{fmt.Sprintf("too many lines to diff: -%d +%d", len(al), len(bl))}
+	}
+	// lcs[i][j] is the length of the longest common subsequence
+	// of al[i:] and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+				continue
+			}
+			lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+		}
+	}
+	var diffs []string
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diffs = append(diffs, "-"+al[i])
+			i++
+		default:
+			diffs = append(diffs, "+"+bl[j])
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		diffs = append(diffs, "-"+al[i])
+	}
+	for ; j < len(bl); j++ {
+		diffs = append(diffs, "+"+bl[j])
+	}
+	return diffs
+}
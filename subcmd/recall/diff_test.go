@@ -0,0 +1,171 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package recall
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	rpb "github.com/bazelbuild/remote-apis/build/bazel/remote/execution/v2"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/encoding/prototext"
+
+	"go.chromium.org/build/siso/reapi/digest"
+)
+
+func TestLineDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want []string
+	}{
+		{
+			name: "same",
+			a:    "foo\nbar\n",
+			b:    "foo\nbar\n",
+		},
+		{
+			name: "changed",
+			a:    "foo\nbar\nbaz\n",
+			b:    "foo\nqux\nbaz\n",
+			want: []string{"-bar", "+qux"},
+		},
+		{
+			name: "appended",
+			a:    "foo\n",
+			b:    "foo\nbar\n",
+			want: []string{"+bar"},
+		},
+		{
+			name: "too_large",
+			a:    "foo\n" + strings.Repeat("a\n", 2000) + "bar\n",
+			b:    "foo\n" + strings.Repeat("b\n", 1000) + "bar\n",
+			want: []string{"too many lines to diff: -2000 +1000"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := lineDiff(tc.a, tc.b)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("lineDiff(%q, %q) diff -want +got:\n%s", tc.a, tc.b, diff)
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, fname, content string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(fname), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(fname, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiffOutputs(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	wd := filepath.Join(dir, "root", "out")
+	writeFile(t, filepath.Join(wd, "same.txt"), "same\n")
+	writeFile(t, filepath.Join(wd, "differ.txt"), "foo\nlocal\n")
+	writeFile(t, filepath.Join(resultDir, "differ.txt"), "foo\ncached\n")
+	outputs := []outputEntry{
+		{path: "same.txt", d: digest.FromBytes("same.txt", []byte("same\n")).Digest()},
+		{path: "missing.txt", d: digest.FromBytes("missing.txt", []byte("missing\n")).Digest()},
+		{path: "differ.txt", d: digest.FromBytes("differ.txt", []byte("foo\ncached\n")).Digest()},
+	}
+	result := &rpb.ActionResult{ExitCode: 1}
+
+	var sb strings.Builder
+	got := diffOutputs(wd, result, outputs, 0, &sb)
+	if got != 3 {
+		t.Errorf("diffOutputs=%d; want 3\n%s", got, sb.String())
+	}
+	out := sb.String()
+	for _, want := range []string{
+		"exit: local=0 cached=1\n",
+		"differ  differ.txt ",
+		"  -cached\n  +local\n",
+		"missing missing.txt: ",
+		"same    same.txt ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diffOutputs output=%q; want to contain %q", out, want)
+		}
+	}
+}
+
+func TestCheckLocalResult(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	wd := filepath.Join(dir, "root", "out")
+	content := []byte("output\n")
+	d := digest.FromBytes("gen/out.txt", content).Digest()
+	writeFile(t, filepath.Join(wd, "gen", "out.txt"), string(content))
+
+	runErr := errors.New("run error")
+	err := checkLocalResult(wd, runErr)
+	if err != runErr {
+		t.Errorf("checkLocalResult without result.txt=%v; want %v", err, runErr)
+	}
+
+	result := &rpb.ActionResult{
+		OutputFiles: []*rpb.OutputFile{
+			{Path: "gen/out.txt", Digest: d.Proto()},
+		},
+	}
+	b, err := prototext.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, "result.txt", string(b))
+	err = checkLocalResult(wd, nil)
+	if err != nil {
+		t.Errorf("checkLocalResult with same outputs=%v; want nil", err)
+	}
+
+	exitErr := exec.Command("sh", "-c", "exit 2").Run()
+	err = checkLocalResult(wd, exitErr)
+	if err == nil || err == exitErr {
+		t.Errorf("checkLocalResult with exit code=%v; want diff error", err)
+	}
+
+	// outputs.txt takes precedence over output files in result.txt.
+	writeFile(t, outputsFile, fmt.Sprintf("%s gen/other.txt\n", d))
+	err = checkLocalResult(wd, nil)
+	if err == nil {
+		t.Errorf("checkLocalResult with missing output=nil; want diff error")
+	}
+}
+
+func TestRemoveOutputs(t *testing.T) {
+	wd := t.TempDir()
+	writeFile(t, filepath.Join(wd, "gen", "out.txt"), "output")
+	writeFile(t, filepath.Join(wd, "gen", "dir", "file"), "output")
+	writeFile(t, filepath.Join(wd, "gen", "input.txt"), "input")
+	err := removeOutputs(wd, &rpb.Command{
+		OutputPaths: []string{"gen/out.txt", "gen/dir", "gen/nonexistent"},
+	})
+	if err != nil {
+		t.Fatalf("removeOutputs=%v; want nil", err)
+	}
+	for _, fname := range []string{"gen/out.txt", "gen/dir"} {
+		_, err := os.Stat(filepath.Join(wd, fname))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Stat(%q)=%v; want not exist", fname, err)
+		}
+	}
+	_, err = os.Stat(filepath.Join(wd, "gen", "input.txt"))
+	if err != nil {
+		t.Errorf("Stat(gen/input.txt)=%v; want nil", err)
+	}
+}
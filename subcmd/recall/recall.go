@@ -11,6 +11,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -63,6 +64,15 @@ You can omit <dir> if it is current directory ".".
  - To use local docker to run the command.
 
  $ siso recall [-local] <dir>
+
+ - To run the command directly on the host in root/.
+
+ $ siso recall -local -nodocker <dir>
+
+When the action result is cached, "result.txt" and "outputs.txt" are
+stored in <dir> and small outputs are fetched into "result/".
+After running locally, outputs in root/ are compared with the cached
+result by digest, and text diffs are shown for small text outputs.
 `
 
 // Cmd returns the Command for the `recall` subcommand provided by this package.
@@ -96,6 +106,7 @@ type Command struct {
 	memLimit          string
 	hddMode           bool
 	local             bool
+	noDocker          bool
 	stats             bool
 }
 
@@ -106,6 +117,7 @@ func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 	flagSet.StringVar(&c.memLimit, "memory", "", "the maximum amount of memory the action can use (e.g. 512m or 2g)")
 	flagSet.BoolVar(&c.hddMode, "hdd", false, "run the action with slowed down I/O that resembles a hard-disk with 12MB/s throughput, 75 read IOPS and 150 write IOPS")
 	flagSet.BoolVar(&c.local, "local", false, "force running the action locally using Docker, even if REAPI is configured")
+	flagSet.BoolVar(&c.noDocker, "nodocker", false, "with -local, run the command directly on the host in root/ instead of using Docker")
 	flagSet.BoolVar(&c.stats, "stats", false, "run the command under /usr/bin/time and print detailed resource stats after execution (note: this may fail if the container glibc is incompatible with the host)")
 	c.reopt = new(reapi.Option)
 	c.reopt.RegisterFlags(flagSet, reapi.Envs("REAPI"))
@@ -134,11 +146,13 @@ func (c *Command) run(ctx context.Context) error {
 
 	c.reopt.UpdateProjectID(c.projectID)
 	err := c.reopt.CheckValid()
-	if err != nil {
+	// local run of the recalled action doesn't need REAPI.
+	localOnly := c.local && c.Flags.NArg() <= 1
+	if err != nil && !localOnly {
 		return fmt.Errorf("reapi option is invalid: %w", err)
 	}
 	var credential cred.Cred
-	if c.reopt.NeedCred() {
+	if c.reopt.NeedCred() && !localOnly {
 		credential, err = cred.New(ctx, c.reopt.ServiceURI(), c.authOpts)
 		if err != nil {
 			return err
@@ -214,6 +228,10 @@ func (c *Command) run(ctx context.Context) error {
 		}
 		fmt.Printf("cache=true\n")
 		printActionResult(result)
+		err = fetchResultOutputs(ctx, client, result)
+		if err != nil {
+			return err
+		}
 
 	case codes.NotFound:
 	default:
@@ -415,25 +433,17 @@ func (c *Command) callLocal(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	_, err = os.Stat("passwd")
-	if err != nil {
-		// generate passwd file that contains user's passwd entry
-		// otherwise, docker run will fail because it can't find
-		// user in /etc/passwd.
-		err = genPasswd(ctx, "passwd")
-		if err != nil {
-			return err
-		}
-	}
-	passwd, err := filepath.Abs("passwd")
+	root, err := filepath.Abs("root")
 	if err != nil {
 		return err
 	}
-	root, err := filepath.Abs("root")
+	wd := filepath.Join(root, command.WorkingDirectory)
+	// remove outputs left by previous runs, so diff against
+	// the cached result won't see stale outputs.
+	err = removeOutputs(wd, command)
 	if err != nil {
 		return err
 	}
-	wd := filepath.Join(root, command.WorkingDirectory)
 	for _, output := range command.OutputFiles {
 		odir := filepath.Join(wd, filepath.Dir(output))
 		err = os.MkdirAll(odir, 0755)
@@ -446,7 +456,27 @@ func (c *Command) callLocal(ctx context.Context) error {
 			return err
 		}
 	}
+	if len(command.Arguments) == 0 {
+		return fmt.Errorf("no arguments in command.txt")
+	}
 	p := command.Platform
+	if c.noDocker || platformProperty(p, "container-image") == "" {
+		return c.callHost(command, wd)
+	}
+	_, err = os.Stat("passwd")
+	if err != nil {
+		// generate passwd file that contains user's passwd entry
+		// otherwise, docker run will fail because it can't find
+		// user in /etc/passwd.
+		err = genPasswd(ctx, "passwd")
+		if err != nil {
+			return err
+		}
+	}
+	passwd, err := filepath.Abs("passwd")
+	if err != nil {
+		return err
+	}
 	inputRootDir := "/mnt"
 	if v := platformProperty(p, "InputRootAbsolutePath"); v != "" {
 		inputRootDir = v
@@ -521,7 +551,83 @@ func (c *Command) callLocal(ctx context.Context) error {
 	cmd := exec.CommandContext(ctx, cmdline[0], cmdline[1:]...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	return checkLocalResult(wd, cmd.Run())
+}
+
+// removeOutputs removes outputs declared in command under wd.
+func removeOutputs(wd string, command *rpb.Command) error {
+	var outputs []string
+	outputs = append(outputs, command.OutputFiles...)
+	outputs = append(outputs, command.OutputDirectories...)
+	outputs = append(outputs, command.OutputPaths...)
+	for _, output := range outputs {
+		err := os.RemoveAll(filepath.Join(wd, filepath.FromSlash(output)))
+		if err != nil {
+			return fmt.Errorf("failed to remove output %s: %w", output, err)
+		}
+	}
+	return nil
+}
+
+// callHost runs the command directly on the host in root/ with
+// the command's environment variables and working directory.
+func (c *Command) callHost(command *rpb.Command, wd string) error {
+	cmd := exec.Command(command.Arguments[0], command.Arguments[1:]...)
+	cmd.Dir = wd
+	cmd.Env = []string{}
+	hasPath := false
+	for _, e := range command.EnvironmentVariables {
+		if e.Name == "PATH" {
+			hasPath = true
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", e.Name, e.Value))
+	}
+	if !hasPath {
+		cmd.Env = append(cmd.Env, "PATH="+os.Getenv("PATH"))
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	fmt.Printf("run in %s: %q\n", wd, command.Arguments)
+	return checkLocalResult(wd, cmd.Run())
+}
+
+// checkLocalResult compares outputs of the local run in wd with
+// the cached action result, if result.txt exists.
+func checkLocalResult(wd string, runErr error) error {
+	result := &rpb.ActionResult{}
+	err := loadTextProto("result.txt", result)
+	if errors.Is(err, fs.ErrNotExist) {
+		return runErr
+	}
+	if err != nil {
+		return err
+	}
+	exitCode := 0
+	var eerr *exec.ExitError
+	switch {
+	case errors.As(runErr, &eerr):
+		exitCode = eerr.ExitCode()
+	case runErr != nil:
+		return runErr
+	}
+	outputs, err := loadOutputs(outputsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		for _, f := range result.GetOutputFiles() {
+			outputs = append(outputs, outputEntry{
+				path: f.GetPath(),
+				d:    digest.FromProto(f.GetDigest()),
+			})
+		}
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("diff against cached result:\n")
+	ndiff := diffOutputs(wd, result, outputs, exitCode, os.Stdout)
+	if ndiff > 0 {
+		return fmt.Errorf("differs from cached result: %d diffs", ndiff)
+	}
+	fmt.Printf("no differences from cached result\n")
+	return runErr
 }
 
 //go:embed rusage.go.in
@@ -583,7 +689,7 @@ func uidInPasswd(fname string) (string, error) {
 }
 
 func platformProperty(p *rpb.Platform, key string) string {
-	for _, pp := range p.Properties {
+	for _, pp := range p.GetProperties() {
 		if pp.Name == key {
 			return pp.Value
 		}
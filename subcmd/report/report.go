@@ -9,17 +9,20 @@ import (
 	"archive/tar"
 	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/google/subcommands"
 
+	"go.chromium.org/build/siso/hashfs"
 	"go.chromium.org/build/siso/hashfs/osfs"
 	"go.chromium.org/build/siso/o11y/clog"
 	"go.chromium.org/build/siso/reapi/digest"
@@ -30,9 +33,26 @@ import (
 const usage = `report siso logs
 Collect siso logs in <dir>.
 
- $ siso report -C <dir>
+ $ siso report -C <dir> [-scrub [-scrub_rules <rules.json>]]
+
+With -scrub, it redacts home dirs, usernames, hostnames, environment
+variable values and auth-looking tokens consistently across files,
+exports .siso_fs_state as json to scrub it, and excludes binary files.
+It also writes ` + scrubManifestFile + ` that lists what was redacted,
+without original values.
+
+<rules.json> configures additional redaction rules:
+ {
+   "literals": {"<string>": "<replacement>", ...},
+   "patterns": [{"name": "<name>", "regexp": "<regexp>"}, ...],
+   "host_suffixes": [".corp.example.com", ...],
+   "keep_env": ["<env name>", ...]
+ }
 `
 
+// scrubManifestFile is a manifest file of redactions in scrubbed report.
+const scrubManifestFile = "siso_report_scrub_manifest.json"
+
 // Cmd returns the Command for the `report` subcommand provided by this package.
 func Cmd() *Command {
 	return &Command{}
@@ -52,13 +72,17 @@ func (*Command) Usage() string {
 
 // Command implements report subcommand.
 type Command struct {
-	dir     string
-	osfsopt osfs.Option
+	dir        string
+	osfsopt    osfs.Option
+	scrub      bool
+	scrubRules string
 }
 
 func (c *Command) SetFlags(flagSet *flag.FlagSet) {
 	flagSet.StringVar(&c.dir, "C", ".", "ninja running directory")
 	c.osfsopt.RegisterFlags(flagSet)
+	flagSet.BoolVar(&c.scrub, "scrub", false, "redact private information to share the report publicly")
+	flagSet.StringVar(&c.scrubRules, "scrub_rules", "", "json file of additional redaction rules for -scrub")
 }
 
 func (c *Command) Execute(ctx context.Context, flagSet *flag.FlagSet, _ ...any) subcommands.ExitStatus {
@@ -80,13 +104,28 @@ func (c *Command) run(ctx context.Context) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer signals.HandleInterrupt(ctx, cancel)()
 
+	if c.scrubRules != "" && !c.scrub {
+		return fmt.Errorf("-scrub_rules requires -scrub: %w", flag.ErrHelp)
+	}
+	var sc *scrubber
+	if c.scrub {
+		// load rules before chdir, as -scrub_rules is relative to cwd.
+		rules, err := loadScrubRules(c.scrubRules)
+		if err != nil {
+			return err
+		}
+		sc, err = newScrubber(rules)
+		if err != nil {
+			return err
+		}
+	}
 	clog.Infof(ctx, "dir %s", c.dir)
 	err := os.Chdir(c.dir)
 	if err != nil {
 		return err
 	}
 	// TODO: upload report to make it easy to share.
-	return c.archive(ctx)
+	return c.archive(ctx, sc)
 }
 
 func (c *Command) collect(ctx context.Context) (map[string]digest.Data, error) {
@@ -181,12 +220,17 @@ func collectInDir(ctx context.Context, fsys fs.FS, osfs *osfs.OSFS, dname string
 	})
 }
 
-func (c *Command) archive(ctx context.Context) (err error) {
+// archive archives report files. If sc is not nil, files are scrubbed.
+func (c *Command) archive(ctx context.Context, sc *scrubber) (err error) {
 	report, err := c.collect(ctx)
 	if err != nil {
 		return err
 	}
-	f, err := os.CreateTemp("", "siso-report-*.tgz")
+	pattern := "siso-report-*.tgz"
+	if sc != nil {
+		pattern = "siso-report-scrubbed-*.tgz"
+	}
+	f, err := os.CreateTemp("", pattern)
 	if err != nil {
 		return err
 	}
@@ -217,26 +261,78 @@ func (c *Command) archive(ctx context.Context) (err error) {
 	}
 	sort.Strings(fnames)
 	now := time.Now()
+	var scrubbed []scrubFile
+	var excluded []scrubExcluded
 	for _, fname := range fnames {
 		ui.Default.PrintLines(fmt.Sprintf("packing %s", fname))
 		buf, err := digest.DataToBytes(ctx, report[fname])
 		if err != nil {
 			return fmt.Errorf("failed to get bytes for %s: %w", fname, err)
 		}
-		err = tw.WriteHeader(&tar.Header{
-			Name:    fname,
-			Size:    int64(len(buf)),
-			Mode:    0644,
-			ModTime: now,
-		})
+		name := fname
+		if sc != nil {
+			var source string
+			if filepath.Base(fname) == ".siso_fs_state" {
+				buf, err = exportFSState(ctx, fname)
+				if err != nil {
+					clog.Warningf(ctx, "failed to export %s: %v", fname, err)
+					excluded = append(excluded, scrubExcluded{Name: fname, Reason: "failed to export"})
+					continue
+				}
+				source = fname
+				name = fname + ".json"
+			} else if isBinary(buf) {
+				clog.Infof(ctx, "exclude binary %s", fname)
+				excluded = append(excluded, scrubExcluded{Name: fname, Reason: "binary"})
+				continue
+			}
+			var n int
+			buf, n = sc.scrub(name, buf)
+			nameBuf, _ := sc.scrub(name, []byte(name))
+			name = string(nameBuf)
+			scrubbed = append(scrubbed, scrubFile{Name: name, Source: source, Redactions: n})
+		}
+		err = writeTarEntry(tw, name, buf, now)
 		if err != nil {
-			return fmt.Errorf("failed to write header for %s: %w", fname, err)
+			return err
 		}
-		_, err = tw.Write(buf)
+	}
+	if sc != nil {
+		buf, err := sc.manifest(scrubbed, excluded)
 		if err != nil {
-			return fmt.Errorf("failed to write data of %s: %w", fname, err)
+			return fmt.Errorf("failed to create scrub manifest: %w", err)
+		}
+		err = writeTarEntry(tw, scrubManifestFile, buf, now)
+		if err != nil {
+			return err
 		}
 	}
 	ui.Default.PrintLines(fmt.Sprintf("report file: %s\n\n", f.Name()))
 	return tw.Flush()
 }
+
+func writeTarEntry(tw *tar.Writer, fname string, buf []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    fname,
+		Size:    int64(len(buf)),
+		Mode:    0644,
+		ModTime: modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write header for %s: %w", fname, err)
+	}
+	_, err = tw.Write(buf)
+	if err != nil {
+		return fmt.Errorf("failed to write data of %s: %w", fname, err)
+	}
+	return nil
+}
+
+// exportFSState exports fs state in json, same as `siso fs export`.
+func exportFSState(ctx context.Context, fname string) ([]byte, error) {
+	st, err := hashfs.Load(ctx, hashfs.Option{StateFile: fname})
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(st, "", " ")
+}
@@ -0,0 +1,388 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScrubRules is redaction rules to scrub report files.
+type ScrubRules struct {
+	// Literals maps literal strings to redact to their replacements.
+	Literals map[string]string `json:"literals,omitempty"`
+
+	// Patterns are regexps to redact, in addition to the default
+	// patterns for auth-looking tokens.
+	Patterns []ScrubPattern `json:"patterns,omitempty"`
+
+	// HostSuffixes are domain suffixes of internal hostnames,
+	// e.g. ".corp.example.com".
+	HostSuffixes []string `json:"host_suffixes,omitempty"`
+
+	// KeepEnv are names of environment variables whose values
+	// are kept as is, in addition to the default.
+	KeepEnv []string `json:"keep_env,omitempty"`
+}
+
+// ScrubPattern is a regexp to redact.
+// Each distinct match is replaced with "<name-N>", consistently
+// across files.
+// If the regexp has a capturing group, only the first group is
+// redacted, e.g. to keep "token=" prefix.
+type ScrubPattern struct {
+	Name   string `json:"name"`
+	Regexp string `json:"regexp"`
+}
+
+// defaultScrubPatterns are patterns of auth-looking tokens.
+var defaultScrubPatterns = []ScrubPattern{
+	{Name: "oauth-token", Regexp: `ya29\.[0-9A-Za-z_\-]{20,}`},
+	{Name: "jwt", Regexp: `eyJ[0-9A-Za-z_\-]+\.[0-9A-Za-z_\-]+\.[0-9A-Za-z_\-]+`},
+	{Name: "github-token", Regexp: `gh[pousr]_[0-9A-Za-z]{20,}`},
+	{Name: "aws-key", Regexp: `AKIA[0-9A-Z]{16}`},
+	{Name: "bearer", Regexp: `(?i)bearer\s+([0-9A-Za-z._~+/\-]{8,}=*)`},
+	{Name: "secret", Regexp: `(?i)(?:token|secret|password|passwd|api_?key|authorization)["']?\s*[:=]\s*["']?([^\s"',;&<]{8,})`},
+}
+
+// defaultKeepEnv are names of environment variables whose values are
+// not sensitive, or are covered by other rules (e.g. paths by home dir).
+var defaultKeepEnv = []string{
+	"HOME", "USER", "USERNAME", "LOGNAME", "HOSTNAME",
+	"PATH", "PWD", "OLDPWD", "SHELL", "SHLVL", "TERM", "COLORTERM",
+	"LANG", "LANGUAGE", "TMPDIR", "TEMP", "TMP", "_",
+}
+
+// minEnvValueLen is minimum length of environment variable values
+// to redact, to avoid redacting common short words.
+const minEnvValueLen = 6
+
+// scrubRule is a compiled redaction rule.
+type scrubRule struct {
+	// kind is pattern name, used for placeholder "<kind-N>".
+	kind string
+	re   *regexp.Regexp
+	// literals maps matched string to literal, for literal rule.
+	literals map[string]literal
+}
+
+// scrubber redacts sensitive strings consistently across files.
+type scrubber struct {
+	rules []scrubRule
+
+	mu sync.Mutex
+	// placeholders of distinct matches of pattern rules.
+	placeholders map[string]string
+	nextID       map[string]int
+	// counts, kinds and files per placeholder.
+	counts map[string]int
+	kinds  map[string]string
+	files  map[string]map[string]bool
+}
+
+// literal is a literal to redact.
+type literal struct {
+	kind, value, replacement string
+}
+
+// newScrubber creates scrubber with rules, and the default rules for
+// the current user, host and environment.
+func newScrubber(rules ScrubRules) (*scrubber, error) {
+	s := &scrubber{
+		placeholders: make(map[string]string),
+		nextID:       make(map[string]int),
+		counts:       make(map[string]int),
+		kinds:        make(map[string]string),
+		files:        make(map[string]map[string]bool),
+	}
+	for _, p := range append(slices.Clone(defaultScrubPatterns), rules.Patterns...) {
+		re, err := regexp.Compile(p.Regexp)
+		if err != nil {
+			return nil, fmt.Errorf("bad scrub pattern %s %q: %w", p.Name, p.Regexp, err)
+		}
+		s.rules = append(s.rules, scrubRule{kind: p.Name, re: re})
+	}
+
+	var literals []literal
+	for v, r := range rules.Literals {
+		literals = append(literals, literal{kind: "literal", value: v, replacement: r})
+	}
+	literals = append(literals, envLiterals(rules.KeepEnv)...)
+	if home, err := os.UserHomeDir(); err == nil && len(home) > 1 {
+		literals = append(literals, literal{kind: "home", value: home, replacement: "<home>"})
+	}
+	if u, err := user.Current(); err == nil {
+		name := u.Username
+		// DOMAIN\user on windows.
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			literals = append(literals, literal{kind: "user", value: name, replacement: "<user>"})
+			name = name[i+1:]
+		}
+		// too short name would redact unrelated words.
+		if len(name) >= 3 {
+			literals = append(literals, literal{kind: "user", value: name, replacement: "<user>"})
+		}
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		literals = append(literals, literal{kind: "host", value: hostname, replacement: "<host>"})
+		if short, _, ok := strings.Cut(hostname, "."); ok {
+			literals = append(literals, literal{kind: "host", value: short, replacement: "<host>"})
+		}
+	}
+	if r := literalRule(literals); r != nil {
+		s.rules = append(s.rules, *r)
+	}
+
+	for _, suffix := range rules.HostSuffixes {
+		suffix = "." + strings.TrimPrefix(suffix, ".")
+		re, err := regexp.Compile(`\b[0-9A-Za-z][0-9A-Za-z\-.]*` + regexp.QuoteMeta(suffix) + `\b`)
+		if err != nil {
+			return nil, fmt.Errorf("bad host suffix %q: %w", suffix, err)
+		}
+		s.rules = append(s.rules, scrubRule{kind: "host", re: re})
+	}
+	return s, nil
+}
+
+// envLiterals returns literals for environment variable values.
+// Values of keepEnv, short values and paths are not redacted.
+func envLiterals(keepEnv []string) []literal {
+	keep := make(map[string]bool)
+	for _, name := range append(slices.Clone(defaultKeepEnv), keepEnv...) {
+		keep[strings.ToUpper(name)] = true
+	}
+	var literals []literal
+	for _, env := range os.Environ() {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || name == "" || keep[strings.ToUpper(name)] || strings.HasPrefix(name, "LC_") {
+			continue
+		}
+		if len(value) < minEnvValueLen || isPathList(value) {
+			continue
+		}
+		literals = append(literals, literal{kind: "env", value: value, replacement: "<env:" + name + ">"})
+	}
+	return literals
+}
+
+// isPathList reports whether v looks like a path or a path list,
+// which would be scrubbed by home dir and username rules.
+func isPathList(v string) bool {
+	for _, p := range filepath.SplitList(v) {
+		if p != "" && !filepath.IsAbs(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// literalRule returns a rule to redact literals, or nil if no literals.
+// Longer literals are preferred, e.g. home dir over username.
+func literalRule(literals []literal) *scrubRule {
+	m := make(map[string]literal)
+	add := func(l literal, v string) {
+		if _, ok := m[v]; ok {
+			return
+		}
+		m[v] = l
+	}
+	for _, l := range literals {
+		if l.value == "" {
+			continue
+		}
+		add(l, l.value)
+		if strings.Contains(l.value, `\`) {
+			// escaped in json.
+			add(l, strings.ReplaceAll(l.value, `\`, `\\`))
+		}
+		if l.kind == "home" && strings.Contains(l.value, `\`) {
+			add(l, strings.ReplaceAll(l.value, `\`, "/"))
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(m))
+	for v := range m {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	alts := make([]string, 0, len(values))
+	for _, v := range values {
+		alt := regexp.QuoteMeta(v)
+		if isWordByte(v[0]) {
+			alt = `\b` + alt
+		}
+		if isWordByte(v[len(v)-1]) {
+			alt += `\b`
+		}
+		alts = append(alts, alt)
+	}
+	return &scrubRule{
+		kind:     "literal",
+		re:       regexp.MustCompile(strings.Join(alts, "|")),
+		literals: m,
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// scrub redacts buf of fname, and returns redacted buf and
+// number of redactions.
+func (s *scrubber) scrub(fname string, buf []byte) ([]byte, int) {
+	total := 0
+	for _, r := range s.rules {
+		var n int
+		buf, n = s.apply(r, fname, buf)
+		total += n
+	}
+	return buf, total
+}
+
+func (s *scrubber) apply(r scrubRule, fname string, buf []byte) ([]byte, int) {
+	idxs := r.re.FindAllSubmatchIndex(buf, -1)
+	if len(idxs) == 0 {
+		return buf, 0
+	}
+	var out bytes.Buffer
+	out.Grow(len(buf))
+	last := 0
+	for _, idx := range idxs {
+		start, end := idx[0], idx[1]
+		if len(idx) >= 4 && idx[2] >= 0 {
+			start, end = idx[2], idx[3]
+		}
+		out.Write(buf[last:start])
+		out.WriteString(s.placeholder(r, fname, string(buf[start:end])))
+		last = end
+	}
+	out.Write(buf[last:])
+	return out.Bytes(), len(idxs)
+}
+
+// placeholder returns placeholder for the match, and records it
+// for the manifest.
+func (s *scrubber) placeholder(r scrubRule, fname, match string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var p, kind string
+	if l, ok := r.literals[match]; ok {
+		p, kind = l.replacement, l.kind
+	} else {
+		key := r.kind + "\x00" + match
+		var ok bool
+		p, ok = s.placeholders[key]
+		if !ok {
+			s.nextID[r.kind]++
+			p = fmt.Sprintf("<%s-%d>", r.kind, s.nextID[r.kind])
+			s.placeholders[key] = p
+		}
+		kind = r.kind
+	}
+	s.counts[p]++
+	s.kinds[p] = kind
+	if s.files[p] == nil {
+		s.files[p] = make(map[string]bool)
+	}
+	s.files[p][fname] = true
+	return p
+}
+
+// scrubManifest is a manifest of redactions in a scrubbed report.
+// It doesn't contain original values.
+type scrubManifest struct {
+	Redactions []scrubRedaction `json:"redactions"`
+	Files      []scrubFile      `json:"files"`
+	Excluded   []scrubExcluded  `json:"excluded,omitempty"`
+}
+
+type scrubRedaction struct {
+	Placeholder string   `json:"placeholder"`
+	Kind        string   `json:"kind"`
+	Count       int      `json:"count"`
+	Files       []string `json:"files"`
+}
+
+type scrubFile struct {
+	Name       string `json:"name"`
+	Source     string `json:"source,omitempty"`
+	Redactions int    `json:"redactions"`
+}
+
+type scrubExcluded struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// manifest returns manifest of redactions.
+func (s *scrubber) manifest(files []scrubFile, excluded []scrubExcluded) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := scrubManifest{
+		Files:    files,
+		Excluded: excluded,
+	}
+	for p, n := range s.counts {
+		var fnames []string
+		for fname := range s.files[p] {
+			fnames = append(fnames, fname)
+		}
+		sort.Strings(fnames)
+		m.Redactions = append(m.Redactions, scrubRedaction{
+			Placeholder: p,
+			Kind:        s.kinds[p],
+			Count:       n,
+			Files:       fnames,
+		})
+	}
+	sort.Slice(m.Redactions, func(i, j int) bool {
+		return m.Redactions[i].Placeholder < m.Redactions[j].Placeholder
+	})
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", " ")
+	err := enc.Encode(m)
+	return buf.Bytes(), err
+}
+
+// loadScrubRules loads scrub rules from fname.
+func loadScrubRules(fname string) (ScrubRules, error) {
+	var rules ScrubRules
+	if fname == "" {
+		return rules, nil
+	}
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		return rules, err
+	}
+	err = json.Unmarshal(buf, &rules)
+	if err != nil {
+		return rules, fmt.Errorf("failed to parse %s: %w", fname, err)
+	}
+	return rules, nil
+}
+
+// isBinary reports whether buf looks like binary, which can't be
+// scrubbed, e.g. compressed or protobuf.
+func isBinary(buf []byte) bool {
+	return bytes.IndexByte(buf[:min(len(buf), 8192)], 0) >= 0
+}
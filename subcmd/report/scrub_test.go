@@ -0,0 +1,93 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package report
+
+import (
+	"encoding/json"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScrub(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses unix home dir")
+	}
+	t.Setenv("HOME", "/home/alice")
+	t.Setenv("SISO_TEST_PROJECT", "rbe-internal-project")
+	t.Setenv("SISO_TEST_SHORT", "abc")
+	t.Setenv("SISO_TEST_DIR", "/opt/tools")
+
+	sc, err := newScrubber(ScrubRules{
+		Literals: map[string]string{
+			"chrome-secret-branch": "<branch>",
+		},
+		HostSuffixes: []string{".corp.example.com"},
+	})
+	if err != nil {
+		t.Fatalf("newScrubber=%v; want nil error", err)
+	}
+
+	log := strings.Join([]string{
+		"cwd=/home/alice/chromium/src/out/Default",
+		"project rbe-internal-project on builder1.corp.example.com",
+		"connect to builder2.corp.example.com and builder1.corp.example.com",
+		"Authorization: Bearer abcdefghijklmnop",
+		"token=ya29.a0AfH6SMBxxxxxxxxxxxxxxxxxxxxxxxx",
+		"branch chrome-secret-branch abc /opt/tools/bin",
+	}, "\n")
+	got, n := sc.scrub("siso.INFO", []byte(log))
+	want := strings.Join([]string{
+		"cwd=<home>/chromium/src/out/Default",
+		"project <env:SISO_TEST_PROJECT> on <host-1>",
+		"connect to <host-2> and <host-1>",
+		"Authorization: Bearer <bearer-1>",
+		"token=<oauth-token-1>",
+		"branch <branch> abc /opt/tools/bin",
+	}, "\n")
+	if string(got) != want {
+		t.Errorf("scrub=\n%s\nwant\n%s", got, want)
+	}
+	if n != 8 {
+		t.Errorf("scrub redactions=%d; want 8", n)
+	}
+
+	// consistent across files.
+	got, _ = sc.scrub("siso_metrics.json", []byte(`{"output":"/home/alice/out","host":"builder2.corp.example.com"}`))
+	if want := `{"output":"<home>/out","host":"<host-2>"}`; string(got) != want {
+		t.Errorf("scrub=%s; want %s", got, want)
+	}
+
+	buf, err := sc.manifest([]scrubFile{{Name: "siso.INFO", Redactions: n}}, []scrubExcluded{{Name: ".siso_deps", Reason: "binary"}})
+	if err != nil {
+		t.Fatalf("manifest=%v; want nil error", err)
+	}
+	for _, s := range []string{"alice", "rbe-internal-project", "builder1", "abcdefghijklmnop", "chrome-secret-branch"} {
+		if strings.Contains(string(buf), s) {
+			t.Errorf("manifest contains %q:\n%s", s, buf)
+		}
+	}
+	var m scrubManifest
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		t.Fatalf("unmarshal manifest=%v; want nil error", err)
+	}
+	counts := make(map[string]int)
+	for _, r := range m.Redactions {
+		counts[r.Placeholder] = r.Count
+	}
+	if counts["<host-1>"] != 2 || counts["<host-2>"] != 2 || counts["<home>"] != 2 {
+		t.Errorf("manifest counts=%v; want <host-1>=2 <host-2>=2 <home>=2", counts)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if isBinary([]byte("text\nlog\n")) {
+		t.Errorf("isBinary(text)=true; want false")
+	}
+	if !isBinary([]byte{0x1f, 0x8b, 0x08, 0x00}) {
+		t.Errorf("isBinary(gzip)=false; want true")
+	}
+}
@@ -362,6 +362,7 @@ func (d *DepsLog) Recompact(ctx context.Context) error {
 
 	d.mu.Lock()
 	d.rPaths = nd.paths
+	clear(d.rPathIdx)
 	maps.Copy(d.rPathIdx, nd.pathIdx)
 	d.rDeps = nd.deps
 
@@ -374,6 +375,31 @@ func (d *DepsLog) Recompact(ctx context.Context) error {
 	return nil
 }
 
+// Remove removes deps log entries for the outputs, and recompacts
+// the deps log file if any entry is removed.
+// It returns number of removed entries.
+func (d *DepsLog) Remove(ctx context.Context, outputs []string) (int, error) {
+	if d == nil {
+		return 0, nil
+	}
+	n := 0
+	d.mu.Lock()
+	for _, output := range outputs {
+		i, found := d.pathIdx[filepath.ToSlash(output)]
+		if !found || i >= len(d.deps) || d.deps[i] == nil {
+			continue
+		}
+		d.deps[i] = nil
+		n++
+	}
+	d.mu.Unlock()
+	if n == 0 {
+		return 0, nil
+	}
+	d.Reset()
+	return n, d.Recompact(ctx)
+}
+
 // Close closes the deps log.
 func (d *DepsLog) Close() error {
 	if d == nil || d.w == nil {
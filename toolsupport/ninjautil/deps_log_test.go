@@ -5,6 +5,7 @@
 package ninjautil
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -267,3 +268,51 @@ func TestDepsLog_broken(t *testing.T) {
 		t.Errorf("dl3.Close=%v; want nil error", err)
 	}
 }
+
+func TestDepsLog_Remove(t *testing.T) {
+	ctx := t.Context()
+	fname := filepath.Join(t.TempDir(), "mydepslog")
+	t1 := time.Unix(1, 0)
+	t2 := time.Unix(2, 0)
+
+	dl, err := NewDepsLog(ctx, fname)
+	if err != nil {
+		t.Fatalf("NewDepsLog(ctx, %q)=_, %v; want nil error", fname, err)
+	}
+	_, err = dl.Record(ctx, "out.o", t1, []string{"foo.h", "bar.h"})
+	if err != nil {
+		t.Fatalf(`dl.Record(ctx, "out.o", %v, ...)=_, %v; want nil error`, t1, err)
+	}
+	_, err = dl.Record(ctx, "out2.o", t2, []string{"foo.h"})
+	if err != nil {
+		t.Fatalf(`dl.Record(ctx, "out2.o", %v, ...)=_, %v; want nil error`, t2, err)
+	}
+	n, err := dl.Remove(ctx, []string{"out.o", "unknown.o"})
+	if n != 1 || err != nil {
+		t.Errorf(`dl.Remove(ctx, {"out.o", "unknown.o"})=%d, %v; want 1, nil error`, n, err)
+	}
+	_, _, err = dl.RetrievePaths(ctx, "out.o")
+	if !errors.Is(err, ErrNoDepsLog) {
+		t.Errorf(`dl.RetrievePaths(ctx, "out.o")=_, _, %v; want %v`, err, ErrNoDepsLog)
+	}
+	err = dl.Close()
+	if err != nil {
+		t.Fatalf("dl.Close()=%v; want nil error", err)
+	}
+
+	t.Logf("reload deps log. removed entry should not exist")
+	dl, err = NewDepsLog(ctx, fname)
+	if err != nil {
+		t.Fatalf("NewDepsLog(ctx, %q)=_, %v; want nil error", fname, err)
+	}
+	defer dl.Close()
+	_, _, err = dl.RetrievePaths(ctx, "out.o")
+	if !errors.Is(err, ErrNoDepsLog) {
+		t.Errorf(`dl.RetrievePaths(ctx, "out.o")=_, _, %v; want %v`, err, ErrNoDepsLog)
+	}
+	deps, ts, err := dl.RetrievePaths(ctx, "out2.o")
+	want := []string{"foo.h"}
+	if !cmp.Equal(deps, want) || !ts.Equal(t2) || err != nil {
+		t.Errorf(`dl.RetrievePaths(ctx, "out2.o")=%v, %v, %v; want %v, %v, nil`, deps, ts, err, want, t2)
+	}
+}
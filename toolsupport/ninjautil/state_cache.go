@@ -0,0 +1,653 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninjautil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// stateCacheMagic is a magic header of state cache file.
+// Update version when serialization format or State is changed.
+const stateCacheMagic = "siso-ninja-state\x00v1\n"
+
+// ErrStaleStateCache is an error when state cache is stale.
+var ErrStaleStateCache = errors.New("stale ninja state cache")
+
+// SaveStateCache saves the state as binary form in fname.
+// key is digests of files in s.Filenames(), which is checked in
+// LoadStateCache to detect staleness.
+func SaveStateCache(fname string, s *State, key []byte) error {
+	w := newStateEncoder(s)
+	err := w.encode(key)
+	if err != nil {
+		return err
+	}
+	tmpname := fname + ".tmp"
+	err = os.WriteFile(tmpname, w.buf, 0644)
+	if err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return os.Rename(tmpname, fname)
+}
+
+// LoadStateCache loads the state saved by SaveStateCache from fname.
+// keyFunc computes the key from filenames recorded in the cache.
+// It returns ErrStaleStateCache if the key doesn't match with the key
+// stored in the cache.
+// Bytes in nodes and edges of the state refer to the contents of fname
+// read in heap, so they stay valid while nodes or edges are reachable,
+// even after the state is dropped.
+// It doesn't mmap fname, as the mapping can't be released while nodes
+// or edges may be reachable (e.g. after ninjabuild.Graph reloads state).
+func LoadStateCache(fname string, keyFunc func(filenames []string) ([]byte, error)) (*State, error) {
+	buf, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	s, err := decodeStateCache(buf, keyFunc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return s, nil
+}
+
+// stateEncoder encodes State into buf.
+type stateEncoder struct {
+	s   *State
+	buf []byte
+
+	nodeIDs map[*Node]int
+	scopes  []*fileScope
+	scopeID map[*fileScope]int
+	ruleRef map[*rule][2]int // scope id + 1, rule index
+}
+
+func newStateEncoder(s *State) *stateEncoder {
+	return &stateEncoder{
+		s:       s,
+		nodeIDs: make(map[*Node]int, len(s.nodes)),
+		scopeID: make(map[*fileScope]int),
+		ruleRef: make(map[*rule][2]int),
+	}
+}
+
+func (w *stateEncoder) uvarint(v int) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(v))
+}
+
+func (w *stateEncoder) varint(v int) {
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *stateEncoder) bytes(b []byte) {
+	w.uvarint(len(b))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *stateEncoder) string(s string) {
+	w.uvarint(len(s))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *stateEncoder) evalString(ev evalString) {
+	w.varint(ev.pos)
+	w.bytes(ev.v)
+	w.uvarint(ev.esc)
+}
+
+func (w *stateEncoder) nodes(nodes []*Node) {
+	w.uvarint(len(nodes))
+	for _, n := range nodes {
+		w.uvarint(w.nodeIDs[n])
+	}
+}
+
+// addScope adds scope and its parents, so parent scope has smaller id.
+func (w *stateEncoder) addScope(scope *fileScope) {
+	if scope == nil {
+		return
+	}
+	if _, ok := w.scopeID[scope]; ok {
+		return
+	}
+	w.addScope(scope.parent)
+	w.scopeID[scope] = len(w.scopes)
+	w.scopes = append(w.scopes, scope)
+}
+
+func (w *stateEncoder) encode(key []byte) error {
+	s := w.s
+	w.buf = append(w.buf, stateCacheMagic...)
+	w.bytes(key)
+	w.uvarint(len(s.filenames))
+	for _, fname := range s.filenames {
+		w.string(fname)
+	}
+
+	// pools
+	pools := s.Pools()
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		if name == defaultPool.name || name == consolePool.name {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w.uvarint(len(names))
+	for _, name := range names {
+		w.string(name)
+		w.uvarint(pools[name].depth)
+	}
+
+	// nodes. id 0 is invalid.
+	w.uvarint(len(s.nodes))
+	for i, n := range s.nodes {
+		if i == 0 {
+			continue
+		}
+		if n == nil {
+			return fmt.Errorf("nil node at %d", i)
+		}
+		w.nodeIDs[n] = i
+		w.string(n.path)
+	}
+
+	// scopes
+	w.addScope(s.scope)
+	for _, e := range s.edges {
+		w.addScope(e.scope)
+	}
+	w.uvarint(len(w.scopes))
+	for i, scope := range w.scopes {
+		parent := 0
+		if scope.parent != nil {
+			parent = w.scopeID[scope.parent] + 1
+		}
+		w.uvarint(parent)
+		var rules []*rule
+		if scope.rules != nil {
+			for j := range scope.rules.rules {
+				for r := scope.rules.rules[j].Load(); r != nil; r = r.next {
+					rules = append(rules, r)
+				}
+			}
+		}
+		w.uvarint(len(rules))
+		for j, r := range rules {
+			w.ruleRef[r] = [2]int{i + 1, j}
+			w.string(r.name)
+			w.uvarint(len(r.bindings))
+			for _, b := range r.bindings {
+				w.bytes(b.name)
+				w.evalString(b.value)
+			}
+		}
+		var bindings []binding
+		if scope.bindings != nil {
+			for j := range scope.bindings.shards {
+				bindings = append(bindings, scope.bindings.shards[j].list...)
+			}
+		}
+		w.uvarint(len(bindings))
+		for _, b := range bindings {
+			w.bytes(b.name)
+			w.evalString(b.value)
+		}
+	}
+
+	// edges
+	w.uvarint(len(s.edges))
+	for _, e := range s.edges {
+		switch {
+		case e.rule == phonyRule:
+			w.uvarint(0)
+		default:
+			ref, ok := w.ruleRef[e.rule]
+			if !ok {
+				return fmt.Errorf("rule %q not found in scopes", e.rule.name)
+			}
+			w.uvarint(ref[0])
+			w.uvarint(ref[1])
+		}
+		poolName := ""
+		if e.pool != nil {
+			poolName = e.pool.name
+		}
+		w.string(poolName)
+		w.varint(e.pos)
+		w.uvarint(w.scopeID[e.scope])
+		// keep only statements used by the edge, not whole chunk.
+		w.uvarint(len(e.env.statements))
+		var size int
+		for _, st := range e.env.statements {
+			size += st.e - st.s
+		}
+		w.uvarint(size)
+		for _, st := range e.env.statements {
+			w.buf = append(w.buf, e.env.buf[st.s:st.e]...)
+		}
+		for _, st := range e.env.statements {
+			w.varint(st.pos)
+			w.uvarint(int(st.t))
+			w.uvarint(st.v - st.s)
+			w.uvarint(st.e - st.s)
+		}
+		w.nodes(e.inputs)
+		w.nodes(e.outputs)
+		w.nodes(e.validations)
+		w.uvarint(e.implicitDeps)
+		w.uvarint(e.orderOnlyDeps)
+		w.uvarint(e.implicitOuts)
+	}
+	w.nodes(s.defaults)
+	return nil
+}
+
+// stateDecoder decodes State from buf.
+type stateDecoder struct {
+	buf []byte
+	err error
+}
+
+func (r *stateDecoder) uvarint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 || v > math.MaxInt32 {
+		r.err = fmt.Errorf("corrupted state cache: bad uvarint")
+		r.buf = nil
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(v)
+}
+
+func (r *stateDecoder) varint() int {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.buf)
+	if n <= 0 {
+		r.err = fmt.Errorf("corrupted state cache: bad varint")
+		r.buf = nil
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return int(v)
+}
+
+// bytes returns bytes referring buf (i.e. contents of the file).
+func (r *stateDecoder) bytes() []byte {
+	return r.bytesN(r.uvarint())
+}
+
+func (r *stateDecoder) bytesN(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.buf) {
+		r.err = fmt.Errorf("corrupted state cache: short buffer %d > %d", n, len(r.buf))
+		r.buf = nil
+		return nil
+	}
+	b := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *stateDecoder) string() string {
+	return string(r.bytes())
+}
+
+func (r *stateDecoder) evalString() evalString {
+	return evalString{
+		pos: r.varint(),
+		v:   r.bytes(),
+		esc: r.uvarint(),
+	}
+}
+
+func (r *stateDecoder) nodes(nodes []*Node) []*Node {
+	n := r.uvarint()
+	if r.err != nil || n > len(r.buf) {
+		r.err = errors.Join(r.err, fmt.Errorf("corrupted state cache: too many nodes %d", n))
+		return nil
+	}
+	ns := make([]*Node, 0, n)
+	for range n {
+		id := r.uvarint()
+		if id <= 0 || id >= len(nodes) {
+			r.err = errors.Join(r.err, fmt.Errorf("corrupted state cache: bad node id %d", id))
+			return nil
+		}
+		ns = append(ns, nodes[id])
+	}
+	return ns
+}
+
+func decodeStateCache(buf []byte, keyFunc func([]string) ([]byte, error)) (*State, error) {
+	if !bytes.HasPrefix(buf, []byte(stateCacheMagic)) {
+		return nil, fmt.Errorf("%w: bad magic", ErrStaleStateCache)
+	}
+	r := &stateDecoder{buf: buf[len(stateCacheMagic):]}
+	key := r.bytes()
+	nfiles := r.uvarint()
+	if r.err != nil {
+		return nil, r.err
+	}
+	filenames := make([]string, 0, min(nfiles, len(r.buf)))
+	for range nfiles {
+		filenames = append(filenames, r.string())
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	curKey, err := keyFunc(filenames)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStaleStateCache, err)
+	}
+	if !bytes.Equal(key, curKey) {
+		return nil, ErrStaleStateCache
+	}
+
+	s := NewState()
+	s.filenames = filenames
+
+	npools := r.uvarint()
+	for range npools {
+		name := r.string()
+		depth := r.uvarint()
+		s.addPool(newPool(name, depth))
+	}
+
+	nnodes := r.uvarint()
+	if r.err != nil || nnodes == 0 || nnodes > len(r.buf) {
+		return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad nodes %d", nnodes))
+	}
+	lm := s.nodeMap.localNodeMap(nnodes)
+	s.nodes = make([]*Node, 1, nnodes)
+	for i := 1; i < nnodes; i++ {
+		n := lm.node(r.bytes())
+		if r.err != nil {
+			return nil, r.err
+		}
+		if n.id != 0 {
+			return nil, fmt.Errorf("corrupted state cache: duplicate node %q", n.path)
+		}
+		n.id = i
+		s.nodes = append(s.nodes, n)
+	}
+	s.nodeMap.n.Store(int64(nnodes - 1))
+
+	nscopes := r.uvarint()
+	if r.err != nil || nscopes == 0 || nscopes > len(r.buf) {
+		return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad scopes %d", nscopes))
+	}
+	scopes := make([]*fileScope, 0, nscopes)
+	rules := make([][]*rule, 0, nscopes)
+	for i := range nscopes {
+		parent := r.uvarint()
+		if parent > i {
+			return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad parent scope %d for %d", parent, i))
+		}
+		scope := s.scope
+		if i > 0 {
+			var p *fileScope
+			if parent > 0 {
+				p = scopes[parent-1]
+			}
+			scope = newFileScope(p)
+		}
+		nrules := r.uvarint()
+		scope.rules = newRuleMap(min(nrules, len(r.buf)))
+		var scopeRules []*rule
+		for range nrules {
+			rl := &rule{name: r.string()}
+			nbindings := r.uvarint()
+			if r.err != nil {
+				return nil, r.err
+			}
+			rl.bindings = make([]binding, 0, min(nbindings, len(r.buf)))
+			for range nbindings {
+				rl.bindings = append(rl.bindings, binding{
+					name:  r.bytes(),
+					value: r.evalString(),
+				})
+			}
+			if r.err != nil {
+				return nil, r.err
+			}
+			err := scope.setRule(rl)
+			if err != nil {
+				return nil, fmt.Errorf("corrupted state cache: %w", err)
+			}
+			scopeRules = append(scopeRules, rl)
+		}
+		nvars := r.uvarint()
+		scope.bindings = newShardBindings(min(nvars, len(r.buf)))
+		for range nvars {
+			name := r.bytes()
+			scope.setVar(name, r.evalString())
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		scopes = append(scopes, scope)
+		rules = append(rules, scopeRules)
+	}
+
+	nedges := r.uvarint()
+	if r.err != nil || nedges > len(r.buf) {
+		return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad edges %d", nedges))
+	}
+	edges := make([]Edge, nedges)
+	s.edges = make([]*Edge, 0, nedges)
+	for i := range edges {
+		e := &edges[i]
+		scopeRef := r.uvarint()
+		switch {
+		case scopeRef == 0:
+			e.rule = phonyRule
+		case scopeRef <= len(rules):
+			idx := r.uvarint()
+			if idx >= len(rules[scopeRef-1]) {
+				return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad rule %d in scope %d", idx, scopeRef-1))
+			}
+			e.rule = rules[scopeRef-1][idx]
+		default:
+			return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad rule scope %d", scopeRef))
+		}
+		poolName := r.bytes()
+		pool, ok := s.lookupPool(poolName)
+		if !ok {
+			return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: unknown pool %q", poolName))
+		}
+		e.pool = pool
+		e.pos = r.varint()
+		scopeID := r.uvarint()
+		if scopeID >= len(scopes) {
+			return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad scope %d", scopeID))
+		}
+		e.scope = scopes[scopeID]
+		nstatements := r.uvarint()
+		size := r.uvarint()
+		if r.err != nil || nstatements > len(r.buf) {
+			return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad statements %d", nstatements))
+		}
+		envBuf := r.bytesN(size)
+		statements := make([]statement, 0, nstatements)
+		off := 0
+		for range nstatements {
+			st := statement{
+				pos: r.varint(),
+				t:   statementType(r.uvarint()),
+				s:   off,
+			}
+			st.v = off + r.uvarint()
+			st.e = off + r.uvarint()
+			if st.v > st.e || st.e > len(envBuf) {
+				return nil, errors.Join(r.err, fmt.Errorf("corrupted state cache: bad statement"))
+			}
+			off = st.e
+			statements = append(statements, st)
+		}
+		e.env.set(envBuf, statements)
+		e.inputs = r.nodes(s.nodes)
+		e.outputs = r.nodes(s.nodes)
+		e.validations = r.nodes(s.nodes)
+		e.implicitDeps = r.uvarint()
+		e.orderOnlyDeps = r.uvarint()
+		e.implicitOuts = r.uvarint()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if e.implicitDeps+e.orderOnlyDeps > len(e.inputs) || e.implicitOuts > len(e.outputs) {
+			return nil, fmt.Errorf("corrupted state cache: bad implicit/order-only counts")
+		}
+		for _, out := range e.outputs {
+			if !out.setInEdge(e) {
+				return nil, fmt.Errorf("corrupted state cache: %w", multipleRulesError{target: out.path})
+			}
+		}
+		for _, in := range e.inputs {
+			in.nouts.Add(1)
+		}
+		s.edges = append(s.edges, e)
+	}
+	s.defaults = r.nodes(s.nodes)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.buf) != 0 {
+		return nil, fmt.Errorf("corrupted state cache: %d bytes trailing", len(r.buf))
+	}
+	for _, edge := range s.edges {
+		for _, in := range edge.inputs {
+			if in.outs == nil {
+				in.outs = make([]*Edge, 0, in.nouts.Load())
+			}
+			in.outs = append(in.outs, edge)
+		}
+	}
+	return s, nil
+}
+
+// DiffState compares state with fresh parsed state, and returns
+// differences, up to maxDiffs.
+// It is used to validate state cache.
+func DiffState(fresh, cached *State, maxDiffs int) []string {
+	var diffs []string
+	add := func(format string, args ...any) bool {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+		return len(diffs) < maxDiffs
+	}
+	paths := func(nodes []*Node) string {
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.Path())
+		}
+		return strings.Join(s, " ")
+	}
+	if !slices.Equal(fresh.Filenames(), cached.Filenames()) {
+		// parse order of subninjas may differ.
+		f := slices.Clone(fresh.Filenames())
+		c := slices.Clone(cached.Filenames())
+		sort.Strings(f)
+		sort.Strings(c)
+		if !slices.Equal(f, c) && !add("filenames: %q != %q", f, c) {
+			return diffs
+		}
+	}
+	if fresh.NumNodes() != cached.NumNodes() {
+		if !add("nodes: %d != %d", fresh.NumNodes(), cached.NumNodes()) {
+			return diffs
+		}
+	}
+	for name, pool := range fresh.Pools() {
+		cpool, ok := cached.LookupPool(name)
+		if !ok {
+			if !add("pool %q: missing", name) {
+				return diffs
+			}
+			continue
+		}
+		if cpool.Depth() != pool.Depth() {
+			if !add("pool %q: depth %d != %d", name, pool.Depth(), cpool.Depth()) {
+				return diffs
+			}
+		}
+	}
+	if a, b := paths(fresh.defaults), paths(cached.defaults); a != b {
+		if !add("defaults: %q != %q", a, b) {
+			return diffs
+		}
+	}
+	bindings := []string{"command", "description", "depfile", "deps", "rspfile", "rspfile_content", "restat", "generator", "pool", "msvc_deps_prefix", "dyndep"}
+	for _, n := range fresh.nodes {
+		if n == nil {
+			continue
+		}
+		cn, ok := cached.LookupNodeByPath(n.Path())
+		if !ok {
+			if !add("node %q: missing", n.Path()) {
+				return diffs
+			}
+			continue
+		}
+		e, ok := n.InEdge()
+		ce, cok := cn.InEdge()
+		if ok != cok {
+			if !add("node %q: in-edge %t != %t", n.Path(), ok, cok) {
+				return diffs
+			}
+			continue
+		}
+		if a, b := len(n.OutEdges()), len(cn.OutEdges()); a != b {
+			if !add("node %q: out-edges %d != %d", n.Path(), a, b) {
+				return diffs
+			}
+		}
+		if !ok || e.Outputs()[0] != n {
+			continue
+		}
+		if e.RuleName() != ce.RuleName() {
+			if !add("edge %q: rule %q != %q", n.Path(), e.RuleName(), ce.RuleName()) {
+				return diffs
+			}
+		}
+		for _, nodes := range [][2][]*Node{
+			{e.Inputs(), ce.Inputs()},
+			{e.Ins(), ce.Ins()},
+			{e.TriggerInputs(), ce.TriggerInputs()},
+			{e.Outputs(), ce.Outputs()},
+			{e.Validations(), ce.Validations()},
+		} {
+			if a, b := paths(nodes[0]), paths(nodes[1]); a != b {
+				if !add("edge %q: nodes %q != %q", n.Path(), a, b) {
+					return diffs
+				}
+			}
+		}
+		for _, name := range bindings {
+			if a, b := e.Binding(name), ce.Binding(name); a != b {
+				if !add("edge %q: binding %s %q != %q", n.Path(), name, a, b) {
+					return diffs
+				}
+			}
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package ninjautil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStateCache(t *testing.T) {
+	ctx := t.Context()
+	dir := t.TempDir()
+	for fname, content := range map[string]string{
+		"build.ninja": `
+cflags = -O2
+pool link_pool
+  depth = 2
+
+rule cxx
+  command = clang++ ${cflags} -c ${in} -o ${out}
+  description = CXX ${out}
+  depfile = ${out}.d
+  deps = gcc
+
+rule link
+  command = clang++ -o ${out} @${out}.rsp
+  rspfile = ${out}.rsp
+  rspfile_content = ${in}
+  pool = link_pool
+
+build obj/foo.o: cxx ../../foo.cc | ../../foo.h || gen
+build gen: phony
+subninja obj/bar.ninja
+build foo: link obj/foo.o obj/bar.o |@ check
+build check: phony
+default foo
+`,
+		"obj/bar.ninja": `
+cflags = -O0
+build obj/bar.o: cxx ../../bar.cc
+  cflags = -g
+`,
+	} {
+		fname := filepath.Join(dir, fname)
+		err := os.MkdirAll(filepath.Dir(fname), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(fname, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	state := NewState()
+	p := NewManifestParser(state)
+	err := p.Load(ctx, "build.ninja")
+	if err != nil {
+		t.Fatalf("Load=%v; want nil error", err)
+	}
+
+	cacheFile := filepath.Join(dir, ".siso_ninja_state")
+	key := []byte("key")
+	err = SaveStateCache(cacheFile, state, key)
+	if err != nil {
+		t.Fatalf("SaveStateCache=%v; want nil error", err)
+	}
+
+	var gotFilenames []string
+	cached, err := LoadStateCache(cacheFile, func(filenames []string) ([]byte, error) {
+		gotFilenames = filenames
+		return key, nil
+	})
+	if err != nil {
+		t.Fatalf("LoadStateCache=%v; want nil error", err)
+	}
+	if len(gotFilenames) != 2 {
+		t.Errorf("filenames=%q; want 2 files", gotFilenames)
+	}
+	if diffs := DiffState(state, cached, 10); len(diffs) > 0 {
+		t.Errorf("DiffState:\n%s", strings.Join(diffs, "\n"))
+	}
+
+	node, ok := cached.LookupNodeByPath("obj/bar.o")
+	if !ok {
+		t.Fatalf("obj/bar.o not found in cached state")
+	}
+	edge, ok := node.InEdge()
+	if !ok {
+		t.Fatalf("no in-edge for obj/bar.o")
+	}
+	if got, want := edge.Binding("command"), "clang++ -g -c ../../bar.cc -o obj/bar.o"; got != want {
+		t.Errorf("command=%q; want %q", got, want)
+	}
+	node, ok = cached.LookupNodeByPath("foo")
+	if !ok {
+		t.Fatalf("foo not found in cached state")
+	}
+	edge, ok = node.InEdge()
+	if !ok {
+		t.Fatalf("no in-edge for foo")
+	}
+	if got, want := edge.Pool().Name(), "link_pool"; got != want {
+		t.Errorf("pool=%q; want %q", got, want)
+	}
+	if got, want := edge.Binding("rspfile_content"), "obj/foo.o obj/bar.o"; got != want {
+		t.Errorf("rspfile_content=%q; want %q", got, want)
+	}
+	targets, err := cached.Targets(nil)
+	if err != nil || len(targets) != 1 || targets[0].Path() != "foo" {
+		t.Errorf("Targets(nil)=%v, %v; want [foo], nil", targets, err)
+	}
+
+	// edges may outlive the state, e.g. graph reload.
+	cached = nil
+	runtime.GC()
+	if got, want := edge.Binding("rspfile_content"), "obj/foo.o obj/bar.o"; got != want {
+		t.Errorf("rspfile_content after GC=%q; want %q", got, want)
+	}
+
+	_, err = LoadStateCache(cacheFile, func([]string) ([]byte, error) {
+		return []byte("other-key"), nil
+	})
+	if !errors.Is(err, ErrStaleStateCache) {
+		t.Errorf("LoadStateCache with other key=%v; want %v", err, ErrStaleStateCache)
+	}
+
+	buf, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(cacheFile, buf[:len(buf)/2], 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadStateCache(cacheFile, func([]string) ([]byte, error) {
+		return key, nil
+	})
+	if err == nil {
+		t.Errorf("LoadStateCache with truncated file=nil; want error")
+	}
+}
@@ -0,0 +1,514 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package tui provides full-screen interactive terminal UI for builds.
+package tui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+
+	"go.chromium.org/build/siso/build"
+	"go.chromium.org/build/siso/ui"
+)
+
+// Controller is an interface to the running build.
+// build.Builder implements this interface.
+type Controller interface {
+	LiveSummary(start, n int) build.LiveStatus
+	SetPaused(bool)
+	Paused() bool
+	SetKeepGoing(bool)
+	KeepGoing() bool
+}
+
+// ErrNotSupported is returned by Start if full-screen UI
+// is not supported on the terminal.
+var ErrNotSupported = errors.New("full-screen terminal UI is not supported")
+
+const (
+	refreshInterval = 500 * time.Millisecond
+
+	// maxLogs is max number of log messages captured while
+	// full-screen UI is active.
+	maxLogs = 10000
+
+	// failuresPane is the number of failures shown on the screen.
+	failuresPane = 5
+)
+
+type logEntry struct {
+	// permanent is true if the message was printed by PrintLines,
+	// false if it was printed by Infof etc.
+	permanent bool
+	msg       string
+}
+
+// TUI is a full-screen interactive terminal UI.
+// While it is active, it is used as ui.Default, and messages
+// are captured and printed after it stops.
+type TUI struct {
+	c        Controller
+	prev     ui.UI
+	oldState *term.State
+
+	quit     chan struct{}
+	done     chan struct{}
+	redraw   chan struct{}
+	stopOnce sync.Once
+
+	mu     sync.Mutex
+	view   viewState
+	logs   []logEntry
+	status build.LiveStatus
+}
+
+// Start starts full-screen terminal UI for the build.
+// It returns ErrNotSupported if it is not on a smart terminal,
+// so the caller can fall back to the current ui.
+func Start(ctx context.Context, c Controller) (*TUI, error) {
+	if !ui.IsTerminal() || !term.IsTerminal(int(os.Stdin.Fd())) || !supported() {
+		return nil, ErrNotSupported
+	}
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotSupported, err)
+	}
+	t := &TUI{
+		c:        c,
+		prev:     ui.Default,
+		oldState: oldState,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+		redraw:   make(chan struct{}, 1),
+	}
+	// alternate screen, hide cursor.
+	os.Stdout.WriteString("\033[?1049h\033[?25l")
+	ui.Default = t
+	go t.readKeys()
+	go t.loop(ctx)
+	return t, nil
+}
+
+// Stop stops full-screen terminal UI, and prints captured messages.
+func (t *TUI) Stop() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() {
+		close(t.quit)
+		<-t.done
+		// don't leave the build paused without the way to resume.
+		t.c.SetPaused(false)
+		// show cursor, normal screen.
+		os.Stdout.WriteString("\033[?25h\033[?1049l")
+		err := term.Restore(int(os.Stdin.Fd()), t.oldState)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to restore terminal: %v\n", err)
+		}
+		ui.Default = t.prev
+		t.mu.Lock()
+		logs := t.logs
+		t.logs = nil
+		t.mu.Unlock()
+		for _, l := range logs {
+			if l.permanent {
+				t.prev.PrintLines("\n", l.msg+"\n")
+				continue
+			}
+			t.prev.Infof("%s", l.msg)
+		}
+	})
+}
+
+func (t *TUI) loop(ctx context.Context) {
+	defer close(t.done)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		t.refresh()
+		select {
+		case <-t.quit:
+			return
+		case <-ctx.Done():
+			return
+		case <-t.redraw:
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh fetches live status and redraws the screen.
+// It fetches only failures around the selected one to show.
+func (t *TUI) refresh() {
+	t.mu.Lock()
+	start := t.view.selected - failuresPane/2
+	t.mu.Unlock()
+	st := t.c.LiveSummary(start, failuresPane)
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	t.mu.Lock()
+	t.status = st
+	t.view.paused = t.c.Paused()
+	t.view.keepGoing = t.c.KeepGoing()
+	lines := render(st, t.view, width, height)
+	t.mu.Unlock()
+
+	var buf bytes.Buffer
+	buf.WriteString("\033[H")
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteString("\r\n")
+		}
+		buf.WriteString(line)
+		buf.WriteString("\033[K")
+	}
+	buf.WriteString("\033[J")
+	os.Stdout.Write(buf.Bytes())
+}
+
+// key is an input key.
+type key int
+
+const (
+	keyNone key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyCtrlC
+)
+
+// readKeys reads keys from stdin until stopped.
+func (t *TUI) readKeys() {
+	buf := make([]byte, 16)
+	for {
+		// don't block in read, so it won't consume input
+		// after stopped.
+		if !waitInput(t.quit) {
+			return
+		}
+		n, err := os.Stdin.Read(buf)
+		select {
+		case <-t.quit:
+			return
+		default:
+		}
+		if err != nil {
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			if !t.handleKey(k) {
+				return
+			}
+		}
+	}
+}
+
+// parseKeys parses input bytes into keys.
+// Printable keys are returned as key of the rune.
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch {
+		case bytes.HasPrefix(b, []byte("\033[A")):
+			keys = append(keys, keyUp)
+			b = b[3:]
+		case bytes.HasPrefix(b, []byte("\033[B")):
+			keys = append(keys, keyDown)
+			b = b[3:]
+		case bytes.HasPrefix(b, []byte("\033[5~")):
+			keys = append(keys, keyPageUp)
+			b = b[4:]
+		case bytes.HasPrefix(b, []byte("\033[6~")):
+			keys = append(keys, keyPageDown)
+			b = b[4:]
+		case b[0] == 0x03:
+			keys = append(keys, keyCtrlC)
+			b = b[1:]
+		case b[0] >= ' ' && b[0] < 0x7f:
+			keys = append(keys, key(b[0]))
+			b = b[1:]
+		default:
+			b = b[1:]
+		}
+	}
+	return keys
+}
+
+// handleKey handles key input.
+// It returns false if it stops reading keys.
+func (t *TUI) handleKey(k key) bool {
+	switch k {
+	case keyCtrlC:
+		// terminal in raw mode doesn't send SIGINT.
+		interrupt()
+		return true
+	case 'q':
+		// exit full-screen UI, and continue the build with the
+		// previous UI.
+		go t.Stop()
+		return false
+	case 'p':
+		t.c.SetPaused(!t.c.Paused())
+	case 'k':
+		// with `-k 0`, turning off stops at the next failure.
+		t.c.SetKeepGoing(!t.c.KeepGoing())
+	}
+	t.mu.Lock()
+	t.view.handleKey(k, t.status.NumFailures)
+	t.mu.Unlock()
+	select {
+	case t.redraw <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// viewState is a state of the view controlled by keys.
+type viewState struct {
+	paused    bool
+	keepGoing bool
+
+	// progress is the last progress message.
+	progress string
+
+	// sortByPhase sorts active steps by phase,
+	// instead of by duration.
+	sortByPhase bool
+
+	// selected is the index of selected failure.
+	selected int
+	// showOutput shows output of the selected failure.
+	showOutput bool
+	// scroll is scroll offset of the output.
+	scroll int
+}
+
+func (v *viewState) handleKey(k key, nfailures int) {
+	switch k {
+	case 's':
+		v.sortByPhase = !v.sortByPhase
+	case 'o':
+		v.showOutput = !v.showOutput && nfailures > 0
+		v.scroll = 0
+	case keyUp:
+		if v.showOutput {
+			v.scroll = max(v.scroll-1, 0)
+			return
+		}
+		v.selected = max(v.selected-1, 0)
+	case keyDown:
+		if v.showOutput {
+			v.scroll++
+			return
+		}
+		v.selected = min(v.selected+1, max(nfailures-1, 0))
+	case keyPageUp:
+		v.scroll = max(v.scroll-10, 0)
+	case keyPageDown:
+		v.scroll += 10
+	}
+}
+
+const helpLine = "p:pause k:keep-going s:sort ↑/↓:select o:output q:exit full-screen ^C:interrupt"
+
+// render renders live status in lines that fit in width x height.
+func render(st build.LiveStatus, v viewState, width, height int) []string {
+	var lines []string
+	add := func(format string, args ...any) {
+		line := fmt.Sprintf(format, args...)
+		if n := len([]rune(line)); width > 0 && n > width {
+			line = string([]rune(line)[:width])
+		}
+		lines = append(lines, line)
+	}
+	stats := st.Stats
+	var cacheHit float64
+	if stats.CacheHit+stats.Remote > 0 {
+		cacheHit = float64(stats.CacheHit) / float64(stats.CacheHit+stats.Remote) * 100
+	}
+	var flags string
+	if v.paused {
+		flags += " [PAUSED]"
+	}
+	if v.keepGoing {
+		flags += " [KEEP-GOING]"
+	}
+	// flags first, so it won't be truncated in narrow terminal.
+	add("siso ninja%s %s [%d/%d] local:%d remote:%d cache:%d(%.1f%%) fallback:%d fail:%d",
+		flags, st.Elapsed,
+		stats.Done-stats.Skipped, stats.Total-stats.Skipped,
+		stats.Local, stats.Remote, stats.CacheHit, cacheHit,
+		stats.LocalFallback, stats.Fail)
+	add("%s", v.progress)
+
+	if i := v.selected - st.FailuresStart; v.showOutput && i >= 0 && i < len(st.Failures) {
+		f := st.Failures[i]
+		add("Output of %s (↑/↓ PgUp/PgDn:scroll o:close)", f.Output)
+		outLines := strings.Split(strings.TrimRight(ui.StripANSIEscapeCodes(f.Result), "\n"), "\n")
+		n := max(height-len(lines)-1, 0)
+		scroll := min(v.scroll, max(len(outLines)-n, 0))
+		for _, line := range outLines[scroll:min(scroll+n, len(outLines))] {
+			add("%s", line)
+		}
+		return finishLines(lines, height)
+	}
+
+	var semas []string
+	for _, s := range st.Semaphores {
+		if s.Serv == 0 && s.Wait == 0 {
+			continue
+		}
+		sema := fmt.Sprintf("%s:%d/%d", s.Name, s.Serv, s.Capacity)
+		if s.Wait > 0 {
+			sema += fmt.Sprintf("+%d", s.Wait)
+		}
+		semas = append(semas, sema)
+	}
+	add("semaphores: %s", strings.Join(semas, " "))
+
+	// fixed size panes for slowest steps and failures,
+	// and the rest for active steps.
+	nslowest := min(len(st.Slowest), 5)
+	nfailures := min(len(st.Failures), failuresPane)
+	nactive := height - len(lines) - 1 - 3 - nslowest - nfailures
+	if len(st.Failures) == 0 {
+		nactive++
+	}
+
+	sortBy := "duration"
+	active := st.ActiveSteps
+	if v.sortByPhase {
+		sortBy = "phase"
+		active = append([]build.ActiveStepInfo(nil), active...)
+		sort.SliceStable(active, func(i, j int) bool {
+			return active[i].Phase < active[j].Phase
+		})
+	}
+	add("Active steps: %d (sort by %s)", len(active), sortBy)
+	for _, s := range active[:max(min(nactive, len(active)), 0)] {
+		add("%8s %-12s %s", s.Dur, s.Phase, s.Desc)
+	}
+	for range max(nactive-len(active), 0) {
+		add("")
+	}
+
+	add("Slowest steps:")
+	for _, s := range st.Slowest[:nslowest] {
+		add("%8s %-8s %s", s.Dur, s.Status, s.Output)
+	}
+	if len(st.Failures) > 0 {
+		add("Failures: %d", st.NumFailures)
+		// st.Failures is a window of failures around the selected one.
+		for i, f := range st.Failures[:nfailures] {
+			marker := " "
+			if st.FailuresStart+i == v.selected {
+				marker = ">"
+			}
+			add("%s%7s %s", marker, f.Dur, f.Output)
+		}
+	}
+	return finishLines(lines, height)
+}
+
+// finishLines fits lines in height with help line at the bottom.
+func finishLines(lines []string, height int) []string {
+	if height > 0 && len(lines) > height-1 {
+		lines = lines[:max(height-1, 0)]
+	}
+	return append(lines, helpLine)
+}
+
+// PrintLines implements ui.UI.
+// The last message is shown as progress, and messages
+// that need to be kept (e.g. command outputs) are captured
+// and printed after full-screen UI stops.
+func (t *TUI) PrintLines(msgs ...string) {
+	permanent := len(msgs) > 0 && msgs[0] == "\n"
+	if permanent {
+		msgs = msgs[1:]
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, msg := range msgs {
+		msg = strings.TrimPrefix(msg, "\n")
+		if strings.TrimSpace(msg) == "" {
+			continue
+		}
+		if !permanent && i == len(msgs)-1 && !strings.Contains(msg, "\n") {
+			t.view.progress = ui.StripANSIEscapeCodes(msg)
+			continue
+		}
+		t.addLog(logEntry{permanent: true, msg: strings.TrimSuffix(msg, "\n")})
+	}
+}
+
+// addLog adds a log entry. t.mu must be held.
+func (t *TUI) addLog(l logEntry) {
+	if len(t.logs) >= maxLogs {
+		t.logs = t.logs[1:]
+	}
+	t.logs = append(t.logs, l)
+}
+
+// NewSpinner implements ui.UI.
+func (t *TUI) NewSpinner() ui.Spinner {
+	return &spinner{t: t}
+}
+
+// Infof implements ui.UI.
+func (t *TUI) Infof(format string, args ...any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addLog(logEntry{msg: fmt.Sprintf(format, args...)})
+}
+
+// Warningf implements ui.UI.
+func (t *TUI) Warningf(format string, args ...any) {
+	t.Infof(format, args...)
+}
+
+// Errorf implements ui.UI.
+func (t *TUI) Errorf(format string, args ...any) {
+	t.Infof(format, args...)
+}
+
+// spinner shows spinner message as progress.
+type spinner struct {
+	t       *TUI
+	started time.Time
+	msg     string
+}
+
+func (s *spinner) Start(format string, args ...any) {
+	s.started = time.Now()
+	s.msg = fmt.Sprintf(format, args...)
+	s.t.mu.Lock()
+	defer s.t.mu.Unlock()
+	s.t.view.progress = s.msg + "..."
+}
+
+func (s *spinner) Stop(err error) {
+	if err != nil {
+		s.t.Infof("%s failed %s %v\n", s.msg, ui.FormatDuration(time.Since(s.started)), err)
+		return
+	}
+	s.t.Infof("%s done %s\n", s.msg, ui.FormatDuration(time.Since(s.started)))
+}
+
+func (s *spinner) Done(format string, args ...any) {
+	s.t.Infof("%s %s %s\n", s.msg, fmt.Sprintf(format, args...), ui.FormatDuration(time.Since(s.started)))
+}
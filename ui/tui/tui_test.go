@@ -0,0 +1,144 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"go.chromium.org/build/siso/build"
+)
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("p\033[A\033[B\033[5~\033[6~\x03\033q"))
+	want := []key{'p', keyUp, keyDown, keyPageUp, keyPageDown, keyCtrlC, 'q'}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("parseKeys(...) diff -want +got:\n%s", diff)
+	}
+}
+
+func TestViewStateHandleKey(t *testing.T) {
+	var v viewState
+	v.handleKey(keyDown, 3)
+	v.handleKey(keyDown, 3)
+	v.handleKey(keyDown, 3)
+	if v.selected != 2 {
+		t.Errorf("selected=%d; want 2", v.selected)
+	}
+	v.handleKey(keyUp, 3)
+	if v.selected != 1 {
+		t.Errorf("selected=%d; want 1", v.selected)
+	}
+	v.handleKey('o', 3)
+	if !v.showOutput {
+		t.Errorf("showOutput=false; want true")
+	}
+	v.handleKey(keyDown, 3)
+	if v.selected != 1 || v.scroll != 1 {
+		t.Errorf("selected=%d scroll=%d; want selected=1 scroll=1", v.selected, v.scroll)
+	}
+	v.handleKey('o', 3)
+	if v.showOutput || v.scroll != 0 {
+		t.Errorf("showOutput=%t scroll=%d; want false 0", v.showOutput, v.scroll)
+	}
+
+	v = viewState{}
+	v.handleKey('o', 0)
+	if v.showOutput {
+		t.Errorf("showOutput=true without failures; want false")
+	}
+}
+
+func TestRender(t *testing.T) {
+	st := build.LiveStatus{
+		Elapsed: "1m02.00s",
+		Stats: build.Stats{
+			Done:     10,
+			Total:    20,
+			Remote:   3,
+			CacheHit: 1,
+			Fail:     1,
+		},
+		ActiveSteps: []build.ActiveStepInfo{
+			{Desc: "CXX b.o", Dur: "3s", Phase: "remote exec"},
+			{Desc: "CXX a.o", Dur: "2s", Phase: "local exec"},
+		},
+		Semaphores: []build.SemaphoreInfo{
+			{Name: "localexec", Capacity: 4, Serv: 1},
+			{Name: "rbe", Capacity: 100},
+		},
+		Slowest: []build.CompletedStepInfo{
+			{Output: "c.o", Dur: "5s", Status: "remote"},
+		},
+		Failures: []build.CompletedStepInfo{
+			{Output: "d.o", Dur: "1s", Err: true, Result: "line1\nline2\nline3\n"},
+		},
+		NumFailures: 1,
+	}
+	lines := render(st, viewState{paused: true, progress: "[10/20] CXX c.o"}, 80, 14)
+	if len(lines) != 14 {
+		t.Errorf("len(lines)=%d; want 14\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 80 {
+			t.Errorf("line %q is too long: %d", line, n)
+		}
+	}
+	for _, want := range []string{"[10/20]", "[PAUSED]", "localexec:1/4", "remote exec", "Slowest steps:", "Failures: 1", ">"} {
+		if !strings.Contains(strings.Join(lines, "\n"), want) {
+			t.Errorf("render(...) doesn't contain %q\n%s", want, strings.Join(lines, "\n"))
+		}
+	}
+	if strings.Contains(strings.Join(lines, "\n"), "rbe:") {
+		t.Errorf("render(...) shows idle semaphore\n%s", strings.Join(lines, "\n"))
+	}
+
+	// sort by phase.
+	lines = render(st, viewState{sortByPhase: true}, 80, 14)
+	var active []string
+	for _, line := range lines {
+		if strings.Contains(line, " exec ") {
+			active = append(active, strings.Fields(line)[1])
+		}
+	}
+	if diff := cmp.Diff([]string{"local", "remote"}, active); diff != "" {
+		t.Errorf("active steps sort by phase; diff -want +got:\n%s", diff)
+	}
+
+	// output of the failure, scrolled to fit in height.
+	lines = render(st, viewState{showOutput: true, scroll: 1}, 80, 5)
+	got := strings.Join(lines, "\n")
+	if !strings.Contains(got, "Output of d.o") || strings.Contains(got, "line1") || !strings.Contains(got, "line2") || strings.Contains(got, "line3") {
+		t.Errorf("render(...) output\n%s", got)
+	}
+}
+
+func TestRenderFailuresWindow(t *testing.T) {
+	st := build.LiveStatus{
+		Failures: []build.CompletedStepInfo{
+			{Output: "f3.o", Result: "error3\n"},
+			{Output: "f4.o", Result: "error4\n"},
+			{Output: "f5.o", Result: "error5\n"},
+		},
+		NumFailures:   10,
+		FailuresStart: 3,
+	}
+	got := strings.Join(render(st, viewState{selected: 4}, 80, 20), "\n")
+	if !strings.Contains(got, "Failures: 10") || !strings.Contains(got, "> ") || !strings.Contains(got, "f4.o") {
+		t.Errorf("render(...) failures\n%s", got)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if strings.HasPrefix(line, ">") && !strings.Contains(line, "f4.o") {
+			t.Errorf("selected line %q; want f4.o", line)
+		}
+	}
+
+	got = strings.Join(render(st, viewState{selected: 5, showOutput: true}, 80, 20), "\n")
+	if !strings.Contains(got, "Output of f5.o") || !strings.Contains(got, "error5") {
+		t.Errorf("render(...) output of selected failure\n%s", got)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build !windows
+
+package tui
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func supported() bool { return true }
+
+// waitInput waits until stdin has input to read.
+// It returns false if quit is closed before that.
+func waitInput(quit <-chan struct{}) bool {
+	fds := []unix.PollFd{{Fd: int32(os.Stdin.Fd()), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-quit:
+			return false
+		default:
+		}
+		n, err := unix.Poll(fds, 100)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil || n > 0 {
+			// let read report the error, if any.
+			return true
+		}
+	}
+}
+
+// interrupt sends SIGINT to the process.
+func interrupt() {
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
+}
@@ -0,0 +1,14 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build windows
+
+package tui
+
+// TODO: support windows console.
+func supported() bool { return false }
+
+func interrupt() {}
+
+func waitInput(quit <-chan struct{}) bool { return true }
@@ -0,0 +1,236 @@
+// Copyright 2025 The Chromium Authors
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package webui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.chromium.org/build/siso/build"
+)
+
+// livebuildState is a state of live build view.
+// It attaches to a running `siso ninja` in an outdir through the
+// statusz server in `.siso_port`, and streams the build status
+// over SSE.
+type livebuildState struct {
+	liveMu     sync.Mutex
+	liveOutdir string
+	liveCancel context.CancelFunc
+}
+
+func (s *WebuiServer) handleLivebuildGet(w http.ResponseWriter, r *http.Request) {
+	outdirInfo, err := s.getOutdirForRequest(r)
+	if err != nil {
+		s.renderBuildViewError(http.StatusNotFound, fmt.Sprintf("outdir failed to load for request %s: %v", r.URL, err), w, r)
+		return
+	}
+	tmpl, err := s.loadView("_live.html")
+	if err != nil {
+		s.renderBuildViewError(http.StatusInternalServerError, fmt.Sprintf("failed to load view: %s", err), w, r)
+		return
+	}
+	s.attachLivebuild(outdirInfo.path)
+	err = s.renderBuildView(w, r, tmpl, map[string]any{})
+	if err != nil {
+		s.renderBuildViewError(http.StatusInternalServerError, fmt.Sprintf("failed to render view: %v", err), w, r)
+	}
+}
+
+// attachLivebuild starts to stream live build status of outdir,
+// if it is not streamed yet.
+// It detaches from the previous outdir, since SSE messages
+// are broadcasted to all clients.
+func (s *WebuiServer) attachLivebuild(outdir string) {
+	s.liveMu.Lock()
+	defer s.liveMu.Unlock()
+	if s.liveOutdir == outdir && s.liveCancel != nil {
+		return
+	}
+	if s.liveCancel != nil {
+		s.liveCancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.liveOutdir = outdir
+	s.liveCancel = cancel
+	go s.pollLivebuild(ctx, outdir)
+}
+
+// pollLivebuild polls live status of the build running in outdir,
+// and sends SSE messages until ctx is canceled.
+// It waits for a build to start if no build is running,
+// so it works for builds started from the terminal too.
+func (s *WebuiServer) pollLivebuild(ctx context.Context, outdir string) {
+	var buildID, lastState string
+	var seq int64
+	var nfailures int
+	for {
+		st, err := fetchLiveStatus(ctx, outdir, seq)
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if err != nil {
+			state := fmt.Sprintf("<p>No running siso ninja in %s</p>", html.EscapeString(outdir))
+			if buildID != "" {
+				state = fmt.Sprintf("<p>Build %s finished</p>", html.EscapeString(buildID))
+			}
+			if state != lastState {
+				s.sseServer.messages <- sseMessage{"livestate", state}
+				s.sseServer.messages <- sseMessage{"liveactive", ""}
+				lastState = state
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if st.BuildID != buildID {
+			buildID = st.BuildID
+			seq = 0
+			nfailures = 0
+			s.sseServer.messages <- sseMessage{"livereset", livebuildResetHTML}
+			// completed steps may be missed in this response, as seq was
+			// requested for the previous build.
+			st, err = fetchLiveStatus(ctx, outdir, seq)
+			if err != nil {
+				continue
+			}
+		}
+		state := fmt.Sprintf("<p>Build %s running</p>", html.EscapeString(st.BuildID))
+		if state != lastState {
+			s.sseServer.messages <- sseMessage{"livestate", state}
+			lastState = state
+		}
+		s.sseServer.messages <- sseMessage{"livesummary", renderLiveSummary(st)}
+		s.sseServer.messages <- sseMessage{"liveactive", renderLiveActiveSteps(st.ActiveSteps)}
+		s.sseServer.messages <- sseMessage{"livesemas", renderLiveSemaphores(st.Semaphores)}
+		if len(st.Completed) > 0 {
+			s.sseServer.messages <- sseMessage{"livecompleted", renderLiveCompletedSteps(st.Completed)}
+		}
+		if len(st.Failures) > nfailures {
+			s.sseServer.messages <- sseMessage{"livefailures", renderLiveCompletedSteps(st.Failures[nfailures:])}
+			nfailures = len(st.Failures)
+		}
+		seq = st.Seq
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// livebuildResetHTML resets completed steps and failures for new build.
+// It must be single line to send as SSE data.
+const livebuildResetHTML = `<h3>Failures</h3>` +
+	`<table><tbody sse-swap="livefailures" hx-swap="afterbegin"></tbody></table>` +
+	`<h3>Completed steps</h3>` +
+	`<table><tbody sse-swap="livecompleted" hx-swap="afterbegin"></tbody></table>`
+
+// fetchLiveStatus fetches live status from siso ninja running in outdir.
+func fetchLiveStatus(ctx context.Context, outdir string, since int64) (build.LiveStatus, error) {
+	var st build.LiveStatus
+	buf, err := os.ReadFile(filepath.Join(outdir, ".siso_port"))
+	if err != nil {
+		return st, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("http://%s/api/live_status?since=%d", strings.TrimSpace(string(buf)), since), nil)
+	if err != nil {
+		return st, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return st, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return st, fmt.Errorf("/api/live_status error: %d %s", resp.StatusCode, resp.Status)
+	}
+	buf, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return st, err
+	}
+	err = json.Unmarshal(buf, &st)
+	return st, err
+}
+
+func renderLiveSummary(st build.LiveStatus) string {
+	stats := st.Stats
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "<ul>")
+	fmt.Fprintf(b, "<li>elapsed %s", st.Elapsed)
+	fmt.Fprintf(b, "<li>%d/%d steps done", stats.Done-stats.Skipped, stats.Total-stats.Skipped)
+	fmt.Fprintf(b, "<li>%d active steps", len(st.ActiveSteps))
+	fmt.Fprintf(b, "<li>local:%d remote:%d cache:%d fallback:%d", stats.Local, stats.Remote, stats.CacheHit, stats.LocalFallback)
+	if stats.Fail > 0 {
+		fmt.Fprintf(b, "<li>failed:%d", stats.Fail)
+	}
+	fmt.Fprintf(b, "</ul>")
+	return b.String()
+}
+
+func renderLiveActiveSteps(steps []build.ActiveStepInfo) string {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "<table>")
+	for _, step := range steps {
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(step.Phase),
+			step.Dur,
+			step.ServDur,
+			html.EscapeString(step.Desc))
+	}
+	fmt.Fprintf(b, "</table>")
+	return b.String()
+}
+
+func renderLiveSemaphores(semas []build.SemaphoreInfo) string {
+	b := new(bytes.Buffer)
+	fmt.Fprintf(b, "<table>")
+	for _, sema := range semas {
+		if sema.Serv == 0 && sema.Wait == 0 {
+			continue
+		}
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%d/%d</td><td>", html.EscapeString(sema.Name), sema.Serv, sema.Capacity)
+		if sema.Wait > 0 {
+			fmt.Fprintf(b, "%d waits", sema.Wait)
+		}
+		fmt.Fprintf(b, "</td></tr>")
+	}
+	fmt.Fprintf(b, "</table>")
+	return b.String()
+}
+
+// renderLiveCompletedSteps renders rows of completed steps, newest first.
+func renderLiveCompletedSteps(steps []build.CompletedStepInfo) string {
+	b := new(bytes.Buffer)
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		status := step.Status
+		if step.Err {
+			status = "FAILED " + status
+		}
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(status),
+			step.Dur,
+			html.EscapeString(step.Output))
+	}
+	return b.String()
+}
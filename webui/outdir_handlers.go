@@ -108,6 +108,10 @@ func loadBuildMetrics(metricsPath string) (*buildMetrics, error) {
 		if err != nil {
 			return nil, fmt.Errorf("parse error in %s:%d: %w", metricsPath, d.InputOffset(), err)
 		}
+		if m.Circuit != "" {
+			// RBE circuit transition, neither build nor step metric.
+			continue
+		}
 		if m.BuildID != "" {
 			metricsData.buildMetrics = append(metricsData.buildMetrics, &m)
 			// The last build metric found has the actual build duration.
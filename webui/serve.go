@@ -151,18 +151,13 @@ type WebuiServer struct {
 	defaultOutdirSub  string
 	outsubs           []string
 	runbuildState
+	livebuildState
 
 	metricsMu       sync.Mutex
 	outdirMetrics   map[string]*outdirInfo
 	uploadedMetrics []*buildMetrics
 }
 
-type runningStepInfo struct {
-	stepOut  string
-	stepType string
-	started  time.Time
-}
-
 // ErrExecrootNotExist represents error when exec root was not found.
 type ErrExecrootNotExist struct {
 	err error
@@ -195,7 +190,7 @@ func (s *WebuiServer) loadView(view string) (*template.Template, error) {
 	if err != nil {
 		return nil, fmt.Errorf("templates not found: %w", err)
 	}
-	template, err := template.New("").Funcs(baseFunctions).ParseFS(templatesFS, "webui_base.html", view)
+	template, err := template.New("").Funcs(baseFunctions).ParseFS(templatesFS, "webui_base.html", "live_panels.html", view)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse view: %w", err)
 	}
@@ -402,6 +397,7 @@ func (s *WebuiServer) Serve() int {
 	})
 	outdirRouter.HandleFunc("/{outroot}/{outsub}/runbuild/", s.handleRunbuildGet)
 	outdirRouter.HandleFunc("POST /{outroot}/{outsub}/runbuild/", s.handleRunbuildPost)
+	outdirRouter.HandleFunc("/{outroot}/{outsub}/live/", s.handleLivebuildGet)
 	outdirRouter.HandleFunc("/{outroot}/{outsub}/reload", s.handleOutdirReload)
 	outdirRouter.HandleFunc("/{outroot}/{outsub}/builds/{rev}/logs/{file}", s.handleOutdirViewLog)
 	outdirRouter.HandleFunc("/{outroot}/{outsub}/builds/{rev}/aggregates/", s.handleOutdirAggregates)